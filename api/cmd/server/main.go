@@ -6,22 +6,19 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"sort"
 
 	"api/internal"
 
 	"github.com/joho/godotenv"
 )
 
-func runMigrations(db *internal.Database) error {
-	migrationsDir := "./migrations"
-
+func runMigrations(db *internal.Database, migrationsDir string) error {
 	if _, err := os.Stat(migrationsDir); os.IsNotExist(err) {
 		log.Println("No migrations directory found, skipping migrations")
 		return nil
 	}
 
-	files, err := filepath.Glob(filepath.Join(migrationsDir, "*.sql"))
+	files, err := internal.MigrationFiles(migrationsDir)
 	if err != nil {
 		return err
 	}
@@ -31,8 +28,6 @@ func runMigrations(db *internal.Database) error {
 		return nil
 	}
 
-	sort.Strings(files)
-
 	log.Printf("Running %d migration files...", len(files))
 	for _, file := range files {
 		log.Printf("Executing migration: %s", filepath.Base(file))
@@ -45,6 +40,14 @@ func runMigrations(db *internal.Database) error {
 		if err := db.ExecMigration(context.Background(), string(content)); err != nil {
 			return err
 		}
+
+		version, err := internal.MigrationVersion(file)
+		if err != nil {
+			return err
+		}
+		if err := db.RecordMigration(context.Background(), version); err != nil {
+			return err
+		}
 	}
 
 	log.Println("All migrations completed successfully")
@@ -59,19 +62,31 @@ func main() {
 		log.Println("No .env file found, using environment variables")
 	}
 
+	log.Printf("Starting divvy-api %s", internal.Version)
+
 	config := internal.LoadConfig()
 
 	if err := config.Validate(); err != nil {
 		log.Fatal("Configuration validation failed:", err)
 	}
 
+	shutdownTracing, err := internal.InitTracing(context.Background(), config.Tracing)
+	if err != nil {
+		log.Fatal("Failed to initialize tracing:", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Printf("Failed to flush traces: %v", err)
+		}
+	}()
+
 	database, err := internal.NewDatabase(config)
 	if err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
 	defer database.Close()
 
-	if err := runMigrations(database); err != nil {
+	if err := runMigrations(database, config.Database.MigrationsDir); err != nil {
 		log.Fatal("Failed to run migrations:", err)
 	}
 
@@ -84,6 +99,12 @@ func main() {
 
 	handlers := internal.NewHTTPHandlers(database, divvyClient, config)
 
+	report := handlers.SelfTest(context.Background())
+	report.Log()
+	if config.Server.StrictStartup && !report.OK {
+		log.Fatal("Startup self-test failed and STRICT_STARTUP is set")
+	}
+
 	// AUTO-REFRESH DATA ON STARTUP
 	log.Println("Refreshing station data on startup in background...")
 	go func() {
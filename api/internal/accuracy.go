@@ -0,0 +1,245 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// evaluationLookback bounds how old a prediction may be and still be
+	// evaluated, so predictions that never get a matching actual are not
+	// re-queried forever.
+	evaluationLookback = 7 * 24 * time.Hour
+	// evaluationFetchLimit caps the predictions evaluated per run.
+	evaluationFetchLimit = 20000
+	// evaluationBatchSize is how many results are inserted per transaction.
+	evaluationBatchSize = 500
+	// defaultCapacity stands in for an unknown capacity, as in the ML
+	// pipeline's target construction.
+	defaultCapacity = 20
+)
+
+// ErrNoActualAvailability is returned when no availability was recorded close
+// enough to a prediction's time to evaluate it.
+var ErrNoActualAvailability = errors.New("no actual availability near prediction time")
+
+// ActualAvailability is the recorded availability a prediction is scored
+// against.
+type ActualAvailability struct {
+	NumBikesAvailable int
+	Capacity          int
+	RecordedAt        time.Time
+}
+
+// PredictionAccuracy is the outcome of scoring one matured prediction.
+type PredictionAccuracy struct {
+	PredictionID   int       `json:"prediction_id"`
+	StationID      string    `json:"station_id"`
+	HorizonHours   int       `json:"horizon_hours"`
+	PredictedClass int       `json:"predicted_class"`
+	ActualClass    int       `json:"actual_class"`
+	Correct        bool      `json:"correct"`
+	PredictionTime time.Time `json:"prediction_time"`
+}
+
+// availabilityClass mirrors the ML pipeline's target: 0 (green) when at
+// least 60% of capacity is bikes, 1 (yellow) from 30%, else 2 (red). Use it
+// wherever current availability is compared with predicted classes.
+func availabilityClass(bikes, capacity int) int {
+	if capacity <= 0 {
+		capacity = defaultCapacity
+	}
+	ratio := float64(bikes) / float64(capacity)
+	switch {
+	case ratio >= 0.6:
+		return 0
+	case ratio >= 0.3:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// EvaluationResult summarizes one evaluation run.
+type EvaluationResult struct {
+	Evaluated int
+	NoActual  int
+	// Correct and Total per horizon, for the accuracy breakdown.
+	Correct map[int]int
+	Total   map[int]int
+}
+
+func (r EvaluationResult) String() string {
+	horizons := make([]int, 0, len(r.Total))
+	for h := range r.Total {
+		horizons = append(horizons, h)
+	}
+	slices.Sort(horizons)
+
+	parts := make([]string, len(horizons))
+	for i, h := range horizons {
+		parts[i] = fmt.Sprintf("%dh %.1f%% (%d/%d)", h, 100*float64(r.Correct[h])/float64(r.Total[h]), r.Correct[h], r.Total[h])
+	}
+	return fmt.Sprintf("evaluated %d predictions, %d without actual data; accuracy by horizon: %s",
+		r.Evaluated, r.NoActual, strings.Join(parts, ", "))
+}
+
+// PredictionEvaluator scores matured predictions against the availability
+// recorded at their prediction time, using a bounded pool of workers that
+// each take a station at a time.
+type PredictionEvaluator struct {
+	database AccuracyRepository
+	workers  int
+	maxGap   time.Duration
+}
+
+func NewPredictionEvaluator(database AccuracyRepository, cfg EvaluationConfig) *PredictionEvaluator {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	return &PredictionEvaluator{
+		database: database,
+		workers:  workers,
+		maxGap:   time.Duration(cfg.MaxGapMin) * time.Minute,
+	}
+}
+
+// Run evaluates every matured, unevaluated prediction. A failing station or
+// insert batch does not stop the run; all failures are returned joined along
+// with the result of what did succeed. Cancelling ctx stops the workers.
+func (e *PredictionEvaluator) Run(ctx context.Context) (EvaluationResult, error) {
+	result := EvaluationResult{Correct: map[int]int{}, Total: map[int]int{}}
+
+	// A prediction has matured once its closest possible actual is recorded.
+	to := time.Now().Add(-e.maxGap)
+	predictions, err := e.database.GetUnevaluatedPredictions(ctx, to.Add(-evaluationLookback), to, evaluationFetchLimit)
+	if err != nil {
+		return result, fmt.Errorf("load unevaluated predictions: %w", err)
+	}
+
+	stations := make(chan []Prediction)
+	results := make(chan PredictionAccuracy)
+	var noActual int
+	var errs []error
+	var mu sync.Mutex
+
+	var wg sync.WaitGroup
+	for range e.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for station := range stations {
+				skipped, err := e.evaluateStation(ctx, station, results)
+				mu.Lock()
+				noActual += skipped
+				if err != nil {
+					errs = append(errs, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	go func() {
+		defer close(stations)
+		for _, group := range groupByStation(predictions) {
+			select {
+			case stations <- group:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	batch := make([]PredictionAccuracy, 0, evaluationBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.database.InsertPredictionAccuracy(ctx, batch); err != nil {
+			mu.Lock()
+			errs = append(errs, fmt.Errorf("insert %d results: %w", len(batch), err))
+			mu.Unlock()
+		} else {
+			for _, r := range batch {
+				result.Evaluated++
+				result.Total[r.HorizonHours]++
+				if r.Correct {
+					result.Correct[r.HorizonHours]++
+				}
+			}
+		}
+		batch = batch[:0]
+	}
+	for r := range results {
+		batch = append(batch, r)
+		if len(batch) == evaluationBatchSize {
+			flush()
+		}
+	}
+	flush()
+
+	result.NoActual = noActual
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return result, errors.Join(errs...)
+}
+
+// evaluateStation scores one station's predictions, sending each result and
+// returning how many had no actual availability to compare against.
+func (e *PredictionEvaluator) evaluateStation(ctx context.Context, predictions []Prediction, results chan<- PredictionAccuracy) (int, error) {
+	skipped := 0
+	for _, p := range predictions {
+		actual, err := e.database.GetActualAvailability(ctx, p.StationID, p.PredictionTime, e.maxGap)
+		if errors.Is(err, ErrNoActualAvailability) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return skipped, fmt.Errorf("station %s: %w", p.StationID, err)
+		}
+
+		actualClass := availabilityClass(actual.NumBikesAvailable, actual.Capacity)
+		select {
+		case results <- PredictionAccuracy{
+			PredictionID:   p.ID,
+			StationID:      p.StationID,
+			HorizonHours:   p.HorizonHours,
+			PredictedClass: p.PredictedAvailabilityClass,
+			ActualClass:    actualClass,
+			Correct:        actualClass == p.PredictedAvailabilityClass,
+			PredictionTime: p.PredictionTime,
+		}:
+		case <-ctx.Done():
+			return skipped, ctx.Err()
+		}
+	}
+	return skipped, nil
+}
+
+// groupByStation splits predictions ordered by station into one slice per
+// station.
+func groupByStation(predictions []Prediction) [][]Prediction {
+	var groups [][]Prediction
+	for i := 0; i < len(predictions); {
+		j := i + 1
+		for j < len(predictions) && predictions[j].StationID == predictions[i].StationID {
+			j++
+		}
+		groups = append(groups, predictions[i:j])
+		i = j
+	}
+	return groups
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAvailabilityClass(t *testing.T) {
+	assert.Equal(t, 0, availabilityClass(6, 10))
+	assert.Equal(t, 1, availabilityClass(3, 10))
+	assert.Equal(t, 2, availabilityClass(2, 10))
+	// Unknown capacity falls back to 20 docks.
+	assert.Equal(t, 1, availabilityClass(6, 0))
+}
+
+func TestPredictionEvaluator_Run(t *testing.T) {
+	predictionTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	prediction := func(id int, station string, horizon, class int) Prediction {
+		return Prediction{ID: id, StationID: station, HorizonHours: horizon, PredictedAvailabilityClass: class, PredictionTime: predictionTime}
+	}
+
+	mockDB := new(MockDatabase)
+	mockDB.On("GetUnevaluatedPredictions", mock.Anything, mock.Anything, mock.Anything, evaluationFetchLimit).Return([]Prediction{
+		prediction(1, "a", 6, 0),
+		prediction(2, "a", 12, 2),
+		prediction(3, "b", 6, 1),
+		prediction(4, "c", 6, 0),
+		prediction(5, "d", 6, 0),
+	}, nil)
+	maxGap := 15 * time.Minute
+	// Station a has 8 of 10 bikes (green): the 6h prediction is right, the 12h one wrong.
+	mockDB.On("GetActualAvailability", mock.Anything, "a", predictionTime, maxGap).
+		Return(ActualAvailability{NumBikesAvailable: 8, Capacity: 10}, nil)
+	mockDB.On("GetActualAvailability", mock.Anything, "b", predictionTime, maxGap).
+		Return(ActualAvailability{NumBikesAvailable: 4, Capacity: 10}, nil)
+	mockDB.On("GetActualAvailability", mock.Anything, "c", predictionTime, maxGap).
+		Return(ActualAvailability{}, ErrNoActualAvailability)
+	mockDB.On("GetActualAvailability", mock.Anything, "d", predictionTime, maxGap).
+		Return(ActualAvailability{}, errors.New("db down"))
+	mockDB.On("InsertPredictionAccuracy", mock.Anything, mock.Anything).Return(nil)
+
+	evaluator := NewPredictionEvaluator(mockDB, EvaluationConfig{Workers: 3, MaxGapMin: 15})
+	result, err := evaluator.Run(context.Background())
+
+	// Station d's failure is reported without stopping the others.
+	assert.ErrorContains(t, err, "station d")
+	assert.Equal(t, 3, result.Evaluated)
+	assert.Equal(t, 1, result.NoActual)
+	assert.Equal(t, map[int]int{6: 2, 12: 1}, result.Total)
+	assert.Equal(t, map[int]int{6: 2}, result.Correct)
+	assert.Contains(t, result.String(), "6h 100.0% (2/2)")
+	mockDB.AssertExpectations(t)
+}
+
+func TestGroupByStation(t *testing.T) {
+	groups := groupByStation([]Prediction{{StationID: "a"}, {StationID: "a"}, {StationID: "b"}})
+	assert.Len(t, groups, 2)
+	assert.Len(t, groups[0], 2)
+	assert.Len(t, groups[1], 1)
+	assert.Empty(t, groupByStation(nil))
+}
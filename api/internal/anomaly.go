@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Anomaly kinds. Swings are plausible but unusual; the others mean the feed
+// reported something impossible and point at upstream data corruption.
+const (
+	AnomalyKindSwing                 = "swing"
+	AnomalyKindLastReportedBackwards = "last_reported_backwards"
+	AnomalyKindOverCapacity          = "over_capacity"
+)
+
+// AvailabilityAnomaly records a suspicious availability update: a large swing
+// in a station's bike count between two consecutive snapshots (e.g. a full
+// station suddenly reporting zero bikes), a last_reported timestamp that went
+// backwards, or more bikes and docks than the station has capacity for. The
+// update itself is still ingested.
+type AvailabilityAnomaly struct {
+	ID          int       `json:"id" db:"id"`
+	Kind        string    `json:"kind" db:"kind"`
+	StationID   string    `json:"station_id" db:"station_id"`
+	SystemID    string    `json:"system_id" db:"system_id"`
+	BikesBefore int       `json:"bikes_before" db:"bikes_before"`
+	BikesAfter  int       `json:"bikes_after" db:"bikes_after"`
+	DocksBefore int       `json:"docks_before" db:"docks_before"`
+	DocksAfter  int       `json:"docks_after" db:"docks_after"`
+	ChangePct   float64   `json:"change_pct" db:"change_pct"`
+	Detail      string    `json:"detail,omitempty" db:"detail"`
+	DetectedAt  time.Time `json:"detected_at" db:"detected_at"`
+}
+
+// detectAnomalies compares the new availabilities against the previous
+// snapshot and flags stations whose bike count moved by at least thresholdPct
+// percent of the station's size (bikes plus docks before the change).
+func detectAnomalies(previous map[string]StationAvailability, current []StationAvailability, thresholdPct int) []AvailabilityAnomaly {
+	var anomalies []AvailabilityAnomaly
+	for _, after := range current {
+		before, ok := previous[after.StationID]
+		if !ok {
+			continue
+		}
+		size := before.NumBikesAvailable + before.NumDocksAvailable
+		if size <= 0 {
+			continue
+		}
+
+		changePct := math.Abs(float64(after.NumBikesAvailable-before.NumBikesAvailable)) / float64(size) * 100
+		if changePct < float64(thresholdPct) {
+			continue
+		}
+
+		anomalies = append(anomalies, AvailabilityAnomaly{
+			Kind:        AnomalyKindSwing,
+			StationID:   after.StationID,
+			SystemID:    after.SystemID,
+			BikesBefore: before.NumBikesAvailable,
+			BikesAfter:  after.NumBikesAvailable,
+			DocksBefore: before.NumDocksAvailable,
+			DocksAfter:  after.NumDocksAvailable,
+			ChangePct:   changePct,
+		})
+	}
+	return anomalies
+}
+
+// detectImpossibleAvailability flags updates no real station can produce: a
+// last_reported older than the previous snapshot's, or bikes plus docks
+// exceeding the station's capacity by more than overCapacityPct percent. A
+// non-positive overCapacityPct skips the capacity check, as does an unknown
+// or zero capacity.
+func detectImpossibleAvailability(previous map[string]StationAvailability, current []StationAvailability, capacities map[string]int, overCapacityPct int) []AvailabilityAnomaly {
+	var anomalies []AvailabilityAnomaly
+	for _, after := range current {
+		before, ok := previous[after.StationID]
+		if ok && after.LastReported < before.LastReported {
+			anomalies = append(anomalies, newImpossibleAnomaly(AnomalyKindLastReportedBackwards, before, after,
+				fmt.Sprintf("last_reported went from %d to %d", before.LastReported, after.LastReported)))
+		}
+
+		capacity := capacities[after.StationID]
+		if overCapacityPct <= 0 || capacity <= 0 {
+			continue
+		}
+		total := after.NumBikesAvailable + after.NumDocksAvailable
+		if total*100 > capacity*(100+overCapacityPct) {
+			anomalies = append(anomalies, newImpossibleAnomaly(AnomalyKindOverCapacity, before, after,
+				fmt.Sprintf("%d bikes and docks reported for capacity %d", total, capacity)))
+		}
+	}
+	return anomalies
+}
+
+// newImpossibleAnomaly builds an anomaly of kind; before is the zero value
+// when the station had no previous snapshot.
+func newImpossibleAnomaly(kind string, before, after StationAvailability, detail string) AvailabilityAnomaly {
+	return AvailabilityAnomaly{
+		Kind:        kind,
+		StationID:   after.StationID,
+		SystemID:    after.SystemID,
+		BikesBefore: before.NumBikesAvailable,
+		BikesAfter:  after.NumBikesAvailable,
+		DocksBefore: before.NumDocksAvailable,
+		DocksAfter:  after.NumDocksAvailable,
+		Detail:      detail,
+	}
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectAnomalies(t *testing.T) {
+	previous := map[string]StationAvailability{
+		"full":   {StationID: "full", NumBikesAvailable: 15, NumDocksAvailable: 0},
+		"steady": {StationID: "steady", NumBikesAvailable: 5, NumDocksAvailable: 10},
+		"empty":  {StationID: "empty", NumBikesAvailable: 0, NumDocksAvailable: 0},
+	}
+	current := []StationAvailability{
+		{StationID: "full", SystemID: DefaultSystemID, NumBikesAvailable: 0, NumDocksAvailable: 15},
+		{StationID: "steady", NumBikesAvailable: 7, NumDocksAvailable: 8},
+		{StationID: "empty", NumBikesAvailable: 3, NumDocksAvailable: 0},
+		{StationID: "new", NumBikesAvailable: 10, NumDocksAvailable: 0},
+	}
+
+	anomalies := detectAnomalies(previous, current, 75)
+
+	assert.Len(t, anomalies, 1)
+	assert.Equal(t, AvailabilityAnomaly{
+		Kind:        AnomalyKindSwing,
+		StationID:   "full",
+		SystemID:    DefaultSystemID,
+		BikesBefore: 15,
+		BikesAfter:  0,
+		DocksBefore: 0,
+		DocksAfter:  15,
+		ChangePct:   100,
+	}, anomalies[0])
+}
+
+func TestDetectImpossibleAvailability(t *testing.T) {
+	previous := map[string]StationAvailability{
+		"rewound": {StationID: "rewound", NumBikesAvailable: 4, NumDocksAvailable: 6, LastReported: 2000},
+		"steady":  {StationID: "steady", NumBikesAvailable: 4, NumDocksAvailable: 6, LastReported: 2000},
+	}
+	current := []StationAvailability{
+		{StationID: "rewound", NumBikesAvailable: 4, NumDocksAvailable: 6, LastReported: 1000},
+		{StationID: "steady", NumBikesAvailable: 5, NumDocksAvailable: 7, LastReported: 2000},
+		{StationID: "overfull", NumBikesAvailable: 20, NumDocksAvailable: 6, LastReported: 3000},
+		{StationID: "unknown", NumBikesAvailable: 50, NumDocksAvailable: 50, LastReported: 3000},
+	}
+	capacities := map[string]int{"rewound": 10, "steady": 10, "overfull": 10}
+
+	anomalies := detectImpossibleAvailability(previous, current, capacities, 25)
+
+	if assert.Len(t, anomalies, 2) {
+		assert.Equal(t, AvailabilityAnomaly{
+			Kind:        AnomalyKindLastReportedBackwards,
+			StationID:   "rewound",
+			BikesBefore: 4,
+			BikesAfter:  4,
+			DocksBefore: 6,
+			DocksAfter:  6,
+			Detail:      "last_reported went from 2000 to 1000",
+		}, anomalies[0])
+		assert.Equal(t, AnomalyKindOverCapacity, anomalies[1].Kind)
+		assert.Equal(t, "overfull", anomalies[1].StationID)
+		assert.Equal(t, "26 bikes and docks reported for capacity 10", anomalies[1].Detail)
+	}
+
+	// Within the margin (12 of 10 is 20% over) or with the check disabled.
+	assert.Empty(t, detectImpossibleAvailability(nil, current[1:2], capacities, 25))
+	assert.Empty(t, detectImpossibleAvailability(nil, current[2:3], capacities, 0))
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stable, machine-readable error codes returned in the error envelope.
+const (
+	ErrCodeBadRequest             = "bad_request"
+	ErrCodeDBError                = "db_error"
+	ErrCodeNotFound               = "not_found"
+	ErrCodePredictionsUnavailable = "predictions_unavailable"
+	ErrCodeRefreshFailed          = "refresh_failed"
+	ErrCodeInferenceFailed        = "inference_failed"
+	ErrCodeTimeout                = "timeout"
+	ErrCodeUnauthorized           = "unauthorized"
+	ErrCodeForbidden              = "forbidden"
+	ErrCodeQueryTooLong           = "query_too_long"
+	ErrCodeFeatureDisabled        = "feature_disabled"
+	ErrCodePreconditionRequired   = "precondition_required"
+	ErrCodePreconditionFailed     = "precondition_failed"
+	ErrCodeFeedFetchFailed        = "feed_fetch_failed"
+	ErrCodeMaintenance            = "maintenance"
+	ErrCodeShuttingDown           = "shutting_down"
+	ErrCodeOverloaded             = "overloaded"
+)
+
+const (
+	requestIDHeader     = "X-Request-ID"
+	requestIDContextKey = "request_id"
+)
+
+type APIError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	// Details carries what a failed request did complete, e.g. the counts
+	// of an import that stopped at a malformed record.
+	Details interface{} `json:"details,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// respondError aborts the request with the shared error envelope.
+func respondError(c *gin.Context, statusCode int, code, message string) {
+	respondErrorDetails(c, statusCode, code, message, nil)
+}
+
+// respondErrorDetails is respondError with the envelope's details set.
+func respondErrorDetails(c *gin.Context, statusCode int, code, message string, details interface{}) {
+	c.AbortWithStatusJSON(statusCode, ErrorResponse{Error: APIError{
+		Code:      code,
+		Message:   message,
+		RequestID: c.GetString(requestIDContextKey),
+		Details:   details,
+	}})
+}
+
+// RequestID tags each request with the caller's X-Request-ID, or a generated
+// one, and echoes it back in the response headers.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if id == "" {
+			id = newRequestID()
+		}
+		c.Set(requestIDContextKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxHistoryStations caps how many stations one
+	// /api/availability/history request may chart.
+	maxHistoryStations   = 20
+	defaultHistoryWindow = 24 * time.Hour
+	maxHistoryWindow     = 7 * 24 * time.Hour
+)
+
+// GetAvailabilityHistory returns the raw availability records of several
+// stations over one window, ?ids=a,b,c&from=..&to=.., grouped by station ID
+// so a chart can draw one line per station from a single request. Every
+// requested station is present, with an empty list when it has no records
+// in the window. When the records hit HISTORY_MAX_ROWS, truncated is true and
+// the stations last in ID order are incomplete, so the client should narrow
+// its window.
+func (h *HTTPHandlers) GetAvailabilityHistory(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(c.Query("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "ids is required")
+		return
+	}
+	if len(ids) > maxHistoryStations {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest,
+			fmt.Sprintf("ids accepts at most %d stations", maxHistoryStations))
+		return
+	}
+
+	to, err := parseTimeParam(c, "to", time.Now().UTC())
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	from, err := parseTimeParam(c, "from", to.Add(-defaultHistoryWindow))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if !from.Before(to) {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "from must be before to")
+		return
+	}
+	if to.Sub(from) > maxHistoryWindow {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest,
+			fmt.Sprintf("window from..to must be at most %v", maxHistoryWindow))
+		return
+	}
+	loc, ok := requestLocation(c, time.UTC)
+	if !ok {
+		return
+	}
+
+	records, truncated, err := h.database.GetAvailabilityForStationsBetween(ctx, ids, from, to)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch availability history", err)
+		return
+	}
+	localizeAvailability(records, loc)
+
+	stations := make(map[string][]StationAvailability, len(ids))
+	for _, id := range ids {
+		stations[id] = []StationAvailability{}
+	}
+	for _, record := range records {
+		stations[record.StationID] = append(stations[record.StationID], record)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":      from.In(loc),
+		"to":        to.In(loc),
+		"stations":  stations,
+		"timezone":  loc.String(),
+		"truncated": truncated,
+	})
+}
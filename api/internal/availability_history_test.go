@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHTTPHandlers_GetAvailabilityHistory(t *testing.T) {
+	from := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	window := "&from=2024-01-01T08:00:00Z&to=2024-01-01T10:00:00Z"
+	records := []StationAvailability{
+		{StationID: "a", NumBikesAvailable: 3, RecordedAt: from},
+		{StationID: "a", NumBikesAvailable: 5, RecordedAt: from.Add(time.Hour)},
+		{StationID: "b", NumBikesAvailable: 7, RecordedAt: from},
+	}
+	tooMany := make([]string, maxHistoryStations+1)
+	for i := range tooMany {
+		tooMany[i] = string(rune('a' + i))
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		dbErr          error
+		truncated      bool
+		expectedStatus int
+		expectedCode   string
+		expectedCounts map[string]int
+	}{
+		{
+			name:           "groups records by station",
+			query:          "?ids=a,b,c" + window,
+			expectedStatus: http.StatusOK,
+			expectedCounts: map[string]int{"a": 2, "b": 1, "c": 0},
+		},
+		{
+			name:           "truncated",
+			query:          "?ids=a,b,c" + window,
+			truncated:      true,
+			expectedStatus: http.StatusOK,
+			expectedCounts: map[string]int{"a": 2, "b": 1, "c": 0},
+		},
+		{
+			name:           "duplicate ids",
+			query:          "?ids=a,%20a,b,c," + window,
+			expectedStatus: http.StatusOK,
+			expectedCounts: map[string]int{"a": 2, "b": 1, "c": 0},
+		},
+		{name: "missing ids", query: "?ids=" + window, expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+		{name: "too many ids", query: "?ids=" + strings.Join(tooMany, ",") + window, expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+		{name: "reversed window", query: "?ids=a&from=2024-01-01T10:00:00Z&to=2024-01-01T08:00:00Z", expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+		{name: "window too long", query: "?ids=a&from=2023-12-01T08:00:00Z&to=2024-01-01T08:00:00Z", expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+		{name: "invalid time", query: "?ids=a&from=yesterday", expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+		{name: "database error", query: "?ids=a,b,c" + window, dbErr: errors.New("connection refused"), expectedStatus: http.StatusInternalServerError, expectedCode: ErrCodeDBError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			mockDB.On("GetAvailabilityForStationsBetween", mock.Anything, []string{"a", "b", "c"}, from, to).
+				Return(records, tt.truncated, tt.dbErr).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/history", handlers.GetAvailabilityHistory)
+
+			req := httptest.NewRequest("GET", "/history"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, tt.expectedCode)
+				return
+			}
+
+			var response struct {
+				Stations  map[string][]StationAvailability `json:"stations"`
+				Truncated bool                             `json:"truncated"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			counts := make(map[string]int)
+			for id, rows := range response.Stations {
+				assert.NotNil(t, rows)
+				counts[id] = len(rows)
+			}
+			assert.Equal(t, tt.expectedCounts, counts)
+			assert.Equal(t, tt.truncated, response.Truncated)
+			assert.Equal(t, 5, response.Stations["a"][1].NumBikesAvailable)
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
@@ -2,9 +2,13 @@ package internal
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -13,62 +17,421 @@ type Config struct {
 	Divvy    DivvyConfig
 	ML       MLConfig
 	Timing   TimingConfig
+	Health   HealthConfig
+	HTTP     HTTPClientConfig
+	Anomaly  AnomalyConfig
+
+	Availability AvailabilityThresholds
+	Logging      LoggingConfig
+	Evaluation   EvaluationConfig
+	Tracing      TracingConfig
+	Webhooks     WebhookConfig
 }
 
 type DatabaseConfig struct {
 	URL string
+	// ReadURL, when set, points reads at a second pool (e.g. a replica) with
+	// the same pool settings; writes always use URL.
+	ReadURL             string
+	MaxOpenConns        int
+	MaxIdleConns        int
+	ConnMaxLifetimeMin  int
+	PredictionBatchSize int
+	MigrationsDir       string
+	// HistoryMaxRows caps the rows a raw availability history query loads
+	// into memory; longer results are truncated and flagged. Non-positive
+	// disables the cap.
+	HistoryMaxRows int
 }
 
 type ServerConfig struct {
-	Port        string
-	Environment string
+	Port            string
+	Environment     string
+	StaticDir       string
+	TemplatesGlob   string
+	AdminAPIKey     string
+	DisplayTimezone string
+	// MaxQueryBytes caps the raw query string length; MaxQueryIDs caps the
+	// number of comma-separated values in ?ids=. Non-positive disables.
+	MaxQueryBytes int
+	MaxQueryIDs   int
+	// StrictStartup exits at startup when a critical self-test check fails.
+	StrictStartup bool
+	// MaintenanceMode starts the server in maintenance mode (see
+	// MaintenanceMode); it can also be toggled at runtime by an admin.
+	MaintenanceMode bool
+	// DefaultStationsMode is the stations view ("current" or "predicted")
+	// used when a request has no ?mode=. Empty means current.
+	DefaultStationsMode string
+	// MaxInflightRequests caps concurrently served requests; beyond it
+	// requests are shed with 503. Non-positive disables the cap.
+	MaxInflightRequests int
+	// TLSCertFile and TLSKeyFile, when both set, make the server serve HTTPS
+	// directly instead of relying on a TLS-terminating proxy.
+	TLSCertFile string
+	TLSKeyFile  string
+	// CoordinatePrecision is the number of decimal places lat/lon are
+	// rounded to when read for responses, 0 through maxCoordinatePrecision.
+	CoordinatePrecision int
+}
+
+// TLSEnabled reports whether the server should serve HTTPS.
+func (c ServerConfig) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
 }
 
 type DivvyConfig struct {
-	StationInfoURL   string
-	StationStatusURL string
+	Systems []SystemConfig
+	// MaxConcurrentFetches caps simultaneous GBFS feed requests across all
+	// systems. A non-positive value means unlimited.
+	MaxConcurrentFetches int
+	// MinExpectedStations is the station count below which a fetch is
+	// treated as a feed shape change once a system has previously reached
+	// it. A non-positive value disables the check.
+	MinExpectedStations int
+	// AllowedFeedHosts lists the hostnames that user-supplied feed URLs (e.g.
+	// POST /api/admin/validate-feed) may point at. Empty rejects every
+	// user-supplied URL; the configured systems are always allowed.
+	AllowedFeedHosts []string
+	// AllowStaleFeed serves a system's last good station information or
+	// status in place of a feed that failed to fetch, instead of failing the
+	// refresh.
+	AllowStaleFeed bool
+	// UnmatchedStatusMode decides what happens to station_status entries
+	// for stations station_information does not list (a new station often
+	// appears in status first): "skip" drops them, "placeholder" stores a
+	// station named after its ID, without a location, until information
+	// lists it.
+	UnmatchedStatusMode string
+}
+
+// SystemConfig describes a single GBFS system and the feeds it is ingested from.
+// FreeBikeStatusURL is optional; dockless bikes are skipped when it is empty.
+type SystemConfig struct {
+	ID                string
+	StationInfoURL    string
+	StationStatusURL  string
+	FreeBikeStatusURL string
 }
 
 type MLConfig struct {
-	ServiceURL        string
-	RequestTimeoutMin int
-	Port              int
+	ServiceURL              string
+	RequestTimeoutMin       int
+	Port                    int
+	PredictRetryAttempts    int
+	PredictRetryBaseDelayMs int
+	StrictDecode            bool
+	// PushAvailability sends current station availability in the /predict
+	// body instead of having the ML service read it from the database.
+	PushAvailability bool
+	// PredictionTimeFallback decides what happens to a prediction whose
+	// prediction_time does not parse: "now" stores it with the current
+	// time, "skip" drops it. Either way it is logged and counted.
+	PredictionTimeFallback string
+	// FailFastUnreachable skips the startup readiness wait when nothing is
+	// listening at ServiceURL, instead of retrying until MLServiceMaxWaitMin.
+	FailFastUnreachable bool
+	// SeedPredictionsOnStartup makes Start wait, for at most
+	// SeedPredictionsTimeoutSec, for the ML service and a first inference
+	// run before accepting traffic, so predicted mode works from the first
+	// request. Otherwise initial predictions are generated in the background.
+	SeedPredictionsOnStartup  bool
+	SeedPredictionsTimeoutSec int
+	// SmoothingWindow is how many stored predictions per station and
+	// horizon ?smooth=true averages over; see smoothPredictions.
+	SmoothingWindow int
+	// SnapshotMaxAgeMin is how long the in-memory latest predictions
+	// snapshot is served before it is reloaded; it is also rebuilt after
+	// each inference run. Non-positive disables the snapshot.
+	SnapshotMaxAgeMin int
+	// OutlierMinDeviation is how far, in availability classes, a station's
+	// prediction must be from its neighbors' average to be listed by
+	// GET /api/predictions/outliers.
+	OutlierMinDeviation float64
 }
 
 type TimingConfig struct {
-	DataCollectionIntervalMin int
-	PredictionIntervalHours   int
-	ServerShutdownTimeoutSec  int
-	MLServiceMaxWaitMin       int
-	MLServiceCheckIntervalSec int
+	DataCollectionIntervalMin    int
+	PredictionIntervalHours      int
+	ServerShutdownTimeoutSec     int
+	RequestTimeoutSec            int
+	MLServiceMaxWaitMin          int
+	MLServiceCheckIntervalSec    int
+	MLServiceMaxCheckIntervalSec int
+	// FeatureFlagRefreshSec is how often the feature flag cache reloads;
+	// non-positive loads flags once at startup only.
+	FeatureFlagRefreshSec int
+	// RecentAvailabilityWindowMin bounds what counts as "recent"
+	// availability. Non-positive derives it from the collection interval;
+	// see RecentAvailabilityWindow.
+	RecentAvailabilityWindowMin int
+	// AvailabilityPersistEveryN stores availability only on every Nth
+	// collection cycle, so a short collection interval keeps the stations
+	// snapshot fresh without growing station_availability as fast. Values
+	// below 2 store every cycle.
+	AvailabilityPersistEveryN int
+	// StationInfoRefreshMin is how often station_information is fetched and
+	// stations are upserted; collection cycles in between fetch only
+	// station_status. Non-positive fetches both every cycle.
+	StationInfoRefreshMin int
+	// LatestAvailabilityMaxAgeMin is how old a station's latest availability
+	// may be before it is reported as stale, with zero counts, instead of
+	// as current. Zero derives it from RecentAvailabilityWindow; negative
+	// never treats availability as stale.
+	LatestAvailabilityMaxAgeMin int
+	// RefreshRetryBudget is how many retries one refresh cycle may spend,
+	// in total, on failed feed fetches and database writes lost to a
+	// dropped connection; RefreshRetryBudgetSec caps the time they take.
+	// A cycle that runs out is abandoned. Non-positive never retries.
+	RefreshRetryBudget      int
+	RefreshRetryBudgetSec   int
+	RefreshRetryBaseDelayMs int
+	// IdempotencyKeyTTLSec is how long the response to a request carrying an
+	// Idempotency-Key is replayed to retries with the same key.
+	// Non-positive ignores the header.
+	IdempotencyKeyTTLSec int
+}
+
+// LatestAvailabilityMaxAge is the configured LatestAvailabilityMaxAgeMin,
+// RecentAvailabilityWindow when unset, or zero when disabled.
+func (t TimingConfig) LatestAvailabilityMaxAge() time.Duration {
+	switch {
+	case t.LatestAvailabilityMaxAgeMin > 0:
+		return time.Duration(t.LatestAvailabilityMaxAgeMin) * time.Minute
+	case t.LatestAvailabilityMaxAgeMin < 0:
+		return 0
+	}
+	return t.RecentAvailabilityWindow()
+}
+
+// RecentAvailabilityWindow is the configured recent-availability window, or
+// two persisted collections when unset, so a single late collection is
+// still covered.
+func (t TimingConfig) RecentAvailabilityWindow() time.Duration {
+	if t.RecentAvailabilityWindowMin > 0 {
+		return time.Duration(t.RecentAvailabilityWindowMin) * time.Minute
+	}
+	return 2 * time.Duration(t.DataCollectionIntervalMin*max(t.AvailabilityPersistEveryN, 1)) * time.Minute
+}
+
+// HTTPClientConfig applies to all outbound requests (GBFS feeds and the ML service).
+type HTTPClientConfig struct {
+	UserAgent string
+	// ProxyURL, when set, sends outbound feed, ML service and webhook
+	// requests through this proxy instead of the one in HTTPS_PROXY or
+	// HTTP_PROXY. NO_PROXY still applies. Requests to user-supplied URLs
+	// (feed validation, webhooks) are proxied too, once their host resolves
+	// to public addresses only.
+	ProxyURL string
+}
+
+// AnomalyConfig controls availability anomaly detection after each refresh.
+// A non-positive threshold disables its check; detection, including the
+// last_reported regression check, is off when both are disabled.
+type AnomalyConfig struct {
+	SwingThresholdPct int
+	// OverCapacityPct flags stations whose bikes plus docks exceed their
+	// capacity by more than this percentage.
+	OverCapacityPct int
+}
+
+// Enabled reports whether any anomaly check is configured.
+func (a AnomalyConfig) Enabled() bool {
+	return a.SwingThresholdPct > 0 || a.OverCapacityPct > 0
+}
+
+// EvaluationConfig controls the job that scores matured predictions against
+// the availability actually recorded. A non-positive interval disables it.
+type EvaluationConfig struct {
+	IntervalMin int
+	Workers     int
+	// MaxGapMin is how far from a prediction's time the closest availability
+	// record may be and still count as the actual value.
+	MaxGapMin int
+}
+
+// WebhookConfig controls delivery of station webhooks. A delivery is tried
+// MaxAttempts times with exponential backoff, each attempt limited to
+// TimeoutSec and the whole delivery to DeliveryTimeoutSec (non-positive for
+// no limit); a webhook whose deliveries fail DisableAfterFailures times in a
+// row is disabled. At most Concurrency deliveries run at once.
+type WebhookConfig struct {
+	TimeoutSec           int
+	MaxAttempts          int
+	DisableAfterFailures int
+	Concurrency          int
+	DeliveryTimeoutSec   int
 }
 
+// TracingConfig enables OpenTelemetry tracing. The endpoint is taken from the
+// standard OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT
+// variables, which the exporter also reads directly along with the rest of
+// the OTEL_EXPORTER_OTLP_* settings.
+type TracingConfig struct {
+	OTLPEndpoint string
+	// Disabled mirrors OTEL_SDK_DISABLED, turning tracing off even when an
+	// endpoint is set.
+	Disabled bool
+}
+
+// Enabled reports whether spans should be exported.
+func (c TracingConfig) Enabled() bool {
+	return c.OTLPEndpoint != "" && !c.Disabled
+}
+
+// AvailabilityThresholds buckets stations by bikes available: at most Low is
+// red, at most Medium is yellow, anything above is green.
+type AvailabilityThresholds struct {
+	Low    int
+	Medium int
+}
+
+// Color returns the availability bucket for a bike count, using the same
+// red/yellow/green names as predicted availability.
+func (t AvailabilityThresholds) Color(bikesAvailable int) string {
+	switch {
+	case bikesAvailable <= t.Low:
+		return "red"
+	case bikesAvailable <= t.Medium:
+		return "yellow"
+	default:
+		return "green"
+	}
+}
+
+// LoggingConfig controls sampling of high-volume warnings. A non-positive
+// limit logs every message.
+type LoggingConfig struct {
+	SampleLimitPerMin int
+}
+
+type HealthConfig struct {
+	MinHealthyPredictions    int
+	StaleStationThresholdMin int
+	// ReadinessCriticalChecks lists the subsystems whose failing checks make
+	// /readyz answer 503; other failures only degrade it.
+	ReadinessCriticalChecks []string
+}
+
+// Subsystems READINESS_CRITICAL_CHECKS can name.
+const (
+	SubsystemDB          = "db"
+	SubsystemML          = "ml"
+	SubsystemFeed        = "feed"
+	SubsystemPredictions = "predictions"
+)
+
 func LoadConfig() *Config {
 	return &Config{
 		Database: DatabaseConfig{
-			URL: getEnv("DB_URL", ""),
+			URL:                 getEnv("DB_URL", databaseURLFromComponents()),
+			ReadURL:             getEnv("DB_READ_URL", ""),
+			MaxOpenConns:        getEnvInt("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:        getEnvInt("DB_MAX_IDLE_CONNS", 5),
+			ConnMaxLifetimeMin:  getEnvInt("DB_CONN_MAX_LIFETIME_MIN", 5),
+			PredictionBatchSize: getEnvInt("PREDICTION_BATCH_SIZE", 5000),
+			MigrationsDir:       getEnv("MIGRATIONS_DIR", "./migrations"),
+			HistoryMaxRows:      getEnvInt("HISTORY_MAX_ROWS", 100000),
 		},
 		Server: ServerConfig{
-			Port:        getEnv("SERVER_PORT", "8080"),
-			Environment: getEnv("ENVIRONMENT", ""),
+			Port:            getEnv("SERVER_PORT", "8080"),
+			Environment:     getEnv("ENVIRONMENT", ""),
+			StaticDir:       getEnv("STATIC_DIR", "./static"),
+			TemplatesGlob:   getEnv("TEMPLATES_GLOB", "templates/*"),
+			AdminAPIKey:     getEnv("ADMIN_API_KEY", ""),
+			DisplayTimezone: getEnv("DISPLAY_TIMEZONE", "America/Chicago"),
+			MaxQueryBytes:   getEnvInt("MAX_QUERY_BYTES", 4096),
+			MaxQueryIDs:     getEnvInt("MAX_QUERY_IDS", 100),
+			StrictStartup:   getEnvBool("STRICT_STARTUP", false),
+			MaintenanceMode: getEnvBool("MAINTENANCE_MODE", false),
+
+			DefaultStationsMode: getEnv("DEFAULT_STATIONS_MODE", "current"),
+			MaxInflightRequests: getEnvInt("MAX_INFLIGHT_REQUESTS", 200),
+			TLSCertFile:         getEnv("TLS_CERT_FILE", ""),
+			TLSKeyFile:          getEnv("TLS_KEY_FILE", ""),
+			CoordinatePrecision: getEnvInt("COORDINATE_PRECISION", defaultCoordinatePrecision),
 		},
 		Divvy: DivvyConfig{
-			StationInfoURL:   getEnv("DIVVY_STATION_INFO_URL", "https://gbfs.divvybikes.com/gbfs/en/station_information.json"),
-			StationStatusURL: getEnv("DIVVY_STATION_STATUS_URL", "https://gbfs.divvybikes.com/gbfs/en/station_status.json"),
+			Systems:              loadSystems(),
+			MaxConcurrentFetches: getEnvInt("MAX_CONCURRENT_FEED_FETCHES", 4),
+			MinExpectedStations:  getEnvInt("MIN_EXPECTED_STATIONS", 50),
+			AllowedFeedHosts:     getEnvList("ALLOWED_FEED_HOSTS", nil),
+			AllowStaleFeed:       getEnvBool("ALLOW_STALE_FEED", false),
+			UnmatchedStatusMode:  getEnv("UNMATCHED_STATUS_MODE", UnmatchedStatusSkip),
 		},
 
 		ML: MLConfig{
-			ServiceURL:        getEnv("ML_SERVICE_URL", "http://ml:5000"),
-			RequestTimeoutMin: getEnvInt("ML_REQUEST_TIMEOUT_MIN", 5),
-			Port:              getEnvInt("ML_PORT", 5000),
+			ServiceURL:                getEnv("ML_SERVICE_URL", "http://ml:5000"),
+			RequestTimeoutMin:         getEnvInt("ML_REQUEST_TIMEOUT_MIN", 5),
+			Port:                      getEnvInt("ML_PORT", 5000),
+			PredictRetryAttempts:      getEnvInt("ML_PREDICT_RETRY_ATTEMPTS", 3),
+			PredictRetryBaseDelayMs:   getEnvInt("ML_PREDICT_RETRY_BASE_DELAY_MS", 2000),
+			StrictDecode:              getEnvBool("ML_STRICT_DECODE", false),
+			PushAvailability:          getEnvBool("ML_PUSH_AVAILABILITY", false),
+			PredictionTimeFallback:    getEnv("PREDICTION_TIME_FALLBACK", PredictionTimeFallbackNow),
+			FailFastUnreachable:       getEnvBool("ML_FAIL_FAST_UNREACHABLE", true),
+			SeedPredictionsOnStartup:  getEnvBool("SEED_PREDICTIONS_ON_STARTUP", false),
+			SeedPredictionsTimeoutSec: getEnvInt("SEED_PREDICTIONS_TIMEOUT_SEC", 120),
+			SmoothingWindow:           getEnvInt("PREDICTION_SMOOTHING_WINDOW", 3),
+			SnapshotMaxAgeMin:         getEnvInt("PREDICTIONS_SNAPSHOT_MAX_AGE_MIN", 15),
+			OutlierMinDeviation:       getEnvFloat("PREDICTION_OUTLIER_MIN_DEVIATION", 1),
 		},
 
 		Timing: TimingConfig{
-			DataCollectionIntervalMin: getEnvInt("DATA_COLLECTION_INTERVAL_MIN", 15),
-			PredictionIntervalHours:   getEnvInt("PREDICTION_INTERVAL_HOURS", 2),
-			ServerShutdownTimeoutSec:  getEnvInt("SERVER_SHUTDOWN_TIMEOUT_SEC", 10),
-			MLServiceMaxWaitMin:       getEnvInt("ML_SERVICE_MAX_WAIT_MIN", 5),
-			MLServiceCheckIntervalSec: getEnvInt("ML_SERVICE_CHECK_INTERVAL_SEC", 10),
+			DataCollectionIntervalMin:    getEnvInt("DATA_COLLECTION_INTERVAL_MIN", 15),
+			PredictionIntervalHours:      getEnvInt("PREDICTION_INTERVAL_HOURS", 2),
+			ServerShutdownTimeoutSec:     getEnvInt("SERVER_SHUTDOWN_TIMEOUT_SEC", 10),
+			RequestTimeoutSec:            getEnvInt("REQUEST_TIMEOUT_SEC", 30),
+			MLServiceMaxWaitMin:          getEnvInt("ML_SERVICE_MAX_WAIT_MIN", 5),
+			MLServiceCheckIntervalSec:    getEnvInt("ML_SERVICE_CHECK_INTERVAL_SEC", 10),
+			MLServiceMaxCheckIntervalSec: getEnvInt("ML_SERVICE_MAX_CHECK_INTERVAL_SEC", 60),
+			FeatureFlagRefreshSec:        getEnvInt("FEATURE_FLAG_REFRESH_SEC", 60),
+			RecentAvailabilityWindowMin:  getEnvInt("RECENT_AVAILABILITY_WINDOW_MIN", 0),
+			AvailabilityPersistEveryN:    getEnvInt("AVAILABILITY_PERSIST_EVERY_N", 1),
+			StationInfoRefreshMin:        getEnvInt("STATION_INFO_REFRESH_MIN", 24*60),
+			LatestAvailabilityMaxAgeMin:  getEnvInt("LATEST_AVAILABILITY_MAX_AGE_MIN", 0),
+			RefreshRetryBudget:           getEnvInt("REFRESH_RETRY_BUDGET", 3),
+			RefreshRetryBudgetSec:        getEnvInt("REFRESH_RETRY_BUDGET_SEC", 60),
+			RefreshRetryBaseDelayMs:      getEnvInt("REFRESH_RETRY_BASE_DELAY_MS", 1000),
+			IdempotencyKeyTTLSec:         getEnvInt("IDEMPOTENCY_KEY_TTL_SEC", 3600),
+		},
+
+		Health: HealthConfig{
+			MinHealthyPredictions:    getEnvInt("MIN_HEALTHY_PREDICTIONS", 1),
+			StaleStationThresholdMin: getEnvInt("STALE_STATION_THRESHOLD_MIN", 60),
+			ReadinessCriticalChecks:  getEnvList("READINESS_CRITICAL_CHECKS", []string{SubsystemDB}),
+		},
+
+		HTTP: HTTPClientConfig{
+			UserAgent: getEnv("HTTP_USER_AGENT", DefaultUserAgent()),
+			ProxyURL:  getEnv("HTTP_PROXY_URL", ""),
+		},
+
+		Anomaly: AnomalyConfig{
+			SwingThresholdPct: getEnvInt("ANOMALY_SWING_THRESHOLD_PCT", 75),
+			OverCapacityPct:   getEnvInt("ANOMALY_OVER_CAPACITY_PCT", 25),
+		},
+
+		Evaluation: EvaluationConfig{
+			IntervalMin: getEnvInt("EVALUATION_INTERVAL_MIN", 60),
+			Workers:     getEnvInt("EVALUATION_WORKERS", 4),
+			MaxGapMin:   getEnvInt("EVALUATION_MAX_GAP_MIN", 15),
+		},
+
+		Availability: loadAvailabilityThresholds(),
+		Logging: LoggingConfig{
+			SampleLimitPerMin: getEnvInt("LOG_SAMPLE_LIMIT_PER_MIN", defaultLogSampleLimit),
+		},
+		Tracing: TracingConfig{
+			OTLPEndpoint: getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "")),
+			Disabled:     getEnvBool("OTEL_SDK_DISABLED", false),
+		},
+		Webhooks: WebhookConfig{
+			TimeoutSec:           getEnvInt("WEBHOOK_TIMEOUT_SEC", 10),
+			MaxAttempts:          getEnvInt("WEBHOOK_MAX_ATTEMPTS", 3),
+			DisableAfterFailures: getEnvInt("WEBHOOK_DISABLE_AFTER_FAILURES", 5),
+			Concurrency:          getEnvInt("WEBHOOK_CONCURRENCY", 8),
+			DeliveryTimeoutSec:   getEnvInt("WEBHOOK_DELIVERY_TIMEOUT_SEC", 60),
 		},
 	}
 }
@@ -80,9 +443,100 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// databaseURLFromComponents assembles a connection string from DB_HOST,
+// DB_PORT, DB_USER, DB_PASSWORD, DB_NAME and DB_SSLMODE for deployments whose
+// secret manager cannot provide a single DB_URL. It returns "" unless host,
+// user and database name are all set.
+func databaseURLFromComponents() string {
+	host, user, name := os.Getenv("DB_HOST"), os.Getenv("DB_USER"), os.Getenv("DB_NAME")
+	if host == "" || user == "" || name == "" {
+		return ""
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.User(user),
+		Host:     host + ":" + getEnv("DB_PORT", "5432"),
+		Path:     "/" + name,
+		RawQuery: url.Values{"sslmode": {getEnv("DB_SSLMODE", "require")}}.Encode(),
+	}
+	if password := os.Getenv("DB_PASSWORD"); password != "" {
+		u.User = url.UserPassword(user, password)
+	}
+	return u.String()
+}
+
+// loadSystems returns the primary Divvy system followed by any extra systems
+// listed in GBFS_SYSTEMS as "id|station_info_url|station_status_url" entries,
+// optionally followed by "|free_bike_status_url", separated by semicolons.
+// Station IDs must be unique across systems: a refresh skips stations whose
+// ID another system already stores.
+func loadSystems() []SystemConfig {
+	systems := []SystemConfig{{
+		ID:                getEnv("DIVVY_SYSTEM_ID", DefaultSystemID),
+		StationInfoURL:    getEnv("DIVVY_STATION_INFO_URL", "https://gbfs.divvybikes.com/gbfs/en/station_information.json"),
+		StationStatusURL:  getEnv("DIVVY_STATION_STATUS_URL", "https://gbfs.divvybikes.com/gbfs/en/station_status.json"),
+		FreeBikeStatusURL: getEnv("DIVVY_FREE_BIKE_STATUS_URL", "https://gbfs.divvybikes.com/gbfs/en/free_bike_status.json"),
+	}}
+
+	for _, entry := range strings.Split(os.Getenv("GBFS_SYSTEMS"), ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		system, err := parseSystem(entry)
+		if err != nil {
+			log.Printf("Warning: ignoring GBFS_SYSTEMS entry %q: %v", entry, err)
+			continue
+		}
+		systems = append(systems, system)
+	}
+	return systems
+}
+
+func parseSystem(entry string) (SystemConfig, error) {
+	parts := strings.Split(entry, "|")
+	if len(parts) != 3 && len(parts) != 4 {
+		return SystemConfig{}, fmt.Errorf("expected id|station_info_url|station_status_url[|free_bike_status_url], got %d fields", len(parts))
+	}
+	system := SystemConfig{
+		ID:               strings.TrimSpace(parts[0]),
+		StationInfoURL:   strings.TrimSpace(parts[1]),
+		StationStatusURL: strings.TrimSpace(parts[2]),
+	}
+	if len(parts) == 4 {
+		system.FreeBikeStatusURL = strings.TrimSpace(parts[3])
+	}
+	if system.ID == "" || system.StationInfoURL == "" || system.StationStatusURL == "" {
+		return SystemConfig{}, errors.New("id and feed URLs must not be empty")
+	}
+	return system, nil
+}
+
+var defaultAvailabilityThresholds = AvailabilityThresholds{Low: 0, Medium: 3}
+
+// loadAvailabilityThresholds parses AVAILABILITY_THRESHOLDS as "low,medium".
+func loadAvailabilityThresholds() AvailabilityThresholds {
+	raw := os.Getenv("AVAILABILITY_THRESHOLDS")
+	if raw == "" {
+		return defaultAvailabilityThresholds
+	}
+	parts := strings.Split(raw, ",")
+	if len(parts) == 2 {
+		low, lowErr := strconv.Atoi(strings.TrimSpace(parts[0]))
+		medium, mediumErr := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if lowErr == nil && mediumErr == nil {
+			return AvailabilityThresholds{Low: low, Medium: medium}
+		}
+	}
+	log.Printf("Warning: invalid AVAILABILITY_THRESHOLDS %q, expected low,medium; using default %d,%d",
+		raw, defaultAvailabilityThresholds.Low, defaultAvailabilityThresholds.Medium)
+	return defaultAvailabilityThresholds
+}
+
 func (c *Config) Validate() error {
 	if c.Database.URL == "" {
-		return errors.New("DB_URL is required but not provided")
+		return errors.New("DB_URL or DB_HOST, DB_USER and DB_NAME are required but not provided")
 	}
 	if c.Timing.DataCollectionIntervalMin <= 0 {
 		return errors.New("data collection interval must be positive")
@@ -90,9 +544,82 @@ func (c *Config) Validate() error {
 	if c.Server.Port == "" {
 		return errors.New("server port is required")
 	}
+	if _, err := time.LoadLocation(c.Server.DisplayTimezone); err != nil {
+		return fmt.Errorf("invalid DISPLAY_TIMEZONE %q: %w", c.Server.DisplayTimezone, err)
+	}
+	if mode := c.Server.DefaultStationsMode; mode != "" && mode != "current" && mode != "predicted" {
+		return fmt.Errorf("invalid DEFAULT_STATIONS_MODE %q: must be current or predicted", mode)
+	}
+	if mode := c.Divvy.UnmatchedStatusMode; mode != "" && mode != UnmatchedStatusSkip && mode != UnmatchedStatusPlaceholder {
+		return fmt.Errorf("invalid UNMATCHED_STATUS_MODE %q: must be %s or %s", mode, UnmatchedStatusSkip, UnmatchedStatusPlaceholder)
+	}
+	if mode := c.ML.PredictionTimeFallback; mode != "" && mode != PredictionTimeFallbackNow && mode != PredictionTimeFallbackSkip {
+		return fmt.Errorf("invalid PREDICTION_TIME_FALLBACK %q: must be %s or %s", mode, PredictionTimeFallbackNow, PredictionTimeFallbackSkip)
+	}
+	for _, subsystem := range c.Health.ReadinessCriticalChecks {
+		switch subsystem {
+		case SubsystemDB, SubsystemML, SubsystemFeed, SubsystemPredictions:
+		default:
+			return fmt.Errorf("invalid READINESS_CRITICAL_CHECKS entry %q: must be %s, %s, %s or %s",
+				subsystem, SubsystemDB, SubsystemML, SubsystemFeed, SubsystemPredictions)
+		}
+	}
+	if raw := c.HTTP.ProxyURL; raw != "" {
+		proxy, err := url.Parse(raw)
+		if err != nil || (proxy.Scheme != "http" && proxy.Scheme != "https" && proxy.Scheme != "socks5") || proxy.Host == "" {
+			return fmt.Errorf("invalid HTTP_PROXY_URL %q: must be an http, https or socks5 URL with a host", redactURL(raw))
+		}
+	}
+	if p := c.Server.CoordinatePrecision; p < 0 || p > maxCoordinatePrecision {
+		return fmt.Errorf("invalid COORDINATE_PRECISION %d: must be between 0 and %d", p, maxCoordinatePrecision)
+	}
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		return errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.Timing.RequestTimeoutSec < 0 {
+		return errors.New("request timeout cannot be negative")
+	}
+	if c.Availability.Low < 0 || c.Availability.Medium < c.Availability.Low {
+		return fmt.Errorf("availability thresholds must satisfy 0 <= low <= medium, got %d,%d",
+			c.Availability.Low, c.Availability.Medium)
+	}
+	seen := make(map[string]bool, len(c.Divvy.Systems))
+	for _, system := range c.Divvy.Systems {
+		if seen[system.ID] {
+			return fmt.Errorf("duplicate system ID %q", system.ID)
+		}
+		seen[system.ID] = true
+	}
 	return nil
 }
 
+const redactedValue = "[REDACTED]"
+
+// Redacted returns a copy of the config that is safe to expose: the database
+// password and any auth tokens are masked.
+func (c *Config) Redacted() Config {
+	redacted := *c
+	redacted.Database.URL = redactURL(c.Database.URL)
+	redacted.Database.ReadURL = redactURL(c.Database.ReadURL)
+	redacted.HTTP.ProxyURL = redactURL(c.HTTP.ProxyURL)
+	if redacted.Server.AdminAPIKey != "" {
+		redacted.Server.AdminAPIKey = redactedValue
+	}
+	return redacted
+}
+
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme == "" {
+		// Not a URL (e.g. a key=value DSN); assume it may carry a password.
+		return redactedValue
+	}
+	return parsed.Redacted()
+}
+
 func getEnvInt(key string, defaultValue int) int {
 	val := os.Getenv(key)
 	if val == "" {
@@ -104,3 +631,43 @@ func getEnvInt(key string, defaultValue int) int {
 	log.Printf("Warning: invalid integer value for %s: %s, using default %d", key, val, defaultValue)
 	return defaultValue
 }
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultValue
+	}
+	if floatVal, err := strconv.ParseFloat(val, 64); err == nil {
+		return floatVal
+	}
+	log.Printf("Warning: invalid number value for %s: %s, using default %v", key, val, defaultValue)
+	return defaultValue
+}
+
+// getEnvList splits a comma-separated variable into trimmed, lowercased,
+// non-empty values; defaultValue when unset.
+func getEnvList(key string, defaultValue []string) []string {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultValue
+	}
+	var values []string
+	for _, v := range strings.Split(val, ",") {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultValue
+	}
+	if boolVal, err := strconv.ParseBool(val); err == nil {
+		return boolVal
+	}
+	log.Printf("Warning: invalid boolean value for %s: %s, using default %t", key, val, defaultValue)
+	return defaultValue
+}
@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"math"
+)
+
+const defaultCoordinatePrecision = 6
+
+// maxCoordinatePrecision bounds COORDINATE_PRECISION: float64 carries about
+// 15 significant decimal digits, so more places cannot change the value.
+const maxCoordinatePrecision = 15
+
+// Coordinate is a latitude or longitude. It is stored at full precision;
+// Database rounds the coordinates it reads to COORDINATE_PRECISION places,
+// which drops float artifacts such as 41.878100000000003 that trip up
+// clients comparing coordinates exactly.
+type Coordinate float64
+
+// Round returns c rounded to places decimal places.
+func (c Coordinate) Round(places int) Coordinate {
+	v := float64(c)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return c
+	}
+	scale := math.Pow10(places)
+	return Coordinate(math.Round(v*scale) / scale)
+}
@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoordinate_Round(t *testing.T) {
+	tests := []struct {
+		name     string
+		places   int
+		value    Coordinate
+		expected string
+	}{
+		{name: "float artifact", places: 6, value: 41.878100000000003, expected: "41.8781"},
+		{name: "rounds", places: 6, value: -87.62979849, expected: "-87.629798"},
+		{name: "fewer places", places: 3, value: 41.8781, expected: "41.878"},
+		{name: "whole degrees", places: 0, value: -87.62979849, expected: "-88"},
+		{name: "max places", places: maxCoordinatePrecision, value: -87.62979849, expected: "-87.62979849"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(tt.value.Round(tt.places))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(body))
+		})
+	}
+}
+
+func TestDatabase_RoundCoordinates(t *testing.T) {
+	d := &Database{coordinatePrecision: defaultCoordinatePrecision}
+	station := StationWithAvailability{Station: Station{StationID: "1", Lat: 41.8781234567, Lon: -87.6298765432}, NumBikesAvailable: 3}
+	d.roundCoordinates(&station.Lat, &station.Lon)
+
+	body, err := json.Marshal(station)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"lat":41.878123,"lon":-87.629877`)
+	assert.Contains(t, string(body), `"num_bikes_available":3`)
+}
@@ -3,32 +3,54 @@ package internal
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
     queryUpsertStation = `
-        INSERT INTO stations (station_id, name, lat, lon, capacity)
-        VALUES ($1, $2, $3, $4, $5)
+        INSERT INTO stations (station_id, system_id, name, lat, lon, capacity)
+        VALUES ($1, $2, $3, $4, $5, $6)
         ON CONFLICT (station_id)
         DO UPDATE SET
             name = EXCLUDED.name,
             lat = EXCLUDED.lat,
             lon = EXCLUDED.lon,
             capacity = EXCLUDED.capacity,
-            updated_at = CURRENT_TIMESTAMP`
+            updated_at = CURRENT_TIMESTAMP
+        WHERE stations.system_id = EXCLUDED.system_id
+            AND (stations.name, stations.lat, stations.lon, stations.capacity)
+            IS DISTINCT FROM (EXCLUDED.name, EXCLUDED.lat, EXCLUDED.lon, EXCLUDED.capacity)`
+
+    queryInsertPlaceholderStation = `
+        INSERT INTO stations (station_id, system_id, name, lat, lon, capacity)
+        VALUES ($1, $2, $3, 0, 0, 0)
+        ON CONFLICT (station_id) DO NOTHING`
 
     queryInsertPrediction = `
-        INSERT INTO predictions (station_id, predicted_availability_class, availability_prediction, prediction_time, horizon_hours)
-        VALUES ($1, $2, $3, $4, $5)`
+        INSERT INTO predictions (station_id, predicted_availability_class, availability_prediction, prediction_time, horizon_hours, confidence, model_version)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)`
 )
 
+// metersPerDegreeLat approximates the length of one degree of latitude.
+const metersPerDegreeLat = 111320.0
+
 type Database struct {
 	db *sql.DB
+	// read serves queryContext and queryRow: the DB_READ_URL pool when one
+	// is configured, otherwise db.
+	read                *sql.DB
+	predictionBatchSize int
+	recentWindow        time.Duration
+	latestMaxAge        time.Duration
+	historyMaxRows      int
+	coordinatePrecision int
 }
 
 func NewDatabase(cfg *Config) (*Database, error) {
@@ -36,34 +58,73 @@ func NewDatabase(cfg *Config) (*Database, error) {
 		return nil, fmt.Errorf("DB_URL is required but not provided")
 	}
 
-	db, err := sql.Open("postgres", cfg.Database.URL)
+	db, err := openPool(cfg.Database.URL, cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Successfully connected to database")
+
+	read := db
+	if cfg.Database.ReadURL != "" {
+		if read, err = openPool(cfg.Database.ReadURL, cfg.Database); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("read database: %w", err)
+		}
+		log.Println("Successfully connected to read database")
+	}
+
+	return &Database{
+		db:                  db,
+		read:                read,
+		predictionBatchSize: cfg.Database.PredictionBatchSize,
+		recentWindow:        cfg.Timing.RecentAvailabilityWindow(),
+		latestMaxAge:        cfg.Timing.LatestAvailabilityMaxAge(),
+		historyMaxRows:      cfg.Database.HistoryMaxRows,
+		coordinatePrecision: cfg.Server.CoordinatePrecision,
+	}, nil
+}
+
+// roundCoordinates rounds coordinates read from the database to
+// COORDINATE_PRECISION decimal places. Stored coordinates keep full precision.
+func (d *Database) roundCoordinates(lat, lon *Coordinate) {
+	*lat = lat.Round(d.coordinatePrecision)
+	*lon = lon.Round(d.coordinatePrecision)
+}
+
+// openPool opens and pings a connection pool configured from cfg.
+func openPool(url string, cfg DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	// Configure connection pool for cloud database
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetimeMin) * time.Minute)
 
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
-
-	log.Println("Successfully connected to database")
-	return &Database{db: db}, nil
+	return db, nil
 }
 
 func (d *Database) Close() error {
+	if d.read != d.db {
+		d.read.Close()
+	}
 	return d.db.Close()
 }
 
-func (d *Database) UpsertStations(ctx context.Context, stations []Station) error {
+func (d *Database) UpsertStations(ctx context.Context, stations []Station) (err error) {
 	if len(stations) == 0 {
 		return nil
 	}
+	ctx, span := startDBSpan(ctx, "UpsertStations", attribute.Int("db.rows", len(stations)))
+	defer func() { endSpan(span, err) }()
 
-	tx, err := d.db.BeginTx(ctx, nil)
+	tx, err := d.beginTx(ctx)
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
 	}
@@ -76,26 +137,60 @@ func (d *Database) UpsertStations(ctx context.Context, stations []Station) error
 	defer stmt.Close()
 
 	for _, station := range stations {
-		_, err := stmt.ExecContext(ctx, station.StationID, station.Name, station.Lat, station.Lon, station.Capacity)
+		_, err := stmt.ExecContext(ctx, station.StationID, station.SystemID, station.Name, station.Lat, station.Lon, station.Capacity)
 		if err != nil {
 			return fmt.Errorf("exec station %s: %w", station.StationID, err)
 		}
 	}
 
-	return tx.Commit()
+	return commitTx(tx)
+}
+
+// InsertPlaceholderStations stores stations that station_status lists
+// before station_information does, at 0,0 with no capacity. A station that
+// already exists is left as it is, so a real station missing from one
+// station_information fetch keeps its name, location and capacity.
+func (d *Database) InsertPlaceholderStations(ctx context.Context, stations []Station) (err error) {
+	if len(stations) == 0 {
+		return nil
+	}
+	ctx, span := startDBSpan(ctx, "InsertPlaceholderStations", attribute.Int("db.rows", len(stations)))
+	defer func() { endSpan(span, err) }()
+
+	tx, err := d.beginTx(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, queryInsertPlaceholderStation)
+	if err != nil {
+		return fmt.Errorf("prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, station := range stations {
+		if _, err := stmt.ExecContext(ctx, station.StationID, station.SystemID, station.Name); err != nil {
+			return fmt.Errorf("exec placeholder station %s: %w", station.StationID, err)
+		}
+	}
+
+	return commitTx(tx)
 }
 
-func (d *Database) InsertAvailabilities(ctx context.Context, availabilities []StationAvailability) error {
+func (d *Database) InsertAvailabilities(ctx context.Context, availabilities []StationAvailability) (err error) {
 	if len(availabilities) == 0 {
 		return nil
 	}
+	ctx, span := startDBSpan(ctx, "InsertAvailabilities", attribute.Int("db.rows", len(availabilities)))
+	defer func() { endSpan(span, err) }()
 
 	query := `
 		INSERT INTO station_availability
-		(station_id, num_bikes_available, num_docks_available, is_installed, is_renting, is_returning, last_reported)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+		(station_id, system_id, num_bikes_available, num_docks_available, is_installed, is_renting, is_returning, last_reported)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
 
-	tx, err := d.db.BeginTx(ctx, nil)
+	tx, err := d.beginTx(ctx)
 	if err != nil {
 		return fmt.Errorf("begin transaction: %w", err)
 	}
@@ -110,6 +205,7 @@ func (d *Database) InsertAvailabilities(ctx context.Context, availabilities []St
 	for _, availability := range availabilities {
 		_, err := stmt.ExecContext(ctx,
 			availability.StationID,
+			availability.SystemID,
 			availability.NumBikesAvailable,
 			availability.NumDocksAvailable,
 			availability.IsInstalled,
@@ -122,60 +218,250 @@ func (d *Database) InsertAvailabilities(ctx context.Context, availabilities []St
 		}
 	}
 
-	return tx.Commit()
+	return commitTx(tx)
+}
+
+// locatedStation excludes stations at 0,0: placeholder stations
+// (UNMATCHED_STATUS_MODE=placeholder) are stored there until
+// station_information lists them.
+const locatedStation = `NOT (s.lat = 0 AND s.lon = 0)`
+
+// queryStationsWithAvailability joins each station with its latest
+// availability row no older than latestMaxAge; stations without one are
+// stale, and placeholder stations are left out. where filters stations
+// (aliased s) using args.
+func (d *Database) queryStationsWithAvailability(ctx context.Context, where string, args ...interface{}) ([]StationWithAvailability, error) {
+	var stations []StationWithAvailability
+	err := d.eachStationWithAvailability(ctx, func(station StationWithAvailability) error {
+		stations = append(stations, station)
+		return nil
+	}, where, args...)
+	if err != nil {
+		return nil, err
+	}
+	return stations, nil
 }
 
-func (d *Database) GetStationsWithAvailability(ctx context.Context) ([]StationWithAvailability, error) {
+// eachStationWithAvailability runs the queryStationsWithAvailability query
+// and calls fn for every row as it is scanned, stopping at the first error
+// from fn or once ctx is done.
+func (d *Database) eachStationWithAvailability(ctx context.Context, fn func(StationWithAvailability) error, where string, args ...interface{}) error {
+	// The max age follows the caller's parameters.
+	maxAge := fmt.Sprintf("$%d::int", len(args)+1)
+	args = append(args, int64(d.latestMaxAge.Seconds()))
+
 	query := `
 		SELECT
-			s.station_id, s.name, s.lat, s.lon, s.capacity, s.updated_at,
+			s.station_id, s.system_id, s.name, s.lat, s.lon, s.capacity, s.updated_at,
 			COALESCE(sa.num_bikes_available, 0) as num_bikes_available,
 			COALESCE(sa.num_docks_available, 0) as num_docks_available,
 			COALESCE(sa.is_installed, 0) as is_installed,
 			COALESCE(sa.is_renting, 0) as is_renting,
 			COALESCE(sa.is_returning, 0) as is_returning,
-			COALESCE(sa.last_reported, 0) as last_reported
+			COALESCE(sa.last_reported, 0) as last_reported,
+			sa.station_id IS NULL as is_stale
 		FROM stations s
 		LEFT JOIN LATERAL (
 			SELECT * FROM station_availability
 			WHERE station_id = s.station_id
+				AND (` + maxAge + ` = 0 OR recorded_at > NOW() - ` + maxAge + ` * INTERVAL '1 second')
 			ORDER BY recorded_at DESC
 			LIMIT 1
 		) sa ON true
+		WHERE (` + where + `) AND ` + locatedStation + `
 		ORDER BY s.name`
 
-	rows, err := d.db.QueryContext(ctx, query)
+	rows, err := d.queryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var stations []StationWithAvailability
 	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		var station StationWithAvailability
 		err := rows.Scan(
-			&station.StationID, &station.Name, &station.Lat, &station.Lon, &station.Capacity, &station.UpdatedAt,
+			&station.StationID, &station.SystemID, &station.Name, &station.Lat, &station.Lon, &station.Capacity, &station.UpdatedAt,
 			&station.NumBikesAvailable, &station.NumDocksAvailable,
 			&station.IsInstalled, &station.IsRenting, &station.IsReturning, &station.LastReported,
+			&station.IsStale,
+		)
+		if err != nil {
+			return err
+		}
+		d.roundCoordinates(&station.Lat, &station.Lon)
+		if err := fn(station); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (d *Database) GetStationsWithAvailability(ctx context.Context, systemID string) (_ []StationWithAvailability, err error) {
+	ctx, span := startDBSpan(ctx, "GetStationsWithAvailability", attribute.String("system.id", systemID))
+	defer func() { endSpan(span, err) }()
+
+	return d.queryStationsWithAvailability(ctx, `$1 = '' OR s.system_id = $1`, systemID)
+}
+
+// StreamStationsWithAvailability is GetStationsWithAvailability without
+// buffering: fn receives each station as its row is scanned.
+func (d *Database) StreamStationsWithAvailability(ctx context.Context, systemID string, fn func(StationWithAvailability) error) error {
+	return d.eachStationWithAvailability(ctx, fn, `$1 = '' OR s.system_id = $1`, systemID)
+}
+
+func (d *Database) GetStationsInBBox(ctx context.Context, bounds Bounds) ([]StationWithAvailability, error) {
+	return d.queryStationsWithAvailability(ctx,
+		`s.lat BETWEEN $1 AND $2 AND s.lon BETWEEN $3 AND $4`,
+		bounds.MinLat, bounds.MaxLat, bounds.MinLon, bounds.MaxLon)
+}
+
+func (d *Database) GetStationWithAvailability(ctx context.Context, stationID string) (StationWithAvailability, error) {
+	stations, err := d.queryStationsWithAvailability(ctx, `s.station_id = $1`, stationID)
+	if err != nil {
+		return StationWithAvailability{}, err
+	}
+	if len(stations) == 0 {
+		return StationWithAvailability{}, ErrStationNotFound
+	}
+	return stations[0], nil
+}
+
+// GetStationsByIDs returns the listed stations that exist, in no particular
+// order.
+func (d *Database) GetStationsByIDs(ctx context.Context, stationIDs []string) ([]StationWithAvailability, error) {
+	return d.queryStationsWithAvailability(ctx, `s.station_id = ANY($1)`, pq.Array(stationIDs))
+}
+
+func (d *Database) GetStationsUpdatedSince(ctx context.Context, since time.Time) ([]Station, error) {
+	query := `
+		SELECT station_id, system_id, name, lat, lon, capacity, created_at, updated_at
+		FROM stations
+		WHERE updated_at > $1
+		ORDER BY updated_at ASC`
+
+	rows, err := d.queryContext(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stations []Station
+	for rows.Next() {
+		var station Station
+		err := rows.Scan(
+			&station.StationID, &station.SystemID, &station.Name, &station.Lat, &station.Lon,
+			&station.Capacity, &station.CreatedAt, &station.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
+		d.roundCoordinates(&station.Lat, &station.Lon)
 		stations = append(stations, station)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stations, nil
+}
+
+// GetStationsMissingAvailability returns stations with no availability row
+// recorded after since, i.e. stations the status feed has dropped, with the
+// age of their last record. Stations that never reported come first. The
+// last record is looked up per station through the (station_id,
+// recorded_at) index instead of grouping the whole table.
+func (d *Database) GetStationsMissingAvailability(ctx context.Context, since time.Time) ([]MissingStation, error) {
+	query := `
+		SELECT s.station_id, s.system_id, s.name, s.lat, s.lon, s.capacity, s.created_at, s.updated_at,
+			EXTRACT(EPOCH FROM (CURRENT_TIMESTAMP - a.recorded_at))::bigint AS last_record_age
+		FROM stations s
+		LEFT JOIN LATERAL (
+			SELECT recorded_at
+			FROM station_availability
+			WHERE station_id = s.station_id
+			ORDER BY recorded_at DESC
+			LIMIT 1
+		) a ON true
+		WHERE a.recorded_at IS NULL OR a.recorded_at <= $1
+		ORDER BY last_record_age DESC NULLS FIRST, s.station_id`
+
+	rows, err := d.queryContext(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stations missing availability: %w", err)
+	}
+	defer rows.Close()
 
+	var stations []MissingStation
+	for rows.Next() {
+		var station MissingStation
+		var age sql.NullInt64
+		err := rows.Scan(
+			&station.StationID, &station.SystemID, &station.Name, &station.Lat, &station.Lon,
+			&station.Capacity, &station.CreatedAt, &station.UpdatedAt, &age,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan station missing availability: %w", err)
+		}
+		if age.Valid {
+			station.LastRecordAgeSeconds = &age.Int64
+		}
+		d.roundCoordinates(&station.Lat, &station.Lon)
+		stations = append(stations, station)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stations missing availability: %w", err)
+	}
 	return stations, nil
 }
 
+func (d *Database) CountStations(ctx context.Context) (int, error) {
+	var count int
+	if err := d.queryRow(ctx, `SELECT COUNT(*) FROM stations`, nil, &count); err != nil {
+		return 0, fmt.Errorf("count stations: %w", err)
+	}
+	return count, nil
+}
+
+// GetStationIDsOfOtherSystems returns the stationIDs already stored for a
+// system other than systemID. Station IDs are unique across systems, so
+// those stations belong to the other system.
+func (d *Database) GetStationIDsOfOtherSystems(ctx context.Context, systemID string, stationIDs []string) ([]string, error) {
+	rows, err := d.queryContext(ctx,
+		`SELECT station_id FROM stations WHERE station_id = ANY($1) AND system_id <> $2 ORDER BY station_id`,
+		pq.Array(stationIDs), systemID)
+	if err != nil {
+		return nil, fmt.Errorf("query station owners: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan station owner: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetRecentAvailability returns availability recorded within the recent
+// window (see TimingConfig.RecentAvailabilityWindow), newest first. Only
+// stored cycles are returned; StationService.GetRecentAvailability adds the
+// availability held back by AVAILABILITY_PERSIST_EVERY_N.
 func (d *Database) GetRecentAvailability(ctx context.Context) ([]StationAvailability, error) {
 	query := `
-		SELECT id, station_id, num_bikes_available, num_docks_available,
+		SELECT id, station_id, system_id, num_bikes_available, num_docks_available,
 		       is_installed, is_renting, is_returning, last_reported, recorded_at
 		FROM station_availability
-		WHERE recorded_at > NOW() - INTERVAL '20 minutes'
+		WHERE recorded_at > NOW() - $1 * INTERVAL '1 second'
 		ORDER BY recorded_at DESC`
 
-	rows, err := d.db.QueryContext(ctx, query)
+	rows, err := d.queryContext(ctx, query, d.recentWindow.Seconds())
 	if err != nil {
 		return nil, err
 	}
@@ -183,9 +469,12 @@ func (d *Database) GetRecentAvailability(ctx context.Context) ([]StationAvailabi
 
 	var records []StationAvailability
 	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		var record StationAvailability
 		err := rows.Scan(
-			&record.ID, &record.StationID, &record.NumBikesAvailable,
+			&record.ID, &record.StationID, &record.SystemID, &record.NumBikesAvailable,
 			&record.NumDocksAvailable, &record.IsInstalled, &record.IsRenting,
 			&record.IsReturning, &record.LastReported, &record.RecordedAt,
 		)
@@ -194,117 +483,1379 @@ func (d *Database) GetRecentAvailability(ctx context.Context) ([]StationAvailabi
 		}
 		records = append(records, record)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return records, nil
 }
 
-func (d *Database) GetAvailabilitySince(ctx context.Context, since time.Time) ([]StationAvailability, error) {
+// GetAvailabilitySince returns availability recorded after since, oldest
+// first, capped at HISTORY_MAX_ROWS; the bool reports that it was capped.
+func (d *Database) GetAvailabilitySince(ctx context.Context, since time.Time) ([]StationAvailability, bool, error) {
 	query := `
-		SELECT id, station_id, num_bikes_available, num_docks_available,
+		SELECT id, station_id, system_id, num_bikes_available, num_docks_available,
 		       is_installed, is_renting, is_returning, last_reported, recorded_at
 		FROM station_availability
 		WHERE recorded_at > $1
-		ORDER BY recorded_at ASC`
+		ORDER BY recorded_at ASC` + d.historyLimit()
 
-	rows, err := d.db.QueryContext(ctx, query, since)
+	rows, err := d.queryContext(ctx, query, since)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer rows.Close()
 
 	var records []StationAvailability
 	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
 		var record StationAvailability
 		err := rows.Scan(
-			&record.ID, &record.StationID, &record.NumBikesAvailable,
+			&record.ID, &record.StationID, &record.SystemID, &record.NumBikesAvailable,
 			&record.NumDocksAvailable, &record.IsInstalled, &record.IsRenting,
 			&record.IsReturning, &record.LastReported, &record.RecordedAt,
 		)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		records = append(records, record)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
 
-	return records, nil
+	records, truncated := d.truncateHistory("availability since "+since.Format(time.RFC3339), records)
+	return records, truncated, nil
 }
 
-func (d *Database) withTransaction(ctx context.Context, fn func(*sql.Tx) error) error {
-    tx, err := d.db.BeginTx(ctx, nil)
-    if err != nil {
-        return fmt.Errorf("begin transaction: %w", err)
-    }
-    
-    defer func() {
-        if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
-            log.Printf("Error rolling back transaction: %v", err)
-        }
-    }()
+// historyLimit returns the LIMIT clause capping a raw history query at
+// HISTORY_MAX_ROWS, plus one row so truncateHistory can tell the result was
+// cut short, or nothing when there is no cap.
+func (d *Database) historyLimit() string {
+	if d.historyMaxRows <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" LIMIT %d", d.historyMaxRows+1)
+}
 
-    if err := fn(tx); err != nil {
-        return err
-    }
+// truncateHistory drops the extra row historyLimit fetches and reports
+// whether there was one.
+func (d *Database) truncateHistory(query string, records []StationAvailability) ([]StationAvailability, bool) {
+	if d.historyMaxRows <= 0 || len(records) <= d.historyMaxRows {
+		return records, false
+	}
+	log.Printf("Truncated %s to HISTORY_MAX_ROWS=%d rows", query, d.historyMaxRows)
+	return records[:d.historyMaxRows], true
+}
+
+// GetAvailabilitySeries averages a station's availability into fixed-width
+// buckets aligned to the Unix epoch, so the result size depends only on the
+// range and bucket width, not on how many raw rows were recorded.
+func (d *Database) GetAvailabilitySeries(ctx context.Context, stationID string, from, to time.Time, bucket time.Duration) ([]AvailabilityBucket, error) {
+	query := `
+		SELECT
+			to_timestamp(floor(extract(epoch FROM recorded_at) / $4) * $4) AS bucket_start,
+			AVG(num_bikes_available)::float8,
+			AVG(num_docks_available)::float8,
+			COUNT(*)
+		FROM station_availability
+		WHERE station_id = $1 AND recorded_at >= $2 AND recorded_at < $3
+		GROUP BY bucket_start
+		ORDER BY bucket_start ASC`
+
+	rows, err := d.queryContext(ctx, query, stationID, from, to, int64(bucket.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query availability series: %w", err)
+	}
+	defer rows.Close()
 
-    return tx.Commit()
+	var buckets []AvailabilityBucket
+	for rows.Next() {
+		var b AvailabilityBucket
+		if err := rows.Scan(&b.BucketStart, &b.AvgBikesAvailable, &b.AvgDocksAvailable, &b.Samples); err != nil {
+			return nil, fmt.Errorf("failed to scan availability bucket: %w", err)
+		}
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read availability series: %w", err)
+	}
+	return buckets, nil
 }
 
-func (d *Database) InsertPredictions(ctx context.Context, predictions []Prediction) error {
-    if len(predictions) == 0 {
-        return nil
-    }
+// GetRollupAvailabilitySeries is GetAvailabilitySeries for buckets of an
+// hour or more. It reads availability_hourly, weighting each hour by its
+// sample count, and falls back to raw rows for every hour the station has
+// not been rolled up for: the current hour, and any hour a rollup run
+// skipped (see rollupMaxCatchUp).
+func (d *Database) GetRollupAvailabilitySeries(ctx context.Context, stationID string, from, to time.Time, bucket time.Duration) ([]AvailabilityBucket, error) {
+	query := `
+		WITH samples AS (
+			SELECT hour AS sampled_at, avg_bikes_available * samples AS bikes,
+				avg_docks_available * samples AS docks, samples
+			FROM availability_hourly
+			WHERE station_id = $1 AND hour >= $2 AND hour < $3
+			UNION ALL
+			SELECT a.recorded_at, a.num_bikes_available, a.num_docks_available, 1
+			FROM station_availability a
+			WHERE a.station_id = $1 AND a.recorded_at >= $2 AND a.recorded_at < $3
+				AND NOT EXISTS (
+					SELECT 1 FROM availability_hourly h
+					WHERE h.station_id = $1
+						AND h.hour <= a.recorded_at AND a.recorded_at < h.hour + INTERVAL '1 hour'
+				)
+		)
+		SELECT
+			to_timestamp(floor(extract(epoch FROM sampled_at) / $4) * $4) AS bucket_start,
+			(SUM(bikes) / SUM(samples))::float8,
+			(SUM(docks) / SUM(samples))::float8,
+			SUM(samples)
+		FROM samples
+		GROUP BY bucket_start
+		ORDER BY bucket_start ASC`
 
-    return d.withTransaction(ctx, func(tx *sql.Tx) error {
-        stmt, err := tx.PrepareContext(ctx, queryInsertPrediction)
-        if err != nil {
-            return fmt.Errorf("prepare statement: %w", err)
-        }
-        defer stmt.Close()
+	rows, err := d.queryContext(ctx, query, stationID, from, to, int64(bucket.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rollup availability series: %w", err)
+	}
+	defer rows.Close()
 
-        for _, pred := range predictions {
-            if _, err := stmt.ExecContext(ctx, pred.StationID, pred.PredictedAvailabilityClass,
-                pred.AvailabilityPrediction, pred.PredictionTime, pred.HorizonHours); err != nil {
-                return fmt.Errorf("insert prediction for station %s: %w", pred.StationID, err)
-            }
-        }
-        return nil
-    })
+	var buckets []AvailabilityBucket
+	for rows.Next() {
+		var b AvailabilityBucket
+		if err := rows.Scan(&b.BucketStart, &b.AvgBikesAvailable, &b.AvgDocksAvailable, &b.Samples); err != nil {
+			return nil, fmt.Errorf("failed to scan availability bucket: %w", err)
+		}
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rollup availability series: %w", err)
+	}
+	return buckets, nil
+}
+
+// UpsertHourlyRollup aggregates the raw availability recorded in
+// [hour, hour+1h) into one availability_hourly row per station, replacing
+// any earlier rollup of that hour. hour must be on an hour boundary. It
+// returns the number of stations rolled up.
+func (d *Database) UpsertHourlyRollup(ctx context.Context, hour time.Time) (_ int, err error) {
+	ctx, span := startDBSpan(ctx, "UpsertHourlyRollup")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+		INSERT INTO availability_hourly (
+			station_id, hour,
+			avg_bikes_available, min_bikes_available, max_bikes_available,
+			avg_docks_available, min_docks_available, max_docks_available,
+			samples
+		)
+		SELECT station_id, $1,
+			AVG(num_bikes_available), MIN(num_bikes_available), MAX(num_bikes_available),
+			AVG(num_docks_available), MIN(num_docks_available), MAX(num_docks_available),
+			COUNT(*)
+		FROM station_availability
+		WHERE recorded_at >= $1 AND recorded_at < $1 + INTERVAL '1 hour'
+		GROUP BY station_id
+		ON CONFLICT (station_id, hour) DO UPDATE SET
+			avg_bikes_available = EXCLUDED.avg_bikes_available,
+			min_bikes_available = EXCLUDED.min_bikes_available,
+			max_bikes_available = EXCLUDED.max_bikes_available,
+			avg_docks_available = EXCLUDED.avg_docks_available,
+			min_docks_available = EXCLUDED.min_docks_available,
+			max_docks_available = EXCLUDED.max_docks_available,
+			samples = EXCLUDED.samples`
+
+	result, err := d.db.ExecContext(ctx, query, hour.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to upsert hourly rollup for %s: %w", hour.UTC().Format(time.RFC3339), err)
+	}
+	upserted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count hourly rollup rows: %w", err)
+	}
+	return int(upserted), nil
+}
+
+// GetLatestRollupHour returns the newest hour in availability_hourly, or the
+// zero time when nothing has been rolled up yet.
+func (d *Database) GetLatestRollupHour(ctx context.Context) (time.Time, error) {
+	var latest sql.NullTime
+	if err := d.queryRow(ctx, `SELECT MAX(hour) FROM availability_hourly`, nil, &latest); err != nil {
+		return time.Time{}, fmt.Errorf("failed to query latest rollup hour: %w", err)
+	}
+	return latest.Time, nil
+}
+
+// GetLatestRecordedAt returns when availability for systemID was last
+// stored, or the zero time when none has been.
+func (d *Database) GetLatestRecordedAt(ctx context.Context, systemID string) (time.Time, error) {
+	var latest sql.NullTime
+	query := `SELECT MAX(recorded_at) FROM station_availability WHERE system_id = $1`
+	if err := d.queryRow(ctx, query, []interface{}{systemID}, &latest); err != nil {
+		return time.Time{}, fmt.Errorf("failed to query latest recorded_at: %w", err)
+	}
+	return latest.Time, nil
+}
+
+// storageStatsTables maps each table GetStorageStats reports on to the
+// column its rows are dated by.
+var storageStatsTables = []struct{ table, dateColumn string }{
+	{"station_availability", "recorded_at"},
+	{"predictions", "created_at"},
+}
+
+func (d *Database) GetStorageStats(ctx context.Context, since time.Time) ([]TableStorageStats, error) {
+	stats := make([]TableStorageStats, 0, len(storageStatsTables))
+	for _, t := range storageStatsTables {
+		table := TableStorageStats{Table: t.table, Days: []DailyRowCount{}}
+		if err := d.queryRow(ctx, `SELECT pg_total_relation_size($1::regclass)`, []interface{}{t.table}, &table.TotalBytes); err != nil {
+			return nil, fmt.Errorf("failed to query %s size: %w", t.table, err)
+		}
+
+		query := fmt.Sprintf(`
+			SELECT date_trunc('day', %[1]s) AS day, COUNT(*)
+			FROM %[2]s
+			WHERE %[1]s >= $1
+			GROUP BY day
+			ORDER BY day`, t.dateColumn, t.table)
+		rows, err := d.queryContext(ctx, query, since)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s daily row counts: %w", t.table, err)
+		}
+		for rows.Next() {
+			var day DailyRowCount
+			if err := rows.Scan(&day.Day, &day.Rows); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan %s daily row count: %w", t.table, err)
+			}
+			table.Days = append(table.Days, day)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s daily row counts: %w", t.table, err)
+		}
+		stats = append(stats, table)
+	}
+	return stats, nil
 }
 
-func (d *Database) GetLatestPredictions(ctx context.Context) ([]Prediction, error) {
+// GetLatestAvailabilityMap returns the most recent availability row for each
+// station, keyed by station ID. Only rows within the recent window are read
+// (see TimingConfig.RecentAvailabilityWindow), so a station that has not
+// reported within it is absent rather than scanning the whole table.
+func (d *Database) GetLatestAvailabilityMap(ctx context.Context) (map[string]StationAvailability, error) {
 	query := `
 		SELECT DISTINCT ON (station_id)
-			id, station_id, predicted_availability_class, availability_prediction,
-			prediction_time, horizon_hours, created_at
-		FROM predictions
-		ORDER BY station_id, created_at DESC`
+			id, station_id, system_id, num_bikes_available, num_docks_available,
+			is_installed, is_renting, is_returning, last_reported, recorded_at
+		FROM station_availability
+		WHERE recorded_at > NOW() - $1 * INTERVAL '1 second'
+		ORDER BY station_id, recorded_at DESC`
 
-	rows, err := d.db.QueryContext(ctx, query)
+	rows, err := d.queryContext(ctx, query, d.recentWindow.Seconds())
 	if err != nil {
-		return nil, fmt.Errorf("failed to query predictions: %w", err)
+		return nil, fmt.Errorf("failed to query latest availability: %w", err)
 	}
 	defer rows.Close()
 
-	var predictions []Prediction
+	latest := make(map[string]StationAvailability)
 	for rows.Next() {
-		var p Prediction
-		err := rows.Scan(&p.ID, &p.StationID, &p.PredictedAvailabilityClass,
-			&p.AvailabilityPrediction, &p.PredictionTime, &p.HorizonHours, &p.CreatedAt)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var record StationAvailability
+		err := rows.Scan(
+			&record.ID, &record.StationID, &record.SystemID, &record.NumBikesAvailable,
+			&record.NumDocksAvailable, &record.IsInstalled, &record.IsRenting,
+			&record.IsReturning, &record.LastReported, &record.RecordedAt,
+		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan prediction: %w", err)
+			return nil, fmt.Errorf("failed to scan latest availability: %w", err)
 		}
-		predictions = append(predictions, p)
+		latest[record.StationID] = record
 	}
-	return predictions, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read latest availability: %w", err)
+	}
+	return latest, nil
 }
 
-func (d *Database) HealthCheck(ctx context.Context) error {
-	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
-	defer cancel()
+func (d *Database) GetAvailabilityNear(ctx context.Context, t time.Time, maxGap time.Duration) (map[string]StationAvailability, error) {
+	query := `
+		SELECT DISTINCT ON (station_id)
+			id, station_id, system_id, num_bikes_available, num_docks_available,
+			is_installed, is_renting, is_returning, last_reported, recorded_at
+		FROM station_availability
+		WHERE recorded_at BETWEEN $1::timestamptz - $2 * INTERVAL '1 second' AND $1::timestamptz + $2 * INTERVAL '1 second'
+		ORDER BY station_id, ABS(EXTRACT(EPOCH FROM (recorded_at - $1::timestamptz)))`
+
+	rows, err := d.queryContext(ctx, query, t, maxGap.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query availability near %s: %w", t.Format(time.RFC3339), err)
+	}
+	defer rows.Close()
 
-	return d.db.PingContext(ctx)
+	nearest := make(map[string]StationAvailability)
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var record StationAvailability
+		err := rows.Scan(
+			&record.ID, &record.StationID, &record.SystemID, &record.NumBikesAvailable,
+			&record.NumDocksAvailable, &record.IsInstalled, &record.IsRenting,
+			&record.IsReturning, &record.LastReported, &record.RecordedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan availability: %w", err)
+		}
+		nearest[record.StationID] = record
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read availability: %w", err)
+	}
+	return nearest, nil
 }
 
-func (d *Database) ExecMigration(ctx context.Context, sql string) error {
-	_, err := d.db.ExecContext(ctx, sql)
-	return err
+// GetAvailabilityForStationsBetween returns every availability record of the
+// given stations recorded between from and to inclusive, ordered by station
+// and then recording time, capped at HISTORY_MAX_ROWS; the bool reports that
+// it was capped.
+func (d *Database) GetAvailabilityForStationsBetween(ctx context.Context, stationIDs []string, from, to time.Time) ([]StationAvailability, bool, error) {
+	query := `
+		SELECT id, station_id, system_id, num_bikes_available, num_docks_available,
+		       is_installed, is_renting, is_returning, last_reported, recorded_at
+		FROM station_availability
+		WHERE station_id = ANY($1) AND recorded_at BETWEEN $2 AND $3
+		ORDER BY station_id, recorded_at` + d.historyLimit()
+
+	rows, err := d.queryContext(ctx, query, pq.Array(stationIDs), from, to)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to query availability history: %w", err)
+	}
+	defer rows.Close()
+
+	var records []StationAvailability
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, false, err
+		}
+		var record StationAvailability
+		err := rows.Scan(
+			&record.ID, &record.StationID, &record.SystemID, &record.NumBikesAvailable,
+			&record.NumDocksAvailable, &record.IsInstalled, &record.IsRenting,
+			&record.IsReturning, &record.LastReported, &record.RecordedAt,
+		)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to scan availability: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to read availability: %w", err)
+	}
+	records, truncated := d.truncateHistory("availability history", records)
+	return records, truncated, nil
+}
+
+// ImportAvailabilities inserts historical availability rows in one
+// transaction, silently skipping rows for stations not in the stations table.
+// Rows keep their recorded_at, falling back to last_reported, and inherit the
+// station's system_id when none is given. It returns the number inserted.
+func (d *Database) ImportAvailabilities(ctx context.Context, availabilities []StationAvailability) (int, error) {
+	if len(availabilities) == 0 {
+		return 0, nil
+	}
+
+	query := `
+		INSERT INTO station_availability
+		(station_id, system_id, num_bikes_available, num_docks_available, is_installed, is_renting, is_returning, last_reported, recorded_at)
+		SELECT s.station_id, COALESCE(NULLIF($2::text, ''), s.system_id), $3, $4, $5, $6, $7, $8::bigint,
+			COALESCE($9::timestamptz, to_timestamp($8::bigint))
+		FROM stations s
+		WHERE s.station_id = $1`
+
+	inserted := 0
+	err := d.withTransaction(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, a := range availabilities {
+			var recordedAt *time.Time
+			if !a.RecordedAt.IsZero() {
+				recordedAt = &a.RecordedAt
+			}
+			result, err := stmt.ExecContext(ctx, a.StationID, a.SystemID, a.NumBikesAvailable, a.NumDocksAvailable,
+				a.IsInstalled, a.IsRenting, a.IsReturning, a.LastReported, recordedAt)
+			if err != nil {
+				return fmt.Errorf("import availability %s: %w", a.StationID, err)
+			}
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("import availability %s: %w", a.StationID, err)
+			}
+			inserted += int(affected)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return inserted, nil
+}
+
+// GetStationStaleness returns each station's last_reported from its newest
+// availability row, found per station through the (station_id, recorded_at)
+// index rather than aggregating the whole table, oldest first.
+func (d *Database) GetStationStaleness(ctx context.Context) ([]StationStaleness, error) {
+	query := `
+		SELECT s.station_id, s.system_id, s.name, a.last_reported,
+			EXTRACT(EPOCH FROM CURRENT_TIMESTAMP)::bigint - a.last_reported AS age_seconds
+		FROM stations s
+		JOIN LATERAL (
+			SELECT last_reported
+			FROM station_availability
+			WHERE station_id = s.station_id
+			ORDER BY recorded_at DESC
+			LIMIT 1
+		) a ON true
+		ORDER BY age_seconds DESC, s.station_id`
+
+	rows, err := d.queryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query station staleness: %w", err)
+	}
+	defer rows.Close()
+
+	var staleness []StationStaleness
+	for rows.Next() {
+		var st StationStaleness
+		if err := rows.Scan(&st.StationID, &st.SystemID, &st.Name, &st.LastReported, &st.AgeSeconds); err != nil {
+			return nil, fmt.Errorf("failed to scan station staleness: %w", err)
+		}
+		staleness = append(staleness, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read station staleness: %w", err)
+	}
+	return staleness, nil
+}
+
+// latestAvailabilityCTE selects each station's most recent availability row.
+const latestAvailabilityCTE = `
+	WITH latest AS (
+		SELECT DISTINCT ON (station_id) station_id, num_bikes_available, num_docks_available
+		FROM station_availability
+		ORDER BY station_id, recorded_at DESC
+	)`
+
+func (d *Database) GetStationStats(ctx context.Context) (StationStats, error) {
+	query := latestAvailabilityCTE + `
+		SELECT COUNT(*), COALESCE(SUM(l.num_bikes_available), 0),
+			COALESCE(SUM(l.num_docks_available), 0), COALESCE(SUM(s.capacity), 0)
+		FROM latest l
+		JOIN stations s ON s.station_id = l.station_id`
+
+	var stats StationStats
+	err := d.queryRow(ctx, query, nil, &stats.StationCount, &stats.TotalBikes, &stats.TotalDocks, &stats.TotalCapacity)
+	if err != nil {
+		return StationStats{}, fmt.Errorf("failed to query station stats: %w", err)
+	}
+	return stats, nil
+}
+
+func (d *Database) GetUtilizationCounts(ctx context.Context, buckets int) (map[int]int, error) {
+	// width_bucket puts a ratio of exactly 1 (or an over-full station) in
+	// bucket buckets+1, so it is folded into the top bucket.
+	query := latestAvailabilityCTE + `
+		SELECT LEAST(width_bucket(l.num_bikes_available::float8 / s.capacity, 0, 1, $1), $1) AS bucket, COUNT(*)
+		FROM latest l
+		JOIN stations s ON s.station_id = l.station_id
+		WHERE s.capacity > 0
+		GROUP BY bucket
+		ORDER BY bucket`
+
+	rows, err := d.queryContext(ctx, query, buckets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query utilization histogram: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int, buckets)
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan utilization bucket: %w", err)
+		}
+		counts[bucket] = count
+	}
+	return counts, rows.Err()
+}
+
+func (d *Database) InsertAnomalies(ctx context.Context, anomalies []AvailabilityAnomaly) error {
+	if len(anomalies) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO availability_anomalies
+		(station_id, system_id, bikes_before, bikes_after, docks_before, docks_after, change_pct, kind, detail)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	return d.withTransaction(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, a := range anomalies {
+			if _, err := stmt.ExecContext(ctx, a.StationID, a.SystemID, a.BikesBefore, a.BikesAfter,
+				a.DocksBefore, a.DocksAfter, a.ChangePct, a.Kind, a.Detail); err != nil {
+				return fmt.Errorf("insert anomaly for station %s: %w", a.StationID, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (d *Database) GetAnomaliesSince(ctx context.Context, since time.Time) ([]AvailabilityAnomaly, error) {
+	query := `
+		SELECT id, kind, station_id, system_id, bikes_before, bikes_after, docks_before, docks_after,
+			change_pct, detail, detected_at
+		FROM availability_anomalies
+		WHERE detected_at > $1
+		ORDER BY detected_at DESC`
+
+	rows, err := d.queryContext(ctx, query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query anomalies: %w", err)
+	}
+	defer rows.Close()
+
+	var anomalies []AvailabilityAnomaly
+	for rows.Next() {
+		var a AvailabilityAnomaly
+		err := rows.Scan(&a.ID, &a.Kind, &a.StationID, &a.SystemID, &a.BikesBefore, &a.BikesAfter,
+			&a.DocksBefore, &a.DocksAfter, &a.ChangePct, &a.Detail, &a.DetectedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan anomaly: %w", err)
+		}
+		anomalies = append(anomalies, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read anomalies: %w", err)
+	}
+	return anomalies, nil
+}
+
+func (d *Database) GetFeatureFlag(ctx context.Context, name string) (FeatureFlag, error) {
+	query := `SELECT name, enabled, version, updated_at FROM feature_flags WHERE name = $1`
+
+	var flag FeatureFlag
+	err := d.queryRow(ctx, query, []interface{}{name}, &flag.Name, &flag.Enabled, &flag.Version, &flag.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return FeatureFlag{}, ErrFeatureFlagNotFound
+	}
+	if err != nil {
+		return FeatureFlag{}, fmt.Errorf("failed to query feature flag %s: %w", name, err)
+	}
+	return flag, nil
+}
+
+func (d *Database) GetFeatureFlags(ctx context.Context) ([]FeatureFlag, error) {
+	query := `SELECT name, enabled, version, updated_at FROM feature_flags ORDER BY name`
+
+	rows, err := d.queryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feature flags: %w", err)
+	}
+	defer rows.Close()
+
+	var flags []FeatureFlag
+	for rows.Next() {
+		var flag FeatureFlag
+		if err := rows.Scan(&flag.Name, &flag.Enabled, &flag.Version, &flag.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan feature flag: %w", err)
+		}
+		flags = append(flags, flag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read feature flags: %w", err)
+	}
+	return flags, nil
+}
+
+// SetFeatureFlag writes a flag only if its stored version still equals
+// expectedVersion (0 meaning "not stored yet"), bumping the version.
+// Otherwise it returns ErrFeatureFlagVersionConflict.
+func (d *Database) SetFeatureFlag(ctx context.Context, name string, enabled bool, expectedVersion int) (FeatureFlag, error) {
+	query := `
+		UPDATE feature_flags
+		SET enabled = $2, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE name = $1 AND version = $3
+		RETURNING name, enabled, version, updated_at`
+	args := []interface{}{name, enabled, expectedVersion}
+	if expectedVersion == 0 {
+		query = `
+			INSERT INTO feature_flags (name, enabled, version, updated_at)
+			VALUES ($1, $2, 1, CURRENT_TIMESTAMP)
+			ON CONFLICT (name) DO NOTHING
+			RETURNING name, enabled, version, updated_at`
+		args = args[:2]
+	}
+
+	var flag FeatureFlag
+	err := d.db.QueryRowContext(ctx, query, args...).Scan(&flag.Name, &flag.Enabled, &flag.Version, &flag.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return FeatureFlag{}, ErrFeatureFlagVersionConflict
+	}
+	if err != nil {
+		return FeatureFlag{}, fmt.Errorf("failed to set feature flag %s: %w", name, err)
+	}
+	return flag, nil
+}
+
+const webhookColumns = `id, station_id, field, operator, threshold, target_url, secret, enabled,
+	condition_met, consecutive_failures, last_triggered_at, created_at`
+
+func scanWebhook(row interface{ Scan(...interface{}) error }) (StationWebhook, error) {
+	var w StationWebhook
+	var lastTriggered sql.NullTime
+	err := row.Scan(&w.ID, &w.StationID, &w.Field, &w.Operator, &w.Threshold, &w.TargetURL, &w.Secret,
+		&w.Enabled, &w.ConditionMet, &w.ConsecutiveFailures, &lastTriggered, &w.CreatedAt)
+	if lastTriggered.Valid {
+		w.LastTriggeredAt = &lastTriggered.Time
+	}
+	return w, err
+}
+
+func (d *Database) GetWebhooks(ctx context.Context) ([]StationWebhook, error) {
+	rows, err := d.queryContext(ctx, `SELECT `+webhookColumns+` FROM station_webhooks ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []StationWebhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+func (d *Database) CreateWebhook(ctx context.Context, webhook StationWebhook) (StationWebhook, error) {
+	query := `
+		INSERT INTO station_webhooks (station_id, field, operator, threshold, target_url, secret)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + webhookColumns
+
+	created, err := scanWebhook(d.db.QueryRowContext(ctx, query, webhook.StationID, webhook.Field,
+		webhook.Operator, webhook.Threshold, webhook.TargetURL, webhook.Secret))
+	if err != nil {
+		return StationWebhook{}, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return created, nil
+}
+
+func (d *Database) DeleteWebhook(ctx context.Context, id int) error {
+	result, err := d.db.ExecContext(ctx, `DELETE FROM station_webhooks WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook %d: %w", id, err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to count deleted webhooks: %w", err)
+	}
+	if deleted == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (d *Database) EnableWebhook(ctx context.Context, id int) (StationWebhook, error) {
+	query := `
+		UPDATE station_webhooks SET enabled = TRUE, consecutive_failures = 0
+		WHERE id = $1
+		RETURNING ` + webhookColumns
+
+	webhook, err := scanWebhook(d.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return StationWebhook{}, ErrWebhookNotFound
+	}
+	if err != nil {
+		return StationWebhook{}, fmt.Errorf("failed to enable webhook %d: %w", id, err)
+	}
+	return webhook, nil
+}
+
+// SetWebhookConditionMet stores whether the webhook's condition holds,
+// stamping last_triggered_at when it starts to. It reports whether this call
+// changed the stored value: when replicas evaluate the same refresh, only
+// the one whose update wins sees true.
+func (d *Database) SetWebhookConditionMet(ctx context.Context, id int, met bool) (bool, error) {
+	query := `
+		UPDATE station_webhooks
+		SET condition_met = $2,
+			last_triggered_at = CASE WHEN $2 THEN CURRENT_TIMESTAMP ELSE last_triggered_at END
+		WHERE id = $1 AND condition_met IS DISTINCT FROM $2`
+
+	result, err := d.db.ExecContext(ctx, query, id, met)
+	if err != nil {
+		return false, fmt.Errorf("failed to update webhook %d condition: %w", id, err)
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to count webhook %d condition update: %w", id, err)
+	}
+	return changed > 0, nil
+}
+
+func (d *Database) RecordWebhookDelivery(ctx context.Context, id int, delivered bool, disableAfter int) (bool, error) {
+	query := `
+		UPDATE station_webhooks
+		SET consecutive_failures = CASE WHEN $2 THEN 0 ELSE consecutive_failures + 1 END,
+			enabled = enabled AND ($2 OR $3 <= 0 OR consecutive_failures + 1 < $3)
+		WHERE id = $1
+		RETURNING enabled`
+
+	var enabled bool
+	err := d.db.QueryRowContext(ctx, query, id, delivered, disableAfter).Scan(&enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Deleted while the delivery was in flight.
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record webhook %d delivery: %w", id, err)
+	}
+	return !delivered && !enabled, nil
+}
+
+func (d *Database) withTransaction(ctx context.Context, fn func(*sql.Tx) error) error {
+    tx, err := d.beginTx(ctx)
+    if err != nil {
+        return fmt.Errorf("begin transaction: %w", err)
+    }
+    
+    defer func() {
+        if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+            log.Printf("Error rolling back transaction: %v", err)
+        }
+    }()
+
+    if err := fn(tx); err != nil {
+        return err
+    }
+
+    return commitTx(tx)
+}
+
+// InsertPredictions stores predictions in transactions of at most
+// predictionBatchSize rows, so locks are held briefly and a failing batch
+// does not roll back earlier ones. It returns the number of rows committed.
+func (d *Database) InsertPredictions(ctx context.Context, predictions []Prediction) (_ int, err error) {
+    ctx, span := startDBSpan(ctx, "InsertPredictions", attribute.Int("db.rows", len(predictions)))
+    defer func() { endSpan(span, err) }()
+
+    inserted := 0
+    for _, batch := range chunkPredictions(predictions, d.predictionBatchSize) {
+        err := d.withTransaction(ctx, func(tx *sql.Tx) error {
+            stmt, err := tx.PrepareContext(ctx, queryInsertPrediction)
+            if err != nil {
+                return fmt.Errorf("prepare statement: %w", err)
+            }
+            defer stmt.Close()
+
+            for _, pred := range batch {
+                if _, err := stmt.ExecContext(ctx, pred.StationID, pred.PredictedAvailabilityClass,
+                    pred.AvailabilityPrediction, pred.PredictionTime, pred.HorizonHours, pred.Confidence, pred.ModelVersion); err != nil {
+                    return fmt.Errorf("insert prediction for station %s: %w", pred.StationID, err)
+                }
+            }
+            return nil
+        })
+        if err != nil {
+            return inserted, fmt.Errorf("after %d of %d predictions: %w", inserted, len(predictions), err)
+        }
+        inserted += len(batch)
+    }
+    return inserted, nil
+}
+
+// chunkPredictions splits predictions into consecutive batches of at most
+// size elements. A non-positive size yields a single batch.
+func chunkPredictions(predictions []Prediction, size int) [][]Prediction {
+    if len(predictions) == 0 {
+        return nil
+    }
+    if size <= 0 || size >= len(predictions) {
+        return [][]Prediction{predictions}
+    }
+
+    batches := make([][]Prediction, 0, (len(predictions)+size-1)/size)
+    for start := 0; start < len(predictions); start += size {
+        end := min(start+size, len(predictions))
+        batches = append(batches, predictions[start:end])
+    }
+    return batches
+}
+
+// GetLatestPredictions returns the most recently confirmed prediction for
+// each station and model version; callers pick a version with selectModel.
+func (d *Database) GetLatestPredictions(ctx context.Context) (_ []Prediction, err error) {
+	ctx, span := startDBSpan(ctx, "GetLatestPredictions")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+		SELECT DISTINCT ON (station_id, model_version)
+			id, station_id, predicted_availability_class, availability_prediction,
+			prediction_time, horizon_hours, created_at, last_confirmed_at, confidence, model_version
+		FROM predictions
+		ORDER BY station_id, model_version, last_confirmed_at DESC, created_at DESC`
+
+	return d.queryPredictions(ctx, query)
+}
+
+// RecordPredictionBatches stores the per-run batch summaries built by
+// summarizePredictionBatches. A summary already recorded for the same run is
+// left as it is.
+func (d *Database) RecordPredictionBatches(ctx context.Context, batches []PredictionBatch) error {
+	var (
+		runs     []string
+		horizons []int64
+		classes  []string
+		counts   []int64
+	)
+	for _, b := range batches {
+		for class, count := range b.Classes {
+			runs = append(runs, b.CreatedAt.UTC().Format(time.RFC3339Nano))
+			horizons = append(horizons, int64(b.HorizonHours))
+			classes = append(classes, class)
+			counts = append(counts, int64(count))
+		}
+	}
+	if len(runs) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO prediction_batches (run_at, horizon_hours, availability_prediction, prediction_count)
+		SELECT * FROM unnest($1::timestamptz[], $2::int[], $3::varchar[], $4::int[])
+		ON CONFLICT DO NOTHING`
+
+	if _, err := d.db.ExecContext(ctx, query, pq.Array(runs), pq.Array(horizons), pq.Array(classes), pq.Array(counts)); err != nil {
+		return fmt.Errorf("failed to record prediction batches: %w", err)
+	}
+	return nil
+}
+
+// GetPredictionBatches returns the recorded per-run batch summaries, which
+// count the predictions each inference run confirmed unchanged as well as
+// those it inserted.
+func (d *Database) GetPredictionBatches(ctx context.Context, limit int) ([]PredictionBatch, error) {
+	query := `
+		WITH batches AS (
+			SELECT DISTINCT run_at, horizon_hours
+			FROM prediction_batches
+			ORDER BY run_at DESC, horizon_hours
+			LIMIT $1
+		)
+		SELECT b.run_at, b.horizon_hours, p.availability_prediction, p.prediction_count
+		FROM batches b
+		JOIN prediction_batches p ON p.run_at = b.run_at AND p.horizon_hours = b.horizon_hours
+		ORDER BY b.run_at DESC, b.horizon_hours, p.availability_prediction`
+
+	rows, err := d.queryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prediction batches: %w", err)
+	}
+	defer rows.Close()
+
+	var batches []PredictionBatch
+	for rows.Next() {
+		var (
+			createdAt time.Time
+			horizon   int
+			class     string
+			count     int
+		)
+		if err := rows.Scan(&createdAt, &horizon, &class, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan prediction batch: %w", err)
+		}
+		// Rows arrive grouped by batch, so a new batch starts whenever the key changes.
+		if n := len(batches); n == 0 || !batches[n-1].CreatedAt.Equal(createdAt) || batches[n-1].HorizonHours != horizon {
+			batches = append(batches, PredictionBatch{CreatedAt: createdAt, HorizonHours: horizon, Classes: map[string]int{}})
+		}
+		batch := &batches[len(batches)-1]
+		batch.Count += count
+		batch.Classes[class] = count
+	}
+	return batches, rows.Err()
+}
+
+func (d *Database) GetPredictionDistribution(ctx context.Context, from, to time.Time, bucket time.Duration, horizon int) ([]PredictionClassBucket, error) {
+	query := `
+		SELECT
+			to_timestamp(floor(extract(epoch FROM created_at) / $3) * $3) AS bucket_start,
+			availability_prediction,
+			COUNT(*)
+		FROM predictions
+		WHERE created_at >= $1 AND created_at < $2 AND ($4 = 0 OR horizon_hours = $4)
+		GROUP BY bucket_start, availability_prediction
+		ORDER BY bucket_start ASC, availability_prediction`
+
+	rows, err := d.queryContext(ctx, query, from, to, int64(bucket.Seconds()), horizon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prediction distribution: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []PredictionClassBucket
+	for rows.Next() {
+		var (
+			bucketStart time.Time
+			class       string
+			count       int
+		)
+		if err := rows.Scan(&bucketStart, &class, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan prediction distribution: %w", err)
+		}
+		if n := len(buckets); n == 0 || !buckets[n-1].BucketStart.Equal(bucketStart) {
+			buckets = append(buckets, PredictionClassBucket{BucketStart: bucketStart, Classes: map[string]int{}})
+		}
+		b := &buckets[len(buckets)-1]
+		b.Count += count
+		b.Classes[class] = count
+	}
+	return buckets, rows.Err()
+}
+
+func (d *Database) GetPredictionFreshness(ctx context.Context) ([]HorizonFreshness, error) {
+	query := `
+		SELECT horizon_hours, MAX(created_at), MAX(last_confirmed_at), MAX(prediction_time), COUNT(*)
+		FROM predictions
+		GROUP BY horizon_hours
+		ORDER BY horizon_hours`
+
+	rows, err := d.queryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prediction freshness: %w", err)
+	}
+	defer rows.Close()
+
+	var freshness []HorizonFreshness
+	for rows.Next() {
+		var f HorizonFreshness
+		if err := rows.Scan(&f.HorizonHours, &f.NewestCreatedAt, &f.NewestConfirmedAt, &f.NewestPredictionTime, &f.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan prediction freshness: %w", err)
+		}
+		freshness = append(freshness, f)
+	}
+	return freshness, rows.Err()
+}
+
+// GetLatestPredictionsByHorizon returns the most recently confirmed
+// prediction for each station, model version and horizon, the baseline new
+// inference runs are compared against.
+func (d *Database) GetLatestPredictionsByHorizon(ctx context.Context) ([]Prediction, error) {
+	query := `
+		SELECT DISTINCT ON (station_id, model_version, horizon_hours)
+			id, station_id, predicted_availability_class, availability_prediction,
+			prediction_time, horizon_hours, created_at, last_confirmed_at, confidence, model_version
+		FROM predictions
+		ORDER BY station_id, model_version, horizon_hours, last_confirmed_at DESC, created_at DESC`
+
+	return d.queryPredictions(ctx, query)
+}
+
+// GetLatestPredictionsWithLocation returns the most recently confirmed
+// prediction for horizon of each located station and model version. It
+// treats 0,0 as missing, see locatedStation.
+func (d *Database) GetLatestPredictionsWithLocation(ctx context.Context, horizon int) ([]PredictionWithLocation, error) {
+	query := `
+		SELECT
+			p.id, p.station_id, p.predicted_availability_class, p.availability_prediction,
+			p.prediction_time, p.horizon_hours, p.created_at, p.last_confirmed_at, p.confidence, p.model_version,
+			s.name, s.lat, s.lon
+		FROM (
+			SELECT DISTINCT ON (station_id, model_version) *
+			FROM predictions
+			WHERE horizon_hours = $1
+			ORDER BY station_id, model_version, last_confirmed_at DESC, created_at DESC
+		) p
+		JOIN stations s ON s.station_id = p.station_id
+		WHERE s.lat IS NOT NULL AND s.lon IS NOT NULL AND ` + locatedStation + `
+		ORDER BY p.station_id`
+
+	rows, err := d.queryContext(ctx, query, horizon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query predictions with location: %w", err)
+	}
+	defer rows.Close()
+
+	var predictions []PredictionWithLocation
+	for rows.Next() {
+		var p PredictionWithLocation
+		err := rows.Scan(&p.ID, &p.StationID, &p.PredictedAvailabilityClass,
+			&p.AvailabilityPrediction, &p.PredictionTime, &p.HorizonHours, &p.CreatedAt, &p.LastConfirmedAt, &p.Confidence, &p.ModelVersion,
+			&p.Name, &p.Lat, &p.Lon)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan prediction with location: %w", err)
+		}
+		d.roundCoordinates(&p.Lat, &p.Lon)
+		predictions = append(predictions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read predictions with location: %w", err)
+	}
+	return predictions, nil
+}
+
+// GetStationPredictions returns the most recently confirmed prediction for
+// each horizon and model version of a single station, shortest horizon
+// first.
+func (d *Database) GetStationPredictions(ctx context.Context, stationID string) ([]Prediction, error) {
+	query := `
+		SELECT DISTINCT ON (horizon_hours, model_version)
+			id, station_id, predicted_availability_class, availability_prediction,
+			prediction_time, horizon_hours, created_at, last_confirmed_at, confidence, model_version
+		FROM predictions
+		WHERE station_id = $1
+		ORDER BY horizon_hours, model_version, last_confirmed_at DESC, created_at DESC`
+
+	return d.queryPredictions(ctx, query, stationID)
+}
+
+func (d *Database) GetPredictionHistory(ctx context.Context, stationID string, limit int) ([]Prediction, error) {
+	query := `
+		SELECT
+			id, station_id, predicted_availability_class, availability_prediction,
+			prediction_time, horizon_hours, created_at, last_confirmed_at, confidence, model_version
+		FROM (
+			SELECT *, ROW_NUMBER() OVER (
+				PARTITION BY station_id, model_version, horizon_hours ORDER BY created_at DESC
+			) AS rn
+			FROM predictions
+			WHERE $1 = '' OR station_id = $1
+		) ranked
+		WHERE rn <= $2
+		ORDER BY station_id, horizon_hours, created_at DESC`
+
+	return d.queryPredictions(ctx, query, stationID, limit)
+}
+
+func (d *Database) queryPredictions(ctx context.Context, query string, args ...interface{}) ([]Prediction, error) {
+	rows, err := d.queryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query predictions: %w", err)
+	}
+	defer rows.Close()
+
+	var predictions []Prediction
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var p Prediction
+		err := rows.Scan(&p.ID, &p.StationID, &p.PredictedAvailabilityClass,
+			&p.AvailabilityPrediction, &p.PredictionTime, &p.HorizonHours, &p.CreatedAt, &p.LastConfirmedAt, &p.Confidence, &p.ModelVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan prediction: %w", err)
+		}
+		predictions = append(predictions, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read predictions: %w", err)
+	}
+	return predictions, nil
+}
+
+// ConfirmPredictions marks stored predictions that a newer inference run
+// reproduced unchanged: each prediction's ID names the stored row, which
+// takes the new run's prediction_time and bumps last_confirmed_at. The row
+// then stands for the newest run that produced it, so smoothing, history
+// and accuracy read it as that run's prediction.
+func (d *Database) ConfirmPredictions(ctx context.Context, predictions []Prediction) (_ int, err error) {
+	if len(predictions) == 0 {
+		return 0, nil
+	}
+	ctx, span := startDBSpan(ctx, "ConfirmPredictions", attribute.Int("db.rows", len(predictions)))
+	defer func() { endSpan(span, err) }()
+
+	ids := make([]int64, len(predictions))
+	times := make([]string, len(predictions))
+	for i, p := range predictions {
+		ids[i] = int64(p.ID)
+		times[i] = p.PredictionTime.UTC().Format(time.RFC3339Nano)
+	}
+
+	query := `
+		UPDATE predictions p
+		SET last_confirmed_at = CURRENT_TIMESTAMP, prediction_time = c.prediction_time
+		FROM unnest($1::bigint[], $2::timestamptz[]) AS c(id, prediction_time)
+		WHERE p.id = c.id`
+
+	result, err := d.db.ExecContext(ctx, query, pq.Array(ids), pq.Array(times))
+	if err != nil {
+		return 0, fmt.Errorf("failed to confirm predictions: %w", err)
+	}
+	confirmed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count confirmed predictions: %w", err)
+	}
+	return int(confirmed), nil
+}
+
+// GetUnevaluatedPredictions returns up to limit predictions whose
+// prediction_time falls in [from, to] and that have no prediction_accuracy
+// row yet, grouped by station.
+func (d *Database) GetUnevaluatedPredictions(ctx context.Context, from, to time.Time, limit int) ([]Prediction, error) {
+	query := `
+		SELECT
+			p.id, p.station_id, p.predicted_availability_class, p.availability_prediction,
+			p.prediction_time, p.horizon_hours, p.created_at, p.last_confirmed_at, p.confidence, p.model_version
+		FROM predictions p
+		LEFT JOIN prediction_accuracy a ON a.prediction_id = p.id
+		WHERE a.prediction_id IS NULL AND p.prediction_time BETWEEN $1 AND $2
+		ORDER BY p.station_id, p.prediction_time
+		LIMIT $3`
+
+	return d.queryPredictions(ctx, query, from, to, limit)
+}
+
+// GetActualAvailability returns the station's availability record closest to
+// t, within maxGap, along with its capacity.
+func (d *Database) GetActualAvailability(ctx context.Context, stationID string, t time.Time, maxGap time.Duration) (ActualAvailability, error) {
+	query := `
+		SELECT sa.num_bikes_available, s.capacity, sa.recorded_at
+		FROM station_availability sa
+		JOIN stations s ON s.station_id = sa.station_id
+		WHERE sa.station_id = $1
+			AND sa.recorded_at BETWEEN $2::timestamptz - $3 * INTERVAL '1 second' AND $2::timestamptz + $3 * INTERVAL '1 second'
+		ORDER BY ABS(EXTRACT(EPOCH FROM (sa.recorded_at - $2::timestamptz)))
+		LIMIT 1`
+
+	var actual ActualAvailability
+	err := d.queryRow(ctx, query, []interface{}{stationID, t, maxGap.Seconds()},
+		&actual.NumBikesAvailable, &actual.Capacity, &actual.RecordedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ActualAvailability{}, ErrNoActualAvailability
+	}
+	if err != nil {
+		return ActualAvailability{}, fmt.Errorf("failed to query actual availability for %s: %w", stationID, err)
+	}
+	return actual, nil
+}
+
+func (d *Database) InsertPredictionAccuracy(ctx context.Context, results []PredictionAccuracy) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO prediction_accuracy
+		(prediction_id, station_id, horizon_hours, predicted_class, actual_class, correct, prediction_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (prediction_id) DO NOTHING`
+
+	return d.withTransaction(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, r := range results {
+			if _, err := stmt.ExecContext(ctx, r.PredictionID, r.StationID, r.HorizonHours,
+				r.PredictedClass, r.ActualClass, r.Correct, r.PredictionTime); err != nil {
+				return fmt.Errorf("insert accuracy for prediction %d: %w", r.PredictionID, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (d *Database) ReplaceFreeBikes(ctx context.Context, systemID string, bikes []FreeBike) error {
+	query := `
+		INSERT INTO free_bikes (system_id, bike_id, lat, lon, is_reserved, is_disabled, vehicle_type_id, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (system_id, bike_id)
+		DO UPDATE SET
+			lat = EXCLUDED.lat,
+			lon = EXCLUDED.lon,
+			is_reserved = EXCLUDED.is_reserved,
+			is_disabled = EXCLUDED.is_disabled,
+			vehicle_type_id = EXCLUDED.vehicle_type_id,
+			last_seen_at = EXCLUDED.last_seen_at`
+
+	seenAt := time.Now()
+
+	return d.withTransaction(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, bike := range bikes {
+			if _, err := stmt.ExecContext(ctx, systemID, bike.BikeID, bike.Lat, bike.Lon,
+				bike.IsReserved, bike.IsDisabled, bike.VehicleTypeID, seenAt); err != nil {
+				return fmt.Errorf("upsert free bike %s: %w", bike.BikeID, err)
+			}
+		}
+
+		// Bikes missing from the latest feed have been docked or removed.
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM free_bikes WHERE system_id = $1 AND last_seen_at < $2`, systemID, seenAt); err != nil {
+			return fmt.Errorf("prune stale free bikes: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetStationNeighbors pairs stations within radiusM meters of each other. As
+// in GetFreeBikesNear, a bounding box prefilter limits the haversine
+// calculation to nearby pairs.
+func (d *Database) GetStationNeighbors(ctx context.Context, radiusM float64) ([]StationNeighbors, error) {
+	query := `
+		SELECT a.station_id, a.name, b.station_id
+		FROM stations a
+		JOIN stations b ON b.station_id <> a.station_id
+			AND b.lat BETWEEN a.lat - $2 AND a.lat + $2
+			AND b.lon BETWEEN a.lon - $2 / GREATEST(cos(radians(a.lat)), 0.01)
+				AND a.lon + $2 / GREATEST(cos(radians(a.lat)), 0.01)
+		WHERE NOT (a.lat = 0 AND a.lon = 0)
+			AND 6371000 * 2 * asin(sqrt(
+			power(sin(radians(b.lat - a.lat) / 2), 2) +
+			cos(radians(a.lat)) * cos(radians(b.lat)) * power(sin(radians(b.lon - a.lon) / 2), 2)
+		)) <= $1
+		ORDER BY a.station_id, b.station_id`
+
+	rows, err := d.queryContext(ctx, query, radiusM, radiusM/metersPerDegreeLat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query station neighbors: %w", err)
+	}
+	defer rows.Close()
+
+	var neighbors []StationNeighbors
+	for rows.Next() {
+		var stationID, name, neighborID string
+		if err := rows.Scan(&stationID, &name, &neighborID); err != nil {
+			return nil, fmt.Errorf("failed to scan station neighbor: %w", err)
+		}
+		if n := len(neighbors); n == 0 || neighbors[n-1].StationID != stationID {
+			neighbors = append(neighbors, StationNeighbors{StationID: stationID, Name: name})
+		}
+		last := &neighbors[len(neighbors)-1]
+		last.NeighborIDs = append(last.NeighborIDs, neighborID)
+	}
+	return neighbors, rows.Err()
+}
+
+// GetFreeBikesNear returns free bikes within radiusM meters of (lat, lon),
+// nearest first. A bounding box prefilter keeps the haversine calculation to
+// nearby rows.
+func (d *Database) GetFreeBikesNear(ctx context.Context, lat, lon, radiusM float64) ([]FreeBike, error) {
+	query := `
+		SELECT bike_id, system_id, lat, lon, is_reserved, is_disabled, vehicle_type_id, last_seen_at, distance_m
+		FROM (
+			SELECT *,
+				6371000 * 2 * asin(sqrt(
+					power(sin(radians(lat - $1) / 2), 2) +
+					cos(radians($1)) * cos(radians(lat)) * power(sin(radians(lon - $2) / 2), 2)
+				)) AS distance_m
+			FROM free_bikes
+			WHERE lat BETWEEN $1 - $4 AND $1 + $4
+			  AND lon BETWEEN $2 - $5 AND $2 + $5
+		) nearby
+		WHERE distance_m <= $3
+		ORDER BY distance_m ASC`
+
+	latDelta := radiusM / metersPerDegreeLat
+	lonDelta := radiusM / (metersPerDegreeLat * math.Max(math.Cos(lat*math.Pi/180), 0.01))
+
+	rows, err := d.queryContext(ctx, query, lat, lon, radiusM, latDelta, lonDelta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query free bikes: %w", err)
+	}
+	defer rows.Close()
+
+	var bikes []FreeBike
+	for rows.Next() {
+		var bike FreeBike
+		err := rows.Scan(&bike.BikeID, &bike.SystemID, &bike.Lat, &bike.Lon, &bike.IsReserved,
+			&bike.IsDisabled, &bike.VehicleTypeID, &bike.LastSeenAt, &bike.DistanceM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan free bike: %w", err)
+		}
+		d.roundCoordinates(&bike.Lat, &bike.Lon)
+		bikes = append(bikes, bike)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read free bikes: %w", err)
+	}
+	return bikes, nil
+}
+
+func (d *Database) HealthCheck(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	if err := d.db.PingContext(ctx); err != nil {
+		return err
+	}
+	if d.read != d.db {
+		if err := d.read.PingContext(ctx); err != nil {
+			return fmt.Errorf("read database: %w", err)
+		}
+	}
+	return nil
+}
+
+func (d *Database) ExecMigration(ctx context.Context, sql string) error {
+	_, err := d.db.ExecContext(ctx, sql)
+	return err
+}
+
+// schema_migrations is created on demand rather than by a migration so that
+// it can record the migrations that run before it would exist.
+const queryCreateSchemaMigrations = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+
+// RecordMigration marks a migration version as applied.
+func (d *Database) RecordMigration(ctx context.Context, version int) error {
+	if _, err := d.db.ExecContext(ctx, queryCreateSchemaMigrations); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	query := `INSERT INTO schema_migrations (version) VALUES ($1) ON CONFLICT (version) DO NOTHING`
+	if _, err := d.db.ExecContext(ctx, query, version); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", version, err)
+	}
+	return nil
+}
+
+// AppliedMigrationVersion returns the highest recorded migration version, or
+// 0 when none has been recorded.
+func (d *Database) AppliedMigrationVersion(ctx context.Context) (int, error) {
+	if _, err := d.db.ExecContext(ctx, queryCreateSchemaMigrations); err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	var version int
+	if err := d.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to query applied migrations: %w", err)
+	}
+	return version, nil
 }
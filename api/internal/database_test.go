@@ -1,11 +1,92 @@
 package internal
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// fakeRowsConnector is a database/sql driver whose every query returns rows,
+// calling onRow after each one is read and failing with failErr once
+// failAfter rows have been read (never when failAfter is negative).
+type fakeRowsConnector struct {
+	rows      [][]driver.Value
+	failAfter int
+	failErr   error
+	onRow     func(i int)
+}
+
+func (c *fakeRowsConnector) Connect(context.Context) (driver.Conn, error) {
+	return fakeRowsConn{c}, nil
+}
+
+func (c *fakeRowsConnector) Driver() driver.Driver { return nil }
+
+type fakeRowsConn struct{ c *fakeRowsConnector }
+
+func (fakeRowsConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (fakeRowsConn) Close() error                        { return nil }
+func (fakeRowsConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (conn fakeRowsConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &fakeRows{c: conn.c}, nil
+}
+
+type fakeRows struct {
+	c *fakeRowsConnector
+	i int
+}
+
+func (r *fakeRows) Columns() []string {
+	columns := make([]string, len(r.c.rows[0]))
+	for i := range columns {
+		columns[i] = fmt.Sprintf("col%d", i)
+	}
+	return columns
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i == r.c.failAfter {
+		return r.c.failErr
+	}
+	if r.i >= len(r.c.rows) {
+		return io.EOF
+	}
+	copy(dest, r.c.rows[r.i])
+	r.i++
+	if r.c.onRow != nil {
+		r.c.onRow(r.i)
+	}
+	return nil
+}
+
+// newFakeRowsDatabase returns a Database backed by connector for reads and
+// writes alike.
+func newFakeRowsDatabase(connector *fakeRowsConnector) *Database {
+	db := sql.OpenDB(connector)
+	return &Database{db: db, read: db, coordinatePrecision: defaultCoordinatePrecision}
+}
+
+// availabilityRows returns n station_availability rows in the column order
+// the availability queries select.
+func availabilityRows(n int) [][]driver.Value {
+	rows := make([][]driver.Value, n)
+	for i := range rows {
+		rows[i] = []driver.Value{int64(i + 1), fmt.Sprintf("station-%d", i), DefaultSystemID,
+			int64(5), int64(10), int64(1), int64(1), int64(1), int64(1700000000), time.Now()}
+	}
+	return rows
+}
+
 func TestStation_Validate(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -64,6 +145,26 @@ func TestStation_Validate(t *testing.T) {
 	}
 }
 
+func TestValidateAll(t *testing.T) {
+	stations := []Station{
+		{StationID: "1", Name: "Valid", Capacity: 10},
+		{StationID: "2", Capacity: 10},
+		{Name: "No ID"},
+		{StationID: "4", Name: "Valid"},
+		{StationID: "5", Name: "Negative", Capacity: -1},
+	}
+
+	errs := ValidateAll(stations)
+
+	assert.Equal(t, []StationValidationError{
+		{Index: 1, StationID: "2", Message: "station name is required"},
+		{Index: 2, StationID: "", Message: "station ID is required"},
+		{Index: 4, StationID: "5", Message: "capacity cannot be negative"},
+	}, errs)
+	assert.EqualError(t, errs[2], `station "5" (record 4): capacity cannot be negative`)
+	assert.Nil(t, ValidateAll(stations[:1]))
+}
+
 func TestStationAvailability_Validate(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -115,3 +216,189 @@ func TestStationAvailability_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestChunkPredictions(t *testing.T) {
+	predictions := make([]Prediction, 7)
+	for i := range predictions {
+		predictions[i] = Prediction{ID: i}
+	}
+
+	tests := []struct {
+		name          string
+		predictions   []Prediction
+		size          int
+		expectedSizes []int
+	}{
+		{
+			name:          "even split with remainder",
+			predictions:   predictions,
+			size:          3,
+			expectedSizes: []int{3, 3, 1},
+		},
+		{
+			name:          "size larger than input",
+			predictions:   predictions,
+			size:          5000,
+			expectedSizes: []int{7},
+		},
+		{
+			name:          "non-positive size uses one batch",
+			predictions:   predictions,
+			size:          0,
+			expectedSizes: []int{7},
+		},
+		{
+			name:          "empty input",
+			predictions:   nil,
+			size:          3,
+			expectedSizes: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batches := chunkPredictions(tt.predictions, tt.size)
+
+			var sizes []int
+			next := 0
+			for _, batch := range batches {
+				sizes = append(sizes, len(batch))
+				for _, p := range batch {
+					assert.Equal(t, next, p.ID, "batches must preserve order")
+					next++
+				}
+			}
+			assert.Equal(t, tt.expectedSizes, sizes)
+		})
+	}
+}
+
+func TestDatabase_ScanLoopsStopOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	read := 0
+	database := newFakeRowsDatabase(&fakeRowsConnector{
+		rows:      availabilityRows(100),
+		failAfter: -1,
+		onRow: func(i int) {
+			read = i
+			if i == 2 {
+				cancel()
+			}
+		},
+	})
+	defer database.Close()
+
+	records, _, err := database.GetAvailabilitySince(ctx, time.Time{})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, records)
+	assert.Less(t, read, 100)
+}
+
+func TestDatabase_HistoryMaxRows(t *testing.T) {
+	tests := []struct {
+		name              string
+		maxRows           int
+		rows              int
+		expectedRecords   int
+		expectedTruncated bool
+	}{
+		{name: "under the cap", maxRows: 5, rows: 5, expectedRecords: 5},
+		{name: "over the cap", maxRows: 3, rows: 4, expectedRecords: 3, expectedTruncated: true},
+		{name: "uncapped", maxRows: 0, rows: 4, expectedRecords: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newFakeRowsDatabase(&fakeRowsConnector{rows: availabilityRows(tt.rows), failAfter: -1})
+			defer database.Close()
+			database.historyMaxRows = tt.maxRows
+
+			records, truncated, err := database.GetAvailabilitySince(context.Background(), time.Time{})
+			assert.NoError(t, err)
+			assert.Len(t, records, tt.expectedRecords)
+			assert.Equal(t, tt.expectedTruncated, truncated)
+
+			records, truncated, err = database.GetAvailabilityForStationsBetween(context.Background(), []string{"station-1"}, time.Time{}, time.Now())
+			assert.NoError(t, err)
+			assert.Len(t, records, tt.expectedRecords)
+			assert.Equal(t, tt.expectedTruncated, truncated)
+		})
+	}
+}
+
+func TestDatabase_ScanLoopsReportIterationErrors(t *testing.T) {
+	stationRows := make([][]driver.Value, 5)
+	predictionRows := make([][]driver.Value, 5)
+	for i := range stationRows {
+		id := fmt.Sprintf("station-%d", i)
+		stationRows[i] = []driver.Value{id, DefaultSystemID, "Station", 41.88, -87.63, int64(15), time.Now(),
+			int64(5), int64(10), int64(1), int64(1), int64(1), int64(1700000000), false}
+		predictionRows[i] = []driver.Value{int64(i + 1), id, int64(0), "high", time.Now(), int64(1), time.Now(), time.Now(), nil, "unknown"}
+	}
+
+	tests := []struct {
+		name  string
+		rows  [][]driver.Value
+		query func(context.Context, *Database) (int, error)
+	}{
+		{
+			name: "GetStationsWithAvailability",
+			rows: stationRows,
+			query: func(ctx context.Context, d *Database) (int, error) {
+				stations, err := d.GetStationsWithAvailability(ctx, "")
+				return len(stations), err
+			},
+		},
+		{
+			name: "GetRecentAvailability",
+			rows: availabilityRows(5),
+			query: func(ctx context.Context, d *Database) (int, error) {
+				records, err := d.GetRecentAvailability(ctx)
+				return len(records), err
+			},
+		},
+		{
+			name: "GetAvailabilitySince",
+			rows: availabilityRows(5),
+			query: func(ctx context.Context, d *Database) (int, error) {
+				records, _, err := d.GetAvailabilitySince(ctx, time.Time{})
+				return len(records), err
+			},
+		},
+		{
+			name: "GetAvailabilityForStationsBetween",
+			rows: availabilityRows(5),
+			query: func(ctx context.Context, d *Database) (int, error) {
+				records, _, err := d.GetAvailabilityForStationsBetween(ctx, []string{"station-1"}, time.Time{}, time.Now())
+				return len(records), err
+			},
+		},
+		{
+			name: "GetLatestPredictions",
+			rows: predictionRows,
+			query: func(ctx context.Context, d *Database) (int, error) {
+				predictions, err := d.GetLatestPredictions(ctx)
+				return len(predictions), err
+			},
+		},
+	}
+
+	streamErr := errors.New("server closed the connection unexpectedly")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			complete := newFakeRowsDatabase(&fakeRowsConnector{rows: tt.rows, failAfter: -1})
+			defer complete.Close()
+			n, err := tt.query(context.Background(), complete)
+			assert.NoError(t, err)
+			assert.Equal(t, len(tt.rows), n)
+
+			truncated := newFakeRowsDatabase(&fakeRowsConnector{rows: tt.rows, failAfter: 2, failErr: streamErr})
+			defer truncated.Close()
+			n, err = tt.query(context.Background(), truncated)
+			assert.ErrorIs(t, err, streamErr)
+			assert.Zero(t, n)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"log"
+	"syscall"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var dbReconnectRetries = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "db_reconnect_retries_total",
+	Help: "Database operations retried after a transient connection error.",
+})
+
+// transientConnCodes are server errors after which the connection is gone
+// and the statement did not take effect, so it can be re-run on another one.
+var transientConnCodes = map[pq.ErrorCode]bool{
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// isTransientConnError reports whether err means the connection was lost
+// rather than the statement failing. Constraint violations and other query
+// errors are never transient, and neither is a failed commit: see
+// commitError.
+func isTransientConnError(err error) bool {
+	var commitErr *commitError
+	if errors.As(err, &commitErr) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Class 08 is connection_exception.
+		return transientConnCodes[pqErr.Code] || pqErr.Code.Class() == "08"
+	}
+	return false
+}
+
+// commitError wraps an error from COMMIT. If the connection dropped while
+// committing, the server may have committed the transaction anyway, so
+// running the batch again could insert its rows twice.
+type commitError struct {
+	err error
+}
+
+func (e *commitError) Error() string { return "commit transaction: " + e.err.Error() }
+
+func (e *commitError) Unwrap() error { return e.err }
+
+// commitTx commits tx, marking a failure as a commitError so it is not
+// retried.
+func commitTx(tx *sql.Tx) error {
+	if err := tx.Commit(); err != nil {
+		return &commitError{err: err}
+	}
+	return nil
+}
+
+// withReconnectRetry runs fn and, if it failed with a transient connection
+// error, runs it once more. database/sql discards the broken connection, so
+// the retry gets a fresh one from the pool. Only use it for reads and other
+// statements that are safe to repeat.
+func withReconnectRetry(ctx context.Context, fn func() error) error {
+	err := fn()
+	if err == nil || !isTransientConnError(err) || ctx.Err() != nil {
+		return err
+	}
+	dbReconnectRetries.Inc()
+	log.Printf("Retrying database operation after connection error: %v", err)
+	return fn()
+}
+
+// queryContext is QueryContext on the read pool with a reconnect retry.
+func (d *Database) queryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := withReconnectRetry(ctx, func() error {
+		var err error
+		rows, err = d.read.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+// queryRow runs a single-row query on the read pool and scans it into dest,
+// with a reconnect retry. It returns sql.ErrNoRows like Row.Scan.
+func (d *Database) queryRow(ctx context.Context, query string, args []interface{}, dest ...interface{}) error {
+	return withReconnectRetry(ctx, func() error {
+		return d.read.QueryRowContext(ctx, query, args...).Scan(dest...)
+	})
+}
+
+// beginTx is db.BeginTx with a reconnect retry; nothing has run on the
+// transaction yet, so starting over is always safe.
+func (d *Database) beginTx(ctx context.Context) (*sql.Tx, error) {
+	var tx *sql.Tx
+	err := withReconnectRetry(ctx, func() error {
+		var err error
+		tx, err = d.db.BeginTx(ctx, nil)
+		return err
+	})
+	return tx, err
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTransientConnError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{name: "admin shutdown", err: &pq.Error{Code: "57P01"}, transient: true},
+		{name: "connection failure", err: &pq.Error{Code: "08006"}, transient: true},
+		{name: "bad conn", err: driver.ErrBadConn, transient: true},
+		{name: "connection refused", err: fmt.Errorf("dial: %w", syscall.ECONNREFUSED), transient: true},
+		{name: "failed commit", err: &commitError{err: driver.ErrBadConn}},
+		{name: "unique violation", err: &pq.Error{Code: "23505"}},
+		{name: "syntax error", err: &pq.Error{Code: "42601"}},
+		{name: "other error", err: errors.New("boom")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.transient, isTransientConnError(tt.err))
+		})
+	}
+}
+
+func TestWithReconnectRetry(t *testing.T) {
+	t.Run("retries once after a transient error", func(t *testing.T) {
+		before := testutil.ToFloat64(dbReconnectRetries)
+		calls := 0
+		err := withReconnectRetry(context.Background(), func() error {
+			calls++
+			if calls == 1 {
+				return &pq.Error{Code: "57P01"}
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, before+1, testutil.ToFloat64(dbReconnectRetries))
+	})
+
+	t.Run("gives up after the second failure", func(t *testing.T) {
+		calls := 0
+		err := withReconnectRetry(context.Background(), func() error {
+			calls++
+			return driver.ErrBadConn
+		})
+		assert.ErrorIs(t, err, driver.ErrBadConn)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("does not retry query errors", func(t *testing.T) {
+		calls := 0
+		err := withReconnectRetry(context.Background(), func() error {
+			calls++
+			return &pq.Error{Code: "23505"}
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+}
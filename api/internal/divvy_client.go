@@ -1,35 +1,101 @@
 package internal
 
 import (
+	"compress/flate"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/sync/errgroup"
 )
 
 type DivvyClient struct {
-	stationInfoURL   string
-	stationStatusURL string
-	httpClient       *http.Client
+	httpClient *http.Client
+	// fetchSlots bounds concurrent feed requests; nil means unlimited.
+	fetchSlots chan struct{}
+
+	// minExpectedStations guards against feed shape changes; see
+	// checkStationCount. Non-positive disables the check.
+	minExpectedStations int
+	countsMu            sync.Mutex
+	lastStationCounts   map[string]int
+
+	// feedUpdated holds each system's latest station_status last_updated.
+	feedUpdatedMu sync.Mutex
+	feedUpdated   map[string]time.Time
+
+	// allowStaleFeed enables falling back to lastGood when a fetch fails;
+	// see FetchStationData.
+	allowStaleFeed bool
+	lastGoodMu     sync.Mutex
+	lastGood       map[string]*lastGoodFeeds
+}
+
+// lastGoodFeeds is a system's most recent successfully fetched feed data.
+type lastGoodFeeds struct {
+	stations   []DivvyStation
+	stationsAt time.Time
+	statuses   []DivvyStationStatus
+	statusesAt time.Time
 }
 
 func NewDivvyClient(cfg *Config) *DivvyClient {
-	return &DivvyClient{
-		stationInfoURL:   cfg.Divvy.StationInfoURL,
-		stationStatusURL: cfg.Divvy.StationStatusURL,
-		httpClient:       &http.Client{Timeout: 30 * time.Second},
+	client := &DivvyClient{
+		httpClient:          newHTTPClient(cfg, 30*time.Second),
+		minExpectedStations: cfg.Divvy.MinExpectedStations,
+		lastStationCounts:   map[string]int{},
+		feedUpdated:         map[string]time.Time{},
+		allowStaleFeed:      cfg.Divvy.AllowStaleFeed,
+		lastGood:            map[string]*lastGoodFeeds{},
+	}
+	if limit := cfg.Divvy.MaxConcurrentFetches; limit > 0 {
+		client.fetchSlots = make(chan struct{}, limit)
 	}
+	return client
 }
 
-func (c *DivvyClient) fetchJSON(ctx context.Context, url string, target interface{}) error {
+// acquireFetchSlot blocks until a feed request may start, returning a release
+// func, or fails if ctx is done first.
+func (c *DivvyClient) acquireFetchSlot(ctx context.Context) (func(), error) {
+	if c.fetchSlots == nil {
+		return func() {}, nil
+	}
+	select {
+	case c.fetchSlots <- struct{}{}:
+		return func() { <-c.fetchSlots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for fetch slot: %w", ctx.Err())
+	}
+}
+
+func (c *DivvyClient) fetchJSON(ctx context.Context, url string, target interface{}) (err error) {
+    ctx, span := startSpan(ctx, "divvy.fetch_json", attribute.String("url.full", url))
+    defer func() { endSpan(span, err) }()
+
+    release, err := c.acquireFetchSlot(ctx)
+    if err != nil {
+        return err
+    }
+    defer release()
+
     req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
     if err != nil {
         return fmt.Errorf("create request: %w", err)
     }
+    // Setting this explicitly turns off the transport's transparent
+    // decompression, so gzip bodies are unwrapped below.
+    req.Header.Set("Accept-Encoding", "gzip")
 
     resp, err := c.httpClient.Do(req)
     if err != nil {
@@ -41,31 +107,395 @@ func (c *DivvyClient) fetchJSON(ctx context.Context, url string, target interfac
         return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
     }
 
-    if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+    body := io.Reader(resp.Body)
+    if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+        gz, err := gzip.NewReader(resp.Body)
+        if err != nil {
+            return fmt.Errorf("decompress gzip: %w", err)
+        }
+        defer gz.Close()
+        body = &gzipErrorReader{gz}
+    }
+
+    if err := json.NewDecoder(body).Decode(target); err != nil {
+        var gzErr *gzipError
+        if errors.As(err, &gzErr) {
+            return fmt.Errorf("decompress gzip: %w", gzErr.err)
+        }
         return fmt.Errorf("decode JSON: %w", err)
     }
 
     return nil
 }
 
-func (c *DivvyClient) FetchStationData(ctx context.Context) ([]DivvyStation, []DivvyStationStatus, error) {
+// gzipError marks a malformed gzip stream so it is reported as a
+// decompression error rather than a JSON error.
+type gzipError struct {
+    err error
+}
+
+func (e *gzipError) Error() string { return e.err.Error() }
+func (e *gzipError) Unwrap() error { return e.err }
+
+type gzipErrorReader struct {
+    r io.Reader
+}
+
+// Read marks only gzip format errors; failures reading the response body
+// itself, such as a reset connection, pass through unchanged.
+func (g *gzipErrorReader) Read(p []byte) (int, error) {
+    n, err := g.r.Read(p)
+    if isGzipFormatError(err) {
+        err = &gzipError{err}
+    }
+    return n, err
+}
+
+func isGzipFormatError(err error) bool {
+    var corrupt flate.CorruptInputError
+    return errors.Is(err, gzip.ErrHeader) || errors.Is(err, gzip.ErrChecksum) || errors.As(err, &corrupt)
+}
+
+const (
+    feedStationInformation = "station_information"
+    feedStationStatus      = "station_status"
+)
+
+// FeedTimeoutError reports which GBFS feeds had not completed when a fetch
+// timed out, along with how long every feed took or had been running.
+type FeedTimeoutError struct {
+    SystemID  string
+    Pending   []string
+    Durations map[string]time.Duration
+    Err       error
+}
+
+func (e *FeedTimeoutError) Error() string {
+    pending := make([]string, len(e.Pending))
+    for i, feed := range e.Pending {
+        pending[i] = fmt.Sprintf("%s (after %v)", feed, e.Durations[feed].Round(time.Millisecond))
+    }
+    return fmt.Sprintf("timed out fetching %s feeds, still pending: %s: %v",
+        e.SystemID, strings.Join(pending, ", "), e.Err)
+}
+
+func (e *FeedTimeoutError) Unwrap() error { return e.Err }
+
+// feedTimer records per-feed fetch durations from concurrent goroutines.
+type feedTimer struct {
+    mu        sync.Mutex
+    start     time.Time
+    durations map[string]time.Duration
+}
+
+func newFeedTimer() *feedTimer {
+    return &feedTimer{start: time.Now(), durations: make(map[string]time.Duration)}
+}
+
+func (t *feedTimer) done(feed string) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    t.durations[feed] = time.Since(t.start)
+}
+
+// pending returns the feeds that never completed, recording how long they ran.
+func (t *feedTimer) pending(feeds ...string) ([]string, map[string]time.Duration) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    var pending []string
+    durations := make(map[string]time.Duration, len(feeds))
+    for _, feed := range feeds {
+        if d, ok := t.durations[feed]; ok {
+            durations[feed] = d
+            continue
+        }
+        pending = append(pending, feed)
+        durations[feed] = time.Since(t.start)
+    }
+    return pending, durations
+}
+
+// StaleFeedError reports that a fetch failed and the system's last good
+// data was returned for Feeds instead. The data returned alongside it is
+// complete; Err is the underlying fetch failure.
+type StaleFeedError struct {
+    SystemID string
+    Feeds    []string
+    Ages     map[string]time.Duration
+    Err      error
+}
+
+func (e *StaleFeedError) Error() string {
+    stale := make([]string, len(e.Feeds))
+    for i, feed := range e.Feeds {
+        stale[i] = fmt.Sprintf("%s (%v old)", feed, e.Ages[feed].Round(time.Second))
+    }
+    return fmt.Sprintf("using last good %s data for %s: %v",
+        e.SystemID, strings.Join(stale, ", "), e.Err)
+}
+
+func (e *StaleFeedError) Unwrap() error { return e.Err }
+
+// FetchStationData fetches station information and status concurrently. If
+// the fetch times out, the returned error is a *FeedTimeoutError naming the
+// feed(s) still outstanding, and whichever feed did complete is returned
+// alongside it so callers can decide whether to use partial data.
+//
+// With ALLOW_STALE_FEED, a failed feed is replaced by the system's last good
+// data when there is any: the returned error is then a *StaleFeedError and
+// both slices are set.
+func (c *DivvyClient) FetchStationData(ctx context.Context, system SystemConfig) ([]DivvyStation, []DivvyStationStatus, error) {
+    stations, statuses, err := c.fetchStationData(ctx, system)
+    if system.ID == "" {
+        return stations, statuses, err
+    }
+    if err == nil {
+        c.rememberGoodData(system.ID, stations, statuses)
+        return stations, statuses, nil
+    }
+    if !c.allowStaleFeed {
+        return stations, statuses, err
+    }
+    return c.fillFromLastGood(system.ID, stations, statuses, err)
+}
+
+// rememberGoodData records freshly fetched feeds; nil slices (feeds that did
+// not complete) leave the previous data in place.
+func (c *DivvyClient) rememberGoodData(systemID string, stations []DivvyStation, statuses []DivvyStationStatus) {
+    c.lastGoodMu.Lock()
+    defer c.lastGoodMu.Unlock()
+
+    good := c.lastGood[systemID]
+    if good == nil {
+        good = &lastGoodFeeds{}
+        c.lastGood[systemID] = good
+    }
+    now := time.Now()
+    if stations != nil {
+        good.stations, good.stationsAt = stations, now
+    }
+    if statuses != nil {
+        good.statuses, good.statusesAt = statuses, now
+    }
+}
+
+// fillFromLastGood replaces the feeds missing from a failed fetch with the
+// system's last good data. It returns the fetch error unchanged when a
+// missing feed has never been fetched successfully.
+func (c *DivvyClient) fillFromLastGood(systemID string, stations []DivvyStation, statuses []DivvyStationStatus, fetchErr error) ([]DivvyStation, []DivvyStationStatus, error) {
+    // Feeds that did complete before a timeout are fresh and still usable.
+    c.rememberGoodData(systemID, stations, statuses)
+
+    c.lastGoodMu.Lock()
+    defer c.lastGoodMu.Unlock()
+
+    good := c.lastGood[systemID]
+    if (stations == nil && good.stations == nil) || (statuses == nil && good.statuses == nil) {
+        return stations, statuses, fetchErr
+    }
+
+    staleErr := &StaleFeedError{SystemID: systemID, Ages: map[string]time.Duration{}, Err: fetchErr}
+    if stations == nil {
+        stations = good.stations
+        staleErr.Feeds = append(staleErr.Feeds, feedStationInformation)
+        staleErr.Ages[feedStationInformation] = time.Since(good.stationsAt)
+    }
+    if statuses == nil {
+        statuses = good.statuses
+        staleErr.Feeds = append(staleErr.Feeds, feedStationStatus)
+        staleErr.Ages[feedStationStatus] = time.Since(good.statusesAt)
+    }
+    return stations, statuses, staleErr
+}
+
+func (c *DivvyClient) fetchStationData(ctx context.Context, system SystemConfig) ([]DivvyStation, []DivvyStationStatus, error) {
     var stationInfo DivvyStationInfoResponse
     var stationStatus DivvyStationStatusResponse
 
-    g, ctx := errgroup.WithContext(ctx)
+    timer := newFeedTimer()
+    g, groupCtx := errgroup.WithContext(ctx)
 
     g.Go(func() error {
-        return c.fetchJSON(ctx, c.stationInfoURL, &stationInfo)
+        if err := c.fetchJSON(groupCtx, system.StationInfoURL, &stationInfo); err != nil {
+            return err
+        }
+        timer.done(feedStationInformation)
+        return nil
     })
 
     g.Go(func() error {
-        return c.fetchJSON(ctx, c.stationStatusURL, &stationStatus)
+        if err := c.fetchJSON(groupCtx, system.StationStatusURL, &stationStatus); err != nil {
+            return err
+        }
+        c.recordFeedUpdated(system.ID, stationStatus.LastUpdated)
+        timer.done(feedStationStatus)
+        return nil
     })
 
     if err := g.Wait(); err != nil {
-        return nil, nil, fmt.Errorf("failed to fetch station data: %w", err)
+        if !isTimeout(ctx, err) {
+            return nil, nil, fmt.Errorf("failed to fetch station data for %s: %w", system.ID, err)
+        }
+        pending, durations := timer.pending(feedStationInformation, feedStationStatus)
+        timeoutErr := &FeedTimeoutError{SystemID: system.ID, Pending: pending, Durations: durations, Err: err}
+        return completedStations(pending, stationInfo), completedStatuses(pending, stationStatus), timeoutErr
+    }
+
+    if err := c.checkStationCount(system.ID, len(stationInfo.Data.Stations), len(stationStatus.Data.Stations)); err != nil {
+        return nil, nil, err
     }
 
-    log.Printf("Fetched data for %d stations", len(stationInfo.Data.Stations))
+    _, durations := timer.pending(feedStationInformation, feedStationStatus)
+    log.Printf("Fetched data for %d %s stations (station_information %v, station_status %v)",
+        len(stationInfo.Data.Stations), system.ID,
+        durations[feedStationInformation].Round(time.Millisecond), durations[feedStationStatus].Round(time.Millisecond))
     return stationInfo.Data.Stations, stationStatus.Data.Stations, nil
 }
+
+// FetchStationStatus fetches only station_status. Like FetchStationData it
+// records the feed's last_updated, rejects a suspiciously short feed and,
+// with ALLOW_STALE_FEED, falls back to the last good statuses with a
+// *StaleFeedError.
+func (c *DivvyClient) FetchStationStatus(ctx context.Context, system SystemConfig) ([]DivvyStationStatus, error) {
+    var stationStatus DivvyStationStatusResponse
+    err := c.fetchJSON(ctx, system.StationStatusURL, &stationStatus)
+    if err == nil {
+        c.recordFeedUpdated(system.ID, stationStatus.LastUpdated)
+        statuses := stationStatus.Data.Stations
+        if err = c.checkStationCount(system.ID, len(statuses), len(statuses)); err != nil {
+            return nil, err
+        }
+        if system.ID != "" {
+            c.rememberGoodData(system.ID, nil, statuses)
+        }
+        log.Printf("Fetched status for %d %s stations", len(statuses), system.ID)
+        return statuses, nil
+    }
+
+    err = fmt.Errorf("failed to fetch station status for %s: %w", system.ID, err)
+    if !c.allowStaleFeed || system.ID == "" {
+        return nil, err
+    }
+    c.lastGoodMu.Lock()
+    defer c.lastGoodMu.Unlock()
+    good := c.lastGood[system.ID]
+    if good == nil || good.statuses == nil {
+        return nil, err
+    }
+    return good.statuses, &StaleFeedError{
+        SystemID: system.ID,
+        Feeds:    []string{feedStationStatus},
+        Ages:     map[string]time.Duration{feedStationStatus: time.Since(good.statusesAt)},
+        Err:      err,
+    }
+}
+
+// FeedShapeError reports a feed that decoded successfully but yielded far
+// fewer stations than the last good fetch, which usually means the GBFS
+// schema changed under us rather than that the stations disappeared.
+type FeedShapeError struct {
+    SystemID string
+    Stations int
+    Statuses int
+    Previous int
+    Minimum  int
+}
+
+func (e *FeedShapeError) Error() string {
+    return fmt.Sprintf("suspicious %s feed: %d stations and %d statuses (previously %d, expected at least %d); feed shape may have changed",
+        e.SystemID, e.Stations, e.Statuses, e.Previous, e.Minimum)
+}
+
+// checkStationCount rejects a fetch that returned fewer than
+// minExpectedStations stations or statuses when the previous good fetch for
+// the system met the minimum. Good fetches update the remembered count.
+// Ad hoc fetches without a system ID, such as feed validation, are not tracked.
+func (c *DivvyClient) checkStationCount(systemID string, stations, statuses int) error {
+    if c.minExpectedStations <= 0 || systemID == "" {
+        return nil
+    }
+
+    c.countsMu.Lock()
+    defer c.countsMu.Unlock()
+
+    previous := c.lastStationCounts[systemID]
+    if min(stations, statuses) < c.minExpectedStations && previous >= c.minExpectedStations {
+        feedShapeSuspicious.WithLabelValues(systemID).Inc()
+        err := &FeedShapeError{SystemID: systemID, Stations: stations, Statuses: statuses, Previous: previous, Minimum: c.minExpectedStations}
+        log.Printf("Error: %v", err)
+        return err
+    }
+    c.lastStationCounts[systemID] = stations
+    return nil
+}
+
+// FetchFeedLastUpdated fetches the station_status feed and returns its
+// last_updated, remembering it like a full fetch would.
+func (c *DivvyClient) FetchFeedLastUpdated(ctx context.Context, system SystemConfig) (time.Time, error) {
+    var status struct {
+        LastUpdated int64 `json:"last_updated"`
+    }
+    if err := c.fetchJSON(ctx, system.StationStatusURL, &status); err != nil {
+        return time.Time{}, fmt.Errorf("failed to fetch %s station_status: %w", system.ID, err)
+    }
+    if status.LastUpdated <= 0 {
+        return time.Time{}, fmt.Errorf("%s station_status has no last_updated", system.ID)
+    }
+    c.recordFeedUpdated(system.ID, status.LastUpdated)
+    return time.Unix(status.LastUpdated, 0).UTC(), nil
+}
+
+// LastFeedUpdated returns the last_updated of the most recent station_status
+// fetch for systemID, if any fetch has reported one.
+func (c *DivvyClient) LastFeedUpdated(systemID string) (time.Time, bool) {
+    c.feedUpdatedMu.Lock()
+    defer c.feedUpdatedMu.Unlock()
+    updated, ok := c.feedUpdated[systemID]
+    return updated, ok
+}
+
+// recordFeedUpdated remembers a station_status last_updated. Ad hoc fetches
+// without a system ID and feeds without the field are ignored.
+func (c *DivvyClient) recordFeedUpdated(systemID string, lastUpdated int64) {
+    if systemID == "" || lastUpdated <= 0 {
+        return
+    }
+    c.feedUpdatedMu.Lock()
+    defer c.feedUpdatedMu.Unlock()
+    c.feedUpdated[systemID] = time.Unix(lastUpdated, 0).UTC()
+}
+
+// isTimeout reports whether a fetch failed because the caller's deadline
+// passed or the HTTP client's own timeout fired.
+func isTimeout(ctx context.Context, err error) bool {
+    if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+        return true
+    }
+    var netErr net.Error
+    return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func completedStations(pending []string, info DivvyStationInfoResponse) []DivvyStation {
+    if slices.Contains(pending, feedStationInformation) {
+        return nil
+    }
+    return info.Data.Stations
+}
+
+func completedStatuses(pending []string, status DivvyStationStatusResponse) []DivvyStationStatus {
+    if slices.Contains(pending, feedStationStatus) {
+        return nil
+    }
+    return status.Data.Stations
+}
+
+func (c *DivvyClient) FetchFreeBikes(ctx context.Context, system SystemConfig) ([]DivvyFreeBike, error) {
+    var freeBikeStatus DivvyFreeBikeStatusResponse
+    if err := c.fetchJSON(ctx, system.FreeBikeStatusURL, &freeBikeStatus); err != nil {
+        return nil, fmt.Errorf("failed to fetch free bikes for %s: %w", system.ID, err)
+    }
+
+    log.Printf("Fetched %d %s free bikes", len(freeBikeStatus.Data.Bikes), system.ID)
+    return freeBikeStatus.Data.Bikes, nil
+}
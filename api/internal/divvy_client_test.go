@@ -0,0 +1,305 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDivvyClient_FetchStationData_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/info":
+			w.Write([]byte(`{"data": {"stations": [{"station_id": "1", "name": "Fast"}]}}`))
+		case "/status":
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := NewDivvyClient(NewTestConfig())
+	system := SystemConfig{ID: "divvy", StationInfoURL: server.URL + "/info", StationStatusURL: server.URL + "/status"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	stations, statuses, err := client.FetchStationData(ctx, system)
+
+	var timeoutErr *FeedTimeoutError
+	if assert.True(t, errors.As(err, &timeoutErr), "expected FeedTimeoutError, got %v", err) {
+		assert.Equal(t, []string{feedStationStatus}, timeoutErr.Pending)
+		assert.Contains(t, err.Error(), "station_status")
+	}
+	assert.Len(t, stations, 1)
+	assert.Nil(t, statuses)
+}
+
+func TestDivvyClient_FetchStationData_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := NewDivvyClient(NewTestConfig())
+	system := SystemConfig{ID: "divvy", StationInfoURL: server.URL + "/info", StationStatusURL: server.URL + "/status"}
+
+	_, _, err := client.FetchStationData(context.Background(), system)
+
+	var timeoutErr *FeedTimeoutError
+	assert.Error(t, err)
+	assert.False(t, errors.As(err, &timeoutErr))
+}
+
+func TestDivvyClient_FetchStationData_ConcurrencyLimit(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{"data": {"stations": []}}`))
+	}))
+	defer server.Close()
+
+	config := NewTestConfig()
+	config.Divvy.MaxConcurrentFetches = 1
+	client := NewDivvyClient(config)
+	system := SystemConfig{ID: "divvy", StationInfoURL: server.URL + "/info", StationStatusURL: server.URL + "/status"}
+
+	_, _, err := client.FetchStationData(context.Background(), system)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), maxInFlight.Load())
+}
+
+func TestDivvyClient_FetchJSON_Gzip(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(`{"data": {"stations": [{"station_id": "1", "name": "Zipped"}]}}`))
+	gz.Close()
+
+	tests := []struct {
+		name        string
+		body        []byte
+		errContains string
+	}{
+		{name: "gzip body", body: compressed.Bytes()},
+		{name: "corrupt gzip header", body: []byte("not gzip"), errContains: "decompress gzip"},
+		{name: "corrupt deflate data", body: append(slices.Clone(compressed.Bytes()[:10]), 0xff, 0xff, 0xff, 0xff), errContains: "decompress gzip"},
+		{name: "truncated gzip stream", body: compressed.Bytes()[:len(compressed.Bytes())/2], errContains: "unexpected EOF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+				w.Header().Set("Content-Encoding", "gzip")
+				w.Write(tt.body)
+			}))
+			defer server.Close()
+
+			var info DivvyStationInfoResponse
+			err := NewDivvyClient(NewTestConfig()).fetchJSON(context.Background(), server.URL, &info)
+
+			if tt.errContains != "" {
+				assert.ErrorContains(t, err, tt.errContains)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "Zipped", info.Data.Stations[0].Name)
+		})
+	}
+}
+
+func TestGzipErrorReader_PassesThroughReadErrors(t *testing.T) {
+	readErr := errors.New("connection reset by peer")
+	_, err := (&gzipErrorReader{iotest.ErrReader(readErr)}).Read(make([]byte, 1))
+	assert.Same(t, readErr, err)
+
+	_, err = (&gzipErrorReader{iotest.ErrReader(gzip.ErrChecksum)}).Read(make([]byte, 1))
+	var gzErr *gzipError
+	assert.ErrorAs(t, err, &gzErr)
+}
+
+func TestDivvyClient_FetchStationData_ShapeChange(t *testing.T) {
+	var empty atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if empty.Load() {
+			w.Write([]byte(`{"data": {"renamed": []}}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"stations": [{"station_id": "1"}, {"station_id": "2"}]}}`))
+	}))
+	defer server.Close()
+
+	cfg := NewTestConfig()
+	cfg.Divvy.MinExpectedStations = 2
+	client := NewDivvyClient(cfg)
+	system := SystemConfig{ID: "divvy", StationInfoURL: server.URL + "/info", StationStatusURL: server.URL + "/status"}
+
+	stations, _, err := client.FetchStationData(context.Background(), system)
+	assert.NoError(t, err)
+	assert.Len(t, stations, 2)
+
+	empty.Store(true)
+	stations, statuses, err := client.FetchStationData(context.Background(), system)
+	var shapeErr *FeedShapeError
+	if assert.True(t, errors.As(err, &shapeErr), "expected FeedShapeError, got %v", err) {
+		assert.Equal(t, 0, shapeErr.Stations)
+		assert.Equal(t, 2, shapeErr.Previous)
+	}
+	assert.Nil(t, stations)
+	assert.Nil(t, statuses)
+
+	// A fresh client has no history, so a small feed is accepted.
+	_, _, err = NewDivvyClient(cfg).FetchStationData(context.Background(), system)
+	assert.NoError(t, err)
+}
+
+func TestDivvyClient_FeedLastUpdated(t *testing.T) {
+	var lastUpdated atomic.Int64
+	lastUpdated.Store(1700000000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"last_updated": %d, "data": {"stations": [{"station_id": "1"}]}}`, lastUpdated.Load())
+	}))
+	defer server.Close()
+
+	client := NewDivvyClient(NewTestConfig())
+	system := SystemConfig{ID: "divvy", StationInfoURL: server.URL + "/info", StationStatusURL: server.URL + "/status"}
+
+	_, ok := client.LastFeedUpdated("divvy")
+	assert.False(t, ok)
+
+	_, _, err := client.FetchStationData(context.Background(), system)
+	assert.NoError(t, err)
+	updated, ok := client.LastFeedUpdated("divvy")
+	assert.True(t, ok)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), updated)
+
+	lastUpdated.Store(1700000060)
+	updated, err = client.FetchFeedLastUpdated(context.Background(), system)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Unix(1700000060, 0).UTC(), updated)
+	updated, _ = client.LastFeedUpdated("divvy")
+	assert.Equal(t, time.Unix(1700000060, 0).UTC(), updated)
+
+	lastUpdated.Store(0)
+	_, err = client.FetchFeedLastUpdated(context.Background(), system)
+	assert.ErrorContains(t, err, "no last_updated")
+}
+
+func TestDivvyClient_FetchStationData_StaleFallback(t *testing.T) {
+	var statusDown atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/status" && statusDown.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"data": {"stations": [{"station_id": "1", "num_bikes_available": 3}]}}`))
+	}))
+	defer server.Close()
+	system := SystemConfig{ID: "divvy", StationInfoURL: server.URL + "/info", StationStatusURL: server.URL + "/status"}
+
+	cfg := NewTestConfig()
+	cfg.Divvy.AllowStaleFeed = true
+	client := NewDivvyClient(cfg)
+
+	// Nothing to fall back to before the first good fetch.
+	statusDown.Store(true)
+	_, _, err := client.FetchStationData(context.Background(), system)
+	assert.ErrorContains(t, err, "HTTP 503")
+	var staleErr *StaleFeedError
+	assert.False(t, errors.As(err, &staleErr))
+
+	statusDown.Store(false)
+	_, _, err = client.FetchStationData(context.Background(), system)
+	assert.NoError(t, err)
+
+	statusDown.Store(true)
+	stations, statuses, err := client.FetchStationData(context.Background(), system)
+	if assert.True(t, errors.As(err, &staleErr), "expected StaleFeedError, got %v", err) {
+		assert.Equal(t, "divvy", staleErr.SystemID)
+		assert.Equal(t, []string{feedStationInformation, feedStationStatus}, staleErr.Feeds)
+		assert.ErrorContains(t, err, "HTTP 503")
+	}
+	assert.Len(t, stations, 1)
+	if assert.Len(t, statuses, 1) {
+		assert.Equal(t, 3, statuses[0].NumBikesAvailable)
+	}
+
+	// Without ALLOW_STALE_FEED the failure is returned as is.
+	cfg.Divvy.AllowStaleFeed = false
+	client = NewDivvyClient(cfg)
+	statusDown.Store(false)
+	_, _, err = client.FetchStationData(context.Background(), system)
+	assert.NoError(t, err)
+	statusDown.Store(true)
+	stations, statuses, err = client.FetchStationData(context.Background(), system)
+	assert.False(t, errors.As(err, &staleErr))
+	assert.Nil(t, stations)
+	assert.Nil(t, statuses)
+}
+
+func TestDivvyClient_FetchStationStatus(t *testing.T) {
+	var statusDown atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/info" {
+			t.Error("station_information should not be fetched")
+		}
+		if statusDown.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"last_updated": 1700000000, "data": {"stations": [{"station_id": "1", "num_bikes_available": 3}]}}`))
+	}))
+	defer server.Close()
+	system := SystemConfig{ID: "divvy", StationInfoURL: server.URL + "/info", StationStatusURL: server.URL + "/status"}
+
+	cfg := NewTestConfig()
+	cfg.Divvy.AllowStaleFeed = true
+	client := NewDivvyClient(cfg)
+
+	statusDown.Store(true)
+	_, err := client.FetchStationStatus(context.Background(), system)
+	assert.ErrorContains(t, err, "HTTP 503")
+
+	statusDown.Store(false)
+	statuses, err := client.FetchStationStatus(context.Background(), system)
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 1)
+	updated, ok := client.LastFeedUpdated("divvy")
+	assert.True(t, ok)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), updated)
+
+	statusDown.Store(true)
+	statuses, err = client.FetchStationStatus(context.Background(), system)
+	var staleErr *StaleFeedError
+	if assert.True(t, errors.As(err, &staleErr), "expected StaleFeedError, got %v", err) {
+		assert.Equal(t, []string{feedStationStatus}, staleErr.Feeds)
+	}
+	if assert.Len(t, statuses, 1) {
+		assert.Equal(t, 3, statuses[0].NumBikesAvailable)
+	}
+}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrFeedHostNotAllowed is returned for user-supplied feed URLs whose
+	// host is not in ALLOWED_FEED_HOSTS.
+	ErrFeedHostNotAllowed = errors.New("feed host not allowed")
+	// ErrPrivateAddress is returned when a user-supplied feed URL points, or
+	// resolves, to a private, loopback or link-local address.
+	ErrPrivateAddress = errors.New("feed URL resolves to a private address")
+)
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which
+// net.IP.IsPrivate does not cover.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// checkFeedURL guards against SSRF through user-supplied feed URLs: the URL
+// must be absolute http(s), its host must be in allowed, and a literal IP
+// host must not be private. Resolved addresses are checked again at dial
+// time (see withPublicOnlyDial) so DNS cannot be used to reach internal
+// services.
+func checkFeedURL(raw string, allowed []string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid feed URL %q: must be an absolute http(s) URL", raw)
+	}
+	host := strings.ToLower(u.Hostname())
+	if ip := net.ParseIP(host); ip != nil && isPrivateIP(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	if !slices.Contains(allowed, host) {
+		return fmt.Errorf("%w: %s is not in ALLOWED_FEED_HOSTS", ErrFeedHostNotAllowed, host)
+	}
+	return nil
+}
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+type publicOnlyDialKey struct{}
+
+// withPublicOnlyDial marks ctx so outbound requests made with it go through
+// publicOnlyTransport and refuse to connect to private addresses, whatever
+// the hostname resolved to.
+func withPublicOnlyDial(ctx context.Context) context.Context {
+	return context.WithValue(ctx, publicOnlyDialKey{}, true)
+}
+
+func isPublicOnly(ctx context.Context) bool {
+	publicOnly, _ := ctx.Value(publicOnlyDialKey{}).(bool)
+	return publicOnly
+}
+
+// checkPublicHost resolves host, which may be an IP literal, and fails with
+// ErrPrivateAddress if any of its addresses is private.
+func checkPublicHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s to check it is public: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if isPrivateIP(addr.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrPrivateAddress, host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// publicOnlyTransport checks each resolved address just before connecting.
+// It keeps its own connection pool, so a connection opened for a configured
+// (possibly internal) feed is never reused for a user-supplied URL. It is
+// only used for requests that connect directly, so it has no proxy; proxied
+// requests are checked by publicOnlyRoundTrip instead.
+var publicOnlyTransport = func() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+			}
+			return nil
+		},
+	}
+	transport.DialContext = dialer.DialContext
+	return transport
+}()
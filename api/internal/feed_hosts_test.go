@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckFeedURL(t *testing.T) {
+	allowed := []string{"gbfs.example.com", "10.0.0.5"}
+
+	tests := []struct {
+		name      string
+		raw       string
+		expectErr error
+	}{
+		{name: "allowed host", raw: "https://gbfs.example.com/station_status.json"},
+		{name: "host match is case-insensitive", raw: "https://GBFS.example.com:8443/feed.json"},
+		{name: "host not in allowlist", raw: "https://evil.example.com/feed.json", expectErr: ErrFeedHostNotAllowed},
+		{name: "private IP even if allowed", raw: "http://10.0.0.5/feed.json", expectErr: ErrPrivateAddress},
+		{name: "loopback", raw: "http://127.0.0.1:5432/", expectErr: ErrPrivateAddress},
+		{name: "link-local metadata", raw: "http://169.254.169.254/latest/meta-data", expectErr: ErrPrivateAddress},
+		{name: "IPv6 loopback", raw: "http://[::1]/", expectErr: ErrPrivateAddress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkFeedURL(tt.raw, allowed)
+			if tt.expectErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expectErr)
+			}
+		})
+	}
+
+	assert.Error(t, checkFeedURL("file:///etc/passwd", allowed))
+	assert.ErrorIs(t, checkFeedURL("https://gbfs.example.com/feed.json", nil), ErrFeedHostNotAllowed)
+}
+
+func TestGuardedDial(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := newHTTPClient(NewTestConfig(), 0)
+
+	// Configured feeds may live anywhere, including on private addresses.
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", server.URL, nil)
+	resp, err := client.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+
+	req, _ = http.NewRequestWithContext(withPublicOnlyDial(context.Background()), "GET", server.URL, nil)
+	_, err = client.Do(req)
+	assert.ErrorIs(t, err, ErrPrivateAddress)
+}
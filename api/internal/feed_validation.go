@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxListedMismatches caps the station IDs listed per mismatch kind so a
+// wholly incompatible feed does not produce an enormous response.
+const maxListedMismatches = 50
+
+// FeedMismatch lists station IDs present in one feed but not the other.
+type FeedMismatch struct {
+	Count      int      `json:"count"`
+	StationIDs []string `json:"station_ids"`
+}
+
+// FeedValidationResult describes how a candidate GBFS system's feeds decode
+// into our structs and whether their station IDs line up.
+type FeedValidationResult struct {
+	Valid             bool                `json:"valid"`
+	StationCount      int                 `json:"station_count"`
+	StatusCount       int                 `json:"status_count"`
+	SampleStation     *DivvyStation       `json:"sample_station"`
+	SampleStatus      *DivvyStationStatus `json:"sample_status"`
+	StatusWithoutInfo FeedMismatch        `json:"status_without_info"`
+	InfoWithoutStatus FeedMismatch        `json:"info_without_status"`
+}
+
+// validateFeeds compares decoded station_information and station_status
+// feeds. A feed set is valid when both are non-empty and every station ID
+// appears in both.
+func validateFeeds(stations []DivvyStation, statuses []DivvyStationStatus) FeedValidationResult {
+	result := FeedValidationResult{
+		StationCount:      len(stations),
+		StatusCount:       len(statuses),
+		StatusWithoutInfo: FeedMismatch{StationIDs: []string{}},
+		InfoWithoutStatus: FeedMismatch{StationIDs: []string{}},
+	}
+	if len(stations) > 0 {
+		result.SampleStation = &stations[0]
+	}
+	if len(statuses) > 0 {
+		result.SampleStatus = &statuses[0]
+	}
+
+	infoIDs := make(map[string]bool, len(stations))
+	for _, s := range stations {
+		infoIDs[s.StationID] = true
+	}
+	statusIDs := make(map[string]bool, len(statuses))
+	for _, s := range statuses {
+		statusIDs[s.StationID] = true
+		if !infoIDs[s.StationID] {
+			result.StatusWithoutInfo.add(s.StationID)
+		}
+	}
+	for _, s := range stations {
+		if !statusIDs[s.StationID] {
+			result.InfoWithoutStatus.add(s.StationID)
+		}
+	}
+	slices.Sort(result.StatusWithoutInfo.StationIDs)
+	slices.Sort(result.InfoWithoutStatus.StationIDs)
+
+	result.Valid = len(stations) > 0 && len(statuses) > 0 &&
+		result.StatusWithoutInfo.Count == 0 && result.InfoWithoutStatus.Count == 0
+	return result
+}
+
+func (m *FeedMismatch) add(stationID string) {
+	m.Count++
+	if len(m.StationIDs) < maxListedMismatches {
+		m.StationIDs = append(m.StationIDs, stationID)
+	}
+}
+
+// ValidateFeed fetches and decodes a candidate system's station_information
+// and station_status feeds and reports whether they are compatible, without
+// ingesting anything. Use it before adding a new system to GBFS_SYSTEMS. The
+// URLs' hosts must be in ALLOWED_FEED_HOSTS and may not be private addresses.
+func (h *HTTPHandlers) ValidateFeed(c *gin.Context) {
+	var body struct {
+		StationInfoURL   string `json:"station_info_url"`
+		StationStatusURL string `json:"station_status_url"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest,
+			"body must be {\"station_info_url\": string, \"station_status_url\": string}")
+		return
+	}
+	for _, raw := range []string{body.StationInfoURL, body.StationStatusURL} {
+		if err := checkFeedURL(raw, h.config.Divvy.AllowedFeedHosts); err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+	}
+
+	// An empty system ID keeps the fetch out of the per-system station count
+	// tracking and last good data used by the ingesting fetches.
+	system := SystemConfig{StationInfoURL: body.StationInfoURL, StationStatusURL: body.StationStatusURL}
+	stations, statuses, err := h.divvyClient.FetchStationData(withPublicOnlyDial(c.Request.Context()), system)
+	if errors.Is(err, ErrPrivateAddress) {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Feed validation fetch failed: %v", err)
+		respondError(c, http.StatusBadGateway, ErrCodeFeedFetchFailed, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, validateFeeds(stations, statuses))
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestValidateFeeds(t *testing.T) {
+	stations := []DivvyStation{{StationID: "a"}, {StationID: "b"}, {StationID: "c"}}
+	statuses := []DivvyStationStatus{{StationID: "c"}, {StationID: "a"}, {StationID: "z"}}
+
+	result := validateFeeds(stations, statuses)
+	assert.False(t, result.Valid)
+	assert.Equal(t, 3, result.StationCount)
+	assert.Equal(t, 3, result.StatusCount)
+	assert.Equal(t, "a", result.SampleStation.StationID)
+	assert.Equal(t, FeedMismatch{Count: 1, StationIDs: []string{"z"}}, result.StatusWithoutInfo)
+	assert.Equal(t, FeedMismatch{Count: 1, StationIDs: []string{"b"}}, result.InfoWithoutStatus)
+
+	assert.True(t, validateFeeds(stations[:1], statuses[1:2]).Valid)
+	assert.False(t, validateFeeds(nil, nil).Valid)
+}
+
+func TestHTTPHandlers_ValidateFeed(t *testing.T) {
+	const validBody = `{"station_info_url": "https://example.com/info.json", "station_status_url": "https://example.com/status.json"}`
+
+	tests := []struct {
+		name           string
+		body           string
+		allowedHosts   []string
+		fetchErr       error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{name: "compatible feeds", body: validBody, allowedHosts: []string{"example.com"}, expectedStatus: http.StatusOK},
+		{name: "host not allowed", body: validBody, allowedHosts: []string{"gbfs.example.org"}, expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+		{name: "empty allowlist", body: validBody, expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+		{
+			name:           "resolves to private address",
+			body:           validBody,
+			allowedHosts:   []string{"example.com"},
+			fetchErr:       fmt.Errorf("fetch: %w", ErrPrivateAddress),
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   ErrCodeBadRequest,
+		},
+		{name: "malformed body", body: `{`, expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+		{
+			name:           "non-http URL",
+			body:           `{"station_info_url": "file:///etc/passwd", "station_status_url": "https://example.com/status.json"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   ErrCodeBadRequest,
+		},
+		{name: "fetch fails", body: validBody, allowedHosts: []string{"example.com"}, fetchErr: assert.AnError, expectedStatus: http.StatusBadGateway, expectedCode: ErrCodeFeedFetchFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No database is wired in: validation must never touch it.
+			mockClient := new(MockDivvyClient)
+			config := NewTestConfig()
+			config.Divvy.AllowedFeedHosts = tt.allowedHosts
+			handlers := &HTTPHandlers{divvyClient: mockClient, config: config}
+			system := SystemConfig{StationInfoURL: "https://example.com/info.json", StationStatusURL: "https://example.com/status.json"}
+			mockClient.On("FetchStationData", mock.MatchedBy(func(ctx context.Context) bool {
+				return ctx.Value(publicOnlyDialKey{}) == true
+			}), system).Return([]DivvyStation{{StationID: "1"}}, []DivvyStationStatus{{StationID: "1"}}, tt.fetchErr).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.POST("/validate-feed", handlers.ValidateFeed)
+
+			req := httptest.NewRequest("POST", "/validate-feed", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedCode != "" {
+				var response ErrorResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, tt.expectedCode, response.Error.Code)
+				return
+			}
+
+			var result FeedValidationResult
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			assert.True(t, result.Valid)
+			assert.Equal(t, 1, result.StationCount)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Known feature flags. Flags without a stored row fall back to
+// defaultFeatureFlags.
+const (
+	FlagPredictedMode        = "predicted_mode"
+	FlagAnomalyDetection     = "anomaly_detection"
+	FlagPredictionEvaluation = "prediction_evaluation"
+)
+
+var defaultFeatureFlags = map[string]bool{
+	FlagPredictedMode:        true,
+	FlagAnomalyDetection:     true,
+	FlagPredictionEvaluation: true,
+}
+
+var (
+	// ErrFeatureFlagNotFound is returned for flags that have no stored row.
+	ErrFeatureFlagNotFound = errors.New("feature flag not found")
+	// ErrUnknownFeatureFlag is returned when setting a flag the server does
+	// not know about.
+	ErrUnknownFeatureFlag = errors.New("unknown feature flag")
+	// ErrFeatureFlagVersionConflict is returned when a flag was changed since
+	// the version the caller based its update on.
+	ErrFeatureFlagVersionConflict = errors.New("feature flag version conflict")
+)
+
+// FeatureFlag is a runtime toggle stored in the feature_flags table. Version
+// is bumped on every write and is 0 for flags still at their default.
+type FeatureFlag struct {
+	Name      string     `json:"name"`
+	Enabled   bool       `json:"enabled"`
+	Version   int        `json:"version"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// FeatureFlags is an in-memory view of the feature_flags table, refreshed
+// periodically so request paths never hit the database to check a flag. A
+// nil *FeatureFlags reports every flag at its default.
+type FeatureFlags struct {
+	repo FeatureFlagRepository
+
+	mu     sync.RWMutex
+	values map[string]bool
+}
+
+func NewFeatureFlags(repo FeatureFlagRepository) *FeatureFlags {
+	return &FeatureFlags{repo: repo, values: map[string]bool{}}
+}
+
+// Enabled reports the cached value of a flag, or its default when the flag
+// has not been stored.
+func (f *FeatureFlags) Enabled(name string) bool {
+	if f != nil {
+		f.mu.RLock()
+		enabled, ok := f.values[name]
+		f.mu.RUnlock()
+		if ok {
+			return enabled
+		}
+	}
+	return defaultFeatureFlags[name]
+}
+
+// Refresh reloads every stored flag into the cache.
+func (f *FeatureFlags) Refresh(ctx context.Context) error {
+	flags, err := f.repo.GetFeatureFlags(ctx)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]bool, len(flags))
+	for _, flag := range flags {
+		values[flag.Name] = flag.Enabled
+	}
+	f.mu.Lock()
+	f.values = values
+	f.mu.Unlock()
+	return nil
+}
+
+// Start refreshes the cache immediately and then every interval until ctx
+// is done. Refresh failures keep the previous values. A non-positive
+// interval only loads the flags once.
+func (f *FeatureFlags) Start(ctx context.Context, interval time.Duration) {
+	if err := f.Refresh(ctx); err != nil {
+		log.Printf("Failed to load feature flags, using defaults: %v", err)
+	}
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := f.Refresh(ctx); err != nil {
+					log.Printf("Failed to refresh feature flags: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// List returns every known flag, merging stored rows over the defaults.
+func (f *FeatureFlags) List(ctx context.Context) ([]FeatureFlag, error) {
+	stored, err := f.repo.GetFeatureFlags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]FeatureFlag, len(stored))
+	for _, flag := range stored {
+		byName[flag.Name] = flag
+	}
+
+	names := make([]string, 0, len(defaultFeatureFlags))
+	for name := range defaultFeatureFlags {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	flags := make([]FeatureFlag, 0, len(names))
+	for _, name := range names {
+		flag, ok := byName[name]
+		if !ok {
+			flag = FeatureFlag{Name: name, Enabled: defaultFeatureFlags[name]}
+		}
+		flags = append(flags, flag)
+	}
+	return flags, nil
+}
+
+// Set stores a known flag if it is still at expectedVersion and updates the
+// cache without waiting for the next refresh.
+func (f *FeatureFlags) Set(ctx context.Context, name string, enabled bool, expectedVersion int) (FeatureFlag, error) {
+	if _, ok := defaultFeatureFlags[name]; !ok {
+		return FeatureFlag{}, fmt.Errorf("%w %q", ErrUnknownFeatureFlag, name)
+	}
+	flag, err := f.repo.SetFeatureFlag(ctx, name, enabled, expectedVersion)
+	if err != nil {
+		return FeatureFlag{}, err
+	}
+	f.mu.Lock()
+	f.values[flag.Name] = flag.Enabled
+	f.mu.Unlock()
+	return flag, nil
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestFeatureFlags_Enabled(t *testing.T) {
+	var nilFlags *FeatureFlags
+	assert.True(t, nilFlags.Enabled(FlagPredictedMode))
+	assert.False(t, nilFlags.Enabled("unknown"))
+
+	mockDB := new(MockDatabase)
+	mockDB.On("GetFeatureFlags", mock.Anything).
+		Return([]FeatureFlag{{Name: FlagPredictedMode, Enabled: false}}, nil).Once()
+	flags := NewFeatureFlags(mockDB)
+
+	assert.True(t, flags.Enabled(FlagPredictedMode))
+	assert.NoError(t, flags.Refresh(context.Background()))
+	assert.False(t, flags.Enabled(FlagPredictedMode))
+	assert.True(t, flags.Enabled(FlagAnomalyDetection))
+
+	// A failed refresh keeps the previous values.
+	mockDB.On("GetFeatureFlags", mock.Anything).Return([]FeatureFlag(nil), errors.New("db down")).Once()
+	assert.Error(t, flags.Refresh(context.Background()))
+	assert.False(t, flags.Enabled(FlagPredictedMode))
+	mockDB.AssertExpectations(t)
+}
+
+func TestFeatureFlags_Set(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockDB.On("SetFeatureFlag", mock.Anything, FlagAnomalyDetection, false, 0).
+		Return(FeatureFlag{Name: FlagAnomalyDetection, Enabled: false, Version: 1}, nil)
+	mockDB.On("SetFeatureFlag", mock.Anything, FlagAnomalyDetection, true, 0).
+		Return(FeatureFlag{}, ErrFeatureFlagVersionConflict)
+	flags := NewFeatureFlags(mockDB)
+
+	_, err := flags.Set(context.Background(), FlagAnomalyDetection, false, 0)
+	assert.NoError(t, err)
+	assert.False(t, flags.Enabled(FlagAnomalyDetection))
+
+	// A stale version leaves the cached value alone.
+	_, err = flags.Set(context.Background(), FlagAnomalyDetection, true, 0)
+	assert.ErrorIs(t, err, ErrFeatureFlagVersionConflict)
+	assert.False(t, flags.Enabled(FlagAnomalyDetection))
+
+	_, err = flags.Set(context.Background(), "unknown", true, 0)
+	assert.ErrorIs(t, err, ErrUnknownFeatureFlag)
+	mockDB.AssertExpectations(t)
+}
+
+func TestFeatureFlags_List(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockDB.On("GetFeatureFlags", mock.Anything).
+		Return([]FeatureFlag{{Name: FlagPredictedMode, Enabled: false, Version: 2}}, nil)
+	flags := NewFeatureFlags(mockDB)
+
+	list, err := flags.List(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []FeatureFlag{
+		{Name: FlagAnomalyDetection, Enabled: true},
+		{Name: FlagPredictedMode, Enabled: false, Version: 2},
+		{Name: FlagPredictionEvaluation, Enabled: true},
+	}, list)
+}
@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// geoJSONFeatureCollection is an RFC 7946 FeatureCollection of points.
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string                      `json:"type"`
+	Geometry   geoJSONPoint                `json:"geometry"`
+	Properties predictionFeatureProperties `json:"properties"`
+}
+
+// geoJSONPoint's coordinates are longitude first.
+type geoJSONPoint struct {
+	Type        string        `json:"type"`
+	Coordinates [2]Coordinate `json:"coordinates"`
+}
+
+type predictionFeatureProperties struct {
+	StationID                  string    `json:"station_id"`
+	Name                       string    `json:"name"`
+	PredictedAvailabilityClass int       `json:"predicted_availability_class"`
+	AvailabilityPrediction     string    `json:"availability_prediction"`
+	HorizonHours               int       `json:"horizon_hours"`
+	PredictionTime             time.Time `json:"prediction_time"`
+	Confidence                 *float64  `json:"confidence"`
+}
+
+// predictionsFeatureCollection maps each prediction to a point feature at
+// its station.
+func predictionsFeatureCollection(predictions []PredictionWithLocation) geoJSONFeatureCollection {
+	features := make([]geoJSONFeature, len(predictions))
+	for i, p := range predictions {
+		features[i] = geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONPoint{Type: "Point", Coordinates: [2]Coordinate{p.Lon, p.Lat}},
+			Properties: predictionFeatureProperties{
+				StationID:                  p.StationID,
+				Name:                       p.Name,
+				PredictedAvailabilityClass: p.PredictedAvailabilityClass,
+				AvailabilityPrediction:     p.AvailabilityPrediction,
+				HorizonHours:               p.HorizonHours,
+				PredictionTime:             p.PredictionTime,
+				Confidence:                 p.Confidence,
+			},
+		}
+	}
+	return geoJSONFeatureCollection{Type: "FeatureCollection", Features: features}
+}
+
+// GetPredictionsGeoJSON serves the latest prediction of every located
+// station for ?horizon= (default the shortest) and ?model= as a GeoJSON
+// FeatureCollection, ready to add to a map as a source.
+func (h *HTTPHandlers) GetPredictionsGeoJSON(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if !h.flags.Enabled(FlagPredictedMode) {
+		respondPredictedModeDisabled(c)
+		return
+	}
+
+	latest, err := h.database.GetLatestPredictionsByHorizon(ctx)
+	if err != nil || len(latest) == 0 {
+		log.Printf("No predictions available: %v", err)
+		respondError(c, http.StatusServiceUnavailable, ErrCodePredictionsUnavailable, "Predictions not ready")
+		return
+	}
+	model := c.Query("model")
+	latest, err = selectModel(model, latest)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	horizon, err := selectHorizon(c.Query("horizon"), availableHorizons(latest))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	predictions, err := h.database.GetLatestPredictionsWithLocation(ctx, horizon)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch predictions", err)
+		return
+	}
+	predictions, err = selectModelWithLocation(model, predictions)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	c.Header("Content-Type", "application/geo+json")
+	c.JSON(http.StatusOK, predictionsFeatureCollection(predictions))
+}
+
+// selectModelWithLocation applies selectModel to predictions with their
+// station locations.
+func selectModelWithLocation(raw string, predictions []PredictionWithLocation) ([]PredictionWithLocation, error) {
+	plain := make([]Prediction, len(predictions))
+	for i, p := range predictions {
+		plain[i] = p.Prediction
+	}
+	selected, err := selectModel(raw, plain)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[int]bool, len(selected))
+	for _, p := range selected {
+		keep[p.ID] = true
+	}
+	located := make([]PredictionWithLocation, 0, len(selected))
+	for _, p := range predictions {
+		if keep[p.ID] {
+			located = append(located, p)
+		}
+	}
+	return located, nil
+}
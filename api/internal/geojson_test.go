@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPredictionsFeatureCollection(t *testing.T) {
+	collection := predictionsFeatureCollection([]PredictionWithLocation{{
+		Prediction: Prediction{StationID: "1", PredictedAvailabilityClass: 2, AvailabilityPrediction: "low", HorizonHours: 1},
+		Name:       "Clark & Lake",
+		Lat:        41.8857,
+		Lon:        -87.6309,
+	}})
+
+	body, err := json.Marshal(collection)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "FeatureCollection",
+		"features": [{
+			"type": "Feature",
+			"geometry": {"type": "Point", "coordinates": [-87.6309, 41.8857]},
+			"properties": {
+				"station_id": "1",
+				"name": "Clark & Lake",
+				"predicted_availability_class": 2,
+				"availability_prediction": "low",
+				"horizon_hours": 1,
+				"prediction_time": "0001-01-01T00:00:00Z",
+				"confidence": null
+			}
+		}]
+	}`, string(body))
+
+	body, err = json.Marshal(predictionsFeatureCollection(nil))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type": "FeatureCollection", "features": []}`, string(body))
+}
+
+func TestHTTPHandlers_GetPredictionsGeoJSON(t *testing.T) {
+	tests := []struct {
+		name            string
+		query           string
+		predictions     []Prediction
+		expectedHorizon int
+		expectedStatus  int
+	}{
+		{name: "default horizon", predictions: []Prediction{{StationID: "1", HorizonHours: 1}, {StationID: "1", HorizonHours: 6}}, expectedHorizon: 1, expectedStatus: http.StatusOK},
+		{name: "requested horizon", query: "?horizon=6", predictions: []Prediction{{StationID: "1", HorizonHours: 1}, {StationID: "1", HorizonHours: 6}}, expectedHorizon: 6, expectedStatus: http.StatusOK},
+		{name: "unknown horizon", query: "?horizon=12", predictions: []Prediction{{StationID: "1", HorizonHours: 1}}, expectedStatus: http.StatusBadRequest},
+		{name: "no predictions", predictions: []Prediction{}, expectedStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			mockDB.On("GetLatestPredictionsByHorizon", mock.Anything).Return(tt.predictions, nil)
+			mockDB.On("GetLatestPredictionsWithLocation", mock.Anything, tt.expectedHorizon).Return([]PredictionWithLocation{{
+				Prediction: Prediction{StationID: "1", HorizonHours: tt.expectedHorizon},
+				Lat:        41.8857,
+				Lon:        -87.6309,
+			}}, nil).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/geojson", handlers.GetPredictionsGeoJSON)
+
+			req := httptest.NewRequest("GET", "/geojson"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "application/geo+json", w.Header().Get("Content-Type"))
+			var response geoJSONFeatureCollection
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			if assert.Len(t, response.Features, 1) {
+				assert.Equal(t, tt.expectedHorizon, response.Features[0].Properties.HorizonHours)
+			}
+		})
+	}
+}
+
+func TestHTTPHandlers_GetPredictionsGeoJSON_Model(t *testing.T) {
+	mockDB := new(MockDatabase)
+	handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+	mockDB.On("GetLatestPredictionsByHorizon", mock.Anything).Return([]Prediction{
+		{StationID: "1", HorizonHours: 1, ModelVersion: "v1"},
+		{StationID: "1", HorizonHours: 6, ModelVersion: "v2"},
+	}, nil)
+	mockDB.On("GetLatestPredictionsWithLocation", mock.Anything, 6).Return([]PredictionWithLocation{
+		{Prediction: Prediction{ID: 1, StationID: "1", HorizonHours: 6, ModelVersion: "v1", PredictedAvailabilityClass: 0}},
+		{Prediction: Prediction{ID: 2, StationID: "1", HorizonHours: 6, ModelVersion: "v2", PredictedAvailabilityClass: 2}},
+	}, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/geojson", handlers.GetPredictionsGeoJSON)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/geojson?model=v2", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response geoJSONFeatureCollection
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Features, 1) {
+		assert.Equal(t, 2, response.Features[0].Properties.PredictedAvailabilityClass)
+		assert.Equal(t, 6, response.Features[0].Properties.HorizonHours, "defaults to v2's shortest horizon")
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/geojson?model=v3", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
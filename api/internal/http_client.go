@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Version is the build version, injected at build time with
+// -ldflags "-X api/internal.Version=<version>".
+var Version = "dev"
+
+// DefaultUserAgent identifies our traffic to upstream feed operators.
+func DefaultUserAgent() string {
+	return "divvy-bike-map/" + Version + " (+https://github.com/ejones77/divvy-bike-map)"
+}
+
+// userAgentTransport sets the User-Agent header on every outbound request and
+// propagates the caller's trace context (a no-op unless tracing is enabled).
+// Requests marked withPublicOnlyDial are checked by publicOnlyRoundTrip.
+type userAgentTransport struct {
+	userAgent string
+	base      http.RoundTripper
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+	if isPublicOnly(req.Context()) {
+		return t.publicOnlyRoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// publicOnlyRoundTrip sends a withPublicOnlyDial request. A request that
+// goes direct is sent through publicOnlyTransport, which checks every address
+// it dials. A request that goes through a proxy is sent through it as usual,
+// because locked-down networks allow no other route out, after its host is
+// resolved and checked here. The proxy resolves the host again, so this check
+// does not stop a host whose DNS changes between the two lookups.
+func (t *userAgentTransport) publicOnlyRoundTrip(req *http.Request) (*http.Response, error) {
+	transport, ok := t.base.(*http.Transport)
+	if !ok || transport.Proxy == nil {
+		return publicOnlyTransport.RoundTrip(req)
+	}
+	proxy, err := transport.Proxy(req)
+	if err != nil {
+		return nil, err
+	}
+	if proxy == nil {
+		return publicOnlyTransport.RoundTrip(req)
+	}
+	if err := checkPublicHost(req.Context(), req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	return transport.RoundTrip(req)
+}
+
+// newHTTPClient builds the client used for all outbound requests. They go
+// through HTTP_PROXY_URL when it is set and otherwise through the proxy named
+// by HTTPS_PROXY or HTTP_PROXY; NO_PROXY exempts hosts either way.
+func newHTTPClient(cfg *Config, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &userAgentTransport{
+			userAgent: cfg.HTTP.UserAgent,
+			base:      outboundTransport(cfg.HTTP.ProxyURL),
+		},
+	}
+}
+
+var (
+	outboundTransportsMu sync.Mutex
+	outboundTransports   = make(map[string]*http.Transport)
+)
+
+// outboundTransport returns the transport for proxyURL, shared by every
+// client using it so they share one connection pool. proxyURL must have
+// passed Config.Validate.
+func outboundTransport(proxyURL string) *http.Transport {
+	outboundTransportsMu.Lock()
+	defer outboundTransportsMu.Unlock()
+	if transport, ok := outboundTransports[proxyURL]; ok {
+		return transport
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc(proxyURL)
+	outboundTransports[proxyURL] = transport
+	return transport
+}
+
+// proxyFunc picks each request's proxy from the environment, with proxyURL,
+// when set, replacing HTTPS_PROXY and HTTP_PROXY but not NO_PROXY.
+func proxyFunc(proxyURL string) func(*http.Request) (*url.URL, error) {
+	if proxyURL == "" {
+		return http.ProxyFromEnvironment
+	}
+	env := httpproxy.FromEnvironment()
+	env.HTTPProxy = proxyURL
+	env.HTTPSProxy = proxyURL
+	proxy := env.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+}
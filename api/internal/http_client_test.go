@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHTTPClient_SetsUserAgent(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	config := NewTestConfig()
+	config.HTTP.UserAgent = "divvy-bike-map/test"
+
+	resp, err := newHTTPClient(config, time.Second).Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, "divvy-bike-map/test", received)
+}
+
+func TestNewHTTPClient_ProxyURL(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+	}))
+	defer proxy.Close()
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	// Loopback destinations never use a proxy, so the proxied request names
+	// a host that only the proxy would know how to reach.
+	config := NewTestConfig()
+	config.HTTP.ProxyURL = proxy.URL
+	client := newHTTPClient(config, time.Second)
+
+	resp, err := client.Get("http://feeds.example.com/gbfs.json")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []string{"http://feeds.example.com/gbfs.json"}, proxied)
+
+	resp, err = client.Get(target.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Len(t, proxied, 1)
+
+	assert.Same(t, client.Transport.(*userAgentTransport).base, newHTTPClient(config, time.Minute).Transport.(*userAgentTransport).base)
+}
+
+func TestNewHTTPClient_PublicOnlyThroughProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+	}))
+	defer proxy.Close()
+
+	config := NewTestConfig()
+	config.HTTP.ProxyURL = proxy.URL
+	client := newHTTPClient(config, time.Second)
+	ctx := withPublicOnlyDial(context.Background())
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://93.184.216.34/gbfs.json", nil)
+	resp, err := client.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+	assert.Equal(t, []string{"http://93.184.216.34/gbfs.json"}, proxied)
+
+	req, _ = http.NewRequestWithContext(ctx, "GET", "http://10.0.0.5/gbfs.json", nil)
+	_, err = client.Do(req)
+	assert.ErrorIs(t, err, ErrPrivateAddress)
+	assert.Len(t, proxied, 1, "a private destination is refused before reaching the proxy")
+}
+
+func TestProxyFunc_NoProxy(t *testing.T) {
+	t.Setenv("NO_PROXY", "exempt.example.com")
+	proxy := proxyFunc("http://proxy.internal:3128")
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{url: "https://gbfs.example.com/station_status.json", expected: "http://proxy.internal:3128"},
+		{url: "http://ml.example.com/predict", expected: "http://proxy.internal:3128"},
+		{url: "https://exempt.example.com/station_status.json", expected: ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		proxyURL, err := proxy(req)
+		assert.NoError(t, err)
+		if tt.expected == "" {
+			assert.Nil(t, proxyURL, tt.url)
+		} else if assert.NotNil(t, proxyURL, tt.url) {
+			assert.Equal(t, tt.expected, proxyURL.String())
+		}
+	}
+}
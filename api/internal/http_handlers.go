@@ -1,11 +1,22 @@
 package internal
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/singleflight"
 )
 
 type HTTPHandlers struct {
@@ -14,103 +25,1471 @@ type HTTPHandlers struct {
 	stationService    StationServiceInterface
 	mlService         MLServiceInterface
 	inferenceService  InferenceServiceInterface
+	predictions       *PredictionsCache
+	flags             *FeatureFlags
+	maintenance       *MaintenanceMode
+	live              *LiveHub
+	webhooks          *WebhookNotifier
 	config            *Config
+	displayLocation   *time.Location
+	statusCache       *statusCache
+	neighbors         stationNeighborCache
 }
 
 func NewHTTPHandlers(database DatabaseInterface, divvyClient DivvyClientInterface, config *Config) *HTTPHandlers {
 	mlService := NewMLService(config)
 	inferenceService := NewInferenceService(mlService, database)
+	predictions := NewPredictionsCache(database, time.Duration(config.ML.SnapshotMaxAgeMin)*time.Minute)
+	inferenceService.predictions = predictions
+	inferenceService.pushAvailability = config.ML.PushAvailability
+	inferenceService.timeFallback = config.ML.PredictionTimeFallback
+	flags := NewFeatureFlags(database)
+	stationService := NewStationService(database, divvyClient, config)
+	stationService.flags = flags
+	inferenceService.stations = stationService
+	live := NewLiveHub()
+	stationService.live = live
+	webhooks := NewWebhookNotifier(database, config)
+	stationService.webhooks = webhooks
 	return &HTTPHandlers{
 		database:         database,
 		divvyClient:      divvyClient,
-		stationService:   NewStationService(database, divvyClient),
+		stationService:   stationService,
 		mlService:        mlService,
 		inferenceService: inferenceService,
+		predictions:      predictions,
+		flags:            flags,
+		maintenance:      NewMaintenanceMode(config.Server.MaintenanceMode),
+		live:             live,
+		webhooks:         webhooks,
 		config:           config,
+		displayLocation:  loadDisplayLocation(config.Server.DisplayTimezone),
+		statusCache:      newStatusCache(statusCacheTTL),
 	}
 }
 
-func (h *HTTPHandlers) handleError(c *gin.Context, statusCode int, message string, err error) {
-	log.Printf("Error in %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
-	c.JSON(statusCode, gin.H{"error": message})
+func loadDisplayLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Warning: invalid display timezone %q, using UTC: %v", name, err)
+		return time.UTC
+	}
+	return loc
+}
+
+// htmlLocation is the timezone used when rendering templates.
+func (h *HTTPHandlers) htmlLocation() *time.Location {
+	if h.displayLocation == nil {
+		return time.UTC
+	}
+	return h.displayLocation
+}
+
+// colorStations sets each station's availability color so HTML and JSON
+// responses bucket stations the same way.
+func colorStations(stations []StationWithAvailability, thresholds AvailabilityThresholds) {
+	for i := range stations {
+		stations[i].Color = thresholds.Color(stations[i].NumBikesAvailable)
+	}
+}
+
+func (h *HTTPHandlers) handleError(c *gin.Context, statusCode int, code, message string, err error) {
+	log.Printf("Error in %s %s [%s]: %v", c.Request.Method, c.Request.URL.Path, c.GetString(requestIDContextKey), err)
+	if errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		statusCode, code, message = http.StatusServiceUnavailable, ErrCodeTimeout, "Request timed out"
+	}
+	respondError(c, statusCode, code, message)
+}
+
+func (h *HTTPHandlers) HomePage(c *gin.Context) {
+	locale := localeFrom(c)
+	c.HTML(http.StatusOK, "index.html", localizedTemplateData(c, gin.H{
+		"title": translate(locale, "title"),
+	}))
+}
+
+func (h *HTTPHandlers) GetStationsHTML(c *gin.Context) {
+	ctx := c.Request.Context()
+	mode := c.DefaultQuery("mode", h.defaultStationsMode())
+	system := c.Query("system")
+
+	loc, ok := requestLocation(c, h.htmlLocation())
+	if !ok {
+		return
+	}
+
+	stations, err := h.database.GetStationsWithAvailability(ctx, system)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch station data", err)
+		return
+	}
+	h.stationService.OverlayUnpersisted(stations)
+
+	colorStations(stations, h.config.Availability)
+
+	if mode == "predicted" && !h.flags.Enabled(FlagPredictedMode) {
+		respondPredictedModeDisabled(c)
+		return
+	}
+
+	predictionsMap := map[string]Prediction{}
+	var horizon int
+	var horizons []int
+	if mode == "predicted" {
+		if predictions, err := h.database.GetLatestPredictionsByHorizon(ctx); err == nil && len(predictions) > 0 {
+			predictions, err = selectModel(c.Query("model"), predictions)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+				return
+			}
+			horizons = availableHorizons(predictions)
+			horizon, err = selectHorizon(c.Query("horizon"), horizons)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+				return
+			}
+			localizePredictions(predictions, loc)
+			for _, p := range predictions {
+				if p.HorizonHours == horizon {
+					predictionsMap[p.StationID] = p
+				}
+			}
+		}
+	}
+
+	c.HTML(http.StatusOK, "stations.html", localizedTemplateData(c, gin.H{
+		"stations":       stations,
+		"predictionsMap": predictionsMap,
+		"mode":           mode,
+		"thresholds":     h.config.Availability,
+		"horizon":        horizon,
+		"horizons":       horizons,
+	}))
+}
+
+func respondPredictedModeDisabled(c *gin.Context) {
+	respondError(c, http.StatusServiceUnavailable, ErrCodeFeatureDisabled, "Predicted mode is disabled")
+}
+
+// availableHorizons returns the distinct prediction horizons, shortest first.
+func availableHorizons(predictions []Prediction) []int {
+	var horizons []int
+	for _, p := range predictions {
+		if !slices.Contains(horizons, p.HorizonHours) {
+			horizons = append(horizons, p.HorizonHours)
+		}
+	}
+	slices.Sort(horizons)
+	return horizons
+}
+
+// selectHorizon resolves the ?horizon= parameter against the available
+// horizons, defaulting to the shortest.
+func selectHorizon(raw string, horizons []int) (int, error) {
+	if raw == "" {
+		return horizons[0], nil
+	}
+	horizon, err := strconv.Atoi(raw)
+	if err != nil || !slices.Contains(horizons, horizon) {
+		return 0, fmt.Errorf("horizon must be one of %v", horizons)
+	}
+	return horizon, nil
+}
+
+// ErrUnknownModel is returned for a ?model= no stored prediction was made by.
+var ErrUnknownModel = errors.New("unknown model version")
+
+// availableModels returns the distinct prediction model versions, sorted.
+func availableModels(predictions []Prediction) []string {
+	var models []string
+	for _, p := range predictions {
+		if !slices.Contains(models, p.ModelVersion) {
+			models = append(models, p.ModelVersion)
+		}
+	}
+	slices.Sort(models)
+	return models
+}
+
+// selectModel resolves the ?model= parameter against the model versions of
+// predictions, which hold the latest prediction per station, model version
+// and horizon, and keeps that version's. Without ?model= the newest
+// prediction per station and horizon is kept, whichever model made it.
+func selectModel(raw string, predictions []Prediction) ([]Prediction, error) {
+	if raw == "" {
+		return newestAcrossModels(predictions), nil
+	}
+	models := availableModels(predictions)
+	if !slices.Contains(models, raw) {
+		return nil, fmt.Errorf("%w: model must be one of %v", ErrUnknownModel, models)
+	}
+	var selected []Prediction
+	for _, p := range predictions {
+		if p.ModelVersion == raw {
+			selected = append(selected, p)
+		}
+	}
+	return selected, nil
+}
+
+// newerPrediction reports whether p supersedes current: it was confirmed
+// later, or at the same time and created later. The latest-prediction
+// queries order by the same rule.
+func newerPrediction(p, current Prediction) bool {
+	return p.LastConfirmedAt.After(current.LastConfirmedAt) ||
+		(p.LastConfirmedAt.Equal(current.LastConfirmedAt) && p.CreatedAt.After(current.CreatedAt))
+}
+
+// newestAcrossModels keeps the most recently confirmed prediction for each
+// station and horizon, in first-seen order.
+func newestAcrossModels(predictions []Prediction) []Prediction {
+	type key struct {
+		stationID    string
+		horizonHours int
+	}
+	index := make(map[key]int, len(predictions))
+	var newest []Prediction
+	for _, p := range predictions {
+		k := key{p.StationID, p.HorizonHours}
+		i, ok := index[k]
+		if !ok {
+			index[k] = len(newest)
+			newest = append(newest, p)
+		} else if newerPrediction(p, newest[i]) {
+			newest[i] = p
+		}
+	}
+	return newest
+}
+
+// latestPerStation keeps each station's most recently confirmed prediction,
+// whichever model version and horizon it is for.
+func latestPerStation(predictions []Prediction) []Prediction {
+	index := make(map[string]int, len(predictions))
+	var latest []Prediction
+	for _, p := range predictions {
+		i, ok := index[p.StationID]
+		if !ok {
+			index[p.StationID] = len(latest)
+			latest = append(latest, p)
+			continue
+		}
+		if newerPrediction(p, latest[i]) {
+			latest[i] = p
+		}
+	}
+	return latest
+}
+
+// defaultStationsMode is the mode for stations requests without ?mode=.
+func (h *HTTPHandlers) defaultStationsMode() string {
+	if h.config.Server.DefaultStationsMode == "" {
+		return "current"
+	}
+	return h.config.Server.DefaultStationsMode
+}
+
+// latestPredictions returns the latest prediction per station, from the
+// predictions snapshot when one is configured, and when they were loaded.
+// With a model version, only that version's predictions are considered; an
+// unknown version is an error.
+func (h *HTTPHandlers) latestPredictions(ctx context.Context, model string) ([]Prediction, time.Time, error) {
+	var (
+		predictions []Prediction
+		generatedAt time.Time
+		err         error
+	)
+	if h.predictions == nil {
+		predictions, err = h.database.GetLatestPredictions(ctx)
+		generatedAt = time.Now().UTC()
+	} else {
+		predictions, generatedAt, err = h.predictions.Latest(ctx)
+	}
+	if err != nil || len(predictions) == 0 {
+		return nil, generatedAt, err
+	}
+	predictions, err = selectModel(model, predictions)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return latestPerStation(predictions), generatedAt, nil
+}
+
+// GetStationsJSON returns all stations with their latest availability. With
+// mode=predicted it adds the latest predictions; ?smooth=true also sets a
+// smoothed class per prediction, averaged over the last
+// PREDICTION_SMOOTHING_WINDOW stored predictions (see smoothPredictions).
+// ?fields=station_id,name,... limits each station to the listed fields.
+func (h *HTTPHandlers) GetStationsJSON(c *gin.Context) {
+	ctx := c.Request.Context()
+	mode := c.DefaultQuery("mode", h.defaultStationsMode())
+	system := c.Query("system")
+
+	if mode == "predicted" && !h.flags.Enabled(FlagPredictedMode) {
+		respondPredictedModeDisabled(c)
+		return
+	}
+
+	fields, err := parseStationFields(c.Query("fields"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	if mode == "current" && system == "" && c.Query("tz") == "" && fields == nil {
+		if snapshot := h.stationService.Snapshot(); snapshot != nil {
+			c.Header("X-Snapshot-Generated-At", snapshot.GeneratedAt.Format(time.RFC3339))
+			c.Data(http.StatusOK, "application/json; charset=utf-8", snapshot.Body)
+			return
+		}
+	}
+	if h.maintenance.Enabled() {
+		// Anything beyond the snapshot would need the database.
+		respondMaintenance(c, "Server is in maintenance mode; only the unfiltered current stations snapshot is available")
+		return
+	}
+
+	loc, ok := requestLocation(c, time.UTC)
+	if !ok {
+		return
+	}
+
+	stations, err := h.database.GetStationsWithAvailability(ctx, system)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch station data", err)
+		return
+	}
+	h.stationService.OverlayUnpersisted(stations)
+	localizeStationsWithAvailability(stations, loc)
+	colorStations(stations, h.config.Availability)
+
+	response := gin.H{"stations": stations, "timezone": loc.String()}
+	if fields != nil {
+		response["stations"] = projectStations(stations, fields)
+	}
+
+	if mode == "predicted" {
+		smooth, err := strconv.ParseBool(c.DefaultQuery("smooth", "false"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "smooth must be true or false")
+			return
+		}
+
+		predictions, generatedAt, err := h.latestPredictions(ctx, c.Query("model"))
+		if errors.Is(err, ErrUnknownModel) {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+		if err != nil || len(predictions) == 0 {
+			log.Printf("No predictions available: %v", err)
+			respondError(c, http.StatusServiceUnavailable, ErrCodePredictionsUnavailable, "Predictions not ready")
+			return
+		}
+		if smooth {
+			history, err := h.database.GetPredictionHistory(ctx, "", h.config.ML.SmoothingWindow)
+			if err != nil {
+				h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch prediction history", err)
+				return
+			}
+			smoothPredictions(predictions, history)
+			response["smoothing_window"] = h.config.ML.SmoothingWindow
+		}
+		localizePredictions(predictions, loc)
+		response["predictions"] = predictions
+		response["predictions_generated_at"] = generatedAt.In(loc)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetStationMetadata returns station records (without availability) updated
+// after ?since=, or all stations when it is absent. The returned server_time
+// is the cursor for the next incremental request.
+func (h *HTTPHandlers) GetStationMetadata(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	since, err := parseTimeParam(c, "since", time.Time{})
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	loc, ok := requestLocation(c, time.UTC)
+	if !ok {
+		return
+	}
+
+	// Captured before querying so rows updated during the query are returned again next time.
+	serverTime := time.Now().In(loc)
+
+	stations, err := h.database.GetStationsUpdatedSince(ctx, since)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch station metadata", err)
+		return
+	}
+	if stations == nil {
+		stations = []Station{}
+	}
+	localizeStations(stations, loc)
+
+	c.JSON(http.StatusOK, gin.H{
+		"stations":    stations,
+		"server_time": serverTime,
+		"timezone":    loc.String(),
+	})
+}
+
+var seriesBucketSizes = map[string]time.Duration{
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+const (
+	defaultPredictionHistoryLimit = 48
+	maxPredictionHistoryLimit     = 500
+)
+
+// GetStationPredictionHistory returns the newest ?limit= (default 48)
+// stored predictions for a station per horizon, showing how its forecast
+// evolved across batches. Unknown stations have no history.
+func (h *HTTPHandlers) GetStationPredictionHistory(c *gin.Context) {
+	stationID := c.Param("id")
+
+	limit := defaultPredictionHistoryLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > maxPredictionHistoryLimit {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest,
+				fmt.Sprintf("limit must be between 1 and %d", maxPredictionHistoryLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	predictions, err := h.database.GetPredictionHistory(c.Request.Context(), stationID, limit)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch prediction history", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"station_id": stationID,
+		"limit":      limit,
+		"horizons":   groupByHorizon(predictions),
+	})
+}
+
+// groupByHorizon splits predictions ordered by horizon into one history per
+// horizon, keeping their order within each.
+func groupByHorizon(predictions []Prediction) []HorizonPredictionHistory {
+	histories := []HorizonPredictionHistory{}
+	for _, p := range predictions {
+		if n := len(histories); n == 0 || histories[n-1].HorizonHours != p.HorizonHours {
+			histories = append(histories, HorizonPredictionHistory{HorizonHours: p.HorizonHours})
+		}
+		last := &histories[len(histories)-1]
+		last.Predictions = append(last.Predictions, p)
+	}
+	return histories
+}
+
+// GetStationSeries returns a station's bikes/docks averaged into buckets for
+// charting. The range defaults to the 24 hours before ?to= (or now).
+func (h *HTTPHandlers) GetStationSeries(c *gin.Context) {
+	ctx := c.Request.Context()
+	stationID := c.Param("id")
+
+	bucketParam := c.DefaultQuery("bucket", "15m")
+	bucket, ok := seriesBucketSizes[bucketParam]
+	if !ok {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "bucket must be one of 15m, 1h, 1d")
+		return
+	}
+
+	to, err := parseTimeParam(c, "to", time.Now().UTC())
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	from, err := parseTimeParam(c, "from", to.Add(-24*time.Hour))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if !from.Before(to) {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "from must be before to")
+		return
+	}
+	loc, ok := requestLocation(c, time.UTC)
+	if !ok {
+		return
+	}
+
+	// Hourly and longer buckets come from the availability_hourly rollup
+	// rather than scanning every raw row in the range.
+	getSeries := h.database.GetAvailabilitySeries
+	if bucket >= time.Hour {
+		getSeries = h.database.GetRollupAvailabilitySeries
+	}
+	series, err := getSeries(ctx, stationID, from, to, bucket)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch availability series", err)
+		return
+	}
+	if series == nil {
+		series = []AvailabilityBucket{}
+	}
+	localizeBuckets(series, loc)
+
+	c.JSON(http.StatusOK, gin.H{
+		"station_id": stationID,
+		"from":       from.In(loc),
+		"to":         to.In(loc),
+		"bucket":     bucketParam,
+		"series":     series,
+		"timezone":   loc.String(),
+	})
+}
+
+// parseTimeParam reads an optional RFC3339 query parameter.
+func parseTimeParam(c *gin.Context, name string, defaultValue time.Time) (time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return defaultValue, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC3339 timestamp", name)
+	}
+	return parsed, nil
+}
+
+// GetStationsInBBox returns stations inside the min/max lat/lon viewport.
+func (h *HTTPHandlers) GetStationsInBBox(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var bounds Bounds
+	params := []struct {
+		name   string
+		target *float64
+	}{
+		{"min_lat", &bounds.MinLat},
+		{"min_lon", &bounds.MinLon},
+		{"max_lat", &bounds.MaxLat},
+		{"max_lon", &bounds.MaxLon},
+	}
+	for _, param := range params {
+		value, err := strconv.ParseFloat(c.Query(param.name), 64)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest, param.name+" must be a number")
+			return
+		}
+		*param.target = value
+	}
+	if err := bounds.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	stations, err := h.database.GetStationsInBBox(ctx, bounds)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch station data", err)
+		return
+	}
+	h.stationService.OverlayUnpersisted(stations)
+	if stations == nil {
+		stations = []StationWithAvailability{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stations": stations, "bounds": bounds})
+}
+
+// GetAnomalies returns availability anomalies of every kind (swings and
+// impossible updates) detected after ?since=, defaulting to the last 24 hours.
+func (h *HTTPHandlers) GetAnomalies(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	since, err := parseTimeParam(c, "since", time.Now().Add(-24*time.Hour))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	anomalies, err := h.database.GetAnomaliesSince(ctx, since)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch anomalies", err)
+		return
+	}
+	if anomalies == nil {
+		anomalies = []AvailabilityAnomaly{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"anomalies": anomalies, "count": len(anomalies)})
+}
+
+// GetStationSummary returns a station's current availability together with
+// its latest prediction for each horizon. predictions_available is false when
+// none have been generated yet, so clients can show a pending state.
+func (h *HTTPHandlers) GetStationSummary(c *gin.Context) {
+	ctx := c.Request.Context()
+	stationID := c.Param("id")
+
+	loc, ok := requestLocation(c, time.UTC)
+	if !ok {
+		return
+	}
+
+	station, err := h.database.GetStationWithAvailability(ctx, stationID)
+	if errors.Is(err, ErrStationNotFound) {
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, "station "+stationID+" not found")
+		return
+	}
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch station", err)
+		return
+	}
+
+	predictions, err := h.database.GetStationPredictions(ctx, stationID)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch station predictions", err)
+		return
+	}
+	if len(predictions) > 0 {
+		predictions, err = selectModel(c.Query("model"), predictions)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+	}
+	if predictions == nil {
+		predictions = []Prediction{}
+	}
+
+	stations := []StationWithAvailability{station}
+	h.stationService.OverlayUnpersisted(stations)
+	localizeStationsWithAvailability(stations, loc)
+	colorStations(stations, h.config.Availability)
+	localizePredictions(predictions, loc)
+
+	c.JSON(http.StatusOK, gin.H{
+		"station":               stations[0],
+		"predictions":           predictions,
+		"predictions_available": len(predictions) > 0,
+		"timezone":              loc.String(),
+	})
+}
+
+const (
+	defaultCompareMaxGap = 30 * time.Minute
+	maxCompareMaxGap     = 6 * time.Hour
+)
+
+// CompareAvailability compares each station's availability nearest to ?t1=
+// and ?t2=, e.g. before and after a rebalancing run. Records further than
+// ?max_gap_min= minutes (default 30) from the requested time are ignored, and
+// stations without a record near both times are counted as unmatched.
+func (h *HTTPHandlers) CompareAvailability(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	t1, err := parseTimeParam(c, "t1", time.Time{})
+	if err == nil && t1.IsZero() {
+		err = errors.New("t1 is required")
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	t2, err := parseTimeParam(c, "t2", time.Time{})
+	if err == nil && t2.IsZero() {
+		err = errors.New("t2 is required")
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	maxGap := defaultCompareMaxGap
+	if raw := c.Query("max_gap_min"); raw != "" {
+		minutes, err := strconv.Atoi(raw)
+		maxGap = time.Duration(minutes) * time.Minute
+		if err != nil || minutes <= 0 || maxGap > maxCompareMaxGap {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest,
+				fmt.Sprintf("max_gap_min must be between 1 and %d", int(maxCompareMaxGap.Minutes())))
+			return
+		}
+	}
+
+	before, err := h.database.GetAvailabilityNear(ctx, t1, maxGap)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch availability", err)
+		return
+	}
+	after, err := h.database.GetAvailabilityNear(ctx, t2, maxGap)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch availability", err)
+		return
+	}
+
+	deltas, totals, unmatched := compareAvailability(before, after)
+
+	c.JSON(http.StatusOK, gin.H{
+		"t1":          t1,
+		"t2":          t2,
+		"max_gap_min": int(maxGap.Minutes()),
+		"stations":    deltas,
+		"totals":      totals,
+		"unmatched":   unmatched,
+	})
+}
+
+// compareAvailability diffs two per-station snapshots, ordered by station ID.
+// Stations present in only one snapshot are counted as unmatched.
+func compareAvailability(before, after map[string]StationAvailability) ([]AvailabilityDelta, AvailabilityTotals, int) {
+	deltas := []AvailabilityDelta{}
+	var totals AvailabilityTotals
+	unmatched := 0
+
+	for stationID, b := range before {
+		a, ok := after[stationID]
+		if !ok {
+			unmatched++
+			continue
+		}
+		deltas = append(deltas, AvailabilityDelta{
+			StationID:   stationID,
+			SystemID:    a.SystemID,
+			BikesBefore: b.NumBikesAvailable,
+			BikesAfter:  a.NumBikesAvailable,
+			BikesDelta:  a.NumBikesAvailable - b.NumBikesAvailable,
+			DocksBefore: b.NumDocksAvailable,
+			DocksAfter:  a.NumDocksAvailable,
+			DocksDelta:  a.NumDocksAvailable - b.NumDocksAvailable,
+		})
+		totals.BikesBefore += b.NumBikesAvailable
+		totals.BikesAfter += a.NumBikesAvailable
+		totals.DocksBefore += b.NumDocksAvailable
+		totals.DocksAfter += a.NumDocksAvailable
+	}
+	for stationID := range after {
+		if _, ok := before[stationID]; !ok {
+			unmatched++
+		}
+	}
+
+	totals.BikesDelta = totals.BikesAfter - totals.BikesBefore
+	totals.DocksDelta = totals.DocksAfter - totals.DocksBefore
+	slices.SortFunc(deltas, func(x, y AvailabilityDelta) int {
+		return strings.Compare(x.StationID, y.StationID)
+	})
+	return deltas, totals, unmatched
+}
+
+const (
+	defaultUtilizationBuckets = 5
+	maxUtilizationBuckets     = 20
+)
+
+// GetStationStats totals the latest availability across stations. With
+// ?histogram=true it adds a utilization_histogram of how full stations are,
+// split into ?buckets= equal-width buckets (default 5, i.e. 0-20%, 20-40%, ...).
+func (h *HTTPHandlers) GetStationStats(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	histogram, err := strconv.ParseBool(c.DefaultQuery("histogram", "false"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "histogram must be a boolean")
+		return
+	}
+	buckets := defaultUtilizationBuckets
+	if raw := c.Query("buckets"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > maxUtilizationBuckets {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest,
+				fmt.Sprintf("buckets must be an integer between 1 and %d", maxUtilizationBuckets))
+			return
+		}
+		buckets = parsed
+	}
+
+	stats, err := h.database.GetStationStats(ctx)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch station stats", err)
+		return
+	}
+	response := gin.H{
+		"station_count":  stats.StationCount,
+		"total_bikes":    stats.TotalBikes,
+		"total_docks":    stats.TotalDocks,
+		"total_capacity": stats.TotalCapacity,
+	}
+
+	if histogram {
+		counts, err := h.database.GetUtilizationCounts(ctx, buckets)
+		if err != nil {
+			h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch utilization histogram", err)
+			return
+		}
+		response["utilization_histogram"] = utilizationHistogram(counts, buckets)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// utilizationHistogram expands per-bucket counts into every bucket, in
+// order, with empty buckets reported as zero.
+func utilizationHistogram(counts map[int]int, buckets int) []UtilizationBucket {
+	// Bounds are rounded to 0.1% so e.g. 3 buckets read 0-33.3%.
+	bound := func(i int) float64 { return math.Round(1000*float64(i)/float64(buckets)) / 10 }
+	histogram := make([]UtilizationBucket, buckets)
+	for i := range histogram {
+		minPct, maxPct := bound(i), bound(i+1)
+		histogram[i] = UtilizationBucket{
+			Label:  fmt.Sprintf("%g-%g%%", minPct, maxPct),
+			MinPct: minPct,
+			MaxPct: maxPct,
+			Count:  counts[i+1],
+		}
+	}
+	return histogram
+}
+
+// GetStationStaleness lists each station's freshest last_reported, stalest
+// first, flagging those older than the configured threshold.
+func (h *HTTPHandlers) GetStationStaleness(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	staleness, err := h.database.GetStationStaleness(ctx)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch station staleness", err)
+		return
+	}
+	if staleness == nil {
+		staleness = []StationStaleness{}
+	}
+
+	threshold := int64(h.config.Health.StaleStationThresholdMin) * 60
+	staleCount := 0
+	for i := range staleness {
+		staleness[i].Stale = staleness[i].AgeSeconds > threshold
+		if staleness[i].Stale {
+			staleCount++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stations":          staleness,
+		"count":             len(staleness),
+		"stale_count":       staleCount,
+		"threshold_seconds": threshold,
+	})
+}
+
+// GetCapacityDiscrepancies lists stations whose capacity differs from
+// num_bikes_available + num_docks_available in their latest status by at
+// least ?min_gap= (default 1), largest gap first.
+func (h *HTTPHandlers) GetCapacityDiscrepancies(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	minGap := 1
+	if raw := c.Query("min_gap"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "min_gap must be a positive integer")
+			return
+		}
+		minGap = parsed
+	}
+
+	stations, err := h.database.GetStationsWithAvailability(ctx, c.Query("system"))
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch station data", err)
+		return
+	}
+	h.stationService.OverlayUnpersisted(stations)
+
+	discrepancies := capacityDiscrepancies(stations, minGap)
+	c.JSON(http.StatusOK, gin.H{"stations": discrepancies, "count": len(discrepancies), "min_gap": minGap})
+}
+
+// capacityDiscrepancies returns stations whose absolute gap is at least
+// minGap, largest first. Stations with no recorded status are skipped.
+func capacityDiscrepancies(stations []StationWithAvailability, minGap int) []CapacityDiscrepancy {
+	discrepancies := []CapacityDiscrepancy{}
+	for _, s := range stations {
+		if s.LastReported == 0 {
+			continue
+		}
+		total := s.NumBikesAvailable + s.NumDocksAvailable
+		gap := s.Capacity - total
+		if abs(gap) < minGap {
+			continue
+		}
+		discrepancies = append(discrepancies, CapacityDiscrepancy{
+			StationID:     s.StationID,
+			SystemID:      s.SystemID,
+			Name:          s.Name,
+			Capacity:      s.Capacity,
+			BikesAndDocks: total,
+			Gap:           gap,
+		})
+	}
+	slices.SortStableFunc(discrepancies, func(a, b CapacityDiscrepancy) int {
+		return abs(b.Gap) - abs(a.Gap)
+	})
+	return discrepancies
+}
+
+// Operational categories, from a station's is_installed, is_renting and
+// is_returning flags. OperationalUnknown is for stations with no recorded
+// status, whose flags are all zero.
+const (
+	OperationalFull       = "operational"
+	OperationalRentOnly   = "rent_only"
+	OperationalReturnOnly = "return_only"
+	OperationalOffline    = "offline"
+	OperationalUnknown    = "unknown"
+)
+
+// GetOperationalSummary counts stations by operational category, optionally
+// for one ?system=.
+func (h *HTTPHandlers) GetOperationalSummary(c *gin.Context) {
+	stations, err := h.database.GetStationsWithAvailability(c.Request.Context(), c.Query("system"))
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch station data", err)
+		return
+	}
+	h.stationService.OverlayUnpersisted(stations)
+	c.JSON(http.StatusOK, operationalSummary(stations))
+}
+
+// operationalCategory classifies a station by its latest status. A station
+// that is not installed is offline whatever its other flags say.
+func operationalCategory(s StationWithAvailability) string {
+	switch {
+	case s.LastReported == 0:
+		return OperationalUnknown
+	case s.IsInstalled == 0:
+		return OperationalOffline
+	case s.IsRenting == 1 && s.IsReturning == 1:
+		return OperationalFull
+	case s.IsRenting == 1:
+		return OperationalRentOnly
+	case s.IsReturning == 1:
+		return OperationalReturnOnly
+	default:
+		return OperationalOffline
+	}
+}
+
+// operationalSummary counts stations per category, every category included,
+// and lists the rent-only, return-only and offline ones sorted by ID.
+// Stations with unknown status are counted but not listed.
+func operationalSummary(stations []StationWithAvailability) OperationalSummary {
+	summary := OperationalSummary{
+		StationCount: len(stations),
+		Counts: map[string]int{
+			OperationalFull:       0,
+			OperationalRentOnly:   0,
+			OperationalReturnOnly: 0,
+			OperationalOffline:    0,
+			OperationalUnknown:    0,
+		},
+		NonOperationalStations: []string{},
+	}
+	for _, s := range stations {
+		category := operationalCategory(s)
+		summary.Counts[category]++
+		if category != OperationalFull && category != OperationalUnknown {
+			summary.NonOperationalStations = append(summary.NonOperationalStations, s.StationID)
+		}
+	}
+	slices.Sort(summary.NonOperationalStations)
+	return summary
+}
+
+// GetStationDivergence lists stations whose latest prediction for ?horizon=
+// (default the shortest) is in a different class than their current
+// availability, largest change first.
+func (h *HTTPHandlers) GetStationDivergence(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if !h.flags.Enabled(FlagPredictedMode) {
+		respondPredictedModeDisabled(c)
+		return
+	}
+
+	predictions, err := h.database.GetLatestPredictionsByHorizon(ctx)
+	if err != nil || len(predictions) == 0 {
+		log.Printf("No predictions available: %v", err)
+		respondError(c, http.StatusServiceUnavailable, ErrCodePredictionsUnavailable, "Predictions not ready")
+		return
+	}
+	predictions, err = selectModel(c.Query("model"), predictions)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	horizon, err := selectHorizon(c.Query("horizon"), availableHorizons(predictions))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	stations, err := h.database.GetStationsWithAvailability(ctx, c.Query("system"))
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch station data", err)
+		return
+	}
+	h.stationService.OverlayUnpersisted(stations)
+
+	divergence := stationDivergence(stations, predictions, horizon)
+	c.JSON(http.StatusOK, gin.H{"stations": divergence, "count": len(divergence), "horizon_hours": horizon})
 }
 
-func (h *HTTPHandlers) HomePage(c *gin.Context) {
-	c.HTML(http.StatusOK, "index.html", gin.H{
-		"title": "Divvy Bike Availability",
+// stationDivergence pairs each station's current class with its prediction
+// for horizon, keeping those that differ, largest change first. Stations
+// with no recorded status or no prediction at that horizon are skipped.
+func stationDivergence(stations []StationWithAvailability, predictions []Prediction, horizon int) []StationDivergence {
+	predicted := make(map[string]Prediction, len(stations))
+	for _, p := range predictions {
+		if p.HorizonHours == horizon {
+			predicted[p.StationID] = p
+		}
+	}
+
+	divergence := []StationDivergence{}
+	for _, s := range stations {
+		p, ok := predicted[s.StationID]
+		if !ok || s.LastReported == 0 {
+			continue
+		}
+		current := availabilityClass(s.NumBikesAvailable, s.Capacity)
+		if current == p.PredictedAvailabilityClass {
+			continue
+		}
+		divergence = append(divergence, StationDivergence{
+			StationID:         s.StationID,
+			SystemID:          s.SystemID,
+			Name:              s.Name,
+			NumBikesAvailable: s.NumBikesAvailable,
+			Capacity:          s.Capacity,
+			CurrentClass:      current,
+			CurrentLabel:      predictionClassLabels[current],
+			PredictedClass:    p.PredictedAvailabilityClass,
+			PredictedLabel:    predictionClassLabels[p.PredictedAvailabilityClass],
+			Change:            p.PredictedAvailabilityClass - current,
+			PredictionTime:    p.PredictionTime,
+		})
+	}
+	slices.SortStableFunc(divergence, func(a, b StationDivergence) int {
+		return abs(b.Change) - abs(a.Change)
 	})
+	return divergence
 }
 
-func (h *HTTPHandlers) GetStationsHTML(c *gin.Context) {
+const (
+	defaultOutlierRadiusM = 500
+	maxOutlierRadiusM     = 5000
+	// maxCachedNeighborRadii bounds how many radii stationNeighborCache
+	// keeps per refresh.
+	maxCachedNeighborRadii = 16
+	neighborLoadTimeout    = 30 * time.Second
+)
+
+// stationNeighborCache keeps GetStationNeighbors results, whose self-join is
+// too heavy to run on every public outliers request, until the next station
+// refresh. Radii are rounded to whole meters; concurrent misses for one
+// radius share a query.
+type stationNeighborCache struct {
+	mu         sync.Mutex
+	generation time.Time
+	byRadius   map[float64][]StationNeighbors
+	loads      singleflight.Group
+}
+
+// get returns the neighbors within radius for the refresh identified by
+// generation, loading them with load on a miss.
+func (c *stationNeighborCache) get(ctx context.Context, generation time.Time, radius float64, load func(context.Context, float64) ([]StationNeighbors, error)) ([]StationNeighbors, error) {
+	radius = math.Round(radius)
+	c.mu.Lock()
+	if !c.generation.Equal(generation) {
+		c.generation, c.byRadius = generation, nil
+	}
+	neighbors, ok := c.byRadius[radius]
+	c.mu.Unlock()
+	if ok {
+		return neighbors, nil
+	}
+
+	key := generation.String() + " " + strconv.FormatFloat(radius, 'f', 0, 64)
+	result, err, _ := c.loads.Do(key, func() (interface{}, error) {
+		// Shared by every waiter, so not cancelled with the first one.
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), neighborLoadTimeout)
+		defer cancel()
+		neighbors, err := load(loadCtx, radius)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.generation.Equal(generation) {
+			if c.byRadius == nil || len(c.byRadius) >= maxCachedNeighborRadii {
+				c.byRadius = make(map[float64][]StationNeighbors)
+			}
+			c.byRadius[radius] = neighbors
+		}
+		return neighbors, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]StationNeighbors), nil
+}
+
+// GetPredictionOutliers lists stations whose prediction for ?horizon=
+// (default the shortest) differs from the average prediction of stations
+// within ?radius_m= meters by at least PREDICTION_OUTLIER_MIN_DEVIATION
+// classes, most deviant first: a sanity check on the model's spatial
+// consistency.
+func (h *HTTPHandlers) GetPredictionOutliers(c *gin.Context) {
 	ctx := c.Request.Context()
-	mode := c.DefaultQuery("mode", "current")
 
-	stations, err := h.database.GetStationsWithAvailability(ctx)
+	if !h.flags.Enabled(FlagPredictedMode) {
+		respondPredictedModeDisabled(c)
+		return
+	}
+
+	radius := float64(defaultOutlierRadiusM)
+	if raw := c.Query("radius_m"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed <= 0 || parsed > maxOutlierRadiusM {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest,
+				fmt.Sprintf("radius_m must be between 0 and %d", maxOutlierRadiusM))
+			return
+		}
+		radius = parsed
+	}
+
+	predictions, err := h.database.GetLatestPredictionsByHorizon(ctx)
+	if err != nil || len(predictions) == 0 {
+		log.Printf("No predictions available: %v", err)
+		respondError(c, http.StatusServiceUnavailable, ErrCodePredictionsUnavailable, "Predictions not ready")
+		return
+	}
+	predictions, err = selectModel(c.Query("model"), predictions)
 	if err != nil {
-		h.handleError(c, http.StatusInternalServerError, "Failed to fetch station data", err)
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	horizon, err := selectHorizon(c.Query("horizon"), availableHorizons(predictions))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
 		return
 	}
 
-	predictionsMap := map[string]Prediction{}
-	if mode == "predicted" {
-		if predictions, err := h.database.GetLatestPredictions(ctx); err == nil && len(predictions) > 0 {
-			for _, p := range predictions {
-				predictionsMap[p.StationID] = p
-			}
+	// Stations only move on a refresh, so the neighbor graph is cached
+	// until the next stations snapshot.
+	var generation time.Time
+	if snapshot := h.stationService.Snapshot(); snapshot != nil {
+		generation = snapshot.GeneratedAt
+	}
+	neighbors, err := h.neighbors.get(ctx, generation, radius, h.database.GetStationNeighbors)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch station neighbors", err)
+		return
+	}
+
+	minDeviation := h.config.ML.OutlierMinDeviation
+	outliers := predictionOutliers(neighbors, predictions, horizon, minDeviation)
+	c.JSON(http.StatusOK, gin.H{
+		"stations":      outliers,
+		"count":         len(outliers),
+		"horizon_hours": horizon,
+		"radius_m":      radius,
+		"min_deviation": minDeviation,
+	})
+}
+
+// predictionOutliers compares each station's prediction for horizon with
+// the mean prediction of its neighbors, keeping those at least minDeviation
+// classes away, largest deviation first. Stations without a prediction, or
+// with no neighbor that has one, are skipped.
+func predictionOutliers(neighbors []StationNeighbors, predictions []Prediction, horizon int, minDeviation float64) []PredictionOutlier {
+	predicted := make(map[string]Prediction, len(neighbors))
+	for _, p := range predictions {
+		if p.HorizonHours == horizon {
+			predicted[p.StationID] = p
 		}
 	}
 
-	c.HTML(http.StatusOK, "stations.html", gin.H{
-		"stations":       stations,
-		"predictionsMap": predictionsMap,
-		"mode":           mode,
+	outliers := []PredictionOutlier{}
+	for _, station := range neighbors {
+		p, ok := predicted[station.StationID]
+		if !ok {
+			continue
+		}
+		sum, count := 0, 0
+		for _, id := range station.NeighborIDs {
+			if neighbor, ok := predicted[id]; ok {
+				sum += neighbor.PredictedAvailabilityClass
+				count++
+			}
+		}
+		if count == 0 {
+			continue
+		}
+		avg := float64(sum) / float64(count)
+		deviation := math.Abs(float64(p.PredictedAvailabilityClass) - avg)
+		if deviation < minDeviation {
+			continue
+		}
+		outliers = append(outliers, PredictionOutlier{
+			StationID:        station.StationID,
+			Name:             station.Name,
+			PredictedClass:   p.PredictedAvailabilityClass,
+			PredictedLabel:   predictionClassLabels[p.PredictedAvailabilityClass],
+			NeighborAvgClass: avg,
+			Deviation:        deviation,
+			NeighborCount:    count,
+			PredictionTime:   p.PredictionTime,
+		})
+	}
+	slices.SortStableFunc(outliers, func(a, b PredictionOutlier) int {
+		return cmp.Compare(b.Deviation, a.Deviation)
 	})
+	return outliers
 }
 
-func (h *HTTPHandlers) GetStationsJSON(c *gin.Context) {
+// maxBatchStationIDs caps the stations one GetStationsBatch call may list,
+// and maxBatchBodyBytes the size of its body.
+const (
+	maxBatchStationIDs = 100
+	maxBatchBodyBytes  = 64 << 10
+)
+
+// GetStationsBatch returns current availability and the prediction at a
+// horizon for a {"ids": [...], "horizon": N} body, one entry per distinct ID
+// in request order. horizon defaults to the shortest available.
+func (h *HTTPHandlers) GetStationsBatch(c *gin.Context) {
 	ctx := c.Request.Context()
-	mode := c.DefaultQuery("mode", "current")
 
-	stations, err := h.database.GetStationsWithAvailability(ctx)
+	var body struct {
+		IDs     []string `json:"ids"`
+		Horizon *int     `json:"horizon"`
+	}
+	err := json.NewDecoder(http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchBodyBytes)).Decode(&body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondError(c, http.StatusRequestEntityTooLarge, ErrCodeBadRequest, fmt.Sprintf("body must be at most %d bytes", maxBatchBodyBytes))
+		return
+	}
+	if err != nil || len(body.IDs) == 0 {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "body must be {\"ids\": [string, ...], \"horizon\": int}")
+		return
+	}
+	ids := make([]string, 0, min(len(body.IDs), maxBatchStationIDs))
+	seen := make(map[string]bool, cap(ids))
+	for _, id := range body.IDs {
+		if seen[id] {
+			continue
+		}
+		if len(ids) == maxBatchStationIDs {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest, fmt.Sprintf("at most %d station ids may be requested", maxBatchStationIDs))
+			return
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	if !h.flags.Enabled(FlagPredictedMode) {
+		respondPredictedModeDisabled(c)
+		return
+	}
+
+	predictions, err := h.database.GetLatestPredictionsByHorizon(ctx)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch predictions", err)
+		return
+	}
+	predictions = newestAcrossModels(predictions)
+	var horizon int
+	if body.Horizon != nil {
+		horizon = *body.Horizon
+	}
+	if horizons := availableHorizons(predictions); len(horizons) > 0 {
+		raw := ""
+		if body.Horizon != nil {
+			raw = strconv.Itoa(*body.Horizon)
+		}
+		if horizon, err = selectHorizon(raw, horizons); err != nil {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+	}
+
+	stations, err := h.database.GetStationsByIDs(ctx, ids)
 	if err != nil {
-		h.handleError(c, http.StatusInternalServerError, "Failed to fetch station data", err)
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch station data", err)
 		return
 	}
+	h.stationService.OverlayUnpersisted(stations)
+
+	forecasts := stationForecasts(ids, stations, predictions, horizon)
+	c.JSON(http.StatusOK, gin.H{"stations": forecasts, "count": len(forecasts), "horizon_hours": horizon})
+}
 
-	response := gin.H{"stations": stations}
+// stationForecasts builds one StationForecast per ID, leaving Current or
+// Forecast nil where there is no station or no prediction at horizon.
+func stationForecasts(ids []string, stations []StationWithAvailability, predictions []Prediction, horizon int) []StationForecast {
+	current := make(map[string]*StationWithAvailability, len(stations))
+	for i := range stations {
+		current[stations[i].StationID] = &stations[i]
+	}
+	predicted := make(map[string]*Prediction, len(ids))
+	for i, p := range predictions {
+		if p.HorizonHours == horizon {
+			predicted[p.StationID] = &predictions[i]
+		}
+	}
 
-	if mode == "predicted" {
-		predictions, err := h.database.GetLatestPredictions(ctx)
-		if err != nil || len(predictions) == 0 {
-			log.Printf("No predictions available: %v", err)
-			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Predictions not ready"})
+	forecasts := make([]StationForecast, len(ids))
+	for i, id := range ids {
+		forecasts[i] = StationForecast{StationID: id, Current: current[id], Forecast: predicted[id]}
+	}
+	return forecasts
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// GetStationsMissingAvailability lists stations without an availability row
+// in the last ?minutes= minutes, defaulting to the stale station threshold.
+func (h *HTTPHandlers) GetStationsMissingAvailability(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	minutes := h.config.Health.StaleStationThresholdMin
+	if raw := c.Query("minutes"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "minutes must be a positive integer")
 			return
 		}
-		response["predictions"] = predictions
+		minutes = parsed
 	}
 
-	c.JSON(http.StatusOK, response)
+	since := time.Now().Add(-time.Duration(minutes) * time.Minute)
+	stations, err := h.database.GetStationsMissingAvailability(ctx, since)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch stations missing availability", err)
+		return
+	}
+	if stations == nil {
+		stations = []MissingStation{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stations": stations, "count": len(stations), "minutes": minutes})
+}
+
+const (
+	defaultFreeBikeRadiusM = 500
+	maxFreeBikeRadiusM     = 5000
+)
+
+// GetFreeBikes returns dockless bikes within radius_m meters of lat/lon.
+func (h *HTTPHandlers) GetFreeBikes(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	lat, latErr := strconv.ParseFloat(c.Query("lat"), 64)
+	lon, lonErr := strconv.ParseFloat(c.Query("lon"), 64)
+	if latErr != nil || lonErr != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "lat and lon must be valid coordinates")
+		return
+	}
+
+	radius := float64(defaultFreeBikeRadiusM)
+	if raw := c.Query("radius_m"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed <= 0 || parsed > maxFreeBikeRadiusM {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest,
+				fmt.Sprintf("radius_m must be between 0 and %d", maxFreeBikeRadiusM))
+			return
+		}
+		radius = parsed
+	}
+
+	bikes, err := h.database.GetFreeBikesNear(ctx, lat, lon, radius)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch free bikes", err)
+		return
+	}
+	if bikes == nil {
+		bikes = []FreeBike{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bikes": bikes, "count": len(bikes)})
 }
 
+// RefreshStationData ingests every system's feeds. With ?dry_run=true it
+// only fetches and validates them, listing every invalid station per system.
 func (h *HTTPHandlers) RefreshStationData(c *gin.Context) {
 	ctx := c.Request.Context()
 
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "dry_run must be true or false")
+		return
+	}
+	if dryRun {
+		systems := h.stationService.DryRunRefresh(ctx)
+		invalid := 0
+		for _, system := range systems {
+			invalid += system.InvalidCount
+		}
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "systems": systems, "invalid_count": invalid})
+		return
+	}
+
 	if err := h.stationService.RefreshStationData(ctx); err != nil {
-		h.handleError(c, http.StatusInternalServerError, "Failed to refresh station data", err)
+		h.handleError(c, http.StatusInternalServerError, ErrCodeRefreshFailed, "Failed to refresh station data", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Station data refreshed successfully"})
 }
 
+// RefreshStationDataInternal refreshes station data for background callers,
+// returning ErrMaintenanceMode instead while maintenance mode is on.
 func (h *HTTPHandlers) RefreshStationDataInternal(ctx context.Context) error {
+	if h.maintenance.Enabled() {
+		return ErrMaintenanceMode
+	}
 	return h.stationService.RefreshStationData(ctx)
 }
 
+// HealthCheck reports whether enough predictions are stored. In maintenance
+// mode it answers from memory instead, see maintenanceReport.
 func (h *HTTPHandlers) HealthCheck(c *gin.Context) {
+	if h.maintenance.Enabled() {
+		report := h.maintenanceReport()
+		response := gin.H{"status": report.Status, "service": "divvy-api", "checks": report.Checks}
+		if report.Status == overallStatusDown {
+			response["status"] = "unhealthy"
+			response["reason"] = "maintenance mode is on and no stations snapshot is cached"
+			c.JSON(http.StatusServiceUnavailable, response)
+			return
+		}
+		response["reason"] = "maintenance mode is on; serving the cached stations snapshot"
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
 	ctx := c.Request.Context()
 	
 	predictions, err := h.database.GetLatestPredictions(ctx)
+	predictions = latestPerStation(predictions)
 	if err != nil || len(predictions) == 0 {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"status":  "unhealthy",
@@ -119,24 +1498,242 @@ func (h *HTTPHandlers) HealthCheck(c *gin.Context) {
 		})
 		return
 	}
-	
-	c.JSON(http.StatusOK, gin.H{
+
+	response := gin.H{
 		"status":            "healthy",
 		"service":           "divvy-api",
 		"predictions_count": len(predictions),
-	})
-}
+	}
+
+	// Latest predictions hold one row per station, so this is station coverage.
+	if stationCount, err := h.database.CountStations(ctx); err != nil {
+		log.Printf("Health check could not count stations: %v", err)
+	} else if stationCount > 0 {
+		response["prediction_coverage"] = float64(len(predictions)) / float64(stationCount)
+	}
 
+	if len(predictions) < h.config.Health.MinHealthyPredictions {
+		response["status"] = "degraded"
+		response["reason"] = fmt.Sprintf("only %d predictions, need at least %d", len(predictions), h.config.Health.MinHealthyPredictions)
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
 
+	c.JSON(http.StatusOK, response)
+}
 
 func (h *HTTPHandlers) TriggerInference(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	err := h.inferenceService.RunInferenceWithResults(ctx)
 	if err != nil {
-		h.handleError(c, http.StatusInternalServerError, "Inference failed", err)
+		h.handleError(c, http.StatusInternalServerError, ErrCodeInferenceFailed, "Inference failed", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Inference completed"})
 }
+
+func (h *HTTPHandlers) GetAdminConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, h.config.Redacted())
+}
+
+// GetFeatureFlags lists every known feature flag with its stored or default value.
+func (h *HTTPHandlers) GetFeatureFlags(c *gin.Context) {
+	flags, err := h.flags.List(c.Request.Context())
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch feature flags", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"flags": flags})
+}
+
+// RefreshPredictionsSnapshot discards the in-memory predictions snapshot and
+// reloads it from the database, e.g. after predictions were edited by hand.
+func (h *HTTPHandlers) RefreshPredictionsSnapshot(c *gin.Context) {
+	snapshot, err := h.predictions.Reload(c.Request.Context())
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to reload predictions snapshot", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"generated_at": snapshot.GeneratedAt, "count": len(snapshot.Predictions)})
+}
+
+const (
+	defaultPredictionBatchLimit = 20
+	maxPredictionBatchLimit     = 500
+)
+
+// GetPredictionBatches returns summaries of the most recent ?limit= prediction
+// batches (see Database.GetPredictionBatches), for checking that each
+// inference run stored a complete set of predictions, along with how many
+// ML predictions had an unparseable prediction_time.
+func (h *HTTPHandlers) GetPredictionBatches(c *gin.Context) {
+	limit := defaultPredictionBatchLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > maxPredictionBatchLimit {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest,
+				fmt.Sprintf("limit must be an integer between 1 and %d", maxPredictionBatchLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	batches, err := h.database.GetPredictionBatches(c.Request.Context(), limit)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch prediction batches", err)
+		return
+	}
+	if batches == nil {
+		batches = []PredictionBatch{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"batches":             batches,
+		"count":               len(batches),
+		"time_parse_failures": h.inferenceService.TimeParseFailures(),
+	})
+}
+
+// GetPredictionDistribution counts stored predictions per class in ?bucket=
+// (15m, 1h or default 1d) buckets of their created_at between ?from= and
+// ?to= (default the last 7 days), optionally for one ?horizon=. A sudden
+// shift between buckets points at a model or input data change.
+func (h *HTTPHandlers) GetPredictionDistribution(c *gin.Context) {
+	bucketParam := c.DefaultQuery("bucket", "1d")
+	bucket, ok := seriesBucketSizes[bucketParam]
+	if !ok {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "bucket must be one of 15m, 1h, 1d")
+		return
+	}
+
+	to, err := parseTimeParam(c, "to", time.Now().UTC())
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	from, err := parseTimeParam(c, "from", to.Add(-7*24*time.Hour))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if !from.Before(to) {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "from must be before to")
+		return
+	}
+
+	horizon := 0
+	if raw := c.Query("horizon"); raw != "" {
+		if horizon, err = strconv.Atoi(raw); err != nil || horizon <= 0 {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "horizon must be a positive integer")
+			return
+		}
+	}
+
+	buckets, err := h.database.GetPredictionDistribution(c.Request.Context(), from, to, bucket, horizon)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch prediction distribution", err)
+		return
+	}
+	if buckets == nil {
+		buckets = []PredictionClassBucket{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"from":    from,
+		"to":      to,
+		"bucket":  bucketParam,
+		"buckets": buckets,
+	})
+}
+
+// GetPredictionFreshness reports, per horizon, when predictions were last
+// stored and confirmed, so the UI can badge each forecast with its age.
+func (h *HTTPHandlers) GetPredictionFreshness(c *gin.Context) {
+	freshness, err := h.database.GetPredictionFreshness(c.Request.Context())
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch prediction freshness", err)
+		return
+	}
+	if freshness == nil {
+		freshness = []HorizonFreshness{}
+	}
+	c.JSON(http.StatusOK, gin.H{"horizons": freshness})
+}
+
+const (
+	defaultStorageStatsDays = 30
+	maxStorageStatsDays     = 365
+)
+
+// GetStorageStats reports the size of the availability and prediction
+// tables and their rows added per day over the last ?days= days (default
+// 30), to plan retention and rollups.
+func (h *HTTPHandlers) GetStorageStats(c *gin.Context) {
+	days := defaultStorageStatsDays
+	if raw := c.Query("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > maxStorageStatsDays {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest,
+				fmt.Sprintf("days must be between 1 and %d", maxStorageStatsDays))
+			return
+		}
+		days = parsed
+	}
+
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+	tables, err := h.database.GetStorageStats(c.Request.Context(), since)
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch storage stats", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"days":   days,
+		"since":  since,
+		"tables": tables,
+	})
+}
+
+// SetFeatureFlag stores a feature flag from a {"name", "enabled"} body. The
+// If-Match header must carry the flag's current version as listed by
+// GetFeatureFlags ("0" for a flag still at its default), so concurrent edits
+// fail with 412 instead of overwriting each other. The change applies to
+// this instance immediately and to others on their next flag refresh.
+func (h *HTTPHandlers) SetFeatureFlag(c *gin.Context) {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		respondError(c, http.StatusPreconditionRequired, ErrCodePreconditionRequired, "If-Match header with the flag version is required")
+		return
+	}
+	expectedVersion, err := strconv.Atoi(strings.Trim(ifMatch, `"`))
+	if err != nil || expectedVersion < 0 {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "If-Match must be a flag version, e.g. \"3\"")
+		return
+	}
+
+	var body struct {
+		Name    string `json:"name"`
+		Enabled *bool  `json:"enabled"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil || body.Name == "" || body.Enabled == nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "body must be {\"name\": string, \"enabled\": bool}")
+		return
+	}
+
+	flag, err := h.flags.Set(c.Request.Context(), body.Name, *body.Enabled, expectedVersion)
+	if errors.Is(err, ErrUnknownFeatureFlag) {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, ErrFeatureFlagVersionConflict) {
+		respondError(c, http.StatusPreconditionFailed, ErrCodePreconditionFailed,
+			"feature flag "+body.Name+" was modified since version "+strconv.Itoa(expectedVersion))
+		return
+	}
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to set feature flag", err)
+		return
+	}
+	log.Printf("Feature flag %s set to %t (version %d)", flag.Name, flag.Enabled, flag.Version)
+	c.Header("ETag", strconv.Quote(strconv.Itoa(flag.Version)))
+	c.JSON(http.StatusOK, flag)
+}
@@ -1,16 +1,29 @@
 package internal
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
+func assertErrorEnvelope(t *testing.T, w *httptest.ResponseRecorder, expectedCode string) {
+	t.Helper()
+	var response ErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, expectedCode, response.Error.Code)
+	assert.NotEmpty(t, response.Error.Message)
+}
+
 func TestHTTPHandlers_GetStationsJSON(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -18,7 +31,10 @@ func TestHTTPHandlers_GetStationsJSON(t *testing.T) {
 		mockError      error
 		expectedStatus int
 		mode           string
+		system         string
 		includePreds   bool
+		defaultMode    string
+		expectedCode   string
 	}{
 		{
 			name:           "success - current mode",
@@ -35,11 +51,27 @@ func TestHTTPHandlers_GetStationsJSON(t *testing.T) {
 			mode:           "predicted",
 			includePreds:   true,
 		},
+		{
+			name:           "success - system filter",
+			mockReturn:     []StationWithAvailability{TestStationWithAvailability},
+			mockError:      nil,
+			expectedStatus: http.StatusOK,
+			mode:           "current",
+			system:         "divvy",
+		},
+		{
+			name:           "success - configured default mode",
+			mockReturn:     []StationWithAvailability{TestStationWithAvailability},
+			expectedStatus: http.StatusOK,
+			includePreds:   true,
+			defaultMode:    "predicted",
+		},
 		{
 			name:           "database error",
 			mockReturn:     nil,
 			mockError:      assert.AnError,
 			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   ErrCodeDBError,
 		},
 	}
 
@@ -48,10 +80,11 @@ func TestHTTPHandlers_GetStationsJSON(t *testing.T) {
 			mockDB := new(MockDatabase)
 			mockClient := new(MockDivvyClient)
 			config := NewTestConfig()
+			config.Server.DefaultStationsMode = tt.defaultMode
 
 			handlers := NewHTTPHandlers(mockDB, mockClient, config)
 
-			mockDB.On("GetStationsWithAvailability", mock.Anything).
+			mockDB.On("GetStationsWithAvailability", mock.Anything, tt.system).
 				Return(tt.mockReturn, tt.mockError)
 
 			if tt.includePreds {
@@ -63,12 +96,16 @@ func TestHTTPHandlers_GetStationsJSON(t *testing.T) {
 			router := gin.New()
 			router.GET("/stations", handlers.GetStationsJSON)
 
-			url := "/stations"
+			query := url.Values{}
 			if tt.mode != "" {
-				url += "?mode=" + tt.mode
+				query.Set("mode", tt.mode)
+			}
+			if tt.system != "" {
+				query.Set("system", tt.system)
 			}
 
-			req := httptest.NewRequest("GET", url, nil)
+			req := httptest.NewRequest("GET", "/stations?"+query.Encode(), nil)
+
 			w := httptest.NewRecorder()
 			router.ServeHTTP(w, req)
 
@@ -79,6 +116,12 @@ func TestHTTPHandlers_GetStationsJSON(t *testing.T) {
 				err := json.Unmarshal(w.Body.Bytes(), &response)
 				assert.NoError(t, err)
 				assert.Contains(t, response, "stations")
+				if tt.includePreds {
+					assert.Contains(t, response, "predictions")
+					assert.Contains(t, response, "predictions_generated_at")
+				}
+			} else {
+				assertErrorEnvelope(t, w, tt.expectedCode)
 			}
 
 			mockDB.AssertExpectations(t)
@@ -138,6 +181,8 @@ func TestHTTPHandlers_RefreshStationData(t *testing.T) {
 				err := json.Unmarshal(w.Body.Bytes(), &response)
 				assert.NoError(t, err)
 				assert.Equal(t, "Station data refreshed successfully", response["message"])
+			} else {
+				assertErrorEnvelope(t, w, ErrCodeRefreshFailed)
 			}
 
 			mockStationService.AssertExpectations(t)
@@ -145,6 +190,60 @@ func TestHTTPHandlers_RefreshStationData(t *testing.T) {
 	}
 }
 
+func TestHTTPHandlers_RefreshStationData_DryRun(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+	}{
+		{name: "dry run", query: "?dry_run=true", expectedStatus: http.StatusOK},
+		{name: "invalid flag", query: "?dry_run=maybe", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStationService := new(MockStationService)
+			handlers := NewHTTPHandlers(new(MockDatabase), new(MockDivvyClient), NewTestConfig())
+			handlers.stationService = mockStationService
+			mockStationService.On("DryRunRefresh", mock.Anything).Return([]RefreshDryRun{
+				{SystemID: "chi", StationCount: 3, InvalidCount: 2, ValidationErrors: []StationValidationError{
+					{Index: 1, StationID: "c2", Message: "station name is required"},
+					{Index: 2, Message: "station ID is required"},
+				}},
+				{SystemID: "nyc", StationCount: 5, InvalidCount: 1, ValidationErrors: []StationValidationError{
+					{Index: 0, StationID: "n1", Message: "capacity cannot be negative"},
+				}},
+			}).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.POST("/refresh", handlers.RefreshStationData)
+
+			req := httptest.NewRequest("POST", "/refresh"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockStationService.AssertNotCalled(t, "RefreshStationData", mock.Anything)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+				return
+			}
+
+			var response struct {
+				DryRun       bool            `json:"dry_run"`
+				InvalidCount int             `json:"invalid_count"`
+				Systems      []RefreshDryRun `json:"systems"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.True(t, response.DryRun)
+			assert.Equal(t, 3, response.InvalidCount)
+			assert.Len(t, response.Systems, 2)
+			assert.Len(t, response.Systems[0].ValidationErrors, 2)
+		})
+	}
+}
+
 func TestHTTPHandlers_TriggerInference(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -196,6 +295,8 @@ func TestHTTPHandlers_TriggerInference(t *testing.T) {
 				err := json.Unmarshal(w.Body.Bytes(), &response)
 				assert.NoError(t, err)
 				assert.Equal(t, "Inference completed", response["message"])
+			} else {
+				assertErrorEnvelope(t, w, ErrCodeInferenceFailed)
 			}
 
 			mockInferenceService.AssertExpectations(t)
@@ -205,29 +306,48 @@ func TestHTTPHandlers_TriggerInference(t *testing.T) {
 
 func TestHTTPHandlers_HealthCheck(t *testing.T) {
 	tests := []struct {
-		name           string
-		predictions    []Prediction
-		dbError        error
-		expectedStatus int
-		expectedHealth string
+		name             string
+		predictions      []Prediction
+		dbError          error
+		stationCount     int
+		minHealthy       int
+		expectedStatus   int
+		expectedHealth   string
+		expectedCoverage float64
 	}{
 		{
 			name: "healthy with predictions",
 			predictions: []Prediction{
 				{StationID: "123", PredictedAvailabilityClass: 1},
 			},
-			expectedStatus: http.StatusOK,
-			expectedHealth: "healthy",
+			stationCount:     2,
+			minHealthy:       1,
+			expectedStatus:   http.StatusOK,
+			expectedHealth:   "healthy",
+			expectedCoverage: 0.5,
+		},
+		{
+			name: "degraded below minimum predictions",
+			predictions: []Prediction{
+				{StationID: "123", PredictedAvailabilityClass: 1},
+			},
+			stationCount:     4,
+			minHealthy:       3,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedHealth:   "degraded",
+			expectedCoverage: 0.25,
 		},
 		{
 			name:           "unhealthy no predictions",
 			predictions:    []Prediction{},
+			minHealthy:     1,
 			expectedStatus: http.StatusServiceUnavailable,
 			expectedHealth: "unhealthy",
 		},
 		{
 			name:           "unhealthy db error",
 			dbError:        assert.AnError,
+			minHealthy:     1,
 			expectedStatus: http.StatusServiceUnavailable,
 			expectedHealth: "unhealthy",
 		},
@@ -238,6 +358,7 @@ func TestHTTPHandlers_HealthCheck(t *testing.T) {
 			mockDB := new(MockDatabase)
 			mockClient := new(MockDivvyClient)
 			config := NewTestConfig()
+			config.Health.MinHealthyPredictions = tt.minHealthy
 
 			if tt.dbError != nil {
 				mockDB.On("GetLatestPredictions", mock.Anything).Return(
@@ -246,6 +367,9 @@ func TestHTTPHandlers_HealthCheck(t *testing.T) {
 				mockDB.On("GetLatestPredictions", mock.Anything).Return(
 					tt.predictions, nil)
 			}
+			if len(tt.predictions) > 0 {
+				mockDB.On("CountStations", mock.Anything).Return(tt.stationCount, nil)
+			}
 
 			handlers := NewHTTPHandlers(mockDB, mockClient, config)
 
@@ -264,7 +388,1670 @@ func TestHTTPHandlers_HealthCheck(t *testing.T) {
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedHealth, response["status"])
 			assert.Equal(t, "divvy-api", response["service"])
+			if tt.expectedCoverage > 0 {
+				assert.Equal(t, tt.expectedCoverage, response["prediction_coverage"])
+			}
+
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHTTPHandlers_GetAdminConfig(t *testing.T) {
+	config := NewTestConfig()
+	config.Server.AdminAPIKey = "admin-key"
+
+	handlers := NewHTTPHandlers(new(MockDatabase), new(MockDivvyClient), config)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/config", handlers.GetAdminConfig)
+
+	req := httptest.NewRequest("GET", "/admin/config", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "test_pass")
+	assert.NotContains(t, w.Body.String(), "admin-key")
+	assert.Contains(t, w.Body.String(), config.ML.ServiceURL)
+}
+
+func TestHTTPHandlers_GetStationsJSON_Snapshot(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockStationService := new(MockStationService)
+	mockStationService.On("Snapshot").Return(&StationsSnapshot{
+		Body:        []byte(`{"stations":[],"timezone":"UTC"}`),
+		GeneratedAt: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	})
+
+	handlers := &HTTPHandlers{
+		database:       mockDB,
+		stationService: mockStationService,
+		config:         NewTestConfig(),
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stations", handlers.GetStationsJSON)
+
+	req := httptest.NewRequest("GET", "/stations?mode=current", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"stations":[],"timezone":"UTC"}`, w.Body.String())
+	assert.Equal(t, "2024-01-01T12:00:00Z", w.Header().Get("X-Snapshot-Generated-At"))
+	mockDB.AssertNotCalled(t, "GetStationsWithAvailability", mock.Anything, mock.Anything)
+}
+
+func TestHTTPHandlers_GetStationsJSON_PredictionsUnavailable(t *testing.T) {
+	mockDB := new(MockDatabase)
+	handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+
+	mockDB.On("GetStationsWithAvailability", mock.Anything, "").
+		Return([]StationWithAvailability{TestStationWithAvailability}, nil)
+	mockDB.On("GetLatestPredictions", mock.Anything).Return([]Prediction{}, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/stations", handlers.GetStationsJSON)
+
+	req := httptest.NewRequest("GET", "/stations?mode=predicted", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var response ErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, ErrCodePredictionsUnavailable, response.Error.Code)
+	assert.Equal(t, "req-123", response.Error.RequestID)
+	assert.Equal(t, "req-123", w.Header().Get("X-Request-ID"))
+}
+
+func TestHTTPHandlers_GetStationMetadata(t *testing.T) {
+	since := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedSince  time.Time
+		mockReturn     []Station
+		mockError      error
+		expectedStatus int
+		expectedCount  int
+	}{
+		{
+			name:           "all stations when since absent",
+			query:          "",
+			expectedSince:  time.Time{},
+			mockReturn:     []Station{TestStation},
+			expectedStatus: http.StatusOK,
+			expectedCount:  1,
+		},
+		{
+			name:           "incremental since",
+			query:          "?since=2024-01-01T12:00:00Z",
+			expectedSince:  since,
+			mockReturn:     nil,
+			expectedStatus: http.StatusOK,
+			expectedCount:  0,
+		},
+		{
+			name:           "invalid since",
+			query:          "?since=yesterday",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "database error",
+			query:          "",
+			expectedSince:  time.Time{},
+			mockError:      assert.AnError,
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+
+			if tt.expectedStatus != http.StatusBadRequest {
+				mockDB.On("GetStationsUpdatedSince", mock.Anything, tt.expectedSince).
+					Return(tt.mockReturn, tt.mockError)
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/stations/metadata", handlers.GetStationMetadata)
+
+			req := httptest.NewRequest("GET", "/stations/metadata"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			if tt.expectedStatus == http.StatusOK {
+				var response struct {
+					Stations   []Station `json:"stations"`
+					ServerTime time.Time `json:"server_time"`
+				}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Len(t, response.Stations, tt.expectedCount)
+				assert.False(t, response.ServerTime.IsZero())
+			}
+
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHTTPHandlers_GetStationSeries(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedBucket time.Duration
+		expectedQuery  string
+		expectedStatus int
+	}{
+		{
+			name:           "hourly buckets",
+			query:          "?from=2024-01-01T00:00:00Z&to=2024-01-02T00:00:00Z&bucket=1h",
+			expectedBucket: time.Hour,
+			expectedQuery:  "GetRollupAvailabilitySeries",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "daily buckets",
+			query:          "?from=2024-01-01T00:00:00Z&to=2024-01-02T00:00:00Z&bucket=1d",
+			expectedBucket: 24 * time.Hour,
+			expectedQuery:  "GetRollupAvailabilitySeries",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "default bucket",
+			query:          "?from=2024-01-01T00:00:00Z&to=2024-01-02T00:00:00Z",
+			expectedBucket: 15 * time.Minute,
+			expectedQuery:  "GetAvailabilitySeries",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unsupported bucket",
+			query:          "?bucket=5m",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "inverted range",
+			query:          "?from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid timestamp",
+			query:          "?from=yesterday",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+
+			if tt.expectedStatus == http.StatusOK {
+				mockDB.On(tt.expectedQuery, mock.Anything, "test-001", from, to, tt.expectedBucket).
+					Return([]AvailabilityBucket{{BucketStart: from, AvgBikesAvailable: 4.5, Samples: 4}}, nil)
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/api/stations/json", handlers.GetStationsJSON)
+			router.GET("/api/stations/:id/series", handlers.GetStationSeries)
+
+			req := httptest.NewRequest("GET", "/api/stations/test-001/series"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var response struct {
+					Series []AvailabilityBucket `json:"series"`
+				}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Len(t, response.Series, 1)
+			} else {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+			}
+
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHTTPHandlers_GetFreeBikes(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedRadius float64
+		expectedStatus int
+	}{
+		{
+			name:           "default radius",
+			query:          "?lat=41.88&lon=-87.63",
+			expectedRadius: defaultFreeBikeRadiusM,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "custom radius",
+			query:          "?lat=41.88&lon=-87.63&radius_m=1200",
+			expectedRadius: 1200,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "missing coordinates",
+			query:          "?lat=41.88",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "radius too large",
+			query:          "?lat=41.88&lon=-87.63&radius_m=100000",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+
+			if tt.expectedStatus == http.StatusOK {
+				mockDB.On("GetFreeBikesNear", mock.Anything, 41.88, -87.63, tt.expectedRadius).
+					Return([]FreeBike{{BikeID: "b1", DistanceM: 42}}, nil)
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/free-bikes", handlers.GetFreeBikes)
+
+			req := httptest.NewRequest("GET", "/free-bikes"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+			}
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHTTPHandlers_GetStationsInBBox(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+	}{
+		{
+			name:           "valid viewport",
+			query:          "?min_lat=41.8&min_lon=-87.7&max_lat=41.9&max_lon=-87.6",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "inverted latitude",
+			query:          "?min_lat=41.9&min_lon=-87.7&max_lat=41.8&max_lon=-87.6",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "degenerate longitude",
+			query:          "?min_lat=41.8&min_lon=-87.7&max_lat=41.9&max_lon=-87.7",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing parameter",
+			query:          "?min_lat=41.8&min_lon=-87.7&max_lat=41.9",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+
+			if tt.expectedStatus == http.StatusOK {
+				mockDB.On("GetStationsInBBox", mock.Anything, Bounds{MinLat: 41.8, MinLon: -87.7, MaxLat: 41.9, MaxLon: -87.6}).
+					Return([]StationWithAvailability{TestStationWithAvailability}, nil)
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/api/stations/bbox", handlers.GetStationsInBBox)
+			router.GET("/api/stations/:id/series", handlers.GetStationSeries)
+
+			req := httptest.NewRequest("GET", "/api/stations/bbox"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+			}
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHTTPHandlers_GetStationsInBBox_OverlaysUnpersisted(t *testing.T) {
+	mockDB := new(MockDatabase)
+	handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+	held := StationAvailability{StationID: TestStation.StationID, SystemID: TestStation.SystemID, NumBikesAvailable: 2, NumDocksAvailable: 13, IsInstalled: 1, IsRenting: 1, IsReturning: 1}
+	handlers.stationService.(*StationService).holdUnpersisted(TestStation.SystemID, []StationAvailability{held})
+
+	mockDB.On("GetStationsInBBox", mock.Anything, mock.Anything).
+		Return([]StationWithAvailability{TestStationWithAvailability}, nil)
 
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/stations/bbox", handlers.GetStationsInBBox)
+
+	req := httptest.NewRequest("GET", "/api/stations/bbox?min_lat=41.8&min_lon=-87.7&max_lat=41.9&max_lon=-87.6", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Stations []StationWithAvailability `json:"stations"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Stations, 1) {
+		assert.Equal(t, 2, response.Stations[0].NumBikesAvailable)
+		assert.Equal(t, 13, response.Stations[0].NumDocksAvailable)
+	}
+	mockDB.AssertExpectations(t)
+}
+
+func TestHTTPHandlers_GetStationsJSON_Timezone(t *testing.T) {
+	predictionTime := time.Date(2024, 7, 1, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		query            string
+		expectedStatus   int
+		expectedTimezone string
+		expectedTime     string
+		expectedLocal    string
+	}{
+		{
+			name:             "UTC by default",
+			query:            "?mode=predicted",
+			expectedStatus:   http.StatusOK,
+			expectedTimezone: "UTC",
+			expectedTime:     "2024-07-01T18:00:00Z",
+		},
+		{
+			name:             "converted to requested timezone",
+			query:            "?mode=predicted&tz=America/Chicago",
+			expectedStatus:   http.StatusOK,
+			expectedTimezone: "America/Chicago",
+			expectedTime:     "2024-07-01T13:00:00-05:00",
+			expectedLocal:    "2024-07-01 13:00 CDT",
+		},
+		{
+			name:           "invalid timezone",
+			query:          "?mode=predicted&tz=Not/AZone",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+
+			if tt.expectedStatus == http.StatusOK {
+				mockDB.On("GetStationsWithAvailability", mock.Anything, "").
+					Return([]StationWithAvailability{TestStationWithAvailability}, nil)
+				mockDB.On("GetLatestPredictions", mock.Anything).
+					Return([]Prediction{{StationID: "test-001", PredictionTime: predictionTime}}, nil)
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/stations", handlers.GetStationsJSON)
+
+			req := httptest.NewRequest("GET", "/stations"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var response struct {
+					Timezone    string `json:"timezone"`
+					Predictions []struct {
+						PredictionTime      string `json:"prediction_time"`
+						PredictionTimeLocal string `json:"prediction_time_local"`
+					} `json:"predictions"`
+				}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, tt.expectedTimezone, response.Timezone)
+				assert.Equal(t, tt.expectedTime, response.Predictions[0].PredictionTime)
+				assert.Equal(t, tt.expectedLocal, response.Predictions[0].PredictionTimeLocal)
+			} else {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+			}
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHTTPHandlers_GetAnomalies(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mockDB := new(MockDatabase)
+	handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+	mockDB.On("GetAnomaliesSince", mock.Anything, since).
+		Return([]AvailabilityAnomaly{{StationID: "test-001", BikesBefore: 15, BikesAfter: 0, ChangePct: 100}}, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/anomalies", handlers.GetAnomalies)
+
+	req := httptest.NewRequest("GET", "/anomalies?since=2024-01-01T00:00:00Z", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Anomalies []AvailabilityAnomaly `json:"anomalies"`
+		Count     int                   `json:"count"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Count)
+	assert.Equal(t, 100.0, response.Anomalies[0].ChangePct)
+	mockDB.AssertExpectations(t)
+}
+
+func TestHTTPHandlers_GetStationStaleness(t *testing.T) {
+	mockDB := new(MockDatabase)
+	config := NewTestConfig()
+	config.Health.StaleStationThresholdMin = 60
+	handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), config)
+	mockDB.On("GetStationStaleness", mock.Anything).Return([]StationStaleness{
+		{StationID: "old", AgeSeconds: 7200},
+		{StationID: "fresh", AgeSeconds: 120},
+	}, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/staleness", handlers.GetStationStaleness)
+
+	req := httptest.NewRequest("GET", "/staleness", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Stations   []StationStaleness `json:"stations"`
+		StaleCount int                `json:"stale_count"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.StaleCount)
+	assert.True(t, response.Stations[0].Stale)
+	assert.False(t, response.Stations[1].Stale)
+	mockDB.AssertExpectations(t)
+}
+
+func TestHTTPHandlers_GetPredictionBatches(t *testing.T) {
+	createdAt := time.Date(2024, 7, 1, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedLimit  int
+		expectedStatus int
+	}{
+		{name: "default limit", expectedLimit: defaultPredictionBatchLimit, expectedStatus: http.StatusOK},
+		{name: "custom limit", query: "?limit=5", expectedLimit: 5, expectedStatus: http.StatusOK},
+		{name: "limit too large", query: "?limit=100000", expectedStatus: http.StatusBadRequest},
+		{name: "invalid limit", query: "?limit=all", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			if tt.expectedStatus == http.StatusOK {
+				mockDB.On("GetPredictionBatches", mock.Anything, tt.expectedLimit).Return([]PredictionBatch{
+					{CreatedAt: createdAt, HorizonHours: 1, Count: 3, Classes: map[string]int{"high": 2, "low": 1}},
+				}, nil)
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/prediction-batches", handlers.GetPredictionBatches)
+
+			req := httptest.NewRequest("GET", "/prediction-batches"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+				return
+			}
+			var response struct {
+				Batches           []PredictionBatch     `json:"batches"`
+				Count             int                   `json:"count"`
+				TimeParseFailures TimeParseFailureStats `json:"time_parse_failures"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, 1, response.Count)
+			assert.Equal(t, map[string]int{"high": 2, "low": 1}, response.Batches[0].Classes)
+			assert.Equal(t, PredictionTimeFallbackNow, response.TimeParseFailures.Fallback)
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHTTPHandlers_GetPredictionDistribution(t *testing.T) {
+	from := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 7, 3, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name            string
+		query           string
+		expectedBucket  time.Duration
+		expectedHorizon int
+		expectedStatus  int
+	}{
+		{name: "daily buckets", query: "?from=2024-07-01T00:00:00Z&to=2024-07-03T00:00:00Z", expectedBucket: 24 * time.Hour, expectedStatus: http.StatusOK},
+		{name: "hourly buckets for one horizon", query: "?from=2024-07-01T00:00:00Z&to=2024-07-03T00:00:00Z&bucket=1h&horizon=6", expectedBucket: time.Hour, expectedHorizon: 6, expectedStatus: http.StatusOK},
+		{name: "unknown bucket", query: "?bucket=1w", expectedStatus: http.StatusBadRequest},
+		{name: "from after to", query: "?from=2024-07-03T00:00:00Z&to=2024-07-01T00:00:00Z", expectedStatus: http.StatusBadRequest},
+		{name: "invalid horizon", query: "?horizon=-1", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			if tt.expectedStatus == http.StatusOK {
+				mockDB.On("GetPredictionDistribution", mock.Anything, from, to, tt.expectedBucket, tt.expectedHorizon).
+					Return([]PredictionClassBucket{
+						{BucketStart: from, Count: 3, Classes: map[string]int{"high": 2, "low": 1}},
+						{BucketStart: from.Add(24 * time.Hour), Count: 3, Classes: map[string]int{"low": 3}},
+					}, nil)
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/prediction-distribution", handlers.GetPredictionDistribution)
+
+			req := httptest.NewRequest("GET", "/prediction-distribution"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+				return
+			}
+			var response struct {
+				Buckets []PredictionClassBucket `json:"buckets"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			if assert.Len(t, response.Buckets, 2) {
+				assert.Equal(t, map[string]int{"low": 3}, response.Buckets[1].Classes)
+			}
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHTTPHandlers_GetPredictionFreshness(t *testing.T) {
+	created := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		freshness      []HorizonFreshness
+		dbErr          error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "per horizon",
+			freshness: []HorizonFreshness{
+				{HorizonHours: 1, NewestCreatedAt: created, NewestConfirmedAt: created.Add(2 * time.Hour), NewestPredictionTime: created.Add(3 * time.Hour), Count: 40},
+				{HorizonHours: 6, NewestCreatedAt: created, NewestConfirmedAt: created, NewestPredictionTime: created.Add(6 * time.Hour), Count: 20},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"horizons":[
+				{"horizon_hours":1,"newest_created_at":"2024-07-01T12:00:00Z","newest_confirmed_at":"2024-07-01T14:00:00Z","newest_prediction_time":"2024-07-01T15:00:00Z","count":40},
+				{"horizon_hours":6,"newest_created_at":"2024-07-01T12:00:00Z","newest_confirmed_at":"2024-07-01T12:00:00Z","newest_prediction_time":"2024-07-01T18:00:00Z","count":20}
+			]}`,
+		},
+		{
+			name:           "no predictions",
+			freshness:      nil,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"horizons":[]}`,
+		},
+		{
+			name:           "database error",
+			freshness:      nil,
+			dbErr:          assert.AnError,
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			mockDB.On("GetPredictionFreshness", mock.Anything).Return(tt.freshness, tt.dbErr)
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/predictions/freshness", handlers.GetPredictionFreshness)
+
+			req := httptest.NewRequest("GET", "/predictions/freshness", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeDBError)
+				return
+			}
+			assert.JSONEq(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestHTTPHandlers_GetStationStats(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedLabels []string
+		expectedCounts []int
+	}{
+		{name: "totals only", query: "", expectedStatus: http.StatusOK},
+		{
+			name:           "default buckets",
+			query:          "?histogram=true",
+			expectedStatus: http.StatusOK,
+			expectedLabels: []string{"0-20%", "20-40%", "40-60%", "60-80%", "80-100%"},
+			expectedCounts: []int{4, 0, 2, 0, 1},
+		},
+		{
+			name:           "custom buckets",
+			query:          "?histogram=true&buckets=3",
+			expectedStatus: http.StatusOK,
+			expectedLabels: []string{"0-33.3%", "33.3-66.7%", "66.7-100%"},
+			expectedCounts: []int{4, 0, 2},
+		},
+		{name: "too many buckets", query: "?histogram=true&buckets=50", expectedStatus: http.StatusBadRequest},
+		{name: "bad histogram flag", query: "?histogram=maybe", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			mockDB.On("GetStationStats", mock.Anything).
+				Return(StationStats{StationCount: 7, TotalBikes: 40, TotalDocks: 60, TotalCapacity: 105}, nil).Maybe()
+			mockDB.On("GetUtilizationCounts", mock.Anything, mock.Anything).
+				Return(map[int]int{1: 4, 3: 2, 5: 1}, nil).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/stats", handlers.GetStationStats)
+
+			req := httptest.NewRequest("GET", "/stats"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				StationCount int                 `json:"station_count"`
+				TotalBikes   int                 `json:"total_bikes"`
+				Histogram    []UtilizationBucket `json:"utilization_histogram"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, 7, response.StationCount)
+			assert.Equal(t, 40, response.TotalBikes)
+			if tt.expectedLabels == nil {
+				assert.Nil(t, response.Histogram)
+				mockDB.AssertNotCalled(t, "GetUtilizationCounts", mock.Anything, mock.Anything)
+				return
+			}
+			labels := make([]string, len(response.Histogram))
+			counts := make([]int, len(response.Histogram))
+			for i, bucket := range response.Histogram {
+				labels[i], counts[i] = bucket.Label, bucket.Count
+			}
+			assert.Equal(t, tt.expectedLabels, labels)
+			assert.Equal(t, tt.expectedCounts, counts)
+		})
+	}
+}
+
+func TestSelectModel(t *testing.T) {
+	older := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	predictions := []Prediction{
+		{StationID: "a", HorizonHours: 1, ModelVersion: "v1", CreatedAt: newer, PredictedAvailabilityClass: 0},
+		{StationID: "a", HorizonHours: 1, ModelVersion: "v2", CreatedAt: older, PredictedAvailabilityClass: 2},
+		{StationID: "a", HorizonHours: 6, ModelVersion: "v2", CreatedAt: older},
+		{StationID: "b", HorizonHours: 1, ModelVersion: "v2", CreatedAt: newer},
+	}
+	assert.Equal(t, []string{"v1", "v2"}, availableModels(predictions))
+
+	newest, err := selectModel("", predictions)
+	assert.NoError(t, err)
+	if assert.Len(t, newest, 3) {
+		assert.Equal(t, "v1", newest[0].ModelVersion, "newest per station and horizon across models")
+	}
+
+	v2, err := selectModel("v2", predictions)
+	assert.NoError(t, err)
+	assert.Len(t, v2, 3)
+	for _, p := range v2 {
+		assert.Equal(t, "v2", p.ModelVersion)
+	}
+
+	_, err = selectModel("v3", predictions)
+	assert.ErrorIs(t, err, ErrUnknownModel)
+	assert.ErrorContains(t, err, "[v1 v2]")
+}
+
+func TestNewestAcrossModels_PrefersLastConfirmed(t *testing.T) {
+	created := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	newest := newestAcrossModels([]Prediction{
+		{ID: 1, StationID: "a", HorizonHours: 1, ModelVersion: "v1", CreatedAt: created, LastConfirmedAt: created.Add(2 * time.Hour)},
+		{ID: 2, StationID: "a", HorizonHours: 1, ModelVersion: "v2", CreatedAt: created.Add(time.Hour), LastConfirmedAt: created.Add(time.Hour)},
+	})
+
+	if assert.Len(t, newest, 1) {
+		assert.Equal(t, 1, newest[0].ID, "a reconfirmed prediction is newer than one created later")
+	}
+}
+
+func TestLatestPerStation(t *testing.T) {
+	confirmed := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	latest := latestPerStation([]Prediction{
+		{ID: 1, StationID: "a", LastConfirmedAt: confirmed, CreatedAt: confirmed.Add(-time.Hour)},
+		{ID: 2, StationID: "a", LastConfirmedAt: confirmed, CreatedAt: confirmed},
+		{ID: 3, StationID: "b", LastConfirmedAt: confirmed.Add(time.Hour), CreatedAt: confirmed},
+		{ID: 4, StationID: "b", LastConfirmedAt: confirmed, CreatedAt: confirmed.Add(time.Minute)},
+	})
+
+	if assert.Len(t, latest, 2) {
+		assert.Equal(t, 2, latest[0].ID)
+		assert.Equal(t, 3, latest[1].ID)
+	}
+}
+
+func TestHTTPHandlers_GetStationsJSON_Model(t *testing.T) {
+	tests := []struct {
+		name           string
+		model          string
+		expectedStatus int
+		expectedClass  int
+	}{
+		{name: "selected model", model: "v2", expectedStatus: http.StatusOK, expectedClass: 2},
+		{name: "unknown model", model: "v3", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			mockDB.On("GetStationsWithAvailability", mock.Anything, "").
+				Return([]StationWithAvailability{TestStationWithAvailability}, nil)
+			mockDB.On("GetLatestPredictions", mock.Anything).Return([]Prediction{
+				{StationID: TestStationWithAvailability.StationID, HorizonHours: 1, ModelVersion: "v1", PredictedAvailabilityClass: 0},
+				{StationID: TestStationWithAvailability.StationID, HorizonHours: 1, ModelVersion: "v2", PredictedAvailabilityClass: 2},
+			}, nil)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/stations", handlers.GetStationsJSON)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/stations?mode=predicted&model="+tt.model, nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+				return
+			}
+			var response struct {
+				Predictions []Prediction `json:"predictions"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			if assert.Len(t, response.Predictions, 1) {
+				assert.Equal(t, tt.model, response.Predictions[0].ModelVersion)
+				assert.Equal(t, tt.expectedClass, response.Predictions[0].PredictedAvailabilityClass)
+			}
+		})
+	}
+}
+
+func TestSelectHorizon(t *testing.T) {
+	predictions := []Prediction{{HorizonHours: 6}, {HorizonHours: 1}, {HorizonHours: 6}, {HorizonHours: 3}}
+	horizons := availableHorizons(predictions)
+	assert.Equal(t, []int{1, 3, 6}, horizons)
+
+	tests := []struct {
+		name      string
+		raw       string
+		expected  int
+		expectErr bool
+	}{
+		{name: "defaults to shortest", raw: "", expected: 1},
+		{name: "explicit horizon", raw: "6", expected: 6},
+		{name: "unavailable horizon", raw: "12", expectErr: true},
+		{name: "not a number", raw: "soon", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			horizon, err := selectHorizon(tt.raw, horizons)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, horizon)
+		})
+	}
+}
+
+func TestHTTPHandlers_GetStationsMissingAvailability(t *testing.T) {
+	age := int64(5400)
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+	}{
+		{name: "default window", query: "", expectedStatus: http.StatusOK},
+		{name: "custom window", query: "?minutes=30", expectedStatus: http.StatusOK},
+		{name: "invalid minutes", query: "?minutes=-5", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			mockDB.On("GetStationsMissingAvailability", mock.Anything, mock.AnythingOfType("time.Time")).
+				Return([]MissingStation{
+					{Station: Station{StationID: "never"}},
+					{Station: Station{StationID: "dropped"}, LastRecordAgeSeconds: &age},
+				}, nil).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/missing", handlers.GetStationsMissingAvailability)
+
+			req := httptest.NewRequest("GET", "/missing"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+				return
+			}
+			assert.Contains(t, w.Body.String(), `"last_record_age_seconds":null`)
+			assert.Contains(t, w.Body.String(), `"last_record_age_seconds":5400`)
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHTTPHandlers_GetStationSummary(t *testing.T) {
+	tests := []struct {
+		name                 string
+		query                string
+		stationErr           error
+		predictions          []Prediction
+		expectedStatus       int
+		expectedCode         string
+		expectedPredictions  int
+		expectPredsAvailable bool
+	}{
+		{
+			name:                 "with predictions",
+			predictions:          []Prediction{{StationID: "test-001", HorizonHours: 1}, {StationID: "test-001", HorizonHours: 6}},
+			expectedStatus:       http.StatusOK,
+			expectedPredictions:  2,
+			expectPredsAvailable: true,
+		},
+		{
+			name:  "selected model",
+			query: "?model=v2",
+			predictions: []Prediction{
+				{StationID: "test-001", HorizonHours: 1, ModelVersion: "v1"},
+				{StationID: "test-001", HorizonHours: 1, ModelVersion: "v2"},
+			},
+			expectedStatus:       http.StatusOK,
+			expectedPredictions:  1,
+			expectPredsAvailable: true,
+		},
+		{
+			name:           "unknown model",
+			query:          "?model=v3",
+			predictions:    []Prediction{{StationID: "test-001", HorizonHours: 1, ModelVersion: "v1"}},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   ErrCodeBadRequest,
+		},
+		{
+			name:           "predictions pending",
+			predictions:    []Prediction{},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown station",
+			stationErr:     ErrStationNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   ErrCodeNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			mockDB.On("GetStationWithAvailability", mock.Anything, "test-001").
+				Return(TestStationWithAvailability, tt.stationErr)
+			if tt.stationErr == nil {
+				mockDB.On("GetStationPredictions", mock.Anything, "test-001").Return(tt.predictions, nil)
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/stations/:id/summary", handlers.GetStationSummary)
+
+			req := httptest.NewRequest("GET", "/stations/test-001/summary"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedCode != "" {
+				assertErrorEnvelope(t, w, tt.expectedCode)
+			} else {
+				var response struct {
+					Station              StationWithAvailability `json:"station"`
+					Predictions          []Prediction            `json:"predictions"`
+					PredictionsAvailable bool                    `json:"predictions_available"`
+				}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, "test-001", response.Station.StationID)
+				if assert.Len(t, response.Predictions, tt.expectedPredictions) && tt.query != "" {
+					assert.Equal(t, "v2", response.Predictions[0].ModelVersion)
+				}
+				assert.Equal(t, tt.expectPredsAvailable, response.PredictionsAvailable)
+			}
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHTTPHandlers_CompareAvailability(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	t2 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+	}{
+		{name: "compares snapshots", query: "?t1=2024-01-01T08:00:00Z&t2=2024-01-01T10:00:00Z", expectedStatus: http.StatusOK},
+		{name: "missing t2", query: "?t1=2024-01-01T08:00:00Z", expectedStatus: http.StatusBadRequest},
+		{name: "gap too large", query: "?t1=2024-01-01T08:00:00Z&t2=2024-01-01T10:00:00Z&max_gap_min=1000", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			mockDB.On("GetAvailabilityNear", mock.Anything, t1, defaultCompareMaxGap).Return(map[string]StationAvailability{
+				"a":    {StationID: "a", NumBikesAvailable: 10, NumDocksAvailable: 5},
+				"b":    {StationID: "b", NumBikesAvailable: 2, NumDocksAvailable: 8},
+				"gone": {StationID: "gone", NumBikesAvailable: 1},
+			}, nil).Maybe()
+			mockDB.On("GetAvailabilityNear", mock.Anything, t2, defaultCompareMaxGap).Return(map[string]StationAvailability{
+				"a": {StationID: "a", NumBikesAvailable: 4, NumDocksAvailable: 11},
+				"b": {StationID: "b", NumBikesAvailable: 7, NumDocksAvailable: 3},
+			}, nil).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/compare", handlers.CompareAvailability)
+
+			req := httptest.NewRequest("GET", "/compare"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+				return
+			}
+
+			var response struct {
+				Stations  []AvailabilityDelta `json:"stations"`
+				Totals    AvailabilityTotals  `json:"totals"`
+				Unmatched int                 `json:"unmatched"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Len(t, response.Stations, 2)
+			assert.Equal(t, -6, response.Stations[0].BikesDelta)
+			assert.Equal(t, 5, response.Stations[1].BikesDelta)
+			assert.Equal(t, AvailabilityTotals{
+				BikesBefore: 12, BikesAfter: 11, BikesDelta: -1,
+				DocksBefore: 13, DocksAfter: 14, DocksDelta: 1,
+			}, response.Totals)
+			assert.Equal(t, 1, response.Unmatched)
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHTTPHandlers_SetFeatureFlag(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		ifMatch        string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{name: "valid", body: `{"name":"predicted_mode","enabled":false}`, ifMatch: `"1"`, expectedStatus: http.StatusOK},
+		{name: "stale version", body: `{"name":"predicted_mode","enabled":false}`, ifMatch: `"0"`, expectedStatus: http.StatusPreconditionFailed, expectedCode: ErrCodePreconditionFailed},
+		{name: "missing If-Match", body: `{"name":"predicted_mode","enabled":false}`, expectedStatus: http.StatusPreconditionRequired, expectedCode: ErrCodePreconditionRequired},
+		{name: "invalid If-Match", body: `{"name":"predicted_mode","enabled":false}`, ifMatch: `"abc"`, expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+		{name: "missing enabled", body: `{"name":"predicted_mode"}`, ifMatch: `"1"`, expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+		{name: "unknown flag", body: `{"name":"nope","enabled":true}`, ifMatch: `"1"`, expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			mockDB.On("SetFeatureFlag", mock.Anything, FlagPredictedMode, false, 1).
+				Return(FeatureFlag{Name: FlagPredictedMode, Enabled: false, Version: 2}, nil).Maybe()
+			mockDB.On("SetFeatureFlag", mock.Anything, FlagPredictedMode, false, 0).
+				Return(FeatureFlag{}, ErrFeatureFlagVersionConflict).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.PUT("/flags", handlers.SetFeatureFlag)
+
+			req := httptest.NewRequest("PUT", "/flags", strings.NewReader(tt.body))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedCode != "" {
+				assertErrorEnvelope(t, w, tt.expectedCode)
+			} else {
+				assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+			}
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHTTPHandlers_PredictedModeDisabled(t *testing.T) {
+	mockDB := new(MockDatabase)
+	handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+	mockDB.On("SetFeatureFlag", mock.Anything, FlagPredictedMode, false, 0).
+		Return(FeatureFlag{Name: FlagPredictedMode, Enabled: false, Version: 1}, nil)
+	_, err := handlers.flags.Set(context.Background(), FlagPredictedMode, false, 0)
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stations/json", handlers.GetStationsJSON)
+
+	req := httptest.NewRequest("GET", "/stations/json?mode=predicted", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assertErrorEnvelope(t, w, ErrCodeFeatureDisabled)
+	mockDB.AssertExpectations(t)
+}
+
+func TestHTTPHandlers_GetStationsJSON_Smooth(t *testing.T) {
+	mockDB := new(MockDatabase)
+	config := NewTestConfig()
+	config.ML.SmoothingWindow = 3
+	handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), config)
+
+	latest := Prediction{ID: 3, StationID: "test-001", HorizonHours: 6, PredictedAvailabilityClass: 2, AvailabilityPrediction: "red"}
+	mockDB.On("GetStationsWithAvailability", mock.Anything, "").
+		Return([]StationWithAvailability{TestStationWithAvailability}, nil)
+	mockDB.On("GetLatestPredictions", mock.Anything).Return([]Prediction{latest}, nil)
+	mockDB.On("GetPredictionHistory", mock.Anything, "", 3).Return([]Prediction{
+		latest,
+		{ID: 2, StationID: "test-001", HorizonHours: 6, PredictedAvailabilityClass: 0},
+		{ID: 1, StationID: "test-001", HorizonHours: 6, PredictedAvailabilityClass: 0},
+	}, nil)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stations", handlers.GetStationsJSON)
+
+	req := httptest.NewRequest("GET", "/stations?mode=predicted&smooth=true", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Predictions     []Prediction `json:"predictions"`
+		SmoothingWindow int          `json:"smoothing_window"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 3, response.SmoothingWindow)
+	if assert.Len(t, response.Predictions, 1) {
+		assert.Equal(t, 2, response.Predictions[0].PredictedAvailabilityClass)
+		assert.Equal(t, "yellow", response.Predictions[0].SmoothedAvailabilityPrediction)
+	}
+	mockDB.AssertExpectations(t)
+}
+
+func TestCapacityDiscrepancies(t *testing.T) {
+	station := func(id string, capacity, bikes, docks int, lastReported int64) StationWithAvailability {
+		s := TestStationWithAvailability
+		s.StationID = id
+		s.Capacity = capacity
+		s.NumBikesAvailable = bikes
+		s.NumDocksAvailable = docks
+		s.LastReported = lastReported
+		return s
+	}
+	stations := []StationWithAvailability{
+		station("matches", 15, 5, 10, 1),
+		station("broken-docks", 15, 5, 7, 1),
+		station("over-reported", 10, 8, 4, 1),
+		station("no-status", 15, 0, 0, 0),
+	}
+
+	discrepancies := capacityDiscrepancies(stations, 1)
+	if assert.Len(t, discrepancies, 2) {
+		assert.Equal(t, "broken-docks", discrepancies[0].StationID)
+		assert.Equal(t, 3, discrepancies[0].Gap)
+		assert.Equal(t, 12, discrepancies[0].BikesAndDocks)
+		assert.Equal(t, "over-reported", discrepancies[1].StationID)
+		assert.Equal(t, -2, discrepancies[1].Gap)
+	}
+
+	discrepancies = capacityDiscrepancies(stations, 3)
+	assert.Len(t, discrepancies, 1)
+
+	assert.Empty(t, capacityDiscrepancies(stations, 4))
+}
+
+func TestOperationalSummary(t *testing.T) {
+	station := func(id string, installed, renting, returning int, lastReported int64) StationWithAvailability {
+		s := TestStationWithAvailability
+		s.StationID = id
+		s.IsInstalled = installed
+		s.IsRenting = renting
+		s.IsReturning = returning
+		s.LastReported = lastReported
+		return s
+	}
+
+	summary := operationalSummary([]StationWithAvailability{
+		station("full", 1, 1, 1, 1),
+		station("rent", 1, 1, 0, 1),
+		station("return", 1, 0, 1, 1),
+		station("closed", 1, 0, 0, 1),
+		station("removed", 0, 1, 1, 1),
+		station("no-status", 0, 0, 0, 0),
+	})
+
+	assert.Equal(t, 6, summary.StationCount)
+	assert.Equal(t, map[string]int{
+		OperationalFull:       1,
+		OperationalRentOnly:   1,
+		OperationalReturnOnly: 1,
+		OperationalOffline:    2,
+		OperationalUnknown:    1,
+	}, summary.Counts)
+	assert.Equal(t, []string{"closed", "removed", "rent", "return"}, summary.NonOperationalStations)
+
+	empty := operationalSummary(nil)
+	assert.Equal(t, 0, empty.Counts[OperationalFull])
+	assert.NotNil(t, empty.NonOperationalStations)
+}
+
+func TestHTTPHandlers_GetOperationalSummary(t *testing.T) {
+	tests := []struct {
+		name           string
+		dbErr          error
+		expectedStatus int
+	}{
+		{name: "success", expectedStatus: http.StatusOK},
+		{name: "database error", dbErr: assert.AnError, expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			stations := []StationWithAvailability{TestStationWithAvailability}
+			if tt.dbErr != nil {
+				stations = nil
+			}
+			mockDB.On("GetStationsWithAvailability", mock.Anything, "chicago").Return(stations, tt.dbErr)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/operational-summary", handlers.GetOperationalSummary)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/operational-summary?system=chicago", nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.dbErr != nil {
+				assertErrorEnvelope(t, w, ErrCodeDBError)
+				return
+			}
+			var summary OperationalSummary
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+			assert.Equal(t, 1, summary.StationCount)
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestStationDivergence(t *testing.T) {
+	station := func(id string, capacity, bikes int, lastReported int64) StationWithAvailability {
+		s := TestStationWithAvailability
+		s.StationID = id
+		s.Capacity = capacity
+		s.NumBikesAvailable = bikes
+		s.LastReported = lastReported
+		return s
+	}
+	stations := []StationWithAvailability{
+		station("steady", 10, 8, 1),        // green, predicted green
+		station("emptying", 10, 9, 1),      // green, predicted red
+		station("filling", 10, 4, 1),       // yellow, predicted green
+		station("no-status", 10, 0, 0),     // skipped: never reported
+		station("no-prediction", 10, 0, 1), // skipped: no prediction at horizon
+	}
+	predictions := []Prediction{
+		{StationID: "steady", HorizonHours: 1, PredictedAvailabilityClass: 0},
+		{StationID: "emptying", HorizonHours: 1, PredictedAvailabilityClass: 2},
+		{StationID: "filling", HorizonHours: 1, PredictedAvailabilityClass: 0},
+		{StationID: "no-status", HorizonHours: 1, PredictedAvailabilityClass: 2},
+		{StationID: "no-prediction", HorizonHours: 6, PredictedAvailabilityClass: 0},
+	}
+
+	divergence := stationDivergence(stations, predictions, 1)
+	if assert.Len(t, divergence, 2) {
+		assert.Equal(t, "emptying", divergence[0].StationID)
+		assert.Equal(t, 2, divergence[0].Change)
+		assert.Equal(t, "green", divergence[0].CurrentLabel)
+		assert.Equal(t, "red", divergence[0].PredictedLabel)
+		assert.Equal(t, "filling", divergence[1].StationID)
+		assert.Equal(t, -1, divergence[1].Change)
+	}
+
+	assert.Len(t, stationDivergence(stations, predictions, 6), 1)
+}
+
+func TestHTTPHandlers_GetStationDivergence(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		predictions    []Prediction
+		expectedStatus int
+	}{
+		{name: "default horizon", predictions: []Prediction{{StationID: "1", HorizonHours: 1}}, expectedStatus: http.StatusOK},
+		{name: "unknown horizon", query: "?horizon=12", predictions: []Prediction{{StationID: "1", HorizonHours: 1}}, expectedStatus: http.StatusBadRequest},
+		{name: "no predictions", predictions: []Prediction{}, expectedStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			mockDB.On("GetLatestPredictionsByHorizon", mock.Anything).Return(tt.predictions, nil)
+			mockDB.On("GetStationsWithAvailability", mock.Anything, "").
+				Return([]StationWithAvailability{TestStationWithAvailability}, nil).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/divergence", handlers.GetStationDivergence)
+
+			req := httptest.NewRequest("GET", "/divergence"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var response struct {
+					Stations     []StationDivergence `json:"stations"`
+					HorizonHours int                 `json:"horizon_hours"`
+				}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, 1, response.HorizonHours)
+				assert.NotNil(t, response.Stations)
+			}
+		})
+	}
+}
+
+func TestHTTPHandlers_GetStorageStats(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedDays   int
+		expectedStatus int
+	}{
+		{name: "default days", expectedDays: defaultStorageStatsDays, expectedStatus: http.StatusOK},
+		{name: "custom days", query: "?days=7", expectedDays: 7, expectedStatus: http.StatusOK},
+		{name: "too many days", query: "?days=1000", expectedStatus: http.StatusBadRequest},
+		{name: "invalid days", query: "?days=week", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			today := time.Now().UTC().Truncate(24 * time.Hour)
+			since := today.AddDate(0, 0, 1-tt.expectedDays)
+			if tt.expectedStatus == http.StatusOK {
+				mockDB.On("GetStorageStats", mock.Anything, since).Return([]TableStorageStats{
+					{Table: "station_availability", TotalBytes: 1 << 20, Days: []DailyRowCount{{Day: today, Rows: 1200}}},
+					{Table: "predictions", TotalBytes: 1 << 16, Days: []DailyRowCount{}},
+				}, nil)
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/storage-stats", handlers.GetStorageStats)
+
+			req := httptest.NewRequest("GET", "/storage-stats"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+				return
+			}
+			var response struct {
+				Days   int                 `json:"days"`
+				Tables []TableStorageStats `json:"tables"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedDays, response.Days)
+			if assert.Len(t, response.Tables, 2) {
+				assert.Equal(t, 1200, response.Tables[0].Days[0].Rows)
+			}
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHTTPHandlers_GetStationPredictionHistory(t *testing.T) {
+	created := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	history := []Prediction{
+		{StationID: "1", HorizonHours: 1, PredictedAvailabilityClass: 2, CreatedAt: created},
+		{StationID: "1", HorizonHours: 1, PredictedAvailabilityClass: 1, CreatedAt: created.Add(-2 * time.Hour)},
+		{StationID: "1", HorizonHours: 6, PredictedAvailabilityClass: 0, CreatedAt: created},
+	}
+
+	tests := []struct {
+		name             string
+		query            string
+		history          []Prediction
+		expectedLimit    int
+		expectedStatus   int
+		expectedHorizons []int
+	}{
+		{name: "default limit", history: history, expectedLimit: defaultPredictionHistoryLimit, expectedStatus: http.StatusOK, expectedHorizons: []int{1, 6}},
+		{name: "custom limit", query: "?limit=10", history: history, expectedLimit: 10, expectedStatus: http.StatusOK, expectedHorizons: []int{1, 6}},
+		{name: "no history", history: []Prediction{}, expectedLimit: defaultPredictionHistoryLimit, expectedStatus: http.StatusOK, expectedHorizons: []int{}},
+		{name: "limit too large", query: "?limit=1000", expectedStatus: http.StatusBadRequest},
+		{name: "invalid limit", query: "?limit=all", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			if tt.expectedStatus == http.StatusOK {
+				mockDB.On("GetPredictionHistory", mock.Anything, "1", tt.expectedLimit).Return(tt.history, nil)
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/stations/:id/prediction-history", handlers.GetStationPredictionHistory)
+
+			req := httptest.NewRequest("GET", "/stations/1/prediction-history"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+				return
+			}
+			var response struct {
+				Limit    int                        `json:"limit"`
+				Horizons []HorizonPredictionHistory `json:"horizons"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedLimit, response.Limit)
+			horizons := []int{}
+			for _, h := range response.Horizons {
+				horizons = append(horizons, h.HorizonHours)
+			}
+			assert.Equal(t, tt.expectedHorizons, horizons)
+			if len(response.Horizons) > 0 && assert.Len(t, response.Horizons[0].Predictions, 2) {
+				assert.Equal(t, 2, response.Horizons[0].Predictions[0].PredictedAvailabilityClass)
+			}
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPredictionOutliers(t *testing.T) {
+	neighbors := []StationNeighbors{
+		{StationID: "a", Name: "A", NeighborIDs: []string{"b", "c"}},
+		{StationID: "b", Name: "B", NeighborIDs: []string{"a", "c"}},
+		{StationID: "c", Name: "C", NeighborIDs: []string{"a", "b", "unpredicted"}},
+		{StationID: "lonely", Name: "Lonely", NeighborIDs: []string{"unpredicted"}}, // skipped: no neighbor predictions
+		{StationID: "unpredicted", Name: "U", NeighborIDs: []string{"c"}},           // skipped: no prediction
+	}
+	predictions := []Prediction{
+		{StationID: "a", HorizonHours: 1, PredictedAvailabilityClass: 0},
+		{StationID: "b", HorizonHours: 1, PredictedAvailabilityClass: 0},
+		{StationID: "c", HorizonHours: 1, PredictedAvailabilityClass: 2},
+		{StationID: "lonely", HorizonHours: 1, PredictedAvailabilityClass: 2},
+		{StationID: "unpredicted", HorizonHours: 6, PredictedAvailabilityClass: 0},
+	}
+
+	outliers := predictionOutliers(neighbors, predictions, 1, 1)
+	if assert.Len(t, outliers, 3) {
+		assert.Equal(t, "c", outliers[0].StationID)
+		assert.Equal(t, "red", outliers[0].PredictedLabel)
+		assert.InDelta(t, 0, outliers[0].NeighborAvgClass, 1e-9)
+		assert.InDelta(t, 2, outliers[0].Deviation, 1e-9)
+		assert.Equal(t, 2, outliers[0].NeighborCount)
+		assert.Equal(t, "a", outliers[1].StationID)
+		assert.InDelta(t, 1, outliers[1].Deviation, 1e-9)
+		assert.Equal(t, "b", outliers[2].StationID)
+	}
+
+	assert.Len(t, predictionOutliers(neighbors, predictions, 1, 1.5), 1)
+	assert.Empty(t, predictionOutliers(neighbors, predictions, 6, 0))
+}
+
+func TestStationNeighborCache(t *testing.T) {
+	var loads []float64
+	load := func(_ context.Context, radius float64) ([]StationNeighbors, error) {
+		loads = append(loads, radius)
+		return []StationNeighbors{{StationID: "1", NeighborIDs: []string{"2"}}}, nil
+	}
+	var cache stationNeighborCache
+	first := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	for _, radius := range []float64{500, 500.2, 1000} {
+		neighbors, err := cache.get(ctx, first, radius, load)
+		assert.NoError(t, err)
+		assert.Len(t, neighbors, 1)
+	}
+	assert.Equal(t, []float64{500, 1000}, loads)
+
+	// A new refresh invalidates every radius.
+	_, err := cache.get(ctx, first.Add(15*time.Minute), 500, load)
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{500, 1000, 500}, loads)
+}
+
+func TestHTTPHandlers_GetPredictionOutliers(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		predictions    []Prediction
+		expectedStatus int
+		expectedRadius float64
+	}{
+		{name: "default radius", predictions: []Prediction{{StationID: "1", HorizonHours: 1}}, expectedStatus: http.StatusOK, expectedRadius: defaultOutlierRadiusM},
+		{name: "custom radius", query: "?radius_m=250", predictions: []Prediction{{StationID: "1", HorizonHours: 1}}, expectedStatus: http.StatusOK, expectedRadius: 250},
+		{name: "radius too large", query: "?radius_m=10000", predictions: []Prediction{{StationID: "1", HorizonHours: 1}}, expectedStatus: http.StatusBadRequest},
+		{name: "invalid radius", query: "?radius_m=near", predictions: []Prediction{{StationID: "1", HorizonHours: 1}}, expectedStatus: http.StatusBadRequest},
+		{name: "unknown horizon", query: "?horizon=12", predictions: []Prediction{{StationID: "1", HorizonHours: 1}}, expectedStatus: http.StatusBadRequest},
+		{name: "no predictions", predictions: []Prediction{}, expectedStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			config := NewTestConfig()
+			config.ML.OutlierMinDeviation = 1
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), config)
+			mockDB.On("GetLatestPredictionsByHorizon", mock.Anything).Return(tt.predictions, nil).Maybe()
+			mockDB.On("GetStationNeighbors", mock.Anything, tt.expectedRadius).
+				Return([]StationNeighbors{{StationID: "1", NeighborIDs: []string{"2"}}}, nil).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/outliers", handlers.GetPredictionOutliers)
+
+			req := httptest.NewRequest("GET", "/outliers"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var response struct {
+					Stations     []PredictionOutlier `json:"stations"`
+					RadiusM      float64             `json:"radius_m"`
+					MinDeviation float64             `json:"min_deviation"`
+				}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, tt.expectedRadius, response.RadiusM)
+				assert.Equal(t, 1.0, response.MinDeviation)
+				assert.NotNil(t, response.Stations)
+				mockDB.AssertCalled(t, "GetStationNeighbors", mock.Anything, tt.expectedRadius)
+			}
+		})
+	}
+}
+
+func TestStationForecasts(t *testing.T) {
+	stations := []StationWithAvailability{{Station: Station{StationID: "1"}}, {Station: Station{StationID: "2"}}}
+	predictions := []Prediction{
+		{StationID: "1", HorizonHours: 1, PredictedAvailabilityClass: 2},
+		{StationID: "1", HorizonHours: 6, PredictedAvailabilityClass: 0},
+		{StationID: "2", HorizonHours: 6, PredictedAvailabilityClass: 1},
+	}
+
+	forecasts := stationForecasts([]string{"2", "unknown", "1"}, stations, predictions, 1)
+	assert.Len(t, forecasts, 3)
+	assert.Equal(t, "2", forecasts[0].StationID)
+	assert.NotNil(t, forecasts[0].Current)
+	assert.Nil(t, forecasts[0].Forecast)
+	assert.Nil(t, forecasts[1].Current)
+	assert.Nil(t, forecasts[1].Forecast)
+	assert.Equal(t, 2, forecasts[2].Forecast.PredictedAvailabilityClass)
+}
+
+func TestHTTPHandlers_GetStationsBatch(t *testing.T) {
+	tooMany := make([]string, maxBatchStationIDs+1)
+	for i := range tooMany {
+		tooMany[i] = strconv.Itoa(i)
+	}
+	manyIDs, _ := json.Marshal(map[string]interface{}{"ids": tooMany})
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedIDs    []string
+	}{
+		{name: "default horizon", body: `{"ids": ["1", "2", "1"]}`, expectedStatus: http.StatusOK, expectedIDs: []string{"1", "2"}},
+		{name: "requested horizon", body: `{"ids": ["1"], "horizon": 1}`, expectedStatus: http.StatusOK, expectedIDs: []string{"1"}},
+		{name: "unknown horizon", body: `{"ids": ["1"], "horizon": 12}`, expectedStatus: http.StatusBadRequest},
+		{name: "no ids", body: `{"ids": []}`, expectedStatus: http.StatusBadRequest},
+		{name: "malformed body", body: `{"ids": "1"}`, expectedStatus: http.StatusBadRequest},
+		{name: "too many ids", body: string(manyIDs), expectedStatus: http.StatusBadRequest},
+		{name: "body too large", body: `{"ids": ["` + strings.Repeat("x", maxBatchBodyBytes) + `"]}`, expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			mockDB.On("GetLatestPredictionsByHorizon", mock.Anything).
+				Return([]Prediction{{StationID: "1", HorizonHours: 1}}, nil).Maybe()
+			mockDB.On("GetStationsByIDs", mock.Anything, tt.expectedIDs).
+				Return([]StationWithAvailability{TestStationWithAvailability}, nil).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.POST("/batch", handlers.GetStationsBatch)
+
+			req := httptest.NewRequest("POST", "/batch", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+				return
+			}
+			var response struct {
+				Stations     []StationForecast `json:"stations"`
+				HorizonHours int               `json:"horizon_hours"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, 1, response.HorizonHours)
+			assert.Len(t, response.Stations, len(tt.expectedIDs))
 			mockDB.AssertExpectations(t)
 		})
 	}
@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+const (
+	defaultLocale    = "en"
+	localeContextKey = "locale"
+)
+
+// translations holds the UI strings for each supported locale. Keys missing
+// from a locale fall back to defaultLocale, then to the key itself.
+var translations = map[string]map[string]string{
+	"en": {
+		"title":                "Divvy Bike Availability",
+		"subtitle":             "Live bike availability across Chicago",
+		"current_availability": "Current Availability",
+		"prediction_6h":        "6h Prediction",
+		"popup_bikes":          "bikes",
+		"popup_docks":          "docks",
+		"popup_prediction_in":  "In",
+		"popup_prediction_at":  "at",
+		"last_updated":         "Last updated",
+	},
+	"es": {
+		"title":                "Disponibilidad de bicicletas Divvy",
+		"subtitle":             "Disponibilidad de bicicletas en tiempo real en Chicago",
+		"current_availability": "Disponibilidad actual",
+		"prediction_6h":        "Predicción a 6 h",
+		"popup_bikes":          "bicicletas",
+		"popup_docks":          "anclajes",
+		"popup_prediction_in":  "En",
+		"popup_prediction_at":  "a las",
+		"last_updated":         "Última actualización",
+	},
+}
+
+// supportedLocales lists the translations in matcher order; the first is
+// the default.
+var supportedLocales = []string{defaultLocale, "es"}
+
+var localeMatcher = func() language.Matcher {
+	tags := make([]language.Tag, len(supportedLocales))
+	for i, locale := range supportedLocales {
+		tags[i] = language.Make(locale)
+	}
+	return language.NewMatcher(tags)
+}()
+
+// translate looks up key for locale with per-key fallback to defaultLocale.
+// It is registered as the "translate" template function.
+func translate(locale, key string) string {
+	if s, ok := translations[locale][key]; ok {
+		return s
+	}
+	if s, ok := translations[defaultLocale][key]; ok {
+		return s
+	}
+	return key
+}
+
+// matchLocale picks the supported locale for a ?lang= value or, failing
+// that, an Accept-Language header, e.g. "es-MX,es;q=0.9,en;q=0.8" selects es.
+func matchLocale(lang, acceptLanguage string) string {
+	for _, preference := range []string{lang, acceptLanguage} {
+		if preference == "" {
+			continue
+		}
+		tags, _, err := language.ParseAcceptLanguage(preference)
+		if err != nil || len(tags) == 0 {
+			continue
+		}
+		if _, index, confidence := localeMatcher.Match(tags...); confidence != language.No {
+			return supportedLocales[index]
+		}
+	}
+	return defaultLocale
+}
+
+// Locale selects the response locale for HTML pages from ?lang= or the
+// Accept-Language header and stores it for localeFrom.
+func Locale() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		locale := matchLocale(c.Query("lang"), c.GetHeader("Accept-Language"))
+		c.Set(localeContextKey, locale)
+		c.Header("Content-Language", locale)
+		c.Writer.Header().Add("Vary", "Accept-Language")
+		c.Next()
+	}
+}
+
+// localeFrom returns the locale chosen by Locale, or defaultLocale when the
+// middleware did not run.
+func localeFrom(c *gin.Context) string {
+	if locale := c.GetString(localeContextKey); locale != "" {
+		return locale
+	}
+	return defaultLocale
+}
+
+// localizedTemplateData adds the request's locale to template data for the
+// translate function ({{translate .locale "key"}}).
+func localizedTemplateData(c *gin.Context, data gin.H) gin.H {
+	data["locale"] = localeFrom(c)
+	return data
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchLocale(t *testing.T) {
+	tests := []struct {
+		name, lang, acceptLanguage, expected string
+	}{
+		{"no preference", "", "", "en"},
+		{"query parameter", "es", "", "es"},
+		{"regional header", "", "es-MX,es;q=0.9,en;q=0.8", "es"},
+		{"header quality order", "", "fr;q=0.9,en;q=0.8,es;q=0.1", "en"},
+		{"unsupported language", "", "fr", "en"},
+		{"query overrides header", "en", "es", "en"},
+		{"unsupported query falls back to header", "fr", "es", "es"},
+		{"malformed header", "", ";;;", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchLocale(tt.lang, tt.acceptLanguage))
+		})
+	}
+}
+
+func TestTranslate_Fallback(t *testing.T) {
+	translations["en"]["only_en"] = "English only"
+	t.Cleanup(func() { delete(translations["en"], "only_en") })
+
+	assert.Equal(t, "Disponibilidad actual", translate("es", "current_availability"))
+	assert.Equal(t, "English only", translate("es", "only_en"))
+	assert.Equal(t, "Current Availability", translate("fr", "current_availability"))
+	assert.Equal(t, "unknown_key", translate("es", "unknown_key"))
+}
+
+func TestLocale_Templates(t *testing.T) {
+	dir := t.TempDir()
+	page := `<html lang="{{.locale}}">{{translate .locale "current_availability"}}|{{translate .locale "prediction_6h"}}</html>`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "page.html"), []byte(page), 0o644))
+	templates := newTemplateSet(filepath.Join(dir, "*"))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.HTMLRender = templates
+	// Vary from earlier middleware, e.g. gzip's Accept-Encoding, is kept.
+	keepVary := func(c *gin.Context) { c.Header("Vary", "Accept-Encoding") }
+	router.GET("/page", templates.RequireTemplates(), keepVary, Locale(), func(c *gin.Context) {
+		c.HTML(http.StatusOK, "page.html", localizedTemplateData(c, gin.H{}))
+	})
+
+	req := httptest.NewRequest("GET", "/page", nil)
+	req.Header.Set("Accept-Language", "es-ES,es;q=0.9")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `<html lang="es">Disponibilidad actual|Predicción a 6 h</html>`, w.Body.String())
+	assert.Equal(t, "es", w.Header().Get("Content-Language"))
+	assert.Equal(t, []string{"Accept-Encoding", "Accept-Language"}, w.Header().Values("Vary"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/page?lang=en", nil))
+	assert.Equal(t, `<html lang="en">Current Availability|6h Prediction</html>`, w.Body.String())
+}
+
+func TestLocale_StationsTemplate(t *testing.T) {
+	templates := newTemplateSet(filepath.Join("..", "templates", "*.html"))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.HTMLRender = templates
+	router.GET("/stations", templates.RequireTemplates(), Locale(), func(c *gin.Context) {
+		c.HTML(http.StatusOK, "stations.html", localizedTemplateData(c, gin.H{
+			"stations":       []StationWithAvailability{},
+			"predictionsMap": map[string]Prediction{},
+			"thresholds":     AvailabilityThresholds{Low: 3, Medium: 8},
+		}))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stations?lang=es", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `data-bikes="bicicletas"`)
+	assert.Contains(t, w.Body.String(), `data-prediction-at="a las"`)
+}
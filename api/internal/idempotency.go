@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxIdempotencyKeyLength bounds Idempotency-Key so clients cannot grow the
+// store with arbitrarily large keys.
+const maxIdempotencyKeyLength = 255
+
+const (
+	// maxIdempotencyEntries caps the store; the least recently used
+	// responses are evicted first. POST /api/refresh is public, so without
+	// a cap clients could grow it without bound.
+	maxIdempotencyEntries = 10000
+	// idempotencySweepInterval is how often Start drops expired responses.
+	idempotencySweepInterval = time.Minute
+)
+
+// IdempotencyStore holds, in memory, the responses to requests that carried
+// an Idempotency-Key, so a retried refresh or inference is answered with the
+// first response instead of running again. Responses expire ttl after they
+// were recorded, and at most maxEntries are kept.
+type IdempotencyStore struct {
+	ttl        time.Duration
+	maxEntries int
+	mu         sync.Mutex
+	entries    map[string]*idempotentResponse
+	// lru orders entries by last use, most recent at the front.
+	lru *list.List
+	now func() time.Time
+}
+
+// idempotentResponse is the response to the first request with a key. done
+// is closed once the request finished; stored reports whether the response
+// was kept for replay.
+type idempotentResponse struct {
+	key         string
+	element     *list.Element
+	done        chan struct{}
+	stored      bool
+	expires     time.Time
+	status      int
+	contentType string
+	body        []byte
+}
+
+// NewIdempotencyStore returns a store keeping responses for ttl, or nil when
+// ttl is not positive.
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	if ttl <= 0 {
+		return nil
+	}
+	return &IdempotencyStore{
+		ttl:        ttl,
+		maxEntries: maxIdempotencyEntries,
+		entries:    make(map[string]*idempotentResponse),
+		lru:        list.New(),
+		now:        time.Now,
+	}
+}
+
+// Start drops expired responses every idempotencySweepInterval until ctx is
+// done. A nil store does nothing.
+func (s *IdempotencyStore) Start(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(idempotencySweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.sweep()
+			}
+		}
+	}()
+}
+
+// sweep drops every expired response.
+func (s *IdempotencyStore) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for _, entry := range s.entries {
+		if entry.stored && now.After(entry.expires) {
+			s.remove(entry)
+		}
+	}
+}
+
+// claim returns the entry for key, creating it when there is none. The
+// caller that created it must run the request and call finish.
+func (s *IdempotencyStore) claim(key string) (*idempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[key]; ok {
+		if !entry.stored || !s.now().After(entry.expires) {
+			s.lru.MoveToFront(entry.element)
+			return entry, false
+		}
+		s.remove(entry)
+	}
+	entry := &idempotentResponse{key: key, done: make(chan struct{})}
+	entry.element = s.lru.PushFront(entry)
+	s.entries[key] = entry
+	s.evict()
+	return entry, true
+}
+
+// evict drops the least recently used stored responses while the store is
+// over maxEntries. Requests still running are never evicted, as their
+// retries are waiting on them.
+func (s *IdempotencyStore) evict() {
+	for element := s.lru.Back(); element != nil && len(s.entries) > s.maxEntries; {
+		entry := element.Value.(*idempotentResponse)
+		element = element.Prev()
+		if entry.stored {
+			s.remove(entry)
+		}
+	}
+}
+
+func (s *IdempotencyStore) remove(entry *idempotentResponse) {
+	s.lru.Remove(entry.element)
+	delete(s.entries, entry.key)
+}
+
+// finish records the response for key. Server errors and requests that
+// never finished (w is nil) are forgotten, so a retry runs the operation
+// again.
+func (s *IdempotencyStore) finish(entry *idempotentResponse, w *recordingWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w != nil && w.Status() < http.StatusInternalServerError {
+		entry.stored = true
+		entry.expires = s.now().Add(s.ttl)
+		entry.status = w.Status()
+		entry.contentType = w.Header().Get("Content-Type")
+		entry.body = w.buf.Bytes()
+	} else {
+		s.remove(entry)
+	}
+	close(entry.done)
+	s.evict()
+}
+
+// Idempotency makes a mutating route safe to retry: the first request with
+// an Idempotency-Key header runs, and later requests with the same key get
+// its response, marked with Idempotent-Replayed, without running again. A
+// request arriving while the first is still running waits for it. Keys are
+// scoped to the method and route. Requests without the header, and every
+// request when store is nil, run normally.
+func Idempotency(store *IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if store == nil || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "Idempotency-Key is too long")
+			return
+		}
+		scoped := c.Request.Method + " " + c.FullPath() + " " + key
+
+		for {
+			entry, owner := store.claim(scoped)
+			if owner {
+				runIdempotent(c, store, entry)
+				return
+			}
+			select {
+			case <-entry.done:
+			case <-c.Request.Context().Done():
+				respondError(c, http.StatusServiceUnavailable, ErrCodeTimeout, "Timed out waiting for the request with this Idempotency-Key")
+				return
+			}
+			if entry.stored {
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(entry.status, entry.contentType, entry.body)
+				c.Abort()
+				return
+			}
+			// The first request failed and was forgotten; run it again.
+		}
+	}
+}
+
+func runIdempotent(c *gin.Context, store *IdempotencyStore, entry *idempotentResponse) {
+	var finished *recordingWriter
+	defer func() { store.finish(entry, finished) }()
+
+	writer := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+	c.Writer = writer.ResponseWriter
+	finished = writer
+}
+
+// recordingWriter passes the response through while keeping a copy of its
+// body for replay.
+type recordingWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.buf.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.buf.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newIdempotentRouter(store *IdempotencyStore, status func() int, runs *atomic.Int32) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/refresh", Idempotency(store), func(c *gin.Context) {
+		n := runs.Add(1)
+		c.JSON(status(), gin.H{"run": n})
+	})
+	router.POST("/inference", Idempotency(store), func(c *gin.Context) {
+		runs.Add(1)
+		c.JSON(http.StatusOK, gin.H{"message": "Inference completed"})
+	})
+	return router
+}
+
+func postWithKey(router *gin.Engine, path, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, nil)
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency(t *testing.T) {
+	ok := func() int { return http.StatusOK }
+
+	t.Run("replays the first response", func(t *testing.T) {
+		var runs atomic.Int32
+		router := newIdempotentRouter(NewIdempotencyStore(time.Hour), ok, &runs)
+
+		first := postWithKey(router, "/refresh", "abc")
+		second := postWithKey(router, "/refresh", "abc")
+
+		assert.Equal(t, int32(1), runs.Load())
+		assert.Equal(t, http.StatusOK, second.Code)
+		assert.JSONEq(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, "application/json; charset=utf-8", second.Header().Get("Content-Type"))
+		assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	})
+
+	t.Run("keys are scoped to the route", func(t *testing.T) {
+		var runs atomic.Int32
+		router := newIdempotentRouter(NewIdempotencyStore(time.Hour), ok, &runs)
+
+		postWithKey(router, "/refresh", "abc")
+		postWithKey(router, "/refresh", "def")
+		w := postWithKey(router, "/inference", "abc")
+
+		assert.Equal(t, int32(3), runs.Load())
+		assert.JSONEq(t, `{"message":"Inference completed"}`, w.Body.String())
+	})
+
+	t.Run("requests without a key always run", func(t *testing.T) {
+		var runs atomic.Int32
+		router := newIdempotentRouter(NewIdempotencyStore(time.Hour), ok, &runs)
+
+		postWithKey(router, "/refresh", "")
+		postWithKey(router, "/refresh", "")
+
+		assert.Equal(t, int32(2), runs.Load())
+	})
+
+	t.Run("server errors are not replayed", func(t *testing.T) {
+		var runs atomic.Int32
+		statuses := []int{http.StatusInternalServerError, http.StatusOK}
+		status := func() int { return statuses[runs.Load()-1] }
+		router := newIdempotentRouter(NewIdempotencyStore(time.Hour), status, &runs)
+
+		assert.Equal(t, http.StatusInternalServerError, postWithKey(router, "/refresh", "abc").Code)
+		assert.Equal(t, http.StatusOK, postWithKey(router, "/refresh", "abc").Code)
+		assert.Equal(t, http.StatusOK, postWithKey(router, "/refresh", "abc").Code)
+		assert.Equal(t, int32(2), runs.Load())
+	})
+
+	t.Run("responses expire after the ttl", func(t *testing.T) {
+		var runs atomic.Int32
+		store := NewIdempotencyStore(time.Minute)
+		now := time.Now()
+		store.now = func() time.Time { return now }
+		router := newIdempotentRouter(store, ok, &runs)
+
+		postWithKey(router, "/refresh", "abc")
+		now = now.Add(30 * time.Second)
+		postWithKey(router, "/refresh", "abc")
+		assert.Equal(t, int32(1), runs.Load())
+
+		now = now.Add(time.Minute)
+		postWithKey(router, "/refresh", "abc")
+		assert.Equal(t, int32(2), runs.Load())
+	})
+
+	t.Run("concurrent retries wait for the first request", func(t *testing.T) {
+		var runs atomic.Int32
+		started := make(chan struct{})
+		release := make(chan struct{})
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST("/refresh", Idempotency(NewIdempotencyStore(time.Hour)), func(c *gin.Context) {
+			runs.Add(1)
+			close(started)
+			<-release
+			c.JSON(http.StatusOK, gin.H{"message": "Station data refreshed successfully"})
+		})
+
+		var wg sync.WaitGroup
+		responses := make([]*httptest.ResponseRecorder, 3)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[0] = postWithKey(router, "/refresh", "abc")
+		}()
+		<-started
+		for i := 1; i < len(responses); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				responses[i] = postWithKey(router, "/refresh", "abc")
+			}()
+		}
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), runs.Load())
+		for _, w := range responses {
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `{"message":"Station data refreshed successfully"}`, w.Body.String())
+		}
+	})
+
+	t.Run("least recently used responses are evicted", func(t *testing.T) {
+		var runs atomic.Int32
+		store := NewIdempotencyStore(time.Hour)
+		store.maxEntries = 2
+		router := newIdempotentRouter(store, ok, &runs)
+
+		postWithKey(router, "/refresh", "a")
+		postWithKey(router, "/refresh", "b")
+		postWithKey(router, "/refresh", "a")
+		postWithKey(router, "/refresh", "c")
+		assert.Equal(t, int32(3), runs.Load())
+		assert.Len(t, store.entries, 2)
+
+		// b was used least recently and is gone; a is still replayed.
+		postWithKey(router, "/refresh", "a")
+		assert.Equal(t, int32(3), runs.Load())
+		postWithKey(router, "/refresh", "b")
+		assert.Equal(t, int32(4), runs.Load())
+	})
+
+	t.Run("sweep drops expired responses", func(t *testing.T) {
+		var runs atomic.Int32
+		store := NewIdempotencyStore(time.Minute)
+		now := time.Now()
+		store.now = func() time.Time { return now }
+		router := newIdempotentRouter(store, ok, &runs)
+
+		postWithKey(router, "/refresh", "a")
+		now = now.Add(30 * time.Second)
+		postWithKey(router, "/refresh", "b")
+		now = now.Add(45 * time.Second)
+		store.sweep()
+
+		assert.Len(t, store.entries, 1)
+		assert.Equal(t, 1, store.lru.Len())
+	})
+
+	t.Run("overlong key", func(t *testing.T) {
+		var runs atomic.Int32
+		router := newIdempotentRouter(NewIdempotencyStore(time.Hour), ok, &runs)
+
+		w := postWithKey(router, "/refresh", strings.Repeat("k", maxIdempotencyKeyLength+1))
+
+		assertErrorEnvelope(t, w, ErrCodeBadRequest)
+		assert.Zero(t, runs.Load())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var runs atomic.Int32
+		router := newIdempotentRouter(NewIdempotencyStore(0), ok, &runs)
+
+		postWithKey(router, "/refresh", "abc")
+		postWithKey(router, "/refresh", "abc")
+
+		assert.Equal(t, int32(2), runs.Load())
+	})
+}
@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// importBatchSize is the number of records committed per transaction during
+// a bulk import.
+const importBatchSize = 1000
+
+// ImportResult summarizes a bulk availability import.
+type ImportResult struct {
+	Inserted int `json:"inserted"`
+	Skipped  int `json:"skipped"`
+}
+
+// decodeAvailabilityStream reads either a JSON array or NDJSON of
+// StationAvailability records from r without buffering the whole body,
+// handing them to fn in batches of at most batchSize.
+func decodeAvailabilityStream(r io.Reader, batchSize int, fn func([]StationAvailability) error) error {
+	reader := bufio.NewReader(r)
+	isArray, err := startsWithArray(reader)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(reader)
+	if isArray {
+		if _, err := decoder.Token(); err != nil {
+			return fmt.Errorf("read array start: %w", err)
+		}
+	}
+
+	batch := make([]StationAvailability, 0, batchSize)
+	for record := 1; ; record++ {
+		if isArray && !decoder.More() {
+			break
+		}
+
+		var availability StationAvailability
+		if err := decoder.Decode(&availability); err != nil {
+			if errors.Is(err, io.EOF) && !isArray {
+				break
+			}
+			return fmt.Errorf("record %d: %w", record, err)
+		}
+		if err := availability.Validate(); err != nil {
+			return fmt.Errorf("record %d: %w", record, err)
+		}
+
+		batch = append(batch, availability)
+		if len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// startsWithArray peeks past leading whitespace to tell a JSON array from
+// NDJSON.
+func startsWithArray(reader *bufio.Reader) (bool, error) {
+	for {
+		b, err := reader.Peek(1)
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			reader.ReadByte()
+		default:
+			return b[0] == '[', nil
+		}
+	}
+}
+
+// ImportAvailability bulk-loads historical availability from a JSON array or
+// NDJSON body, skipping rows for unknown stations. Batches are committed as
+// they are read, so on a malformed record the counts so far are reported
+// alongside the error.
+func (h *HTTPHandlers) ImportAvailability(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var result ImportResult
+	err := decodeAvailabilityStream(c.Request.Body, importBatchSize, func(batch []StationAvailability) error {
+		inserted, err := h.database.ImportAvailabilities(ctx, batch)
+		if err != nil {
+			return &importStoreError{err}
+		}
+		result.Inserted += inserted
+		result.Skipped += len(batch) - inserted
+		return nil
+	})
+
+	var storeErr *importStoreError
+	switch {
+	case errors.As(err, &storeErr):
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to import availability", err)
+		return
+	case err != nil:
+		log.Printf("Availability import stopped after %d inserted, %d skipped: %v", result.Inserted, result.Skipped, err)
+		respondErrorDetails(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error(), result)
+		return
+	}
+
+	log.Printf("Imported %d availability records, skipped %d for unknown stations", result.Inserted, result.Skipped)
+	c.JSON(http.StatusOK, result)
+}
+
+// importStoreError separates database failures from malformed input.
+type importStoreError struct {
+	err error
+}
+
+func (e *importStoreError) Error() string { return e.err.Error() }
+func (e *importStoreError) Unwrap() error { return e.err }
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDecodeAvailabilityStream(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		expectedBatches []int
+		expectErr       bool
+	}{
+		{
+			name:            "json array",
+			body:            ` [{"station_id": "a"}, {"station_id": "b"}, {"station_id": "c"}]`,
+			expectedBatches: []int{2, 1},
+		},
+		{
+			name:            "ndjson",
+			body:            "{\"station_id\": \"a\"}\n{\"station_id\": \"b\"}\n",
+			expectedBatches: []int{2},
+		},
+		{
+			name:            "empty body",
+			body:            "",
+			expectedBatches: nil,
+		},
+		{
+			name:            "malformed record",
+			body:            "{\"station_id\": \"a\"}\n{\"station_id\": \n",
+			expectedBatches: nil,
+			expectErr:       true,
+		},
+		{
+			name:            "invalid record",
+			body:            `[{"station_id": ""}]`,
+			expectedBatches: nil,
+			expectErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var batches []int
+			err := decodeAvailabilityStream(strings.NewReader(tt.body), 2, func(batch []StationAvailability) error {
+				batches = append(batches, len(batch))
+				return nil
+			})
+
+			if tt.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedBatches, batches)
+		})
+	}
+}
+
+func TestHTTPHandlers_ImportAvailability(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		importErr      error
+		expectedStatus int
+		expected       ImportResult
+	}{
+		{
+			name:           "counts skipped stations",
+			body:           "{\"station_id\": \"known\"}\n{\"station_id\": \"unknown\"}\n",
+			expectedStatus: http.StatusOK,
+			expected:       ImportResult{Inserted: 1, Skipped: 1},
+		},
+		{
+			name:           "database error",
+			body:           `[{"station_id": "known"}]`,
+			importErr:      assert.AnError,
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			mockDB.On("ImportAvailabilities", mock.Anything, mock.Anything).Return(tt.expected.Inserted, tt.importErr)
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.POST("/import", handlers.ImportAvailability)
+
+			req := httptest.NewRequest("POST", "/import", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				var result ImportResult
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+				assert.Equal(t, tt.expected, result)
+			} else {
+				assertErrorEnvelope(t, w, ErrCodeDBError)
+			}
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHTTPHandlers_ImportAvailability_Malformed(t *testing.T) {
+	mockDB := new(MockDatabase)
+	handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+	mockDB.On("ImportAvailabilities", mock.Anything, mock.Anything).Return(1, nil).Once()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/import", handlers.ImportAvailability)
+
+	// The first batch is committed before the malformed record is read.
+	body := "{\"station_id\": \"a\"}\n"
+	body = strings.Repeat(body, importBatchSize) + "{\"station_id\": \n"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/import", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assertErrorEnvelope(t, w, ErrCodeBadRequest)
+	var response struct {
+		Error struct {
+			Details ImportResult `json:"details"`
+		} `json:"error"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, ImportResult{Inserted: 1, Skipped: importBatchSize - 1}, response.Error.Details)
+	mockDB.AssertExpectations(t)
+}
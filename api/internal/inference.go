@@ -1,34 +1,98 @@
 package internal
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"slices"
+	"sync/atomic"
+	"syscall"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// MLPrediction is a single prediction as returned by the ML service.
+// Confidence is optional; nil means the service did not send one.
+type MLPrediction struct {
+	StationID                  string   `json:"station_id"`
+	PredictedAvailabilityClass int      `json:"predicted_availability_class"`
+	PredictionTime             string   `json:"prediction_time"`
+	HorizonHours               int      `json:"horizon_hours"`
+	AvailabilityPrediction     string   `json:"availability_prediction"`
+	Confidence                 *float64 `json:"confidence"`
+	ModelVersion               string   `json:"model_version"`
+}
+
 type PredictionResponse struct {
-	Predictions []struct {
-		StationID                  string `json:"station_id"`
-		PredictedAvailabilityClass int    `json:"predicted_availability_class"`
-		PredictionTime             string `json:"prediction_time"`
-		HorizonHours               int    `json:"horizon_hours"`
-		AvailabilityPrediction     string `json:"availability_prediction"`
-	} `json:"predictions"`
+	Predictions []MLPrediction `json:"predictions"`
 	Count     int    `json:"count"`
 	Timestamp string `json:"timestamp"`
+	Cached    bool   `json:"cached"`
+}
+
+// maxLoggedBodyBytes bounds the response snippet logged on decode failures.
+const maxLoggedBodyBytes = 512
+
+// decodePredictionResponse decodes an ML /predict body, distinguishing an
+// empty body, malformed JSON and valid JSON of the wrong shape. In strict mode
+// unknown fields are rejected so contract drift surfaces early.
+func decodePredictionResponse(body []byte, strict bool) (*PredictionResponse, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, errors.New("empty response body")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+
+	var resp PredictionResponse
+	if err := decoder.Decode(&resp); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+			return nil, fmt.Errorf("malformed JSON (%d bytes): %w", len(body), err)
+		case errors.As(err, &typeErr):
+			return nil, fmt.Errorf("unexpected shape at %q, got JSON %s want %s: %w", typeErr.Field, typeErr.Value, typeErr.Type, err)
+		default:
+			return nil, fmt.Errorf("unexpected shape: %w", err)
+		}
+	}
+	return &resp, nil
 }
 
+func truncateBody(body []byte) string {
+	if len(body) <= maxLoggedBodyBytes {
+		return string(body)
+	}
+	return string(body[:maxLoggedBodyBytes]) + "...(truncated)"
+}
+
+var (
+	// ErrNoPredictions is an empty prediction set, which the ML service may
+	// legitimately return, e.g. with nothing to predict overnight.
+	ErrNoPredictions = errors.New("no predictions in response")
+	// ErrCountMismatch is a response whose count disagrees with the
+	// predictions it carries, i.e. a truncated or corrupt response.
+	ErrCountMismatch = errors.New("prediction count mismatch")
+)
+
+// Validate checks a decoded ML response. Empty and count-mismatched
+// responses wrap ErrNoPredictions and ErrCountMismatch.
 func (p *PredictionResponse) Validate() error {
 	if len(p.Predictions) == 0 {
-		return errors.New("no predictions in response")
+		return ErrNoPredictions
 	}
 	if p.Count != len(p.Predictions) {
-		return errors.New("prediction count mismatch")
+		return fmt.Errorf("%w: count is %d but %d predictions were sent", ErrCountMismatch, p.Count, len(p.Predictions))
 	}
 	for i, pred := range p.Predictions {
 		if pred.StationID == "" {
@@ -37,43 +101,151 @@ func (p *PredictionResponse) Validate() error {
 		if pred.PredictionTime == "" {
 			return fmt.Errorf("prediction %d missing prediction time", i)
 		}
+		if c := pred.Confidence; c != nil && !(*c >= 0 && *c <= 1) {
+			return fmt.Errorf("prediction %d confidence %v outside [0, 1]", i, *c)
+		}
 	}
 	return nil
 }
 
 type MLService struct {
-	client  *http.Client
-	baseURL string
+	client         *http.Client
+	baseURL        string
+	retryAttempts  int
+	retryBaseDelay time.Duration
+	strictDecode   bool
 }
 
 func NewMLService(config *Config) *MLService {
 	return &MLService{
-		client: &http.Client{
-			Timeout: time.Duration(config.ML.RequestTimeoutMin) * time.Minute,
-		},
-		baseURL: config.ML.ServiceURL,
+		client:         newHTTPClient(config, time.Duration(config.ML.RequestTimeoutMin)*time.Minute),
+		baseURL:        config.ML.ServiceURL,
+		retryAttempts:  config.ML.PredictRetryAttempts,
+		retryBaseDelay: time.Duration(config.ML.PredictRetryBaseDelayMs) * time.Millisecond,
+		strictDecode:   config.ML.StrictDecode,
+	}
+}
+
+// retryableError marks failures worth retrying: connection errors and 5xx.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// PredictRequest is the /predict body sent when availability is pushed to
+// the ML service instead of read by it from the database.
+type PredictRequest struct {
+	Stations []StationWithAvailability `json:"stations"`
+	Count    int                       `json:"count"`
+}
+
+// encodePredictRequest serializes stations for /predict. A nil slice means
+// the ML service pulls availability itself, so no body is sent.
+func encodePredictRequest(stations []StationWithAvailability) ([]byte, error) {
+	if stations == nil {
+		return nil, nil
+	}
+	body, err := json.Marshal(PredictRequest{Stations: stations, Count: len(stations)})
+	if err != nil {
+		return nil, fmt.Errorf("encode request: %w", err)
 	}
+	return body, nil
 }
 
-func (m *MLService) GetPredictions(ctx context.Context) (*PredictionResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, "POST", m.baseURL+"/predict", nil)
+// GetPredictions calls /predict, retrying connection errors and 5xx responses
+// with exponential backoff. Invalid 200 responses are not retried since they
+// indicate a data problem rather than a transient one. When stations is
+// non-nil it is sent as the request body; otherwise the body is empty.
+func (m *MLService) GetPredictions(ctx context.Context, stations []StationWithAvailability) (_ *PredictionResponse, err error) {
+	ctx, span := startSpan(ctx, "ml.get_predictions")
+	defer func() { endSpan(span, err) }()
+
+	payload, err := encodePredictRequest(stations)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Bool("ml.push_availability", payload != nil))
+
+	attempts := max(m.retryAttempts, 1)
+	delay := m.retryBaseDelay
+
+	for attempt := 1; ; attempt++ {
+		resp, err := m.requestPredictions(ctx, payload)
+		span.SetAttributes(attribute.Int("ml.attempts", attempt))
+		if err == nil {
+			return resp, nil
+		}
+
+		var retryable *retryableError
+		if !errors.As(err, &retryable) || attempt >= attempts {
+			observeInvalidResponse(err)
+			return nil, err
+		}
+
+		log.Printf("ML predict attempt %d/%d failed, retrying in %v: %v", attempt, attempts, delay, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// observeInvalidResponse logs and counts ML responses that failed
+// validation. An empty response may be expected; a count mismatch never is.
+func observeInvalidResponse(err error) {
+	switch {
+	case errors.Is(err, ErrNoPredictions):
+		mlInvalidResponses.WithLabelValues("empty").Inc()
+		log.Printf("ML service returned no predictions")
+	case errors.Is(err, ErrCountMismatch):
+		mlInvalidResponses.WithLabelValues("count_mismatch").Inc()
+		log.Printf("Error: ML response looks truncated or corrupt: %v", err)
+	}
+}
+
+func (m *MLService) requestPredictions(ctx context.Context, payload []byte) (*PredictionResponse, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", m.baseURL+"/predict", reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	resp, err := m.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("ML service request: %w", err)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("ML service request: %w", err)
+		}
+		return nil, &retryableError{fmt.Errorf("ML service request: %w", err)}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ML service error %d: %s", resp.StatusCode, string(body))
+		err := fmt.Errorf("ML service error %d: %s", resp.StatusCode, string(body))
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return nil, &retryableError{err}
+		}
+		return nil, err
 	}
 
-	var predictionResp PredictionResponse
-	if err := json.NewDecoder(resp.Body).Decode(&predictionResp); err != nil {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &retryableError{fmt.Errorf("read response: %w", err)}
+	}
+
+	predictionResp, err := decodePredictionResponse(body, m.strictDecode)
+	if err != nil {
+		log.Printf("Failed to decode ML response (%d bytes): %v; body: %s", len(body), err, truncateBody(body))
 		return nil, fmt.Errorf("decode response: %w", err)
 	}
 
@@ -82,7 +254,7 @@ func (m *MLService) GetPredictions(ctx context.Context) (*PredictionResponse, er
 	}
 
 	log.Printf("ML inference completed: %d predictions generated", predictionResp.Count)
-	return &predictionResp, nil
+	return predictionResp, nil
 }
 
 func (m *MLService) GetStatus(ctx context.Context) (map[string]interface{}, error) {
@@ -109,9 +281,133 @@ func (m *MLService) GetStatus(ctx context.Context) (map[string]interface{}, erro
 	return status, nil
 }
 
+// ErrMLNotReady is returned by CheckMLReadiness while the ML service is still
+// starting up and should be probed again later.
+var ErrMLNotReady = errors.New("ML service not ready")
+
+// CheckMLReadiness interprets a /status payload. It returns nil once the
+// predictor is loaded, ErrMLNotReady while it is still loading, and any other
+// error when the service reports a failure that retrying will not fix.
+func CheckMLReadiness(status map[string]interface{}) error {
+	if status["prediction_status"] == "initialization_failed" {
+		return errors.New("ML service predictor initialization failed")
+	}
+	if loaded, _ := status["predictor_loaded"].(bool); loaded {
+		return nil
+	}
+	return ErrMLNotReady
+}
+
+// ErrMLUnreachable means nothing is listening at the ML service URL at all,
+// as opposed to a service that is up but still loading its model.
+var ErrMLUnreachable = errors.New("ML service unreachable")
+
+// CheckMLReachable makes a single status probe and returns ErrMLUnreachable
+// only when the connection is refused or the host does not resolve. Any other
+// outcome, including error responses, means the service is reachable.
+func CheckMLReachable(ctx context.Context, mlService MLServiceInterface, serviceURL string) error {
+	_, err := mlService.GetStatus(ctx)
+	if err == nil || !isUnreachable(err) {
+		return nil
+	}
+	return fmt.Errorf("%w at %s: %v", ErrMLUnreachable, serviceURL, err)
+}
+
+func isUnreachable(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// WaitForMLService probes GetStatus with exponential backoff, starting at
+// initialInterval and doubling up to maxInterval, until the service is ready,
+// reports a hard failure, or maxWait elapses. Unreachable services are treated
+// as not ready.
+func WaitForMLService(ctx context.Context, mlService MLServiceInterface, maxWait, initialInterval, maxInterval time.Duration) error {
+	start := time.Now()
+	interval := initialInterval
+	for {
+		status, err := mlService.GetStatus(ctx)
+		if err == nil {
+			err = CheckMLReadiness(status)
+			if err == nil {
+				return nil
+			}
+			if !errors.Is(err, ErrMLNotReady) {
+				return err
+			}
+		}
+		hotWarnings.Printf("ml-not-ready", "ML service not ready yet (elapsed: %v, next probe in %v): %v", time.Since(start), interval, err)
+
+		if time.Since(start)+interval > maxWait {
+			return fmt.Errorf("timeout waiting for ML service after %v", maxWait)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
 type InferenceService struct {
-	mlService MLServiceInterface
-	database  DatabaseInterface
+	mlService   MLServiceInterface
+	database    DatabaseInterface
+	predictions *PredictionsCache
+	// pushAvailability sends current availability in the /predict body
+	// rather than letting the ML service query the database itself.
+	// stations, when set, overlays the availability it held back from the
+	// database on it.
+	pushAvailability bool
+	stations         StationServiceInterface
+	// timeFallback is PREDICTION_TIME_FALLBACK: what to do with a
+	// prediction whose prediction_time does not parse.
+	timeFallback string
+
+	lastTimeParseFailures  atomic.Int64
+	totalTimeParseFailures atomic.Int64
+}
+
+// DefaultModelVersion is stored for predictions the ML service did not
+// attribute to a model version.
+const DefaultModelVersion = "unknown"
+
+const (
+	PredictionTimeFallbackNow  = "now"
+	PredictionTimeFallbackSkip = "skip"
+)
+
+// TimeParseFailureStats counts ML predictions whose prediction_time failed
+// to parse, in the last inference run and since startup, along with what
+// was done with them.
+type TimeParseFailureStats struct {
+	LastRun  int64  `json:"last_run"`
+	Total    int64  `json:"total"`
+	Fallback string `json:"fallback"`
+}
+
+// TimeParseFailures reports prediction_time parse failures seen so far.
+func (s *InferenceService) TimeParseFailures() TimeParseFailureStats {
+	return TimeParseFailureStats{
+		LastRun:  s.lastTimeParseFailures.Load(),
+		Total:    s.totalTimeParseFailures.Load(),
+		Fallback: s.fallback(),
+	}
+}
+
+func (s *InferenceService) fallback() string {
+	if s.timeFallback == "" {
+		return PredictionTimeFallbackNow
+	}
+	return s.timeFallback
 }
 
 func NewInferenceService(mlService MLServiceInterface, database DatabaseInterface) *InferenceService {
@@ -121,8 +417,25 @@ func NewInferenceService(mlService MLServiceInterface, database DatabaseInterfac
 	}
 }
 
-func (s *InferenceService) RunInferenceWithResults(ctx context.Context) error {
-	resp, err := s.mlService.GetPredictions(ctx)
+func (s *InferenceService) RunInferenceWithResults(ctx context.Context) (err error) {
+	ctx, span := startSpan(ctx, "inference.run")
+	defer func() { endSpan(span, err) }()
+
+	var stations []StationWithAvailability
+	if s.pushAvailability {
+		stations, err = s.database.GetStationsWithAvailability(ctx, "")
+		if err != nil {
+			return fmt.Errorf("get availability: %w", err)
+		}
+		if s.stations != nil {
+			s.stations.OverlayUnpersisted(stations)
+		}
+		if stations == nil {
+			stations = []StationWithAvailability{}
+		}
+	}
+
+	resp, err := s.mlService.GetPredictions(ctx, stations)
 	if err != nil {
 		return fmt.Errorf("get predictions: %w", err)
 	}
@@ -132,38 +445,143 @@ func (s *InferenceService) RunInferenceWithResults(ctx context.Context) error {
 		return fmt.Errorf("convert predictions: %w", err)
 	}
 
-	if err := s.database.InsertPredictions(ctx, predictions); err != nil {
+	result, err := s.storePredictionChanges(ctx, predictions)
+	if err != nil {
 		return fmt.Errorf("store predictions: %w", err)
 	}
+	log.Printf("Stored %d changed predictions, confirmed %d unchanged", result.Changed, result.Unchanged)
+	s.predictions.rebuild(ctx)
 
 	return nil
 }
 
-func (s *InferenceService) convertPredictions(rawPredictions []struct {
-	StationID                  string `json:"station_id"`
-	PredictedAvailabilityClass int    `json:"predicted_availability_class"`
-	PredictionTime             string `json:"prediction_time"`
-	HorizonHours               int    `json:"horizon_hours"`
-	AvailabilityPrediction     string `json:"availability_prediction"`
-}) ([]Prediction, error) {
-	predictions := make([]Prediction, len(rawPredictions))
+// PredictionStoreResult counts how an inference run's predictions were
+// persisted.
+type PredictionStoreResult struct {
+	Changed   int `json:"changed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// storePredictionChanges inserts only predictions whose class differs from
+// the latest stored one for the same station and horizon, and marks the
+// rest as reconfirmed (see ConfirmPredictions) so "latest" queries still see
+// them as current. It then records the run's batch summaries, counting both.
+func (s *InferenceService) storePredictionChanges(ctx context.Context, predictions []Prediction) (PredictionStoreResult, error) {
+	latest, err := s.database.GetLatestPredictionsByHorizon(ctx)
+	if err != nil {
+		return PredictionStoreResult{}, err
+	}
+
+	runAt := time.Now()
+	changed, unchanged := diffPredictions(latest, predictions, runAt)
+
+	inserted, err := s.database.InsertPredictions(ctx, changed)
+	if err != nil {
+		return PredictionStoreResult{Changed: inserted}, err
+	}
+
+	confirmed, err := s.database.ConfirmPredictions(ctx, unchanged)
+	if err != nil {
+		return PredictionStoreResult{Changed: inserted}, err
+	}
+
+	// The predictions are stored either way, so a missing summary only
+	// leaves a gap in the batch audit.
+	if err := s.database.RecordPredictionBatches(ctx, summarizePredictionBatches(runAt, predictions)); err != nil {
+		log.Printf("Failed to record prediction batches: %v", err)
+	}
+
+	return PredictionStoreResult{Changed: inserted, Unchanged: confirmed}, nil
+}
+
+// summarizePredictionBatches counts one run's predictions per horizon and
+// class, shortest horizon first.
+func summarizePredictionBatches(runAt time.Time, predictions []Prediction) []PredictionBatch {
+	index := make(map[int]int)
+	var batches []PredictionBatch
+	for _, p := range predictions {
+		i, ok := index[p.HorizonHours]
+		if !ok {
+			i = len(batches)
+			index[p.HorizonHours] = i
+			batches = append(batches, PredictionBatch{CreatedAt: runAt, HorizonHours: p.HorizonHours, Classes: map[string]int{}})
+		}
+		batches[i].Count++
+		batches[i].Classes[p.AvailabilityPrediction]++
+	}
+	slices.SortFunc(batches, func(a, b PredictionBatch) int { return a.HorizonHours - b.HorizonHours })
+	return batches
+}
+
+type predictionKey struct {
+	stationID    string
+	modelVersion string
+	horizonHours int
+}
+
+// diffPredictions splits fresh predictions into those that need a new row and
+// those that reproduce a stored prediction unchanged, returned with the
+// stored prediction's ID. A stored prediction already due at now may have
+// been scored for accuracy, so it is never reconfirmed; the fresh one gets
+// its own row.
+func diffPredictions(latest, fresh []Prediction, now time.Time) ([]Prediction, []Prediction) {
+	stored := make(map[predictionKey]Prediction, len(latest))
+	for _, p := range latest {
+		stored[predictionKey{p.StationID, p.ModelVersion, p.HorizonHours}] = p
+	}
+
+	var changed, unchanged []Prediction
+	for _, p := range fresh {
+		previous, ok := stored[predictionKey{p.StationID, p.ModelVersion, p.HorizonHours}]
+		if ok && previous.PredictedAvailabilityClass == p.PredictedAvailabilityClass && previous.PredictionTime.After(now) {
+			p.ID = previous.ID
+			unchanged = append(unchanged, p)
+			continue
+		}
+		changed = append(changed, p)
+	}
+	return changed, unchanged
+}
+
+// convertPredictions converts ML predictions for storage. A prediction whose
+// prediction_time does not parse is counted and, per PREDICTION_TIME_FALLBACK,
+// either skipped or stored with the current time.
+func (s *InferenceService) convertPredictions(rawPredictions []MLPrediction) ([]Prediction, error) {
+	predictions := make([]Prediction, 0, len(rawPredictions))
+	fallback := s.fallback()
+	var failures int64
 	
-	for i, pred := range rawPredictions {
+	for _, pred := range rawPredictions {
 		predTime, err := time.Parse(time.RFC3339, pred.PredictionTime)
 		if err != nil {
-			log.Printf("Warning: failed to parse prediction time '%s' for station %s: %v, using current time", 
+			failures++
+			predictionTimeParseFailures.WithLabelValues(fallback).Inc()
+			if fallback == PredictionTimeFallbackSkip {
+				hotWarnings.Printf("prediction-time-parse", "Warning: failed to parse prediction time '%s' for station %s: %v, skipping prediction",
+					pred.PredictionTime, pred.StationID, err)
+				continue
+			}
+			hotWarnings.Printf("prediction-time-parse", "Warning: failed to parse prediction time '%s' for station %s: %v, using current time",
 				pred.PredictionTime, pred.StationID, err)
 			predTime = time.Now()
 		}
+		modelVersion := pred.ModelVersion
+		if modelVersion == "" {
+			modelVersion = DefaultModelVersion
+		}
 
-		predictions[i] = Prediction{
+		predictions = append(predictions, Prediction{
 			StationID:                  pred.StationID,
 			PredictedAvailabilityClass: pred.PredictedAvailabilityClass,
 			PredictionTime:             predTime,
 			HorizonHours:               pred.HorizonHours,
 			AvailabilityPrediction:     pred.AvailabilityPrediction,
-		}
+			Confidence:                 pred.Confidence,
+			ModelVersion:               modelVersion,
+		})
 	}
-	
+
+	s.lastTimeParseFailures.Store(failures)
+	s.totalTimeParseFailures.Add(failures)
 	return predictions, nil
 }
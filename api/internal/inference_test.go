@@ -2,10 +2,14 @@ package internal
 
 import (
 	"context"
+	"encoding/json"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -16,6 +20,7 @@ func TestMLService_GetPredictions(t *testing.T) {
 		serverResponse string
 		serverStatus   int
 		expectErr      bool
+		expectedErr    error
 		expectedCount  int
 	}{
 		{
@@ -57,7 +62,8 @@ func TestMLService_GetPredictions(t *testing.T) {
 				"count": 0,
 				"timestamp": "2023-01-01T12:00:00Z"
 			}`,
-			expectErr: true,
+			expectErr:   true,
+			expectedErr: ErrNoPredictions,
 		},
 		{
 			name:         "invalid response - count mismatch",
@@ -75,7 +81,8 @@ func TestMLService_GetPredictions(t *testing.T) {
 				"count": 5,
 				"timestamp": "2023-01-01T12:00:00Z"
 			}`,
-			expectErr: true,
+			expectErr:   true,
+			expectedErr: ErrCountMismatch,
 		},
 	}
 
@@ -97,11 +104,20 @@ func TestMLService_GetPredictions(t *testing.T) {
 			}
 
 			mlService := NewMLService(config)
-			result, err := mlService.GetPredictions(context.Background())
+			empty := testutil.ToFloat64(mlInvalidResponses.WithLabelValues("empty"))
+			mismatched := testutil.ToFloat64(mlInvalidResponses.WithLabelValues("count_mismatch"))
+			result, err := mlService.GetPredictions(context.Background(), nil)
 
 			if tt.expectErr {
 				assert.Error(t, err)
 				assert.Nil(t, result)
+				if tt.expectedErr != nil {
+					assert.ErrorIs(t, err, tt.expectedErr)
+				}
+				assert.Equal(t, tt.expectedErr == ErrNoPredictions,
+					testutil.ToFloat64(mlInvalidResponses.WithLabelValues("empty")) == empty+1)
+				assert.Equal(t, tt.expectedErr == ErrCountMismatch,
+					testutil.ToFloat64(mlInvalidResponses.WithLabelValues("count_mismatch")) == mismatched+1)
 			} else {
 				assert.NoError(t, err)
 				assert.NotNil(t, result)
@@ -200,16 +216,10 @@ func TestInferenceService_RunInferenceWithResults(t *testing.T) {
 			mockDB := new(MockDatabase)
 
 			if tt.mlServiceError != nil {
-				mockMLService.On("GetPredictions", mock.Anything).Return((*PredictionResponse)(nil), tt.mlServiceError)
+				mockMLService.On("GetPredictions", mock.Anything, mock.Anything).Return((*PredictionResponse)(nil), tt.mlServiceError)
 			} else {
 				response := &PredictionResponse{
-					Predictions: []struct {
-						StationID                  string `json:"station_id"`
-						PredictedAvailabilityClass int    `json:"predicted_availability_class"`
-						PredictionTime             string `json:"prediction_time"`
-						HorizonHours               int    `json:"horizon_hours"`
-						AvailabilityPrediction     string `json:"availability_prediction"`
-					}{
+					Predictions: []MLPrediction{
 						{
 							StationID:                  "123",
 							PredictedAvailabilityClass: 1,
@@ -220,16 +230,19 @@ func TestInferenceService_RunInferenceWithResults(t *testing.T) {
 					},
 					Count: 1,
 				}
-				mockMLService.On("GetPredictions", mock.Anything).Return(response, nil)
+				mockMLService.On("GetPredictions", mock.Anything, mock.Anything).Return(response, nil)
+				mockDB.On("GetLatestPredictionsByHorizon", mock.Anything).Return([]Prediction{}, nil)
 
 				if tt.mockInsertError != nil {
 					mockDB.On("InsertPredictions", mock.Anything, mock.MatchedBy(func(preds []Prediction) bool {
 						return len(preds) == tt.expectedPredCount
-					})).Return(tt.mockInsertError)
+					})).Return(0, tt.mockInsertError)
 				} else {
 					mockDB.On("InsertPredictions", mock.Anything, mock.MatchedBy(func(preds []Prediction) bool {
 						return len(preds) == tt.expectedPredCount
-					})).Return(nil)
+					})).Return(tt.expectedPredCount, nil)
+					mockDB.On("ConfirmPredictions", mock.Anything, ([]Prediction)(nil)).Return(0, nil)
+					mockDB.On("RecordPredictionBatches", mock.Anything, mock.Anything).Return(nil)
 				}
 			}
 
@@ -250,22 +263,79 @@ func TestInferenceService_RunInferenceWithResults(t *testing.T) {
 	}
 }
 
+func TestInferenceService_StorePredictionChanges(t *testing.T) {
+	mockDB := new(MockDatabase)
+
+	due := time.Now().Add(time.Hour)
+	mockDB.On("GetLatestPredictionsByHorizon", mock.Anything).Return([]Prediction{
+		{ID: 10, StationID: "a", HorizonHours: 1, PredictedAvailabilityClass: 1, PredictionTime: due},
+		{ID: 11, StationID: "a", HorizonHours: 6, PredictedAvailabilityClass: 0, PredictionTime: due},
+	}, nil)
+	mockDB.On("InsertPredictions", mock.Anything, mock.MatchedBy(func(preds []Prediction) bool {
+		return len(preds) == 2 && preds[0].HorizonHours == 6 && preds[1].StationID == "b"
+	})).Return(2, nil)
+	// The confirmed row takes the new run's prediction_time.
+	mockDB.On("ConfirmPredictions", mock.Anything, mock.MatchedBy(func(preds []Prediction) bool {
+		return len(preds) == 1 && preds[0].ID == 10 && preds[0].PredictionTime.Equal(due.Add(15*time.Minute))
+	})).Return(1, nil)
+	mockDB.On("RecordPredictionBatches", mock.Anything, mock.MatchedBy(func(batches []PredictionBatch) bool {
+		return len(batches) == 2 && batches[0].HorizonHours == 1 && batches[0].Count == 2 && batches[1].Count == 1
+	})).Return(nil)
+
+	service := NewInferenceService(new(MockMLService), mockDB)
+	result, err := service.storePredictionChanges(context.Background(), []Prediction{
+		{StationID: "a", HorizonHours: 1, PredictedAvailabilityClass: 1, PredictionTime: due.Add(15 * time.Minute)},
+		{StationID: "a", HorizonHours: 6, PredictedAvailabilityClass: 2},
+		{StationID: "b", HorizonHours: 1, PredictedAvailabilityClass: 1},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, PredictionStoreResult{Changed: 2, Unchanged: 1}, result)
+	mockDB.AssertExpectations(t)
+}
+
+func TestInferenceService_StorePredictionChanges_ConfirmedRunRecordsFullBatch(t *testing.T) {
+	mockDB := new(MockDatabase)
+
+	due := time.Now().Add(time.Hour)
+	mockDB.On("GetLatestPredictionsByHorizon", mock.Anything).Return([]Prediction{
+		{ID: 10, StationID: "a", HorizonHours: 1, PredictedAvailabilityClass: 1, PredictionTime: due},
+		{ID: 11, StationID: "b", HorizonHours: 1, PredictedAvailabilityClass: 2, PredictionTime: due},
+	}, nil)
+	mockDB.On("InsertPredictions", mock.Anything, ([]Prediction)(nil)).Return(0, nil)
+	mockDB.On("ConfirmPredictions", mock.Anything, mock.Anything).Return(2, nil)
+	mockDB.On("RecordPredictionBatches", mock.Anything, mock.Anything).Return(nil)
+
+	service := NewInferenceService(new(MockMLService), mockDB)
+	result, err := service.storePredictionChanges(context.Background(), []Prediction{
+		{StationID: "a", HorizonHours: 1, PredictedAvailabilityClass: 1, AvailabilityPrediction: "low", PredictionTime: due.Add(15 * time.Minute)},
+		{StationID: "b", HorizonHours: 1, PredictedAvailabilityClass: 2, AvailabilityPrediction: "high", PredictionTime: due.Add(15 * time.Minute)},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, PredictionStoreResult{Unchanged: 2}, result)
+	mockDB.AssertExpectations(t)
+
+	// Nothing was inserted, yet the run's batch still counts every prediction.
+	batches := mockDB.Calls[len(mockDB.Calls)-1].Arguments.Get(1).([]PredictionBatch)
+	if assert.Len(t, batches, 1) {
+		assert.Equal(t, 1, batches[0].HorizonHours)
+		assert.Equal(t, 2, batches[0].Count)
+		assert.Equal(t, map[string]int{"low": 1, "high": 1}, batches[0].Classes)
+	}
+}
+
 func TestPredictionResponse_Validate(t *testing.T) {
 	tests := []struct {
 		name     string
 		response *PredictionResponse
 		expectErr bool
+		expectedErr error
 	}{
 		{
 			name: "valid response",
 			response: &PredictionResponse{
-				Predictions: []struct {
-					StationID                  string `json:"station_id"`
-					PredictedAvailabilityClass int    `json:"predicted_availability_class"`
-					PredictionTime             string `json:"prediction_time"`
-					HorizonHours               int    `json:"horizon_hours"`
-					AvailabilityPrediction     string `json:"availability_prediction"`
-				}{
+				Predictions: []MLPrediction{
 					{
 						StationID:      "123",
 						PredictionTime: "2023-01-01T12:00:00Z",
@@ -278,27 +348,16 @@ func TestPredictionResponse_Validate(t *testing.T) {
 		{
 			name: "empty predictions",
 			response: &PredictionResponse{
-				Predictions: []struct {
-					StationID                  string `json:"station_id"`
-					PredictedAvailabilityClass int    `json:"predicted_availability_class"`
-					PredictionTime             string `json:"prediction_time"`
-					HorizonHours               int    `json:"horizon_hours"`
-					AvailabilityPrediction     string `json:"availability_prediction"`
-				}{},
+				Predictions: []MLPrediction{},
 				Count: 0,
 			},
-			expectErr: true,
+			expectErr:   true,
+			expectedErr: ErrNoPredictions,
 		},
 		{
 			name: "count mismatch",
 			response: &PredictionResponse{
-				Predictions: []struct {
-					StationID                  string `json:"station_id"`
-					PredictedAvailabilityClass int    `json:"predicted_availability_class"`
-					PredictionTime             string `json:"prediction_time"`
-					HorizonHours               int    `json:"horizon_hours"`
-					AvailabilityPrediction     string `json:"availability_prediction"`
-				}{
+				Predictions: []MLPrediction{
 					{
 						StationID:      "123",
 						PredictionTime: "2023-01-01T12:00:00Z",
@@ -306,18 +365,27 @@ func TestPredictionResponse_Validate(t *testing.T) {
 				},
 				Count: 5,
 			},
+			expectErr:   true,
+			expectedErr: ErrCountMismatch,
+		},
+		{
+			name: "confidence out of range",
+			response: &PredictionResponse{
+				Predictions: []MLPrediction{
+					{
+						StationID:      "123",
+						PredictionTime: "2023-01-01T12:00:00Z",
+						Confidence:     floatPtr(1.5),
+					},
+				},
+				Count: 1,
+			},
 			expectErr: true,
 		},
 		{
 			name: "missing station ID",
 			response: &PredictionResponse{
-				Predictions: []struct {
-					StationID                  string `json:"station_id"`
-					PredictedAvailabilityClass int    `json:"predicted_availability_class"`
-					PredictionTime             string `json:"prediction_time"`
-					HorizonHours               int    `json:"horizon_hours"`
-					AvailabilityPrediction     string `json:"availability_prediction"`
-				}{
+				Predictions: []MLPrediction{
 					{
 						StationID:      "",
 						PredictionTime: "2023-01-01T12:00:00Z",
@@ -334,9 +402,490 @@ func TestPredictionResponse_Validate(t *testing.T) {
 			err := tt.response.Validate()
 			if tt.expectErr {
 				assert.Error(t, err)
+				if tt.expectedErr != nil {
+					assert.ErrorIs(t, err, tt.expectedErr)
+				} else {
+					assert.NotErrorIs(t, err, ErrNoPredictions)
+					assert.NotErrorIs(t, err, ErrCountMismatch)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckMLReadiness(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      map[string]interface{}
+		expectErr   error
+		expectReady bool
+	}{
+		{
+			name:        "predictor loaded",
+			status:      map[string]interface{}{"prediction_status": "ready", "predictor_loaded": true},
+			expectReady: true,
+		},
+		{
+			name:      "still loading",
+			status:    map[string]interface{}{"prediction_status": "not_started", "predictor_loaded": false},
+			expectErr: ErrMLNotReady,
+		},
+		{
+			name:   "initialization failed",
+			status: map[string]interface{}{"prediction_status": "initialization_failed", "predictor_loaded": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckMLReadiness(tt.status)
+			switch {
+			case tt.expectReady:
+				assert.NoError(t, err)
+			case tt.expectErr != nil:
+				assert.ErrorIs(t, err, tt.expectErr)
+			default:
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, ErrMLNotReady)
+			}
+		})
+	}
+}
+
+func TestWaitForMLService(t *testing.T) {
+	notReady := map[string]interface{}{"predictor_loaded": false}
+	ready := map[string]interface{}{"predictor_loaded": true}
+	failed := map[string]interface{}{"prediction_status": "initialization_failed"}
+
+	tests := []struct {
+		name      string
+		setup     func(m *MockMLService)
+		maxWait   time.Duration
+		expectErr bool
+	}{
+		{
+			name: "ready after unreachable and loading probes",
+			setup: func(m *MockMLService) {
+				m.On("GetStatus", mock.Anything).Return(map[string]interface{}(nil), assert.AnError).Once()
+				m.On("GetStatus", mock.Anything).Return(notReady, nil).Once()
+				m.On("GetStatus", mock.Anything).Return(ready, nil).Once()
+			},
+			maxWait: time.Second,
+		},
+		{
+			name: "hard failure returns immediately",
+			setup: func(m *MockMLService) {
+				m.On("GetStatus", mock.Anything).Return(failed, nil).Once()
+			},
+			maxWait:   time.Second,
+			expectErr: true,
+		},
+		{
+			name: "timeout",
+			setup: func(m *MockMLService) {
+				m.On("GetStatus", mock.Anything).Return(notReady, nil)
+			},
+			maxWait:   5 * time.Millisecond,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockMLService := new(MockMLService)
+			tt.setup(mockMLService)
+
+			err := WaitForMLService(context.Background(), mockMLService, tt.maxWait, time.Millisecond, 4*time.Millisecond)
+
+			if tt.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockMLService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMLService_GetPredictions_Retry(t *testing.T) {
+	validResponse := `{
+		"predictions": [{"station_id": "123", "prediction_time": "2023-01-01T12:00:00Z"}],
+		"count": 1
+	}`
+
+	tests := []struct {
+		name             string
+		statuses         []int
+		bodies           []string
+		expectErr        bool
+		expectedAttempts int
+	}{
+		{
+			name:             "recovers after 5xx",
+			statuses:         []int{http.StatusBadGateway, http.StatusOK},
+			bodies:           []string{"restarting", validResponse},
+			expectedAttempts: 2,
+		},
+		{
+			name:             "gives up after max attempts",
+			statuses:         []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			bodies:           []string{"", "", ""},
+			expectErr:        true,
+			expectedAttempts: 3,
+		},
+		{
+			name:             "no retry on 4xx",
+			statuses:         []int{http.StatusBadRequest},
+			bodies:           []string{"bad request"},
+			expectErr:        true,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "no retry on invalid body",
+			statuses:         []int{http.StatusOK},
+			bodies:           []string{`{"predictions": [], "count": 0}`},
+			expectErr:        true,
+			expectedAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statuses[attempts])
+				w.Write([]byte(tt.bodies[attempts]))
+				attempts++
+			}))
+			defer server.Close()
+
+			config := &Config{
+				ML: MLConfig{
+					ServiceURL:              server.URL,
+					RequestTimeoutMin:       1,
+					PredictRetryAttempts:    3,
+					PredictRetryBaseDelayMs: 1,
+				},
+			}
+
+			result, err := NewMLService(config).GetPredictions(context.Background(), nil)
+
+			if tt.expectErr {
+				assert.Error(t, err)
+				assert.Nil(t, result)
 			} else {
 				assert.NoError(t, err)
+				assert.NotNil(t, result)
 			}
+			assert.Equal(t, tt.expectedAttempts, attempts)
+		})
+	}
+}
+
+func TestMLService_GetPredictions_PushAvailability(t *testing.T) {
+	stations := []StationWithAvailability{
+		{Station: Station{StationID: "a"}, NumBikesAvailable: 3, NumDocksAvailable: 7},
+		{Station: Station{StationID: "b"}, NumBikesAvailable: 0, NumDocksAvailable: 10},
+	}
+
+	var bodies []PredictRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var req PredictRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		bodies = append(bodies, req)
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"predictions": [{"station_id": "a", "prediction_time": "2023-01-01T12:00:00Z"}], "count": 1}`))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		ML: MLConfig{
+			ServiceURL:              server.URL,
+			RequestTimeoutMin:       1,
+			PredictRetryAttempts:    2,
+			PredictRetryBaseDelayMs: 1,
+		},
+	}
+
+	result, err := NewMLService(config).GetPredictions(context.Background(), stations)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Count)
+	// The body is resent in full on retry.
+	assert.Len(t, bodies, 2)
+	for _, body := range bodies {
+		assert.Equal(t, 2, body.Count)
+		assert.Len(t, body.Stations, 2)
+		assert.Equal(t, "a", body.Stations[0].StationID)
+		assert.Equal(t, 3, body.Stations[0].NumBikesAvailable)
+	}
+}
+
+func TestMLService_GetPredictions_UnencodableBody(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	config := &Config{ML: MLConfig{ServiceURL: server.URL, RequestTimeoutMin: 1}}
+	stations := []StationWithAvailability{{Station: Station{StationID: "a", Lat: Coordinate(math.NaN())}}}
+
+	result, err := NewMLService(config).GetPredictions(context.Background(), stations)
+
+	assert.ErrorContains(t, err, "encode request")
+	assert.Nil(t, result)
+	assert.Zero(t, requests)
+}
+
+func TestInferenceService_PushAvailability(t *testing.T) {
+	stations := []StationWithAvailability{{Station: Station{StationID: "123"}, NumBikesAvailable: 4}}
+	response := &PredictionResponse{
+		Predictions: []MLPrediction{{StationID: "123", PredictionTime: "2023-01-01T12:00:00Z", HorizonHours: 1}},
+		Count:       1,
+	}
+
+	mockMLService := new(MockMLService)
+	mockDB := new(MockDatabase)
+	mockDB.On("GetStationsWithAvailability", mock.Anything, "").Return(stations, nil)
+	mockMLService.On("GetPredictions", mock.Anything, stations).Return(response, nil)
+	mockDB.On("GetLatestPredictionsByHorizon", mock.Anything).Return([]Prediction{}, nil)
+	mockDB.On("InsertPredictions", mock.Anything, mock.Anything).Return(1, nil)
+	mockDB.On("ConfirmPredictions", mock.Anything, ([]Prediction)(nil)).Return(0, nil)
+	mockDB.On("RecordPredictionBatches", mock.Anything, mock.Anything).Return(nil)
+
+	service := NewInferenceService(mockMLService, mockDB)
+	service.pushAvailability = true
+
+	assert.NoError(t, service.RunInferenceWithResults(context.Background()))
+	mockMLService.AssertExpectations(t)
+	mockDB.AssertExpectations(t)
+}
+
+func TestInferenceService_PushAvailability_OverlaysUnpersisted(t *testing.T) {
+	stations := []StationWithAvailability{{Station: Station{StationID: "123"}, NumBikesAvailable: 4}}
+	response := &PredictionResponse{
+		Predictions: []MLPrediction{{StationID: "123", PredictionTime: "2023-01-01T12:00:00Z", HorizonHours: 1}},
+		Count:       1,
+	}
+
+	mockMLService := new(MockMLService)
+	mockDB := new(MockDatabase)
+	mockDB.On("GetStationsWithAvailability", mock.Anything, "").Return(stations, nil)
+	mockMLService.On("GetPredictions", mock.Anything, mock.MatchedBy(func(pushed []StationWithAvailability) bool {
+		return len(pushed) == 1 && pushed[0].NumBikesAvailable == 9
+	})).Return(response, nil)
+	mockDB.On("GetLatestPredictionsByHorizon", mock.Anything).Return([]Prediction{}, nil)
+	mockDB.On("InsertPredictions", mock.Anything, mock.Anything).Return(1, nil)
+	mockDB.On("ConfirmPredictions", mock.Anything, ([]Prediction)(nil)).Return(0, nil)
+	mockDB.On("RecordPredictionBatches", mock.Anything, mock.Anything).Return(nil)
+
+	// A cycle held back by AVAILABILITY_PERSIST_EVERY_N is newer than the
+	// stored availability and is what the ML service should see.
+	stationService := NewStationService(mockDB, new(MockDivvyClient), NewTestConfig())
+	stationService.unpersisted["123"] = StationAvailability{StationID: "123", NumBikesAvailable: 9}
+
+	service := NewInferenceService(mockMLService, mockDB)
+	service.pushAvailability = true
+	service.stations = stationService
+
+	assert.NoError(t, service.RunInferenceWithResults(context.Background()))
+	mockMLService.AssertExpectations(t)
+	mockDB.AssertExpectations(t)
+}
+
+func TestInferenceService_ConvertPredictions_TimeFallback(t *testing.T) {
+	raw := []MLPrediction{
+		{StationID: "1", PredictionTime: "2023-01-01T12:00:00Z"},
+		{StationID: "2", PredictionTime: "tomorrow"},
+	}
+
+	tests := []struct {
+		name          string
+		fallback      string
+		expectedCount int
+	}{
+		{name: "default substitutes now", expectedCount: 2},
+		{name: "now", fallback: PredictionTimeFallbackNow, expectedCount: 2},
+		{name: "skip", fallback: PredictionTimeFallbackSkip, expectedCount: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewInferenceService(new(MockMLService), new(MockDatabase))
+			service.timeFallback = tt.fallback
+			metric := predictionTimeParseFailures.WithLabelValues(service.fallback())
+			before := testutil.ToFloat64(metric)
+
+			predictions, err := service.convertPredictions(raw)
+			assert.NoError(t, err)
+			assert.Len(t, predictions, tt.expectedCount)
+			assert.Equal(t, "1", predictions[0].StationID)
+			assert.Equal(t, before+1, testutil.ToFloat64(metric))
+
+			// A clean run resets the last-run count but not the total.
+			_, err = service.convertPredictions(raw[:1])
+			assert.NoError(t, err)
+			stats := service.TimeParseFailures()
+			assert.Equal(t, TimeParseFailureStats{LastRun: 0, Total: 1, Fallback: service.fallback()}, stats)
 		})
 	}
 }
+
+func TestDecodePredictionResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		strict      bool
+		expectedErr string
+	}{
+		{
+			name: "valid",
+			body: `{"predictions": [], "count": 0, "cached": true}`,
+		},
+		{
+			name:        "empty body",
+			body:        "  \n",
+			expectedErr: "empty response body",
+		},
+		{
+			name:        "malformed json",
+			body:        `{"predictions": [`,
+			expectedErr: "malformed JSON",
+		},
+		{
+			name:        "wrong shape",
+			body:        `{"predictions": "none", "count": 0}`,
+			expectedErr: "unexpected shape",
+		},
+		{
+			name: "unknown field tolerated",
+			body: `{"predictions": [], "count": 0, "model_version": "v2"}`,
+		},
+		{
+			name:        "unknown field in strict mode",
+			body:        `{"predictions": [], "count": 0, "model_version": "v2"}`,
+			strict:      true,
+			expectedErr: "unexpected shape",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := decodePredictionResponse([]byte(tt.body), tt.strict)
+
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				assert.Nil(t, resp)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, resp)
+			}
+		})
+	}
+}
+
+func TestCheckMLReachable(t *testing.T) {
+	notReady := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer notReady.Close()
+
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedURL := closed.URL
+	closed.Close()
+
+	tests := []struct {
+		name        string
+		url         string
+		unreachable bool
+	}{
+		{name: "reachable but not ready", url: notReady.URL},
+		{name: "connection refused", url: closedURL, unreachable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mlService := NewMLService(&Config{ML: MLConfig{ServiceURL: tt.url, RequestTimeoutMin: 1}})
+
+			err := CheckMLReachable(context.Background(), mlService, tt.url)
+
+			if tt.unreachable {
+				assert.ErrorIs(t, err, ErrMLUnreachable)
+				assert.ErrorContains(t, err, tt.url)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func floatPtr(f float64) *float64 { return &f }
+
+func TestInferenceService_ConvertPredictions_Confidence(t *testing.T) {
+	service := NewInferenceService(new(MockMLService), new(MockDatabase))
+
+	predictions, err := service.convertPredictions([]MLPrediction{
+		{StationID: "1", PredictionTime: "2023-01-01T12:00:00Z", Confidence: floatPtr(0.8)},
+		{StationID: "2", PredictionTime: "2023-01-01T12:00:00Z"},
+	})
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, predictions[0].Confidence) {
+		assert.Equal(t, 0.8, *predictions[0].Confidence)
+	}
+	assert.Nil(t, predictions[1].Confidence, "absent confidence stays null rather than 0")
+}
+
+func TestInferenceService_ConvertPredictions_ModelVersion(t *testing.T) {
+	service := NewInferenceService(new(MockMLService), new(MockDatabase))
+
+	predictions, err := service.convertPredictions([]MLPrediction{
+		{StationID: "1", PredictionTime: "2023-01-01T12:00:00Z", ModelVersion: "gbm-2024-07"},
+		{StationID: "2", PredictionTime: "2023-01-01T12:00:00Z"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "gbm-2024-07", predictions[0].ModelVersion)
+	assert.Equal(t, DefaultModelVersion, predictions[1].ModelVersion)
+}
+
+func TestDiffPredictions_ModelVersions(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	latest := []Prediction{
+		{ID: 1, StationID: "a", HorizonHours: 1, ModelVersion: "v1", PredictedAvailabilityClass: 1, PredictionTime: now.Add(time.Hour)},
+	}
+
+	changed, unchanged := diffPredictions(latest, []Prediction{
+		{StationID: "a", HorizonHours: 1, ModelVersion: "v1", PredictedAvailabilityClass: 1},
+		// Same class from another model is that model's first prediction.
+		{StationID: "a", HorizonHours: 1, ModelVersion: "v2", PredictedAvailabilityClass: 1},
+	}, now)
+
+	if assert.Len(t, unchanged, 1) {
+		assert.Equal(t, 1, unchanged[0].ID)
+	}
+	if assert.Len(t, changed, 1) {
+		assert.Equal(t, "v2", changed[0].ModelVersion)
+	}
+}
+
+func TestDiffPredictions_DuePredictionNotReconfirmed(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	latest := []Prediction{
+		{ID: 1, StationID: "a", HorizonHours: 1, PredictedAvailabilityClass: 1, PredictionTime: now},
+	}
+
+	changed, unchanged := diffPredictions(latest, []Prediction{
+		{StationID: "a", HorizonHours: 1, PredictedAvailabilityClass: 1, PredictionTime: now.Add(time.Hour)},
+	}, now)
+
+	assert.Empty(t, unchanged)
+	assert.Len(t, changed, 1)
+}
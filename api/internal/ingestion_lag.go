@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IngestionLag compares a system's station_status last_updated with the
+// newest availability stored for it.
+type IngestionLag struct {
+	SystemID         string     `json:"system_id"`
+	FeedLastUpdated  *time.Time `json:"feed_last_updated"`
+	LatestRecordedAt *time.Time `json:"latest_recorded_at"`
+	// LagSeconds is how far the feed is ahead of the stored data, zero when
+	// ingestion has caught up and null when either timestamp is unknown.
+	LagSeconds *int64 `json:"lag_seconds"`
+	// Source is "cached" for the last refresh's last_updated or "live".
+	Source string `json:"source"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ingestionLagSeconds returns how many seconds feedUpdated is past
+// recordedAt, never less than zero: data stored after the feed's last update
+// is current.
+func ingestionLagSeconds(feedUpdated, recordedAt time.Time) int64 {
+	return max(int64(feedUpdated.Sub(recordedAt)/time.Second), 0)
+}
+
+// GetIngestionLag reports, per system, how far the stored availability is
+// behind the GBFS feed. The feed's last_updated comes from the last refresh,
+// or is fetched live with ?live=true or when no refresh has reported one.
+// max_lag_seconds is the worst known lag across systems.
+func (h *HTTPHandlers) GetIngestionLag(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	live, err := strconv.ParseBool(c.DefaultQuery("live", "false"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "live must be true or false")
+		return
+	}
+
+	lags := make([]IngestionLag, 0, len(h.config.Divvy.Systems))
+	var maxLag *int64
+	for _, system := range h.config.Divvy.Systems {
+		lag := IngestionLag{SystemID: system.ID, Source: "cached"}
+
+		recordedAt, err := h.database.GetLatestRecordedAt(ctx, system.ID)
+		if err != nil {
+			h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch latest availability", err)
+			return
+		}
+		if !recordedAt.IsZero() {
+			recordedAt = recordedAt.UTC()
+			lag.LatestRecordedAt = &recordedAt
+		}
+
+		feedUpdated, ok := h.divvyClient.LastFeedUpdated(system.ID)
+		if live || !ok {
+			lag.Source = "live"
+			if feedUpdated, err = h.divvyClient.FetchFeedLastUpdated(ctx, system); err != nil {
+				lag.Error = err.Error()
+				lags = append(lags, lag)
+				continue
+			}
+		}
+		lag.FeedLastUpdated = &feedUpdated
+
+		if lag.LatestRecordedAt != nil {
+			seconds := ingestionLagSeconds(feedUpdated, recordedAt)
+			lag.LagSeconds = &seconds
+			if maxLag == nil || seconds > *maxLag {
+				maxLag = &seconds
+			}
+		}
+		lags = append(lags, lag)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"systems": lags, "max_lag_seconds": maxLag})
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIngestionLagSeconds(t *testing.T) {
+	recordedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, int64(90), ingestionLagSeconds(recordedAt.Add(90*time.Second), recordedAt))
+	assert.Equal(t, int64(0), ingestionLagSeconds(recordedAt.Add(-30*time.Second), recordedAt))
+}
+
+func TestHTTPHandlers_GetIngestionLag(t *testing.T) {
+	recordedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	cached := recordedAt.Add(2 * time.Minute)
+	live := recordedAt.Add(5 * time.Minute)
+	seconds := func(n int64) *int64 { return &n }
+
+	tests := []struct {
+		name           string
+		query          string
+		cached         bool
+		liveErr        error
+		recordedAt     time.Time
+		expectedStatus int
+		expectedSource string
+		expectedLag    *int64
+	}{
+		{name: "cached", cached: true, recordedAt: recordedAt, expectedStatus: http.StatusOK, expectedSource: "cached", expectedLag: seconds(120)},
+		{name: "live requested", query: "?live=true", cached: true, recordedAt: recordedAt, expectedStatus: http.StatusOK, expectedSource: "live", expectedLag: seconds(300)},
+		{name: "nothing cached yet", recordedAt: recordedAt, expectedStatus: http.StatusOK, expectedSource: "live", expectedLag: seconds(300)},
+		{name: "nothing stored", cached: true, expectedStatus: http.StatusOK, expectedSource: "cached"},
+		{name: "live fetch fails", query: "?live=true", liveErr: assert.AnError, recordedAt: recordedAt, expectedStatus: http.StatusOK, expectedSource: "live"},
+		{name: "invalid live", query: "?live=maybe", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			mockClient := new(MockDivvyClient)
+			config := NewTestConfig()
+			config.Divvy.Systems = []SystemConfig{{ID: "divvy"}}
+			handlers := NewHTTPHandlers(mockDB, mockClient, config)
+
+			mockDB.On("GetLatestRecordedAt", mock.Anything, "divvy").Return(tt.recordedAt, nil).Maybe()
+			mockClient.On("LastFeedUpdated", "divvy").Return(cached, tt.cached).Maybe()
+			mockClient.On("FetchFeedLastUpdated", mock.Anything, config.Divvy.Systems[0]).Return(live, tt.liveErr).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/ingestion-lag", handlers.GetIngestionLag)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/ingestion-lag"+tt.query, nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+				return
+			}
+			var response struct {
+				Systems       []IngestionLag `json:"systems"`
+				MaxLagSeconds *int64         `json:"max_lag_seconds"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			if assert.Len(t, response.Systems, 1) {
+				lag := response.Systems[0]
+				assert.Equal(t, tt.expectedSource, lag.Source)
+				assert.Equal(t, tt.expectedLag, lag.LagSeconds)
+				assert.Equal(t, tt.expectedLag, response.MaxLagSeconds)
+				assert.Equal(t, tt.liveErr != nil, lag.Error != "")
+			}
+		})
+	}
+}
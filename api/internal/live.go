@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	liveWriteTimeout = 10 * time.Second
+	livePingInterval = 30 * time.Second
+	// liveSendBuffer is how many snapshots may queue for a client before it
+	// is dropped as too slow.
+	liveSendBuffer = 4
+)
+
+var liveUpgrader = websocket.Upgrader{
+	// Matches the API's permissive CORS policy.
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// LiveHub tracks WebSocket clients of /api/stations/live and pushes them
+// each new stations snapshot. A nil *LiveHub ignores broadcasts.
+type LiveHub struct {
+	mu      sync.Mutex
+	clients map[*liveClient]struct{}
+	closed  bool
+}
+
+type liveClient struct {
+	conn *websocket.Conn
+	send chan []byte
+	// done is closed once the client is unregistered.
+	done chan struct{}
+}
+
+func NewLiveHub() *LiveHub {
+	return &LiveHub{clients: map[*liveClient]struct{}{}}
+}
+
+// register adds a client, or reports false once the hub is shutting down.
+func (h *LiveHub) register(conn *websocket.Conn) (*liveClient, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, false
+	}
+	client := &liveClient{conn: conn, send: make(chan []byte, liveSendBuffer), done: make(chan struct{})}
+	h.clients[client] = struct{}{}
+	return client, true
+}
+
+func (h *LiveHub) unregister(client *liveClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client.done)
+	}
+}
+
+// Accepting reports whether new connections are allowed.
+func (h *LiveHub) Accepting() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return !h.closed
+}
+
+// Broadcast queues msg for every client, dropping clients whose queue is
+// full rather than blocking the refresh that produced it.
+func (h *LiveHub) Broadcast(msg []byte) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	var slow []*liveClient
+	for client := range h.clients {
+		select {
+		case client.send <- msg:
+		default:
+			slow = append(slow, client)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, client := range slow {
+		log.Printf("Dropping slow live updates client %s", client.conn.RemoteAddr())
+		h.unregister(client)
+		client.conn.Close()
+	}
+}
+
+// Shutdown stops accepting connections and sends every client a 1001 Going
+// Away close frame so it reconnects elsewhere, then closes the connections.
+// http.Server.Shutdown does not track hijacked WebSocket connections, so
+// this must run before it. It gives up when ctx is done.
+func (h *LiveHub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closed = true
+	clients := make([]*liveClient, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.Unlock()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(liveWriteTimeout)
+	}
+	closeFrame := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.conn.WriteControl(websocket.CloseMessage, closeFrame, deadline); err != nil {
+				log.Printf("Failed to send close frame to %s: %v", client.conn.RemoteAddr(), err)
+			}
+			h.unregister(client)
+			client.conn.Close()
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Printf("Closed %d live updates connections", len(clients))
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// writePump sends queued messages and keepalive pings until the client is
+// unregistered or a write fails.
+func (h *LiveHub) writePump(client *liveClient) {
+	ticker := time.NewTicker(livePingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-client.done:
+			return
+		case msg := <-client.send:
+			client.conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			if err := client.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				h.unregister(client)
+				client.conn.Close()
+				return
+			}
+		case <-ticker.C:
+			if err := client.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteTimeout)); err != nil {
+				h.unregister(client)
+				client.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// LiveUpdates upgrades to a WebSocket that receives the current stations
+// snapshot (the GET /api/stations/json?mode=current body) on connect and
+// again after every refresh. Messages from the client are ignored.
+func (h *HTTPHandlers) LiveUpdates(c *gin.Context) {
+	if !h.live.Accepting() {
+		respondError(c, http.StatusServiceUnavailable, ErrCodeShuttingDown, "Server is shutting down")
+		return
+	}
+
+	conn, err := liveUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written an error response.
+		log.Printf("Live updates upgrade failed: %v", err)
+		return
+	}
+	client, ok := h.live.register(conn)
+	if !ok {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+			time.Now().Add(liveWriteTimeout))
+		conn.Close()
+		return
+	}
+
+	if snapshot := h.stationService.Snapshot(); snapshot != nil {
+		client.send <- snapshot.Body
+	}
+	go h.live.writePump(client)
+
+	// Reading is required to process control frames; it fails once the
+	// client goes away or the connection is closed on shutdown.
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	h.live.unregister(client)
+	conn.Close()
+}
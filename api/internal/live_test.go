@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveUpdates(t *testing.T) {
+	mockStationService := new(MockStationService)
+	mockStationService.On("Snapshot").Return(&StationsSnapshot{Body: []byte(`{"stations":[]}`)})
+	handlers := &HTTPHandlers{stationService: mockStationService, live: NewLiveHub(), config: NewTestConfig()}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/live", handlers.LiveUpdates)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/live"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// The current snapshot arrives on connect, then each broadcast.
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"stations":[]}`, string(msg))
+
+	handlers.live.Broadcast([]byte(`{"stations":[{"station_id":"1"}]}`))
+	_, msg, err = conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Contains(t, string(msg), `"station_id":"1"`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, handlers.live.Shutdown(ctx))
+
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "expected 1001 close, got %v", err)
+
+	// New connections are refused once shutdown has started.
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	assert.ErrorIs(t, err, websocket.ErrBadHandshake)
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	}
+}
+
+func TestLiveHub_BroadcastNil(t *testing.T) {
+	var hub *LiveHub
+	assert.NotPanics(t, func() { hub.Broadcast([]byte("{}")) })
+}
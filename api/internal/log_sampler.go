@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const defaultLogSampleLimit = 5
+
+// hotWarnings samples warnings that can repeat on every cycle while an
+// upstream is broken, such as ML readiness probes and unparseable prediction
+// times. NewServer applies the configured limit.
+var hotWarnings = newLogSampler(defaultLogSampleLimit, time.Minute)
+
+// logSampler emits at most limit messages per key per window. Messages over
+// the limit are counted and reported as one rolled-up line once the window
+// closes: with the next message for that key, or by flush if the key has
+// gone quiet. A non-positive limit disables sampling.
+type logSampler struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	entries map[string]*sampleWindow
+	logf    func(format string, args ...any)
+	now     func() time.Time
+}
+
+type sampleWindow struct {
+	start      time.Time
+	emitted    int
+	suppressed int
+}
+
+func newLogSampler(limit int, window time.Duration) *logSampler {
+	return &logSampler{
+		limit:   limit,
+		window:  window,
+		entries: make(map[string]*sampleWindow),
+		logf:    log.Printf,
+		now:     time.Now,
+	}
+}
+
+func (s *logSampler) setLimit(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = limit
+}
+
+// Printf logs the message unless key has already hit the limit in the
+// current window.
+func (s *logSampler) Printf(key, format string, args ...any) {
+	s.mu.Lock()
+	if s.limit <= 0 {
+		s.mu.Unlock()
+		s.logf(format, args...)
+		return
+	}
+
+	now := s.now()
+	entry := s.entries[key]
+	if entry == nil || now.Sub(entry.start) >= s.window {
+		if entry != nil && entry.suppressed > 0 {
+			s.logf("%s", s.summary(key, entry))
+		}
+		entry = &sampleWindow{start: now}
+		s.entries[key] = entry
+	}
+
+	if entry.emitted >= s.limit {
+		entry.suppressed++
+		s.mu.Unlock()
+		return
+	}
+	entry.emitted++
+	s.mu.Unlock()
+	s.logf(format, args...)
+}
+
+// Start flushes closed windows every window until ctx is done, so the
+// suppressed count of a key that stops repeating is still reported.
+func (s *logSampler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.window)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.flush()
+			}
+		}
+	}()
+}
+
+// flush reports the suppressed count of every closed window and drops it;
+// the key's next message starts a new window.
+func (s *logSampler) flush() {
+	s.mu.Lock()
+	now := s.now()
+	var summaries []string
+	for key, entry := range s.entries {
+		if now.Sub(entry.start) < s.window {
+			continue
+		}
+		if entry.suppressed > 0 {
+			summaries = append(summaries, s.summary(key, entry))
+		}
+		delete(s.entries, key)
+	}
+	s.mu.Unlock()
+
+	for _, summary := range summaries {
+		s.logf("%s", summary)
+	}
+}
+
+func (s *logSampler) summary(key string, entry *sampleWindow) string {
+	return fmt.Sprintf("Suppressed %d similar messages (%s) in the last %v", entry.suppressed, key, s.window)
+}
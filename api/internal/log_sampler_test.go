@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogSampler(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var lines []string
+
+	sampler := newLogSampler(2, time.Minute)
+	sampler.now = func() time.Time { return now }
+	sampler.logf = func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	for i := 0; i < 5; i++ {
+		sampler.Printf("parse", "bad time %d", i)
+	}
+	sampler.Printf("other", "unrelated")
+	assert.Equal(t, []string{"bad time 0", "bad time 1", "unrelated"}, lines)
+
+	now = now.Add(time.Minute)
+	sampler.Printf("parse", "bad time %d", 5)
+	assert.Equal(t, []string{
+		"bad time 0", "bad time 1", "unrelated",
+		"Suppressed 3 similar messages (parse) in the last 1m0s",
+		"bad time 5",
+	}, lines)
+}
+
+func TestLogSampler_Flush(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var lines []string
+
+	sampler := newLogSampler(1, time.Minute)
+	sampler.now = func() time.Time { return now }
+	sampler.logf = func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	sampler.Printf("parse", "bad time %d", 0)
+	sampler.Printf("parse", "bad time %d", 1)
+	sampler.Printf("quiet", "once")
+
+	sampler.flush()
+	assert.Equal(t, []string{"bad time 0", "once"}, lines, "open windows are not flushed")
+
+	// The key goes quiet; its summary is still reported once.
+	now = now.Add(time.Minute)
+	sampler.flush()
+	sampler.flush()
+	assert.Equal(t, []string{"bad time 0", "once", "Suppressed 1 similar messages (parse) in the last 1m0s"}, lines)
+	assert.Empty(t, sampler.entries)
+}
+
+func TestLogSampler_Disabled(t *testing.T) {
+	count := 0
+	sampler := newLogSampler(0, time.Minute)
+	sampler.logf = func(string, ...any) { count++ }
+
+	for i := 0; i < 10; i++ {
+		sampler.Printf("parse", "bad time")
+	}
+	assert.Equal(t, 10, count)
+}
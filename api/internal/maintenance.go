@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maintenanceMessage     = "Server is in maintenance mode; data refresh and other writes are paused"
+	maintenanceReadMessage = "Server is in maintenance mode; only the current stations snapshot is available"
+)
+
+// ErrMaintenanceMode is returned by background writes skipped because
+// maintenance mode is on.
+var ErrMaintenanceMode = errors.New("skipped: maintenance mode is on")
+
+// MaintenanceMode pauses every write path (scheduled refresh and inference,
+// mutating endpoints) while reads are served from the in-memory stations
+// snapshot, so planned database downtime degrades the API instead of
+// failing it. It is held in memory rather than as a feature flag because the
+// database may be unreachable while it is on. A nil *MaintenanceMode is off.
+type MaintenanceMode struct {
+	enabled atomic.Bool
+}
+
+func NewMaintenanceMode(enabled bool) *MaintenanceMode {
+	m := &MaintenanceMode{}
+	m.Set(enabled)
+	return m
+}
+
+func (m *MaintenanceMode) Enabled() bool {
+	return m != nil && m.enabled.Load()
+}
+
+// Set turns maintenance mode on or off, logging transitions.
+func (m *MaintenanceMode) Set(enabled bool) {
+	if m.enabled.Swap(enabled) == enabled {
+		return
+	}
+	if enabled {
+		log.Println("Entering maintenance mode: writes paused, serving cached station data")
+	} else {
+		log.Println("Leaving maintenance mode: writes resumed")
+	}
+}
+
+// RejectDuringMaintenance fails requests with 503 while maintenance mode is
+// on, since the database may be down: writes always, and reads unless their
+// path is in readable, the routes served from memory. Paths in exempt (e.g.
+// the endpoint that turns it off) are let through for every method.
+func RejectDuringMaintenance(m *MaintenanceMode, exempt, readable map[string]bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.Enabled() || exempt[c.FullPath()] {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if readable[c.FullPath()] {
+				c.Next()
+				return
+			}
+			respondMaintenance(c, maintenanceReadMessage)
+		default:
+			respondMaintenance(c, maintenanceMessage)
+		}
+	}
+}
+
+func respondMaintenance(c *gin.Context, message string) {
+	c.Header("Retry-After", "300")
+	respondError(c, http.StatusServiceUnavailable, ErrCodeMaintenance, message)
+}
+
+// GetMaintenanceMode reports whether maintenance mode is on.
+func (h *HTTPHandlers) GetMaintenanceMode(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"enabled": h.maintenance.Enabled()})
+}
+
+// SetMaintenanceMode turns maintenance mode on or off from a
+// {"enabled": bool} body.
+func (h *HTTPHandlers) SetMaintenanceMode(c *gin.Context) {
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil || body.Enabled == nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "body must be {\"enabled\": bool}")
+		return
+	}
+
+	h.maintenance.Set(*body.Enabled)
+	c.JSON(http.StatusOK, gin.H{"enabled": h.maintenance.Enabled()})
+}
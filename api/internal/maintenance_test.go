@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	var m *MaintenanceMode
+	assert.False(t, m.Enabled())
+
+	m = NewMaintenanceMode(false)
+	assert.False(t, m.Enabled())
+	m.Set(true)
+	assert.True(t, m.Enabled())
+	m.Set(true)
+	assert.True(t, m.Enabled())
+	m.Set(false)
+	assert.False(t, m.Enabled())
+}
+
+func TestRejectDuringMaintenance(t *testing.T) {
+	tests := []struct {
+		name           string
+		enabled        bool
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{name: "write allowed when off", method: "POST", path: "/refresh", expectedStatus: http.StatusOK},
+		{name: "write rejected when on", enabled: true, method: "POST", path: "/refresh", expectedStatus: http.StatusServiceUnavailable},
+		{name: "read allowed when off", method: "GET", path: "/stations/1", expectedStatus: http.StatusOK},
+		{name: "snapshot read allowed when on", enabled: true, method: "GET", path: "/stations", expectedStatus: http.StatusOK},
+		{name: "database read rejected when on", enabled: true, method: "GET", path: "/stations/1", expectedStatus: http.StatusServiceUnavailable},
+		{name: "exempt route allowed when on", enabled: true, method: "PUT", path: "/maintenance", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(RejectDuringMaintenance(NewMaintenanceMode(tt.enabled), map[string]bool{"/maintenance": true}, map[string]bool{"/stations": true}))
+			ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+			router.POST("/refresh", ok)
+			router.GET("/stations", ok)
+			router.GET("/stations/:id", ok)
+			router.PUT("/maintenance", ok)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusServiceUnavailable {
+				var response ErrorResponse
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, ErrCodeMaintenance, response.Error.Code)
+				assert.NotEmpty(t, w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestHTTPHandlers_GetStationsJSON_Maintenance(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		snapshot       *StationsSnapshot
+		expectedStatus int
+	}{
+		{name: "serves snapshot", query: "?mode=current", snapshot: &StationsSnapshot{Body: []byte(`{"stations":[]}`)}, expectedStatus: http.StatusOK},
+		{name: "no snapshot yet", query: "?mode=current", expectedStatus: http.StatusServiceUnavailable},
+		{name: "filtered request", query: "?mode=current&system=chi", expectedStatus: http.StatusServiceUnavailable},
+		{name: "predicted mode", query: "?mode=predicted", expectedStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			mockStationService := new(MockStationService)
+			mockStationService.On("Snapshot").Return(tt.snapshot).Maybe()
+			handlers := &HTTPHandlers{
+				database:       mockDB,
+				stationService: mockStationService,
+				maintenance:    NewMaintenanceMode(true),
+				config:         NewTestConfig(),
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/stations", handlers.GetStationsJSON)
+
+			req := httptest.NewRequest("GET", "/stations"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			mockDB.AssertNotCalled(t, "GetStationsWithAvailability", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestHTTPHandlers_SetMaintenanceMode(t *testing.T) {
+	mockStationService := new(MockStationService)
+	handlers := &HTTPHandlers{stationService: mockStationService, maintenance: NewMaintenanceMode(false), config: NewTestConfig()}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/maintenance", handlers.SetMaintenanceMode)
+
+	req := httptest.NewRequest("PUT", "/maintenance", strings.NewReader(`{"enabled": true}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"enabled": true}`, w.Body.String())
+
+	// Background refreshes are skipped without reaching the station service.
+	assert.ErrorIs(t, handlers.RefreshStationDataInternal(t.Context()), ErrMaintenanceMode)
+	mockStationService.AssertNotCalled(t, "RefreshStationData", mock.Anything)
+
+	req = httptest.NewRequest("PUT", "/maintenance", strings.NewReader(`{}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.True(t, handlers.maintenance.Enabled())
+}
@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// unmatchedRoute labels requests that matched no registered route, keeping
+// arbitrary 404 paths out of the label set.
+const unmatchedRoute = "unmatched"
+
+var httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "http_request_duration_seconds",
+	Help:    "HTTP request latency by route template, method and status code.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"route", "method", "status"})
+
+var feedShapeSuspicious = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "divvy_feed_shape_suspicious_total",
+	Help: "Station feed fetches rejected for returning far fewer stations than the previous fetch.",
+}, []string{"system"})
+
+var stationValidationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "divvy_station_validation_failures_total",
+	Help: "Station records from station_information that failed validation, by system.",
+}, []string{"system"})
+
+var staleFeedRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "divvy_stale_feed_refreshes_total",
+	Help: "Refreshes that used a feed's last good data after the fetch failed, by system and feed.",
+}, []string{"system", "feed"})
+
+var unmatchedStatuses = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "divvy_unmatched_statuses_total",
+	Help: "station_status entries for stations missing from station_information, by system and action (skip or placeholder).",
+}, []string{"system", "action"})
+
+var stationIDConflicts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "divvy_station_id_conflicts_total",
+	Help: "Stations dropped from a refresh because another system already stores their station ID, by system.",
+}, []string{"system"})
+
+var freeBikeRefreshFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "divvy_free_bike_refresh_failures_total",
+	Help: "Refreshes whose free_bike_status fetch or store failed while the stations were refreshed, by system.",
+}, []string{"system"})
+
+var shedRequests = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "divvy_requests_shed_total",
+	Help: "Requests rejected with 503 because MAX_INFLIGHT_REQUESTS were already in flight.",
+})
+
+var mlInvalidResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "divvy_ml_invalid_responses_total",
+	Help: "ML prediction responses rejected by validation, by reason (empty or count_mismatch).",
+}, []string{"reason"})
+
+var predictionTimeParseFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "divvy_prediction_time_parse_failures_total",
+	Help: "ML predictions whose prediction_time failed to parse, by action taken (now or skip).",
+}, []string{"action"})
+
+var availabilityAnomalies = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "divvy_availability_anomalies_total",
+	Help: "Availability anomalies flagged during ingestion, by system and kind.",
+}, []string{"system", "kind"})
+
+var webhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "divvy_webhook_deliveries_total",
+	Help: "Station webhook deliveries by result (delivered, failed after all attempts, or cancelled by shutdown).",
+}, []string{"result"})
+
+var webhookDeliveryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "divvy_webhook_delivery_duration_seconds",
+	Help:    "Time to deliver a station webhook, including retries, by result (delivered, failed or cancelled).",
+	Buckets: prometheus.DefBuckets,
+}, []string{"result"})
+
+// RequestMetrics records each request's duration labeled by its route
+// template (e.g. /api/stations/:id/series) rather than the raw path, so
+// per-station URLs don't explode label cardinality.
+func RequestMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		httpRequestDuration.
+			WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
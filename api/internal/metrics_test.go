@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestMetrics())
+	router.GET("/api/stations/:id/series", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	before := testutil.CollectAndCount(httpRequestDuration)
+
+	for _, path := range []string{"/api/stations/1/series", "/api/stations/2/series", "/nope"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	}
+
+	// Both station IDs share the route template series; the 404 gets its own.
+	assert.Equal(t, before+2, testutil.CollectAndCount(httpRequestDuration))
+}
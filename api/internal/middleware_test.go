@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	slowHandler := func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+			c.JSON(http.StatusOK, gin.H{"message": "done"})
+		}
+	}
+
+	tests := []struct {
+		name           string
+		timeout        time.Duration
+		exempt         map[string]bool
+		expectedStatus int
+	}{
+		{
+			name:           "deadline exceeded",
+			timeout:        10 * time.Millisecond,
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:           "exempt route",
+			timeout:        10 * time.Millisecond,
+			exempt:         map[string]bool{"/slow": true},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "disabled",
+			timeout:        0,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(RequestTimeout(tt.timeout, tt.exempt))
+			router.GET("/slow", slowHandler)
+
+			req := httptest.NewRequest("GET", "/slow", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	tests := []struct {
+		name           string
+		configuredKey  string
+		providedKey    string
+		expectedStatus int
+	}{
+		{
+			name:           "valid key",
+			configuredKey:  "secret",
+			providedKey:    "secret",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "wrong key",
+			configuredKey:  "secret",
+			providedKey:    "guess",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "admin disabled",
+			configuredKey:  "",
+			providedKey:    "",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/admin", RequireAPIKey(tt.configuredKey), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("GET", "/admin", nil)
+			req.Header.Set("X-API-Key", tt.providedKey)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestLimitQuery(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{name: "within limits", query: "?ids=a,b,c", expectedStatus: http.StatusOK},
+		{name: "query too long", query: "?q=" + strings.Repeat("x", 64), expectedStatus: http.StatusRequestURITooLong, expectedCode: ErrCodeQueryTooLong},
+		{name: "too many ids", query: "?ids=a,b,c,d,e,f", expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(LimitQuery(32, 5))
+			router.GET("/stations", func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("GET", "/stations"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedCode != "" {
+				assertErrorEnvelope(t, w, tt.expectedCode)
+			}
+		})
+	}
+}
+
+func TestLimitInflight(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(LimitInflight(1, map[string]bool{"/health": true}))
+	router.GET("/slow", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	router.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serve("/slow") }()
+	<-entered
+
+	w := serve("/fast")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assertErrorEnvelope(t, w, ErrCodeOverloaded)
+	assert.Equal(t, http.StatusOK, serve("/health").Code)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-done).Code)
+	assert.Equal(t, http.StatusOK, serve("/fast").Code)
+}
+
+func TestLimitInflight_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(LimitInflight(0, nil))
+	router.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/fast", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
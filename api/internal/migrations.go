@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// migrationFilePattern matches NNN_description.sql, capturing the version.
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_[A-Za-z0-9_-]+\.sql$`)
+
+// MigrationFiles returns the .sql files in dir ordered by their numeric
+// version prefix, so 10_x.sql runs after 2_x.sql regardless of zero-padding.
+// Files not matching NNN_description.sql and duplicate versions are errors.
+func MigrationFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations directory %q: %w", dir, err)
+	}
+
+	type migration struct {
+		version int
+		path    string
+	}
+
+	var migrations []migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		version, err := MigrationVersion(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %q and %q share version %d", other, entry.Name(), version)
+		}
+		seen[version] = entry.Name()
+
+		migrations = append(migrations, migration{version: version, path: filepath.Join(dir, entry.Name())})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	files := make([]string, len(migrations))
+	for i, m := range migrations {
+		files[i] = m.path
+	}
+	return files, nil
+}
+
+// MigrationVersion returns the numeric version of a migration file, given
+// either its name or its path.
+func MigrationVersion(path string) (int, error) {
+	name := filepath.Base(path)
+	match := migrationFilePattern.FindStringSubmatch(name)
+	if match == nil {
+		return 0, fmt.Errorf("migration %q does not match NNN_description.sql", name)
+	}
+	version, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, fmt.Errorf("migration %q: invalid version: %w", name, err)
+	}
+	return version, nil
+}
+
+// LatestMigrationVersion returns the highest migration version in dir, or 0
+// when it has no migrations.
+func LatestMigrationVersion(dir string) (int, error) {
+	files, err := MigrationFiles(dir)
+	if err != nil || len(files) == 0 {
+		return 0, err
+	}
+	return MigrationVersion(files[len(files)-1])
+}
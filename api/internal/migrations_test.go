@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     []string
+		expected  []string
+		expectErr bool
+	}{
+		{
+			name:     "numeric ordering",
+			files:    []string{"10_later.sql", "2_second.sql", "001_initial.sql", "README.md"},
+			expected: []string{"001_initial.sql", "2_second.sql", "10_later.sql"},
+		},
+		{
+			name:      "invalid name",
+			files:     []string{"001_initial.sql", "add-index.sql"},
+			expectErr: true,
+		},
+		{
+			name:      "duplicate version",
+			files:     []string{"002_a.sql", "2_b.sql"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0o644))
+			}
+
+			files, err := MigrationFiles(dir)
+
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			expected := make([]string, len(tt.expected))
+			for i, name := range tt.expected {
+				expected[i] = filepath.Join(dir, name)
+			}
+			assert.Equal(t, expected, files)
+		})
+	}
+}
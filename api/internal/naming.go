@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JSONFieldNaming rewrites JSON response keys from the default snake_case
+// (num_bikes_available) to camelCase (numBikesAvailable) when the request has
+// ?naming=camel, leaving the response structs' tags untouched. Routes in
+// exempt stream their responses and are never buffered.
+func JSONFieldNaming(exempt map[string]bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Query("naming") {
+		case "", "snake":
+			c.Next()
+			return
+		case "camel":
+		default:
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "naming must be snake or camel")
+			return
+		}
+		if exempt[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		writer := &bufferedJSONWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		body := writer.buf.Bytes()
+		if strings.Contains(writer.Header().Get("Content-Type"), "application/json") {
+			if converted, err := camelCaseJSON(body); err != nil {
+				log.Printf("Failed to camelCase response for %s: %v", c.Request.URL.Path, err)
+			} else {
+				body = converted
+			}
+		}
+		writer.Header().Del("Content-Length")
+		writer.ResponseWriter.Write(body)
+	}
+}
+
+// bufferedJSONWriter holds the response body so its keys can be rewritten
+// once the handler has finished.
+type bufferedJSONWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *bufferedJSONWriter) Write(data []byte) (int, error) {
+	return w.buf.Write(data)
+}
+
+func (w *bufferedJSONWriter) WriteString(s string) (int, error) {
+	return w.buf.WriteString(s)
+}
+
+func camelCaseJSON(body []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(camelCaseKeys(value))
+}
+
+func camelCaseKeys(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		converted := make(map[string]any, len(v))
+		for key, inner := range v {
+			converted[snakeToCamel(key)] = camelCaseKeys(inner)
+		}
+		return converted
+	case []any:
+		for i, inner := range v {
+			v[i] = camelCaseKeys(inner)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func snakeToCamel(key string) string {
+	parts := strings.Split(key, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONFieldNaming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(JSONFieldNaming(nil))
+	router.GET("/stations", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"stations": []StationWithAvailability{{
+			Station:           Station{StationID: "123"},
+			NumBikesAvailable: 5,
+		}}})
+	})
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		contains       string
+		notContains    string
+	}{
+		{name: "default snake_case", query: "", expectedStatus: http.StatusOK, contains: `"num_bikes_available":5`},
+		{name: "camelCase", query: "?naming=camel", expectedStatus: http.StatusOK, contains: `"numBikesAvailable":5`, notContains: "num_bikes_available"},
+		{name: "unknown naming", query: "?naming=kebab", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/stations"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+				return
+			}
+			assert.Contains(t, w.Body.String(), tt.contains)
+			if tt.notContains != "" {
+				assert.NotContains(t, w.Body.String(), tt.notContains)
+			}
+		})
+	}
+}
+
+func TestSnakeToCamel(t *testing.T) {
+	assert.Equal(t, "numBikesAvailable", snakeToCamel("num_bikes_available"))
+	assert.Equal(t, "stations", snakeToCamel("stations"))
+	assert.Equal(t, "lastReported", snakeToCamel("last_reported"))
+}
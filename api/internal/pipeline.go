@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	stageStatusOK      = "ok"
+	stageStatusFailed  = "failed"
+	stageStatusSkipped = "skipped"
+)
+
+// PipelineStage reports the outcome of one step of a pipeline run.
+type PipelineStage struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// PipelineRunResult is the outcome of a full refresh-then-inference run.
+type PipelineRunResult struct {
+	Stages          []PipelineStage `json:"stages"`
+	PredictionCount int             `json:"prediction_count"`
+	DurationMs      int64           `json:"duration_ms"`
+}
+
+type pipelineStep struct {
+	name    string
+	errCode string
+	run     func(ctx context.Context) error
+}
+
+// RunPipeline refreshes station data, runs inference and reports the number
+// of latest predictions, stopping at the first failed stage. It is meant as a
+// single end-to-end check for smoke tests.
+//
+// The run is detached from the request, so a client that disconnects (or
+// whose Idempotency-Key retries are waiting on it) does not abort it half
+// way; it is bounded by one collection interval plus the ML request timeout
+// instead.
+func (h *HTTPHandlers) RunPipeline(c *gin.Context) {
+	ctx := context.WithoutCancel(c.Request.Context())
+	if timeout := h.pipelineTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+
+	var predictionCount int
+	steps := []pipelineStep{
+		{name: "refresh", errCode: ErrCodeRefreshFailed, run: h.stationService.RefreshStationData},
+		{name: "inference", errCode: ErrCodeInferenceFailed, run: h.inferenceService.RunInferenceWithResults},
+		{name: "count_predictions", errCode: ErrCodeDBError, run: func(ctx context.Context) error {
+			predictions, err := h.database.GetLatestPredictions(ctx)
+			predictionCount = len(latestPerStation(predictions))
+			return err
+		}},
+	}
+
+	result := PipelineRunResult{Stages: make([]PipelineStage, 0, len(steps))}
+	var failedStep *pipelineStep
+	var failure error
+	for i, step := range steps {
+		if failedStep != nil {
+			result.Stages = append(result.Stages, PipelineStage{Name: step.name, Status: stageStatusSkipped})
+			continue
+		}
+
+		stageStart := time.Now()
+		err := step.run(ctx)
+		stage := PipelineStage{Name: step.name, Status: stageStatusOK, DurationMs: time.Since(stageStart).Milliseconds()}
+		if err != nil {
+			stage.Status = stageStatusFailed
+			stage.Error = err.Error()
+			failedStep, failure = &steps[i], err
+		}
+		result.Stages = append(result.Stages, stage)
+	}
+	result.PredictionCount = predictionCount
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	if failedStep != nil {
+		log.Printf("Pipeline run failed at %s after %dms: %v", failedStep.name, result.DurationMs, failure)
+		respondErrorDetails(c, http.StatusInternalServerError, failedStep.errCode,
+			fmt.Sprintf("pipeline stage %s failed", failedStep.name), result)
+		return
+	}
+
+	log.Printf("Pipeline run completed in %dms with %d predictions", result.DurationMs, result.PredictionCount)
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *HTTPHandlers) pipelineTimeout() time.Duration {
+	return time.Duration(h.config.Timing.DataCollectionIntervalMin)*time.Minute +
+		time.Duration(h.config.ML.RequestTimeoutMin)*time.Minute
+}
@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHTTPHandlers_RunPipeline(t *testing.T) {
+	tests := []struct {
+		name             string
+		refreshErr       error
+		inferenceErr     error
+		expectedStatus   int
+		expectedStatuses []string
+		expectedCount    int
+	}{
+		{
+			name:             "all stages succeed",
+			expectedStatus:   http.StatusOK,
+			expectedStatuses: []string{stageStatusOK, stageStatusOK, stageStatusOK},
+			expectedCount:    2,
+		},
+		{
+			name:             "refresh fails",
+			refreshErr:       assert.AnError,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedStatuses: []string{stageStatusFailed, stageStatusSkipped, stageStatusSkipped},
+		},
+		{
+			name:             "inference fails",
+			inferenceErr:     assert.AnError,
+			expectedStatus:   http.StatusInternalServerError,
+			expectedStatuses: []string{stageStatusOK, stageStatusFailed, stageStatusSkipped},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			mockStationService := new(MockStationService)
+			mockInferenceService := new(MockInferenceService)
+			handlers := &HTTPHandlers{
+				database:         mockDB,
+				stationService:   mockStationService,
+				inferenceService: mockInferenceService,
+				config:           NewTestConfig(),
+			}
+
+			mockStationService.On("RefreshStationData", mock.Anything).Return(tt.refreshErr)
+			mockInferenceService.On("RunInferenceWithResults", mock.Anything).Return(tt.inferenceErr).Maybe()
+			mockDB.On("GetLatestPredictions", mock.Anything).
+				Return([]Prediction{{StationID: "a"}, {StationID: "b"}}, nil).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.POST("/pipeline-run", handlers.RunPipeline)
+
+			req := httptest.NewRequest("POST", "/pipeline-run", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			var result PipelineRunResult
+			if tt.expectedStatus == http.StatusOK {
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			} else {
+				var response struct {
+					Error struct {
+						Code    string            `json:"code"`
+						Details PipelineRunResult `json:"details"`
+					} `json:"error"`
+				}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.NotEmpty(t, response.Error.Code)
+				result = response.Error.Details
+			}
+
+			statuses := make([]string, len(result.Stages))
+			for i, stage := range result.Stages {
+				statuses[i] = stage.Status
+			}
+			assert.Equal(t, tt.expectedStatuses, statuses)
+			assert.Equal(t, tt.expectedCount, result.PredictionCount)
+		})
+	}
+}
+
+func TestHTTPHandlers_RunPipeline_OutlivesTheRequest(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockStationService := new(MockStationService)
+	mockInferenceService := new(MockInferenceService)
+	handlers := &HTTPHandlers{
+		database:         mockDB,
+		stationService:   mockStationService,
+		inferenceService: mockInferenceService,
+		config:           NewTestConfig(),
+	}
+
+	notCanceled := mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil })
+	mockStationService.On("RefreshStationData", notCanceled).Return(nil).Once()
+	mockInferenceService.On("RunInferenceWithResults", notCanceled).Return(nil).Once()
+	mockDB.On("GetLatestPredictions", notCanceled).Return([]Prediction{{StationID: "a"}}, nil).Once()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/pipeline-run", handlers.RunPipeline)
+
+	// The client is already gone when the run starts.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("POST", "/pipeline-run", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStationService.AssertExpectations(t)
+	mockInferenceService.AssertExpectations(t)
+	mockDB.AssertExpectations(t)
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// stationFields are the StationWithAvailability JSON fields that may be
+// requested with ?fields=, each with its value getter.
+var stationFields = map[string]func(*StationWithAvailability) interface{}{
+	"station_id":          func(s *StationWithAvailability) interface{} { return s.StationID },
+	"system_id":           func(s *StationWithAvailability) interface{} { return s.SystemID },
+	"name":                func(s *StationWithAvailability) interface{} { return s.Name },
+	"lat":                 func(s *StationWithAvailability) interface{} { return s.Lat },
+	"lon":                 func(s *StationWithAvailability) interface{} { return s.Lon },
+	"capacity":            func(s *StationWithAvailability) interface{} { return s.Capacity },
+	"created_at":          func(s *StationWithAvailability) interface{} { return s.CreatedAt },
+	"updated_at":          func(s *StationWithAvailability) interface{} { return s.UpdatedAt },
+	"num_bikes_available": func(s *StationWithAvailability) interface{} { return s.NumBikesAvailable },
+	"num_docks_available": func(s *StationWithAvailability) interface{} { return s.NumDocksAvailable },
+	"is_installed":        func(s *StationWithAvailability) interface{} { return s.IsInstalled },
+	"is_renting":          func(s *StationWithAvailability) interface{} { return s.IsRenting },
+	"is_returning":        func(s *StationWithAvailability) interface{} { return s.IsReturning },
+	"last_reported":       func(s *StationWithAvailability) interface{} { return s.LastReported },
+	"is_stale":            func(s *StationWithAvailability) interface{} { return s.IsStale },
+	"color":               func(s *StationWithAvailability) interface{} { return s.Color },
+}
+
+// parseStationFields parses a comma-separated ?fields= value, rejecting
+// names not in stationFields. An empty value selects every field and
+// returns nil.
+func parseStationFields(raw string) ([]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var fields []string
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if _, ok := stationFields[field]; !ok {
+			allowed := slices.Sorted(maps.Keys(stationFields))
+			return nil, fmt.Errorf("unknown field %q; allowed fields are %s", field, strings.Join(allowed, ", "))
+		}
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+// projectStations returns each station as a map holding only fields.
+func projectStations(stations []StationWithAvailability, fields []string) []map[string]interface{} {
+	projected := make([]map[string]interface{}, len(stations))
+	for i := range stations {
+		selected := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			selected[field] = stationFields[field](&stations[i])
+		}
+		projected[i] = selected
+	}
+	return projected
+}
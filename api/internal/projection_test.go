@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestParseStationFields(t *testing.T) {
+	fields, err := parseStationFields("station_id, name,station_id")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"station_id", "name"}, fields)
+
+	fields, err = parseStationFields("")
+	assert.NoError(t, err)
+	assert.Nil(t, fields)
+
+	_, err = parseStationFields("station_id,password")
+	assert.ErrorContains(t, err, `unknown field "password"`)
+}
+
+func TestHTTPHandlers_GetStationsJSON_Fields(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expected       []map[string]interface{}
+	}{
+		{
+			name:           "projected fields",
+			query:          "?fields=station_id,num_bikes_available",
+			expectedStatus: http.StatusOK,
+			expected:       []map[string]interface{}{{"station_id": "test-001", "num_bikes_available": float64(5)}},
+		},
+		{name: "unknown field", query: "?fields=station_id,secret", expectedStatus: http.StatusBadRequest},
+		{name: "empty field name", query: "?fields=station_id,", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			if tt.expectedStatus == http.StatusOK {
+				mockDB.On("GetStationsWithAvailability", mock.Anything, "").
+					Return([]StationWithAvailability{TestStationWithAvailability}, nil)
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/stations", handlers.GetStationsJSON)
+
+			req := httptest.NewRequest("GET", "/stations"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+				return
+			}
+			var response struct {
+				Stations []map[string]interface{} `json:"stations"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expected, response.Stations)
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrRetryBudgetExhausted ends a refresh cycle whose retries used up
+// REFRESH_RETRY_BUDGET or REFRESH_RETRY_BUDGET_SEC. The error it wraps is
+// the failure that could not be retried.
+var ErrRetryBudgetExhausted = errors.New("refresh retry budget exhausted")
+
+// retryBudget caps the retries of one refresh cycle: every retried fetch
+// and write draws from the same number of retries and the same time spent
+// retrying, so one bad cycle cannot run into the next. A nil *retryBudget
+// never retries.
+type retryBudget struct {
+	retries   int
+	remaining time.Duration
+	baseDelay time.Duration
+}
+
+// newRetryBudget returns the budget for one cycle, or nil when
+// REFRESH_RETRY_BUDGET is not positive.
+func newRetryBudget(config TimingConfig) *retryBudget {
+	if config.RefreshRetryBudget <= 0 {
+		return nil
+	}
+	return &retryBudget{
+		retries:   config.RefreshRetryBudget,
+		remaining: time.Duration(config.RefreshRetryBudgetSec) * time.Second,
+		baseDelay: time.Duration(config.RefreshRetryBaseDelayMs) * time.Millisecond,
+	}
+}
+
+// do runs fn and retries failures retryable accepts, with exponential
+// backoff, while the budget lasts. Backoff and retried attempts both count
+// against the time budget; the first attempt does not.
+func (b *retryBudget) do(ctx context.Context, op string, retryable func(error) bool, fn func() error) error {
+	err := fn()
+	if b == nil {
+		return err
+	}
+	delay := b.baseDelay
+	for err != nil && retryable(err) && ctx.Err() == nil {
+		if b.retries <= 0 || delay >= b.remaining {
+			log.Printf("Refresh retry budget exhausted at %s, abandoning this cycle: %v", op, err)
+			return fmt.Errorf("%w: %w", ErrRetryBudgetExhausted, err)
+		}
+		b.retries--
+		log.Printf("Retrying %s in %v (%d retries left this cycle): %v", op, delay, b.retries, err)
+
+		start := time.Now()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		err = fn()
+		b.remaining -= time.Since(start)
+		delay *= 2
+	}
+	return err
+}
+
+// isRetryableFetch reports whether a feed fetch failure may succeed on a
+// second try. Stale data was already served in its place, and a feed with
+// the wrong shape will not fix itself within a cycle.
+func isRetryableFetch(err error) bool {
+	var staleErr *StaleFeedError
+	var shapeErr *FeedShapeError
+	return !errors.As(err, &staleErr) && !errors.As(err, &shapeErr)
+}
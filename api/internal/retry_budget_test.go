@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBudget_Do(t *testing.T) {
+	always := func(error) bool { return true }
+
+	tests := []struct {
+		name          string
+		budget        *retryBudget
+		failures      int
+		retryable     func(error) bool
+		expectedCalls int
+		exhausted     bool
+		expectErr     bool
+	}{
+		{name: "nil budget never retries", failures: 1, retryable: always, expectedCalls: 1, expectErr: true},
+		{name: "recovers within budget", budget: &retryBudget{retries: 2, remaining: time.Second, baseDelay: time.Millisecond},
+			failures: 2, retryable: always, expectedCalls: 3},
+		{name: "runs out of retries", budget: &retryBudget{retries: 2, remaining: time.Second, baseDelay: time.Millisecond},
+			failures: 5, retryable: always, expectedCalls: 3, exhausted: true, expectErr: true},
+		{name: "runs out of time", budget: &retryBudget{retries: 5, remaining: time.Millisecond, baseDelay: 10 * time.Millisecond},
+			failures: 1, retryable: always, expectedCalls: 1, exhausted: true, expectErr: true},
+		{name: "permanent error not retried", budget: &retryBudget{retries: 2, remaining: time.Second, baseDelay: time.Millisecond},
+			failures: 1, retryable: func(error) bool { return false }, expectedCalls: 1, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := tt.budget.do(context.Background(), "test", tt.retryable, func() error {
+				calls++
+				if calls <= tt.failures {
+					return assert.AnError
+				}
+				return nil
+			})
+
+			assert.Equal(t, tt.expectedCalls, calls)
+			if !tt.expectErr {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, assert.AnError)
+			assert.Equal(t, tt.exhausted, errors.Is(err, ErrRetryBudgetExhausted))
+		})
+	}
+}
+
+func TestRetryBudget_SharedAcrossSteps(t *testing.T) {
+	budget := newRetryBudget(TimingConfig{RefreshRetryBudget: 1, RefreshRetryBudgetSec: 10, RefreshRetryBaseDelayMs: 1})
+	flaky := func() func() error {
+		failed := false
+		return func() error {
+			if !failed {
+				failed = true
+				return driver.ErrBadConn
+			}
+			return nil
+		}
+	}
+
+	assert.NoError(t, budget.do(context.Background(), "first", isTransientConnError, flaky()))
+	err := budget.do(context.Background(), "second", isTransientConnError, flaky())
+	assert.ErrorIs(t, err, ErrRetryBudgetExhausted)
+	assert.ErrorIs(t, err, driver.ErrBadConn)
+
+	assert.Nil(t, newRetryBudget(TimingConfig{}))
+}
+
+func TestIsRetryableFetch(t *testing.T) {
+	assert.True(t, isRetryableFetch(assert.AnError))
+	assert.False(t, isRetryableFetch(&StaleFeedError{SystemID: "chi", Err: assert.AnError}))
+	assert.False(t, isRetryableFetch(&FeedShapeError{SystemID: "chi"}))
+}
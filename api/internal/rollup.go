@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// rollupMaxCatchUp bounds how far back a rollup run fills in missed hours,
+// e.g. after downtime or on the first run against existing data. Older hours
+// stay unrolled; GetRollupAvailabilitySeries serves any hour without a
+// rollup row from raw rows, including gaps between rolled-up hours.
+const rollupMaxCatchUp = 7 * 24 * time.Hour
+
+// rollUpPendingHours upserts availability_hourly for every complete hour
+// from the latest one already rolled up through the hour before now's. The
+// latest hour is rolled up again so rows committed after its previous run
+// are included. It returns the number of hours rolled up.
+func rollUpPendingHours(ctx context.Context, db AvailabilityRepository, now time.Time) (int, error) {
+	lastComplete := now.UTC().Truncate(time.Hour).Add(-time.Hour)
+
+	start, err := db.GetLatestRollupHour(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if earliest := lastComplete.Add(-rollupMaxCatchUp); start.Before(earliest) {
+		start = earliest
+	}
+
+	hours := 0
+	for hour := start.UTC(); !hour.After(lastComplete); hour = hour.Add(time.Hour) {
+		if err := ctx.Err(); err != nil {
+			return hours, err
+		}
+		if _, err := db.UpsertHourlyRollup(ctx, hour); err != nil {
+			return hours, fmt.Errorf("roll up %s: %w", hour.Format(time.RFC3339), err)
+		}
+		hours++
+	}
+	return hours, nil
+}
@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRollUpPendingHours(t *testing.T) {
+	now := time.Date(2024, 1, 2, 10, 20, 0, 0, time.UTC)
+
+	t.Run("re-rolls latest hour and fills the gap", func(t *testing.T) {
+		mockDB := new(MockDatabase)
+		mockDB.On("GetLatestRollupHour", mock.Anything).Return(time.Date(2024, 1, 2, 7, 0, 0, 0, time.UTC), nil)
+		for hour := 7; hour <= 9; hour++ {
+			mockDB.On("UpsertHourlyRollup", mock.Anything, time.Date(2024, 1, 2, hour, 0, 0, 0, time.UTC)).Return(10, nil).Once()
+		}
+
+		hours, err := rollUpPendingHours(context.Background(), mockDB, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, hours)
+		mockDB.AssertExpectations(t)
+	})
+
+	t.Run("first run is bounded by catch-up window", func(t *testing.T) {
+		mockDB := new(MockDatabase)
+		mockDB.On("GetLatestRollupHour", mock.Anything).Return(time.Time{}, nil)
+		mockDB.On("UpsertHourlyRollup", mock.Anything, mock.Anything).Return(0, nil)
+
+		hours, err := rollUpPendingHours(context.Background(), mockDB, now)
+		assert.NoError(t, err)
+		assert.Equal(t, int(rollupMaxCatchUp/time.Hour)+1, hours)
+		mockDB.AssertCalled(t, "UpsertHourlyRollup", mock.Anything, time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC))
+	})
+
+	t.Run("stops at first failure", func(t *testing.T) {
+		mockDB := new(MockDatabase)
+		mockDB.On("GetLatestRollupHour", mock.Anything).Return(time.Date(2024, 1, 2, 8, 0, 0, 0, time.UTC), nil)
+		mockDB.On("UpsertHourlyRollup", mock.Anything, mock.Anything).Return(0, assert.AnError).Once()
+
+		hours, err := rollUpPendingHours(context.Background(), mockDB, now)
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.Equal(t, 0, hours)
+		mockDB.AssertNumberOfCalls(t, "UpsertHourlyRollup", 1)
+	})
+}
@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// selfTestCheckTimeout bounds each individual self-test check.
+const selfTestCheckTimeout = 10 * time.Second
+
+// SelfTestCheck is the outcome of one startup self-test check. Critical
+// checks fail the whole report; the others are informational.
+type SelfTestCheck struct {
+	Name       string `json:"name"`
+	Critical   bool   `json:"critical"`
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// SelfTestReport collects every self-test check. OK is false when any
+// critical check failed.
+type SelfTestReport struct {
+	OK     bool            `json:"ok"`
+	Checks []SelfTestCheck `json:"checks"`
+}
+
+// Log writes the report as a single block, one line per check.
+func (r SelfTestReport) Log() {
+	summary := "PASS"
+	if !r.OK {
+		summary = "FAIL"
+	}
+	lines := fmt.Sprintf("Startup self-test: %s", summary)
+	for _, check := range r.Checks {
+		status := "ok"
+		if !check.OK {
+			status = "FAILED: " + check.Error
+		}
+		critical := ""
+		if check.Critical {
+			critical = " (critical)"
+		}
+		lines += fmt.Sprintf("\n  %-32s %s%s [%dms]", check.Name, status, critical, check.DurationMs)
+	}
+	log.Println(lines)
+}
+
+type selfTestStep struct {
+	name     string
+	critical bool
+	run      func(ctx context.Context) error
+}
+
+// SelfTest verifies the deployment's dependencies: the database, that the
+// latest migration is applied, the static and template assets, every GBFS
+// feed and the ML service. Feeds and the ML service are not critical, since
+// the server runs (degraded) without them.
+func (h *HTTPHandlers) SelfTest(ctx context.Context) SelfTestReport {
+	client := newHTTPClient(h.config, selfTestCheckTimeout)
+
+	steps := []selfTestStep{
+		{name: "database", critical: true, run: h.database.HealthCheck},
+		{name: "migrations", critical: true, run: h.checkMigrations},
+		{name: "assets", critical: true, run: func(context.Context) error {
+			if err := validateAssetPaths(h.config.Server); err != nil {
+				return err
+			}
+			_, err := parseTemplates(h.config.Server.TemplatesGlob)
+			return err
+		}},
+	}
+	steps = append(steps, h.feedProbes(client)...)
+	steps = append(steps, selfTestStep{name: "ml_service", run: h.probeMLService})
+
+	report := SelfTestReport{OK: true, Checks: make([]SelfTestCheck, 0, len(steps))}
+	for _, step := range steps {
+		stepCtx, cancel := context.WithTimeout(ctx, selfTestCheckTimeout)
+		start := time.Now()
+		err := step.run(stepCtx)
+		cancel()
+
+		check := SelfTestCheck{Name: step.name, Critical: step.critical, OK: err == nil, DurationMs: time.Since(start).Milliseconds()}
+		if err != nil {
+			check.Error = err.Error()
+			if step.critical {
+				report.OK = false
+			}
+		}
+		report.Checks = append(report.Checks, check)
+	}
+	return report
+}
+
+// feedProbes returns a non-critical reachability check per configured GBFS
+// feed.
+func (h *HTTPHandlers) feedProbes(client *http.Client) []selfTestStep {
+	var steps []selfTestStep
+	for _, system := range h.config.Divvy.Systems {
+		for _, feed := range []struct{ name, url string }{
+			{feedStationInformation, system.StationInfoURL},
+			{feedStationStatus, system.StationStatusURL},
+			{"free_bike_status", system.FreeBikeStatusURL},
+		} {
+			if feed.url == "" {
+				continue
+			}
+			url := feed.url
+			steps = append(steps, selfTestStep{
+				name: "feed " + system.ID + "/" + feed.name,
+				run:  func(ctx context.Context) error { return probeURL(ctx, client, url) },
+			})
+		}
+	}
+	return steps
+}
+
+func (h *HTTPHandlers) probeMLService(ctx context.Context) error {
+	_, err := h.mlService.GetStatus(ctx)
+	return err
+}
+
+// checkMigrations compares the latest migration on disk with the latest one
+// recorded as applied.
+func (h *HTTPHandlers) checkMigrations(ctx context.Context) error {
+	latest, err := LatestMigrationVersion(h.config.Database.MigrationsDir)
+	if err != nil {
+		return err
+	}
+	applied, err := h.database.AppliedMigrationVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if applied < latest {
+		return fmt.Errorf("latest applied migration is %d, expected %d", applied, latest)
+	}
+	return nil
+}
+
+// probeURL checks that url answers a GET with 200 OK.
+func probeURL(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+	return nil
+}
+
+// RunSelfTest runs SelfTest on demand, responding 503 when a critical check
+// fails.
+func (h *HTTPHandlers) RunSelfTest(c *gin.Context) {
+	report := h.SelfTest(c.Request.Context())
+	status := http.StatusOK
+	if !report.OK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHTTPHandlers_SelfTest(t *testing.T) {
+	dir := t.TempDir()
+	staticDir := filepath.Join(dir, "static")
+	templatesDir := filepath.Join(dir, "templates")
+	migrationsDir := filepath.Join(dir, "migrations")
+	for _, d := range []string{staticDir, templatesDir, migrationsDir} {
+		assert.NoError(t, os.Mkdir(d, 0o755))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(templatesDir, "index.html"), []byte("ok"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(migrationsDir, "001_init.sql"), nil, 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(migrationsDir, "002_more.sql"), nil, 0o644))
+
+	feeds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/status" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer feeds.Close()
+
+	tests := []struct {
+		name           string
+		appliedVersion int
+		expectOK       bool
+		expectFailed   []string
+	}{
+		{
+			name:           "non-critical failures only",
+			appliedVersion: 2,
+			expectOK:       true,
+			expectFailed:   []string{"feed divvy/station_status", "ml_service"},
+		},
+		{
+			name:           "pending migration",
+			appliedVersion: 1,
+			expectOK:       false,
+			expectFailed:   []string{"migrations", "feed divvy/station_status", "ml_service"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewTestConfig()
+			cfg.Server.StaticDir = staticDir
+			cfg.Server.TemplatesGlob = filepath.Join(templatesDir, "*")
+			cfg.Database.MigrationsDir = migrationsDir
+			cfg.Divvy.Systems = []SystemConfig{{
+				ID:               "divvy",
+				StationInfoURL:   feeds.URL + "/info",
+				StationStatusURL: feeds.URL + "/status",
+			}}
+
+			mockDB := new(MockDatabase)
+			mockML := new(MockMLService)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), cfg)
+			handlers.mlService = mockML
+			mockDB.On("HealthCheck", mock.Anything).Return(nil)
+			mockDB.On("AppliedMigrationVersion", mock.Anything).Return(tt.appliedVersion, nil)
+			mockML.On("GetStatus", mock.Anything).Return(map[string]interface{}(nil), errors.New("connection refused"))
+
+			report := handlers.SelfTest(context.Background())
+
+			assert.Equal(t, tt.expectOK, report.OK)
+			var failed []string
+			for _, check := range report.Checks {
+				if !check.OK {
+					failed = append(failed, check.Name)
+				}
+			}
+			assert.Equal(t, tt.expectFailed, failed)
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
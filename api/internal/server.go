@@ -2,11 +2,14 @@ package internal
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -14,52 +17,193 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// probeRoutes are health checks and metrics scrapes, which must keep
+// answering while the server is shedding load.
+var probeRoutes = map[string]bool{
+	"/health":  true,
+	"/metrics": true,
+	"/readyz":  true,
+}
+
+// streamingRoutes hold their connection open (WebSocket, streaming imports
+// and exports, long-running admin jobs) and are exempt from the per-request
+// timeout.
+var streamingRoutes = map[string]bool{
+	"/api/admin/import/availability": true,
+	"/api/admin/inference":           true,
+	"/api/admin/pipeline-run":        true,
+	"/api/admin/selftest":            true,
+	"/api/stations/stream":           true,
+	"/api/stations/live":             true,
+}
+
+// maintenanceExemptRoutes stay writable in maintenance mode so it can be
+// turned off again.
+var maintenanceExemptRoutes = map[string]bool{
+	"/api/admin/maintenance": true,
+}
+
+// maintenanceReadableRoutes are the reads that keep working in maintenance
+// mode because they do not need the database: the stations snapshot (the
+// handler itself refuses requests the snapshot cannot answer), live
+// updates, the status page and the admin config.
+var maintenanceReadableRoutes = map[string]bool{
+	"/api/stations/json": true,
+	"/api/stations/live": true,
+	"/api/status":        true,
+	"/api/admin/config":  true,
+}
+
 type Server struct {
-	router   *gin.Engine
-	handlers *HTTPHandlers
-	config   *Config
+	router      *gin.Engine
+	handlers    *HTTPHandlers
+	config      *Config
+	idempotency *IdempotencyStore
 }
 
 func NewServer(config *Config, handlers *HTTPHandlers) (*Server, error) {
+	if err := validateAssetPaths(config.Server); err != nil {
+		return nil, err
+	}
 
 	if config.Server.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	hotWarnings.setLimit(config.Logging.SampleLimitPerMin)
+
 	router := gin.Default()
 
 	return &Server{
-		router:   router,
-		handlers: handlers,
-		config:   config,
+		router:      router,
+		handlers:    handlers,
+		config:      config,
+		idempotency: NewIdempotencyStore(time.Duration(config.Timing.IdempotencyKeyTTLSec) * time.Second),
 	}, nil
 }
 
+// validateAssetPaths fails fast when the static directory or templates are
+// missing, instead of gin panicking on the first rendered page, and when a
+// configured TLS certificate or key cannot be loaded.
+func validateAssetPaths(cfg ServerConfig) error {
+	info, err := os.Stat(cfg.StaticDir)
+	if err != nil {
+		return fmt.Errorf("static directory %q: %w", cfg.StaticDir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("static directory %q is not a directory", cfg.StaticDir)
+	}
+
+	templates, err := filepath.Glob(cfg.TemplatesGlob)
+	if err != nil {
+		return fmt.Errorf("templates glob %q: %w", cfg.TemplatesGlob, err)
+	}
+	if len(templates) == 0 {
+		return fmt.Errorf("templates glob %q matched no files", cfg.TemplatesGlob)
+	}
+
+	if cfg.TLSEnabled() {
+		if _, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile); err != nil {
+			return fmt.Errorf("TLS certificate %q and key %q: %w", cfg.TLSCertFile, cfg.TLSKeyFile, err)
+		}
+	}
+	return nil
+}
+
 func (s *Server) setupRoutes() {
-	s.router.Static("/static", "./static")
+	s.router.Static("/static", s.config.Server.StaticDir)
 
-	s.router.LoadHTMLGlob("templates/*")
+	templates := newTemplateSet(s.config.Server.TemplatesGlob)
+	s.router.HTMLRender = templates
+	html := []gin.HandlerFunc{templates.RequireTemplates(), Locale()}
+	idempotent := Idempotency(s.idempotency)
+	maintenance := RejectDuringMaintenance(s.handlers.maintenance, maintenanceExemptRoutes, maintenanceReadableRoutes)
 
 	s.router.GET("/health", s.handlers.HealthCheck)
+	s.router.GET("/readyz", s.handlers.GetReadiness)
 	s.router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
-	s.router.GET("/", s.handlers.HomePage)
-	s.router.GET("/stations", s.handlers.GetStationsHTML)
+	s.router.GET("/", append(html, s.handlers.HomePage)...)
+	s.router.GET("/stations", append(html, maintenance, s.handlers.GetStationsHTML)...)
 	s.router.GET("/predictions", func(c *gin.Context) {
+		query := c.Request.URL.Query()
+		query.Set("mode", "predicted")
 		c.Request.URL.Path = "/stations"
-		c.Request.URL.RawQuery = "mode=predicted"
+		c.Request.URL.RawQuery = query.Encode()
 		s.router.HandleContext(c)
 	})
 
-	api := s.router.Group("/api")
+	api := s.router.Group("/api", JSONFieldNaming(streamingRoutes), maintenance)
 	{
-		api.GET("/stations", s.handlers.GetStationsHTML)
+		api.GET("/stations", append(html, s.handlers.GetStationsHTML)...)
 		api.GET("/stations/json", s.handlers.GetStationsJSON)
-		api.POST("/refresh", s.handlers.RefreshStationData)
+		api.GET("/stations/stream", s.handlers.StreamStations)
+		api.GET("/stations/live", s.handlers.LiveUpdates)
+		api.GET("/stations/metadata", s.handlers.GetStationMetadata)
+		api.GET("/stations/bbox", s.handlers.GetStationsInBBox)
+		api.GET("/stations/stats", s.handlers.GetStationStats)
+		api.GET("/stations/staleness", s.handlers.GetStationStaleness)
+		api.GET("/stations/missing", s.handlers.GetStationsMissingAvailability)
+		api.GET("/stations/discrepancies", s.handlers.GetCapacityDiscrepancies)
+		api.GET("/stations/divergence", s.handlers.GetStationDivergence)
+		api.GET("/stations/operational-summary", s.handlers.GetOperationalSummary)
+		api.GET("/predictions/outliers", s.handlers.GetPredictionOutliers)
+		api.GET("/predictions/geojson", s.handlers.GetPredictionsGeoJSON)
+		api.GET("/predictions/freshness", s.handlers.GetPredictionFreshness)
+		api.POST("/stations/batch", s.handlers.GetStationsBatch)
+		api.GET("/stations/:id/series", s.handlers.GetStationSeries)
+		api.GET("/stations/:id/summary", s.handlers.GetStationSummary)
+		api.GET("/stations/:id/prediction-history", s.handlers.GetStationPredictionHistory)
+		api.GET("/free-bikes", s.handlers.GetFreeBikes)
+		api.GET("/anomalies", s.handlers.GetAnomalies)
+		api.GET("/availability/compare", s.handlers.CompareAvailability)
+		api.GET("/availability/history", s.handlers.GetAvailabilityHistory)
+		api.GET("/status", s.handlers.GetStatus)
+		api.POST("/refresh", idempotent, s.handlers.RefreshStationData)
+	}
+
+	admin := api.Group("/admin", RequireAPIKey(s.config.Server.AdminAPIKey))
+	{
+		admin.GET("/config", s.handlers.GetAdminConfig)
+		admin.GET("/flags", s.handlers.GetFeatureFlags)
+		admin.PUT("/flags", s.handlers.SetFeatureFlag)
+		admin.GET("/selftest", s.handlers.RunSelfTest)
+		admin.POST("/import/availability", s.handlers.ImportAvailability)
+		admin.POST("/inference", idempotent, s.handlers.TriggerInference)
+		admin.POST("/pipeline-run", idempotent, s.handlers.RunPipeline)
+		admin.POST("/validate-feed", s.handlers.ValidateFeed)
+		admin.GET("/maintenance", s.handlers.GetMaintenanceMode)
+		admin.PUT("/maintenance", s.handlers.SetMaintenanceMode)
+		admin.GET("/prediction-batches", s.handlers.GetPredictionBatches)
+		admin.GET("/prediction-distribution", s.handlers.GetPredictionDistribution)
+		admin.GET("/ingestion-lag", s.handlers.GetIngestionLag)
+		admin.GET("/storage-stats", s.handlers.GetStorageStats)
+		admin.POST("/predictions-snapshot/refresh", s.handlers.RefreshPredictionsSnapshot)
+		admin.GET("/webhooks", s.handlers.GetWebhooks)
+		admin.GET("/webhooks/deliveries", s.handlers.GetWebhookDeliveries)
+		admin.POST("/webhooks", s.handlers.CreateWebhook)
+		admin.DELETE("/webhooks/:id", s.handlers.DeleteWebhook)
+		admin.POST("/webhooks/:id/enable", s.handlers.EnableWebhook)
 	}
 }
 
+// inflightExemptRoutes are left out of MAX_INFLIGHT_REQUESTS: probes, and
+// streaming routes, which would otherwise hold a slot for as long as a
+// client stays connected.
+func inflightExemptRoutes() map[string]bool {
+	exempt := make(map[string]bool, len(probeRoutes)+len(streamingRoutes))
+	maps.Copy(exempt, probeRoutes)
+	maps.Copy(exempt, streamingRoutes)
+	return exempt
+}
+
 func (s *Server) setupMiddleware() {
+	s.router.Use(RequestID())
+	s.router.Use(LimitInflight(s.config.Server.MaxInflightRequests, inflightExemptRoutes()))
+	if s.config.Tracing.Enabled() {
+		s.router.Use(Tracing())
+	}
+	s.router.Use(RequestMetrics())
 	s.router.Use(gin.Logger())
 	s.router.Use(gin.Recovery())
 
@@ -82,24 +226,45 @@ func (s *Server) setupMiddleware() {
 
 		c.Next()
 	})
+
+	s.router.Use(LimitQuery(s.config.Server.MaxQueryBytes, s.config.Server.MaxQueryIDs))
+	s.router.Use(RequestTimeout(time.Duration(s.config.Timing.RequestTimeoutSec)*time.Second, streamingRoutes))
 }
 
 func (s *Server) Start() error {
 	s.setupMiddleware()
 	s.setupRoutes()
 
+	s.handlers.flags.Start(context.Background(), time.Duration(s.config.Timing.FeatureFlagRefreshSec)*time.Second)
+	s.idempotency.Start(context.Background())
+	hotWarnings.Start(context.Background())
+
 	s.startDataCollection(context.Background())
 
 	s.StartPredictionService(context.Background())
 
+	// Cancelled on shutdown so long-running jobs stop mid-run.
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	s.startPredictionEvaluation(jobsCtx)
+	s.startHourlyRollup(jobsCtx)
+
 	server := &http.Server{
 		Addr:    ":" + s.config.Server.Port,
 		Handler: s.router,
 	}
 
 	go func() {
-		log.Printf("Server starting on port %s", s.config.Server.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.config.Server.TLSEnabled() {
+			server.TLSConfig = newTLSConfig()
+			log.Printf("Server starting on port %s (HTTPS)", s.config.Server.Port)
+			err = server.ListenAndServeTLS(s.config.Server.TLSCertFile, s.config.Server.TLSKeyFile)
+		} else {
+			log.Printf("Server starting on port %s", s.config.Server.Port)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed to start: %v", err)
 		}
 	}()
@@ -110,18 +275,45 @@ func (s *Server) Start() error {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopJobs()
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.config.Timing.ServerShutdownTimeoutSec)*time.Second)
 	defer cancel()
 
+	// Live clients are told to reconnect elsewhere first; server.Shutdown
+	// does not wait for hijacked WebSocket connections.
+	if err := s.handlers.live.Shutdown(ctx); err != nil {
+		log.Printf("Live updates shutdown incomplete: %v", err)
+	}
+
 	if err := server.Shutdown(ctx); err != nil {
 		return fmt.Errorf("server forced to shutdown: %w", err)
 	}
 
+	if err := s.handlers.webhooks.Shutdown(ctx); err != nil {
+		log.Printf("Webhook deliveries shutdown incomplete: %v", err)
+	}
+
 	log.Println("Server exited")
 	return nil
 }
 
+// newTLSConfig requires TLS 1.2 or later and, for TLS 1.2, limits cipher
+// suites to forward-secret AEAD ones. TLS 1.3 suites are not configurable.
+func newTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+	}
+}
+
 func (s *Server) startDataCollection(ctx context.Context) {
 	go func() {
 		now := time.Now()
@@ -171,40 +363,63 @@ func (s *Server) startDataCollection(ctx context.Context) {
 func (s *Server) waitAndGenerateInitialPredictions(ctx context.Context) error {
 	maxWait := time.Duration(s.config.Timing.MLServiceMaxWaitMin) * time.Minute
 	checkInterval := time.Duration(s.config.Timing.MLServiceCheckIntervalSec) * time.Second
+	maxCheckInterval := time.Duration(s.config.Timing.MLServiceMaxCheckIntervalSec) * time.Second
 
-	start := time.Now()
-	for {
-		if time.Since(start) > maxWait {
-			return fmt.Errorf("timeout waiting for ML service after %v", maxWait)
+	if s.config.ML.FailFastUnreachable {
+		if err := CheckMLReachable(ctx, s.handlers.mlService, s.config.ML.ServiceURL); err != nil {
+			log.Printf("Error: %v; skipping wait for initial predictions", err)
+			return err
 		}
+	}
 
-		// Try to call the ML service directly
-		if err := s.handlers.inferenceService.RunInferenceWithResults(ctx); err != nil {
-			log.Printf("ML service not ready yet (elapsed: %v): %v", time.Since(start), err)
+	start := time.Now()
+	if err := WaitForMLService(ctx, s.handlers.mlService, maxWait, checkInterval, maxCheckInterval); err != nil {
+		return err
+	}
+	log.Printf("ML service ready after %v", time.Since(start))
 
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(checkInterval):
-				continue
-			}
-		}
+	if err := s.handlers.inferenceService.RunInferenceWithResults(ctx); err != nil {
+		return fmt.Errorf("initial inference: %w", err)
+	}
 
-		log.Printf("Initial predictions generated successfully after %v", time.Since(start))
-		return nil
+	log.Printf("Initial predictions generated successfully after %v", time.Since(start))
+	return nil
+}
+
+// seedPredictions generates initial predictions before the server accepts
+// traffic, giving up after SEED_PREDICTIONS_TIMEOUT_SEC. It reports whether
+// predictions were generated.
+func (s *Server) seedPredictions(ctx context.Context) bool {
+	timeout := time.Duration(s.config.ML.SeedPredictionsTimeoutSec) * time.Second
+	log.Printf("Seeding predictions before accepting traffic (timeout %v)...", timeout)
+
+	seedCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	if err := s.waitAndGenerateInitialPredictions(seedCtx); err != nil {
+		log.Printf("Seeding predictions failed after %v, falling back to background warmup: %v", time.Since(start).Round(time.Second), err)
+		return false
 	}
+	return true
 }
 
+// StartPredictionService generates initial predictions, synchronously when
+// SEED_PREDICTIONS_ON_STARTUP is set and in the background otherwise, then
+// regenerates them every PREDICTION_INTERVAL_HOURS.
 func (s *Server) StartPredictionService(ctx context.Context) {
 	ticker := time.NewTicker(time.Duration(s.config.Timing.PredictionIntervalHours) * time.Hour)
 	defer ticker.Stop()
 
+	seeded := s.config.ML.SeedPredictionsOnStartup && s.seedPredictions(ctx)
+
 	go func() {
-		log.Println("Waiting for ML service and generating initial predictions...")
-		if err := s.waitAndGenerateInitialPredictions(ctx); err != nil {
-			log.Printf("Initial prediction generation failed: %v", err)
-		} else {
-			log.Printf("Initial predictions generated successfully at %s", time.Now().Format("15:04:05"))
+		if !seeded {
+			log.Println("Waiting for ML service and generating initial predictions in the background...")
+			if err := s.waitAndGenerateInitialPredictions(ctx); err != nil {
+				log.Printf("Initial prediction generation failed: %v", err)
+			} else {
+				log.Printf("Initial predictions generated successfully at %s", time.Now().Format("15:04:05"))
+			}
 		}
 
 		log.Printf("Prediction service running - generating predictions every %d hours", s.config.Timing.PredictionIntervalHours)
@@ -215,6 +430,10 @@ func (s *Server) StartPredictionService(ctx context.Context) {
 				log.Println("Prediction service shutting down")
 				return
 			case <-ticker.C:
+				if s.handlers.maintenance.Enabled() {
+					log.Println("Scheduled prediction generation skipped: maintenance mode is on")
+					continue
+				}
 				if err := s.handlers.inferenceService.RunInferenceWithResults(context.Background()); err != nil {
 					log.Printf("Scheduled prediction generation failed: %v", err)
 				} else {
@@ -224,3 +443,71 @@ func (s *Server) StartPredictionService(ctx context.Context) {
 		}
 	}()
 }
+
+// startHourlyRollup rolls station_availability up into availability_hourly
+// on startup and then every hour, catching up on hours missed while the
+// server was down. Runs are skipped in maintenance mode.
+func (s *Server) startHourlyRollup(ctx context.Context) {
+	rollUp := func() {
+		if s.handlers.maintenance.Enabled() {
+			return
+		}
+		start := time.Now()
+		hours, err := rollUpPendingHours(ctx, s.handlers.database, start)
+		if err != nil {
+			log.Printf("Hourly rollup failed after %d hours: %v", hours, err)
+			return
+		}
+		log.Printf("Hourly rollup completed in %v: %d hours", time.Since(start), hours)
+	}
+
+	go func() {
+		rollUp()
+
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("Hourly rollup shutting down")
+				return
+			case <-ticker.C:
+				rollUp()
+			}
+		}
+	}()
+}
+
+// startPredictionEvaluation scores matured predictions every
+// EVALUATION_INTERVAL_MIN while the prediction_evaluation flag is enabled.
+func (s *Server) startPredictionEvaluation(ctx context.Context) {
+	if s.config.Evaluation.IntervalMin <= 0 {
+		log.Println("Prediction evaluation disabled")
+		return
+	}
+	evaluator := NewPredictionEvaluator(s.handlers.database, s.config.Evaluation)
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(s.config.Evaluation.IntervalMin) * time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("Prediction evaluation shutting down")
+				return
+			case <-ticker.C:
+				if !s.handlers.flags.Enabled(FlagPredictionEvaluation) || s.handlers.maintenance.Enabled() {
+					continue
+				}
+				start := time.Now()
+				result, err := evaluator.Run(ctx)
+				if err != nil {
+					log.Printf("Prediction evaluation finished with errors after %v: %v", time.Since(start), err)
+				}
+				log.Printf("Prediction evaluation completed in %v: %s", time.Since(start), result)
+			}
+		}
+	}()
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestValidateAssetPaths(t *testing.T) {
+	dir := t.TempDir()
+	staticDir := filepath.Join(dir, "static")
+	templatesDir := filepath.Join(dir, "templates")
+	assert.NoError(t, os.Mkdir(staticDir, 0o755))
+	assert.NoError(t, os.Mkdir(templatesDir, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(templatesDir, "index.html"), []byte("ok"), 0o644))
+	certFile, keyFile := writeTestCertificate(t, dir)
+
+	tests := []struct {
+		name        string
+		cfg         ServerConfig
+		errContains string
+	}{
+		{
+			name: "valid paths",
+			cfg:  ServerConfig{StaticDir: staticDir, TemplatesGlob: filepath.Join(templatesDir, "*")},
+		},
+		{
+			name:        "missing static directory",
+			cfg:         ServerConfig{StaticDir: filepath.Join(dir, "missing"), TemplatesGlob: filepath.Join(templatesDir, "*")},
+			errContains: "missing",
+		},
+		{
+			name:        "templates glob matches nothing",
+			cfg:         ServerConfig{StaticDir: staticDir, TemplatesGlob: filepath.Join(dir, "none", "*")},
+			errContains: "matched no files",
+		},
+		{
+			name: "valid TLS certificate",
+			cfg: ServerConfig{StaticDir: staticDir, TemplatesGlob: filepath.Join(templatesDir, "*"),
+				TLSCertFile: certFile, TLSKeyFile: keyFile},
+		},
+		{
+			name: "missing TLS key",
+			cfg: ServerConfig{StaticDir: staticDir, TemplatesGlob: filepath.Join(templatesDir, "*"),
+				TLSCertFile: certFile, TLSKeyFile: filepath.Join(dir, "missing.key")},
+			errContains: "missing.key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAssetPaths(tt.cfg)
+			if tt.errContains != "" {
+				assert.ErrorContains(t, err, tt.errContains)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t, t.TempDir())
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	assert.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = newTLSConfig()
+	server.TLS.Certificates = []tls.Certificate{cert}
+	server.StartTLS()
+	defer server.Close()
+
+	dial := func(maxVersion uint16) error {
+		conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{
+			InsecureSkipVerify: true,
+			MaxVersion:         maxVersion,
+		})
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+	assert.NoError(t, dial(tls.VersionTLS13))
+	assert.NoError(t, dial(tls.VersionTLS12))
+	assert.Error(t, dial(tls.VersionTLS11))
+}
+
+// writeTestCertificate writes a self-signed certificate and its key to dir.
+func TestServer_SeedPredictions(t *testing.T) {
+	ready := map[string]interface{}{"predictor_loaded": true}
+
+	tests := []struct {
+		name         string
+		status       map[string]interface{}
+		statusErr    error
+		inferenceErr error
+		expected     bool
+	}{
+		{name: "seeded", status: ready, expected: true},
+		{name: "inference fails", status: ready, inferenceErr: errors.New("ml returned 500")},
+		{name: "ml never ready", statusErr: errors.New("connection refused")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewTestConfig()
+			config.ML.SeedPredictionsOnStartup = true
+			config.ML.SeedPredictionsTimeoutSec = 1
+			config.Timing.MLServiceMaxWaitMin = 5
+			config.Timing.MLServiceCheckIntervalSec = 1
+			config.Timing.MLServiceMaxCheckIntervalSec = 1
+
+			mockML := new(MockMLService)
+			mockInference := new(MockInferenceService)
+			handlers := NewHTTPHandlers(new(MockDatabase), new(MockDivvyClient), config)
+			handlers.mlService = mockML
+			handlers.inferenceService = mockInference
+			mockML.On("GetStatus", mock.Anything).Return(tt.status, tt.statusErr)
+			mockInference.On("RunInferenceWithResults", mock.Anything).Return(tt.inferenceErr).Maybe()
+
+			server := &Server{config: config, handlers: handlers}
+			start := time.Now()
+			assert.Equal(t, tt.expected, server.seedPredictions(context.Background()))
+			assert.Less(t, time.Since(start), 3*time.Second)
+			if tt.statusErr == nil {
+				mockInference.AssertCalled(t, "RunInferenceWithResults", mock.Anything)
+			} else {
+				mockInference.AssertNotCalled(t, "RunInferenceWithResults", mock.Anything)
+			}
+		})
+	}
+}
+
+func writeTestCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "server.crt")
+	keyFile = filepath.Join(dir, "server.key")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
@@ -0,0 +1,38 @@
+package internal
+
+import "math"
+
+// predictionClassLabels mirrors the class labels assigned by the ML
+// pipeline's predictor.
+var predictionClassLabels = map[int]string{0: "green", 1: "yellow", 2: "red"}
+
+// smoothPredictions sets the smoothed class on each prediction from a
+// linearly weighted moving average over its station, model version and
+// horizon's history
+// (newest first): with a window of K stored predictions the newest weighs K,
+// the next K-1, down to 1 for the oldest. The average is rounded to the
+// nearest class. Predictions without history are smoothed to themselves.
+func smoothPredictions(predictions []Prediction, history []Prediction) {
+	byKey := make(map[predictionKey][]Prediction)
+	for _, p := range history {
+		key := predictionKey{p.StationID, p.ModelVersion, p.HorizonHours}
+		byKey[key] = append(byKey[key], p)
+	}
+
+	for i := range predictions {
+		window := byKey[predictionKey{predictions[i].StationID, predictions[i].ModelVersion, predictions[i].HorizonHours}]
+		if len(window) == 0 {
+			window = predictions[i : i+1]
+		}
+
+		var weighted, total float64
+		for j, p := range window {
+			weight := float64(len(window) - j)
+			weighted += weight * float64(p.PredictedAvailabilityClass)
+			total += weight
+		}
+		class := int(math.Round(weighted / total))
+		predictions[i].SmoothedAvailabilityClass = &class
+		predictions[i].SmoothedAvailabilityPrediction = predictionClassLabels[class]
+	}
+}
@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSmoothPredictions(t *testing.T) {
+	history := func(classes ...int) []Prediction {
+		predictions := make([]Prediction, len(classes))
+		for i, class := range classes {
+			predictions[i] = Prediction{StationID: "s1", HorizonHours: 6, PredictedAvailabilityClass: class}
+		}
+		return predictions
+	}
+
+	tests := []struct {
+		name          string
+		history       []Prediction
+		expectedClass int
+		expectedLabel string
+	}{
+		{name: "steady", history: history(1, 1, 1), expectedClass: 1, expectedLabel: "yellow"},
+		// (3*2 + 2*0 + 1*0) / 6 = 1
+		{name: "single flip toward recent", history: history(2, 0, 0), expectedClass: 1, expectedLabel: "yellow"},
+		// (3*0 + 2*2 + 1*2) / 6 = 1
+		{name: "recent drop", history: history(0, 2, 2), expectedClass: 1, expectedLabel: "yellow"},
+		// (3*0 + 2*0 + 1*2) / 6 = 0.33
+		{name: "old outlier", history: history(0, 0, 2), expectedClass: 0, expectedLabel: "green"},
+		{name: "no history", expectedClass: 2, expectedLabel: "red"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			predictions := []Prediction{{StationID: "s1", HorizonHours: 6, PredictedAvailabilityClass: 2}}
+			smoothPredictions(predictions, tt.history)
+
+			if assert.NotNil(t, predictions[0].SmoothedAvailabilityClass) {
+				assert.Equal(t, tt.expectedClass, *predictions[0].SmoothedAvailabilityClass)
+			}
+			assert.Equal(t, tt.expectedLabel, predictions[0].SmoothedAvailabilityPrediction)
+			assert.Equal(t, 2, predictions[0].PredictedAvailabilityClass, "raw class is kept")
+		})
+	}
+}
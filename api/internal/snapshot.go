@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/singleflight"
+)
+
+// StationsSnapshot is a pre-rendered GET /api/stations/json?mode=current
+// response for all systems, rebuilt after each successful data collection so
+// the hot path can be served without touching the database.
+type StationsSnapshot struct {
+	Body        []byte
+	GeneratedAt time.Time
+}
+
+// snapshotStore holds the latest snapshot. A nil snapshot means none has been
+// built yet and callers should fall back to a live query.
+type snapshotStore struct {
+	current atomic.Pointer[StationsSnapshot]
+}
+
+func (s *snapshotStore) Load() *StationsSnapshot {
+	return s.current.Load()
+}
+
+func (s *snapshotStore) Store(stations []StationWithAvailability) error {
+	localizeStationsWithAvailability(stations, time.UTC)
+	body, err := json.Marshal(gin.H{"stations": stations, "timezone": time.UTC.String()})
+	if err != nil {
+		return err
+	}
+	s.current.Store(&StationsSnapshot{Body: body, GeneratedAt: time.Now().UTC()})
+	return nil
+}
+
+// predictionsLoadTimeout bounds a shared PredictionsCache load.
+const predictionsLoadTimeout = 30 * time.Second
+
+// PredictionsSnapshot is the result of GetLatestPredictions held in memory so
+// predicted-mode reads skip its DISTINCT ON query.
+type PredictionsSnapshot struct {
+	Predictions []Prediction
+	GeneratedAt time.Time
+}
+
+// PredictionsCache serves the latest predictions from a snapshot rebuilt
+// after each inference run and reloaded once it is older than maxAge, so
+// predictions written by another instance are picked up too. With a
+// non-positive maxAge it always queries the database.
+type PredictionsCache struct {
+	database  PredictionRepository
+	maxAge    time.Duration
+	current   atomic.Pointer[PredictionsSnapshot]
+	refreshes singleflight.Group
+}
+
+func NewPredictionsCache(database PredictionRepository, maxAge time.Duration) *PredictionsCache {
+	return &PredictionsCache{database: database, maxAge: maxAge}
+}
+
+// Latest returns the latest predictions and when they were loaded. The
+// slice is the caller's to modify.
+func (c *PredictionsCache) Latest(ctx context.Context) ([]Prediction, time.Time, error) {
+	if c.maxAge <= 0 {
+		return c.load(ctx)
+	}
+	snapshot := c.current.Load()
+	if snapshot == nil || time.Since(snapshot.GeneratedAt) > c.maxAge {
+		var err error
+		if snapshot, err = c.Refresh(ctx); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+	return slices.Clone(snapshot.Predictions), snapshot.GeneratedAt, nil
+}
+
+// Refresh reloads the snapshot. Concurrent refreshes share one query, which
+// is detached from the caller that started it and bounded by
+// predictionsLoadTimeout, so one reader disconnecting does not fail the
+// load for the others. A caller whose ctx is done stops waiting.
+func (c *PredictionsCache) Refresh(ctx context.Context) (*PredictionsSnapshot, error) {
+	results := c.refreshes.DoChan("refresh", func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), predictionsLoadTimeout)
+		defer cancel()
+		predictions, generatedAt, err := c.load(loadCtx)
+		if err != nil {
+			return nil, err
+		}
+		snapshot := &PredictionsSnapshot{Predictions: predictions, GeneratedAt: generatedAt}
+		c.current.Store(snapshot)
+		return snapshot, nil
+	})
+	select {
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(*PredictionsSnapshot), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Reload discards the snapshot and loads a new one, without joining a load
+// that started before predictions were last written. If loading fails the
+// next read retries.
+func (c *PredictionsCache) Reload(ctx context.Context) (*PredictionsSnapshot, error) {
+	c.current.Store(nil)
+	c.refreshes.Forget("refresh")
+	return c.Refresh(ctx)
+}
+
+// rebuild reloads the snapshot after an inference run, logging failures.
+func (c *PredictionsCache) rebuild(ctx context.Context) {
+	if c == nil || c.maxAge <= 0 {
+		return
+	}
+	if _, err := c.Reload(ctx); err != nil {
+		log.Printf("Failed to rebuild predictions snapshot: %v", err)
+	}
+}
+
+func (c *PredictionsCache) load(ctx context.Context) ([]Prediction, time.Time, error) {
+	generatedAt := time.Now().UTC()
+	predictions, err := c.database.GetLatestPredictions(ctx)
+	return predictions, generatedAt, err
+}
@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPredictionsCache_Latest(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockDB.On("GetLatestPredictions", mock.Anything).Return([]Prediction{{StationID: "1", PredictedAvailabilityClass: 2}}, nil).Once()
+	cache := NewPredictionsCache(mockDB, time.Minute)
+	ctx := context.Background()
+
+	first, generatedAt, err := cache.Latest(ctx)
+	require.NoError(t, err)
+	first[0].PredictedAvailabilityClass = 0
+
+	// Served from the snapshot, unaffected by the caller's changes.
+	second, secondGeneratedAt, err := cache.Latest(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, second[0].PredictedAvailabilityClass)
+	assert.Equal(t, generatedAt, secondGeneratedAt)
+	mockDB.AssertNumberOfCalls(t, "GetLatestPredictions", 1)
+
+	// A stale snapshot is reloaded.
+	cache.current.Load().GeneratedAt = time.Now().Add(-2 * time.Minute)
+	mockDB.On("GetLatestPredictions", mock.Anything).Return([]Prediction{{StationID: "1", PredictedAvailabilityClass: 1}}, nil).Once()
+	third, _, err := cache.Latest(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, third[0].PredictedAvailabilityClass)
+	mockDB.AssertExpectations(t)
+}
+
+func TestPredictionsCache_RefreshSurvivesFirstCallerCancel(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mockDB := new(MockDatabase)
+	mockDB.On("GetLatestPredictions", mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil })).
+		Run(func(args mock.Arguments) {
+			close(started)
+			<-release
+		}).
+		Return([]Prediction{{StationID: "1"}}, nil).Once()
+	cache := NewPredictionsCache(mockDB, time.Minute)
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := cache.Refresh(firstCtx)
+		firstErr <- err
+	}()
+	<-started
+
+	second := make(chan *PredictionsSnapshot, 1)
+	go func() {
+		snapshot, err := cache.Refresh(context.Background())
+		assert.NoError(t, err)
+		second <- snapshot
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancelFirst()
+	assert.ErrorIs(t, <-firstErr, context.Canceled)
+	close(release)
+	assert.Len(t, (<-second).Predictions, 1)
+	mockDB.AssertExpectations(t)
+}
+
+func TestPredictionsCache_Disabled(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockDB.On("GetLatestPredictions", mock.Anything).Return([]Prediction{{StationID: "1"}}, nil)
+	cache := NewPredictionsCache(mockDB, 0)
+
+	for range 2 {
+		_, _, err := cache.Latest(context.Background())
+		require.NoError(t, err)
+	}
+	mockDB.AssertNumberOfCalls(t, "GetLatestPredictions", 2)
+	assert.Nil(t, cache.current.Load())
+}
+
+func TestPredictionsCache_ReloadFailure(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockDB.On("GetLatestPredictions", mock.Anything).Return([]Prediction{{StationID: "1"}}, nil).Once()
+	mockDB.On("GetLatestPredictions", mock.Anything).Return([]Prediction(nil), assert.AnError).Once()
+	cache := NewPredictionsCache(mockDB, time.Hour)
+
+	_, err := cache.Refresh(context.Background())
+	require.NoError(t, err)
+
+	// The old snapshot is not served after a failed reload.
+	_, err = cache.Reload(context.Background())
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Nil(t, cache.current.Load())
+}
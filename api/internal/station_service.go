@@ -2,63 +2,741 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 )
 
 type StationService struct {
 	database    DatabaseInterface
 	divvyClient DivvyClientInterface
+	systems     []SystemConfig
+	refreshes   singleflight.Group
+	snapshot    snapshotStore
+	flags       *FeatureFlags
+	live        *LiveHub
+	webhooks    *WebhookNotifier
+
+	anomaly      AnomalyConfig
+	availability AvailabilityThresholds
+
+	unmatchedStatusMode string
+
+	// persistEveryN implements AVAILABILITY_PERSIST_EVERY_N. sincePersist
+	// counts each system's cycles held back since its last stored one, and
+	// unpersisted keeps their availability, keyed by station ID, for the
+	// snapshot.
+	persistEveryN int
+	persistMu     sync.Mutex
+	sincePersist  map[string]int
+	unpersisted   map[string]StationAvailability
+
+	// stations caches each system's station_information between
+	// STATION_INFO_REFRESH_MIN refreshes.
+	stationInfoEvery time.Duration
+	stationsMu       sync.Mutex
+	stations         map[string]cachedStationList
+
+	// retry holds the REFRESH_RETRY_* settings each cycle's retryBudget
+	// starts from.
+	retry TimingConfig
+
+	// refreshTimeout bounds one refresh to the collection interval, so a
+	// hung refresh cannot overlap the next cycle's.
+	refreshTimeout time.Duration
+}
+
+type cachedStationList struct {
+	stations  []DivvyStation
+	fetchedAt time.Time
 }
 
-func NewStationService(database DatabaseInterface, divvyClient DivvyClientInterface) *StationService {
+func NewStationService(database DatabaseInterface, divvyClient DivvyClientInterface, config *Config) *StationService {
 	return &StationService{
 		database:    database,
 		divvyClient: divvyClient,
+		systems:     config.Divvy.Systems,
+
+		anomaly:      config.Anomaly,
+		availability: config.Availability,
+
+		unmatchedStatusMode: config.Divvy.UnmatchedStatusMode,
+
+		persistEveryN: config.Timing.AvailabilityPersistEveryN,
+		sincePersist:  map[string]int{},
+		unpersisted:   map[string]StationAvailability{},
+
+		stationInfoEvery: time.Duration(config.Timing.StationInfoRefreshMin) * time.Minute,
+		stations:         map[string]cachedStationList{},
+
+		retry: config.Timing,
+
+		refreshTimeout: time.Duration(config.Timing.DataCollectionIntervalMin) * time.Minute,
 	}
 }
 
+// RefreshStationData ingests every configured system. A failing system does
+// not stop the others from being refreshed; all failures are returned joined.
+// Overlapping calls (e.g. the startup refresh and the first scheduled one)
+// coalesce into a single refresh whose result is shared by all callers.
+//
+// The shared refresh is detached from the caller that started it, so a
+// client disconnecting from POST /api/refresh does not cancel it for the
+// others, and is bounded by refreshTimeout instead. A caller whose ctx is
+// done stops waiting and gets ctx.Err() while the refresh carries on.
 func (s *StationService) RefreshStationData(ctx context.Context) error {
-	stations, statuses, err := s.divvyClient.FetchStationData(ctx)
+	results := s.refreshes.DoChan("refresh", func() (interface{}, error) {
+		refreshCtx, cancel := s.detachedContext(ctx)
+		defer cancel()
+		if err := s.refreshAllSystems(refreshCtx); err != nil {
+			return nil, err
+		}
+		s.rebuildSnapshot(refreshCtx)
+		return nil, nil
+	})
+	select {
+	case result := <-results:
+		if result.Shared {
+			log.Println("Station data refresh deduplicated with a concurrent refresh")
+		}
+		return result.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// detachedContext returns a context for a shared refresh started by ctx:
+// it keeps ctx's values but not its cancellation, and is bounded by
+// refreshTimeout instead.
+func (s *StationService) detachedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.refreshTimeout > 0 {
+		return context.WithTimeout(context.WithoutCancel(ctx), s.refreshTimeout)
+	}
+	return context.WithCancel(context.WithoutCancel(ctx))
+}
+
+// Snapshot returns the pre-rendered stations response from the last
+// successful refresh, or nil if none has completed yet.
+func (s *StationService) Snapshot() *StationsSnapshot {
+	return s.snapshot.Load()
+}
+
+// rebuildSnapshot re-renders the stations snapshot, pushes it to live
+// clients and evaluates station webhooks against it. Failures keep the
+// previous snapshot in place rather than failing the refresh.
+func (s *StationService) rebuildSnapshot(ctx context.Context) {
+	stations, err := s.database.GetStationsWithAvailability(ctx, "")
 	if err != nil {
+		log.Printf("Failed to rebuild stations snapshot: %v", err)
+		return
+	}
+	s.OverlayUnpersisted(stations)
+	colorStations(stations, s.availability)
+	if err := s.snapshot.Store(stations); err != nil {
+		log.Printf("Failed to render stations snapshot: %v", err)
+		return
+	}
+	log.Printf("Rebuilt stations snapshot with %d stations", len(stations))
+	s.live.Broadcast(s.snapshot.Load().Body)
+	s.webhooks.Evaluate(ctx, stations)
+}
+
+func (s *StationService) refreshAllSystems(ctx context.Context) (err error) {
+	ctx, span := startSpan(ctx, "station.refresh")
+	defer func() { endSpan(span, err) }()
+
+	budget := newRetryBudget(s.retry)
+	var errs []error
+	for i, system := range s.systems {
+		if err := s.refreshSystem(ctx, system, budget); err != nil {
+			log.Printf("Refresh failed for system %s: %v", system.ID, err)
+			errs = append(errs, fmt.Errorf("system %s: %w", system.ID, err))
+			if errors.Is(err, ErrRetryBudgetExhausted) {
+				if skipped := len(s.systems) - i - 1; skipped > 0 {
+					log.Printf("Skipping %d remaining systems until the next cycle", skipped)
+				}
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *StationService) refreshSystem(ctx context.Context, system SystemConfig, budget *retryBudget) (err error) {
+	ctx, span := startSpan(ctx, "station.refresh_system", attribute.String("system.id", system.ID))
+	defer func() { endSpan(span, err) }()
+
+	if stations, ok := s.cachedStations(system.ID); ok {
+		span.SetAttributes(attribute.Bool("stations.cached", true))
+		err = s.refreshAvailability(ctx, system, stations, budget)
+	} else {
+		err = s.refreshStations(ctx, system, budget)
+	}
+	if err != nil || system.FreeBikeStatusURL == "" {
 		return err
 	}
+	// Free bikes are supplementary: the stations are already stored, so a
+	// failed free_bike_status fetch must not fail the refresh or hold back
+	// the snapshot.
+	if err := s.refreshFreeBikes(ctx, system, budget); err != nil {
+		freeBikeRefreshFailures.WithLabelValues(system.ID).Inc()
+		log.Printf("Warning: %s free bike refresh failed, keeping the previous free bikes: %v", system.ID, err)
+	}
+	return nil
+}
+
+// refreshStations fetches both feeds, upserts the stations and caches them
+// for STATION_INFO_REFRESH_MIN, then stores the availability.
+func (s *StationService) refreshStations(ctx context.Context, system SystemConfig, budget *retryBudget) error {
+	var stations []DivvyStation
+	var statuses []DivvyStationStatus
+	err := budget.do(ctx, "fetch "+system.ID+" station data", isRetryableFetch, func() (err error) {
+		stations, statuses, err = s.divvyClient.FetchStationData(ctx, system)
+		return err
+	})
+	var staleErr *StaleFeedError
+	if errors.As(err, &staleErr) {
+		noteStaleFeed(ctx, staleErr)
+	} else if err != nil {
+		return err
+	}
+	// Last good data is already stored; storing it again would duplicate
+	// availability rows under a fresh recorded_at.
+	staleStations := staleErr != nil && slices.Contains(staleErr.Feeds, feedStationInformation)
+	staleStatuses := staleErr != nil && slices.Contains(staleErr.Feeds, feedStationStatus)
+
+	stations = dedupeByStationID(system.ID, feedStationInformation, stations, func(s DivvyStation) string { return s.StationID })
+	statuses = dedupeByStationID(system.ID, feedStationStatus, statuses, func(s DivvyStationStatus) string { return s.StationID })
 
-	dbStations := make([]Station, len(stations))
-	for i, divvyStation := range stations {
-		dbStations[i] = s.convertToStation(divvyStation)
+	ids := make([]string, 0, len(stations)+len(statuses))
+	for _, station := range stations {
+		ids = append(ids, station.StationID)
 	}
+	for _, status := range statuses {
+		ids = append(ids, status.StationID)
+	}
+	foreign, err := s.stationIDsOfOtherSystems(ctx, system.ID, ids, budget)
+	if err != nil {
+		return err
+	}
+	stations = slices.DeleteFunc(stations, func(s DivvyStation) bool { return foreign[s.StationID] })
+	statuses = slices.DeleteFunc(statuses, func(s DivvyStationStatus) bool { return foreign[s.StationID] })
+
+	statuses, placeholders := s.matchStatuses(system.ID, stations, statuses)
 
+	dbStations := s.convertToStations(system.ID, stations)
+	if !staleStations {
+		reportInvalidStations(system.ID, dbStations)
+	}
+
+	if !staleStations {
+		err := budget.do(ctx, "store "+system.ID+" stations", isTransientConnError, func() error {
+			return s.database.UpsertStations(ctx, dbStations)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store stations: %w", err)
+		}
+		s.cacheStations(system.ID, stations)
+	}
+	if err := s.storePlaceholders(ctx, system.ID, placeholders, budget); err != nil {
+		return err
+	}
+
+	if staleStatuses {
+		return nil
+	}
+	return s.storeAvailability(ctx, system.ID, dbStations, statuses, budget)
+}
+
+// maxLoggedInvalidStations bounds how many invalid stations one refresh
+// names in its warning.
+const maxLoggedInvalidStations = 20
+
+// reportInvalidStations counts and logs every station in a fresh
+// station_information fetch that fails validation, naming up to
+// maxLoggedInvalidStations of them. They are still stored; a dry-run refresh
+// lists them all.
+func reportInvalidStations(systemID string, stations []Station) {
+	errs := ValidateAll(stations)
+	if len(errs) == 0 {
+		return
+	}
+	stationValidationFailures.WithLabelValues(systemID).Add(float64(len(errs)))
+
+	named := make([]string, 0, min(len(errs), maxLoggedInvalidStations))
+	for _, err := range errs[:cap(named)] {
+		named = append(named, fmt.Sprintf("%s (%s)", err.StationID, err.Message))
+	}
+	more := ""
+	if extra := len(errs) - len(named); extra > 0 {
+		more = fmt.Sprintf(" and %d more", extra)
+	}
+	hotWarnings.Printf("invalid-stations-"+systemID, "Warning: %s station_information has %d invalid stations: %s%s",
+		systemID, len(errs), strings.Join(named, ", "), more)
+}
+
+// DryRunRefresh fetches every system's station feeds and validates them as
+// a refresh would, reporting every invalid station instead of storing
+// anything. A system whose fetch fails reports the error and the others are
+// still checked. As with RefreshStationData, overlapping calls share one
+// detached run, so repeated dry-run requests cannot multiply feed fetches; a
+// caller whose ctx is done gets nil.
+func (s *StationService) DryRunRefresh(ctx context.Context) []RefreshDryRun {
+	results := s.refreshes.DoChan("dry-run", func() (interface{}, error) {
+		dryRunCtx, cancel := s.detachedContext(ctx)
+		defer cancel()
+		return s.dryRunAllSystems(dryRunCtx), nil
+	})
+	select {
+	case result := <-results:
+		return result.Val.([]RefreshDryRun)
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+func (s *StationService) dryRunAllSystems(ctx context.Context) []RefreshDryRun {
+	results := make([]RefreshDryRun, 0, len(s.systems))
+	for _, system := range s.systems {
+		result := RefreshDryRun{SystemID: system.ID, ValidationErrors: []StationValidationError{}}
+
+		// As in ValidateFeed, an empty system ID keeps the fetch out of the
+		// per-system station count tracking and last good data.
+		fetch := SystemConfig{StationInfoURL: system.StationInfoURL, StationStatusURL: system.StationStatusURL}
+		stations, statuses, err := s.divvyClient.FetchStationData(ctx, fetch)
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+		stations = dedupeByStationID(system.ID, feedStationInformation, stations, func(s DivvyStation) string { return s.StationID })
+
+		result.StationCount = len(stations)
+		result.StatusCount = len(statuses)
+		if errs := ValidateAll(s.convertToStations(system.ID, stations)); errs != nil {
+			result.ValidationErrors = errs
+		}
+		result.InvalidCount = len(result.ValidationErrors)
+		results = append(results, result)
+	}
+	return results
+}
+
+// refreshAvailability fetches only station_status and stores it against
+// the cached stations.
+func (s *StationService) refreshAvailability(ctx context.Context, system SystemConfig, stations []DivvyStation, budget *retryBudget) error {
+	var statuses []DivvyStationStatus
+	err := budget.do(ctx, "fetch "+system.ID+" station status", isRetryableFetch, func() (err error) {
+		statuses, err = s.divvyClient.FetchStationStatus(ctx, system)
+		return err
+	})
+	var staleErr *StaleFeedError
+	if errors.As(err, &staleErr) {
+		// As in refreshStations, the last good statuses are already stored.
+		noteStaleFeed(ctx, staleErr)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	statuses = dedupeByStationID(system.ID, feedStationStatus, statuses, func(s DivvyStationStatus) string { return s.StationID })
+	ids := make([]string, len(statuses))
+	for i, status := range statuses {
+		ids[i] = status.StationID
+	}
+	foreign, err := s.stationIDsOfOtherSystems(ctx, system.ID, ids, budget)
+	if err != nil {
+		return err
+	}
+	statuses = slices.DeleteFunc(statuses, func(s DivvyStationStatus) bool { return foreign[s.StationID] })
+	matched, placeholders := s.matchStatuses(system.ID, stations, statuses)
+	if len(matched) < len(statuses) || len(placeholders) > 0 {
+		// A station new since the last station_information fetch; pick it
+		// up on the next cycle rather than at the next scheduled refresh.
+		s.invalidateStations(system.ID)
+	}
+	if err := s.storePlaceholders(ctx, system.ID, placeholders, budget); err != nil {
+		return err
+	}
+
+	return s.storeAvailability(ctx, system.ID, s.convertToStations(system.ID, stations), matched, budget)
+}
+
+// stationIDsOfOtherSystems returns which of ids another configured system
+// already stores. Stations are keyed by station ID alone, so storing them for
+// this system too would overwrite the other system's station and mix the two
+// systems' availability; they are dropped from the refresh, logged and
+// counted instead. With a single system there is nothing to check.
+func (s *StationService) stationIDsOfOtherSystems(ctx context.Context, systemID string, ids []string, budget *retryBudget) (map[string]bool, error) {
+	if len(s.systems) < 2 || len(ids) == 0 {
+		return nil, nil
+	}
+	var conflicts []string
+	err := budget.do(ctx, "check "+systemID+" station IDs", isTransientConnError, func() (err error) {
+		conflicts, err = s.database.GetStationIDsOfOtherSystems(ctx, systemID, ids)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check station IDs: %w", err)
+	}
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+	stationIDConflicts.WithLabelValues(systemID).Add(float64(len(conflicts)))
+	hotWarnings.Printf("station-id-conflicts-"+systemID, "Warning: %s feeds list %d station IDs stored for another system, skipping them: %v", systemID, len(conflicts), conflicts)
+	foreign := make(map[string]bool, len(conflicts))
+	for _, id := range conflicts {
+		foreign[id] = true
+	}
+	return foreign, nil
+}
+
+// storePlaceholders inserts the placeholder stations from matchStatuses,
+// leaving any that already exist untouched.
+func (s *StationService) storePlaceholders(ctx context.Context, systemID string, placeholders []Station, budget *retryBudget) error {
+	if len(placeholders) == 0 {
+		return nil
+	}
+	err := budget.do(ctx, "store "+systemID+" placeholder stations", isTransientConnError, func() error {
+		return s.database.InsertPlaceholderStations(ctx, placeholders)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store placeholder stations: %w", err)
+	}
+	return nil
+}
+
+// noteStaleFeed logs and counts a refresh that fell back to last good data.
+func noteStaleFeed(ctx context.Context, staleErr *StaleFeedError) {
+	log.Printf("Warning: %v", staleErr)
+	for _, feed := range staleErr.Feeds {
+		staleFeedRefreshes.WithLabelValues(staleErr.SystemID, feed).Inc()
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.StringSlice("feed.stale", staleErr.Feeds))
+}
+
+// storeAvailability stores, or holds back per AVAILABILITY_PERSIST_EVERY_N,
+// availability for stations already stored.
+func (s *StationService) storeAvailability(ctx context.Context, systemID string, dbStations []Station, statuses []DivvyStationStatus, budget *retryBudget) error {
 	availabilities := make([]StationAvailability, len(statuses))
 	for i, divvyStatus := range statuses {
-		availabilities[i] = s.convertToAvailability(divvyStatus)
+		availabilities[i] = s.convertToAvailability(systemID, divvyStatus)
 	}
 
-	if err := s.database.UpsertStations(ctx, dbStations); err != nil {
-		return fmt.Errorf("failed to store stations: %w", err)
+	if !s.shouldPersist(systemID) {
+		s.holdUnpersisted(systemID, availabilities)
+		log.Printf("Holding availability for %d %s stations in memory until the next persisted cycle", len(statuses), systemID)
+		return nil
 	}
 
-	if err := s.database.InsertAvailabilities(ctx, availabilities); err != nil {
+	previous := s.previousSnapshot(ctx)
+
+	// InsertAvailabilities is one transaction, so an attempt that failed
+	// before COMMIT stored nothing. A failed COMMIT may have stored the rows
+	// anyway; isTransientConnError refuses to retry it, so rows are never
+	// inserted twice.
+	err := budget.do(ctx, "store "+systemID+" availability", isTransientConnError, func() error {
+		return s.database.InsertAvailabilities(ctx, availabilities)
+	})
+	if err != nil {
 		return fmt.Errorf("failed to store availabilities: %w", err)
 	}
+	s.markPersisted(systemID)
+
+	if previous != nil {
+		s.recordAnomalies(ctx, systemID, previous, dbStations, availabilities)
+	}
+
+	log.Printf("Stored data for %d %s stations", len(dbStations), systemID)
+	return nil
+}
+
+// cachedStations returns systemID's station list while it is younger than
+// STATION_INFO_REFRESH_MIN.
+func (s *StationService) cachedStations(systemID string) ([]DivvyStation, bool) {
+	if s.stationInfoEvery <= 0 {
+		return nil, false
+	}
+	s.stationsMu.Lock()
+	defer s.stationsMu.Unlock()
+	cached, ok := s.stations[systemID]
+	if !ok || time.Since(cached.fetchedAt) >= s.stationInfoEvery {
+		return nil, false
+	}
+	return cached.stations, true
+}
+
+func (s *StationService) invalidateStations(systemID string) {
+	s.stationsMu.Lock()
+	defer s.stationsMu.Unlock()
+	delete(s.stations, systemID)
+}
+
+func (s *StationService) cacheStations(systemID string, stations []DivvyStation) {
+	if s.stationInfoEvery <= 0 {
+		return
+	}
+	s.stationsMu.Lock()
+	defer s.stationsMu.Unlock()
+	s.stations[systemID] = cachedStationList{stations: stations, fetchedAt: time.Now()}
+}
+
+// shouldPersist reports whether this cycle's availability for systemID is
+// stored: the first cycle and every persistEveryN-th one after the last
+// stored cycle are. A failed store is retried on the next cycle.
+func (s *StationService) shouldPersist(systemID string) bool {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	held, ok := s.sincePersist[systemID]
+	return !ok || held+1 >= s.persistEveryN
+}
+
+// holdUnpersisted keeps a cycle's availability in memory in place of
+// storing it, stamped with when it would have been recorded.
+func (s *StationService) holdUnpersisted(systemID string, availabilities []StationAvailability) {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	s.sincePersist[systemID]++
+	now := time.Now().UTC()
+	for _, availability := range availabilities {
+		availability.RecordedAt = now
+		s.unpersisted[availability.StationID] = availability
+	}
+}
+
+// markPersisted drops systemID's held availability once a cycle is stored.
+func (s *StationService) markPersisted(systemID string) {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	s.sincePersist[systemID] = 0
+	for stationID, availability := range s.unpersisted {
+		if availability.SystemID == systemID {
+			delete(s.unpersisted, stationID)
+		}
+	}
+}
+
+// OverlayUnpersisted replaces stored availability with availability held
+// back by AVAILABILITY_PERSIST_EVERY_N, which is always newer. Every read of
+// stored station availability goes through it so no endpoint lags the
+// snapshot by up to N-1 cycles.
+func (s *StationService) OverlayUnpersisted(stations []StationWithAvailability) {
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	for i := range stations {
+		availability, ok := s.unpersisted[stations[i].StationID]
+		if !ok {
+			continue
+		}
+		stations[i].NumBikesAvailable = availability.NumBikesAvailable
+		stations[i].NumDocksAvailable = availability.NumDocksAvailable
+		stations[i].IsInstalled = availability.IsInstalled
+		stations[i].IsRenting = availability.IsRenting
+		stations[i].IsReturning = availability.IsReturning
+		stations[i].LastReported = availability.LastReported
+		stations[i].IsStale = false
+	}
+}
+
+// GetRecentAvailability is Database.GetRecentAvailability with the
+// availability held back by AVAILABILITY_PERSIST_EVERY_N, which is newer
+// than any stored cycle, in front of it.
+func (s *StationService) GetRecentAvailability(ctx context.Context) ([]StationAvailability, error) {
+	records, err := s.database.GetRecentAvailability(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.persistMu.Lock()
+	held := make([]StationAvailability, 0, len(s.unpersisted)+len(records))
+	for _, availability := range s.unpersisted {
+		held = append(held, availability)
+	}
+	s.persistMu.Unlock()
+
+	slices.SortFunc(held, func(a, b StationAvailability) int {
+		return b.RecordedAt.Compare(a.RecordedAt)
+	})
+	return append(held, records...), nil
+}
+
+// previousSnapshot loads the latest stored availability for anomaly
+// detection. It returns nil when detection is disabled (by thresholds or the
+// anomaly_detection flag) or the snapshot is unavailable; anomaly detection
+// never fails a refresh.
+func (s *StationService) previousSnapshot(ctx context.Context) map[string]StationAvailability {
+	if !s.anomaly.Enabled() || !s.flags.Enabled(FlagAnomalyDetection) {
+		return nil
+	}
+	previous, err := s.database.GetLatestAvailabilityMap(ctx)
+	if err != nil {
+		log.Printf("Skipping anomaly detection, failed to load previous availability: %v", err)
+		return nil
+	}
+	return previous
+}
+
+// recordAnomalies flags swings and impossible updates in a system's freshly
+// ingested availability. Impossible updates are logged and counted in
+// divvy_availability_anomalies_total as they point at a corrupt feed.
+func (s *StationService) recordAnomalies(ctx context.Context, systemID string, previous map[string]StationAvailability, stations []Station, availabilities []StationAvailability) {
+	var anomalies []AvailabilityAnomaly
+	if s.anomaly.SwingThresholdPct > 0 {
+		anomalies = detectAnomalies(previous, availabilities, s.anomaly.SwingThresholdPct)
+	}
 
-	log.Printf("Stored data for %d stations", len(stations))
+	capacities := make(map[string]int, len(stations))
+	for _, station := range stations {
+		capacities[station.StationID] = station.Capacity
+	}
+	impossible := detectImpossibleAvailability(previous, availabilities, capacities, s.anomaly.OverCapacityPct)
+	for _, a := range impossible {
+		hotWarnings.Printf("anomaly:"+systemID+":"+a.Kind, "Impossible availability for %s station %s (%s): %s",
+			systemID, a.StationID, a.Kind, a.Detail)
+	}
+	anomalies = append(anomalies, impossible...)
+
+	if len(anomalies) == 0 {
+		return
+	}
+	for _, a := range anomalies {
+		availabilityAnomalies.WithLabelValues(systemID, a.Kind).Inc()
+	}
+	if err := s.database.InsertAnomalies(ctx, anomalies); err != nil {
+		log.Printf("Failed to store %d availability anomalies: %v", len(anomalies), err)
+		return
+	}
+	log.Printf("Flagged %d availability anomalies", len(anomalies))
+}
+
+func (s *StationService) refreshFreeBikes(ctx context.Context, system SystemConfig, budget *retryBudget) error {
+	var divvyBikes []DivvyFreeBike
+	err := budget.do(ctx, "fetch "+system.ID+" free bikes", isRetryableFetch, func() (err error) {
+		divvyBikes, err = s.divvyClient.FetchFreeBikes(ctx, system)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	bikes := make([]FreeBike, len(divvyBikes))
+	for i, divvyBike := range divvyBikes {
+		bikes[i] = s.convertToFreeBike(system.ID, divvyBike)
+	}
+
+	err = budget.do(ctx, "store "+system.ID+" free bikes", isTransientConnError, func() error {
+		return s.database.ReplaceFreeBikes(ctx, system.ID, bikes)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store free bikes: %w", err)
+	}
+
+	log.Printf("Stored %d %s free bikes", len(bikes), system.ID)
 	return nil
 }
 
-func (s *StationService) convertToStation(divvyStation DivvyStation) Station {
+// dedupeByStationID keeps the first entry for each station ID, preserving
+// feed order, and logs any IDs that appeared more than once. GBFS feeds have
+// briefly listed stations twice during upstream migrations; without this the
+// last duplicate would silently win the upsert.
+func dedupeByStationID[T any](systemID, feed string, entries []T, stationID func(T) string) []T {
+	seen := make(map[string]int, len(entries))
+	var duplicates []string
+	deduped := make([]T, 0, len(entries))
+	for _, entry := range entries {
+		id := stationID(entry)
+		seen[id]++
+		switch seen[id] {
+		case 1:
+			deduped = append(deduped, entry)
+		case 2:
+			duplicates = append(duplicates, id)
+		}
+	}
+	if len(duplicates) == 0 {
+		return entries
+	}
+	log.Printf("Warning: %s %s feed has duplicate station IDs %v; keeping the first entry of each", systemID, feed, duplicates)
+	return deduped
+}
+
+const (
+	UnmatchedStatusSkip        = "skip"
+	UnmatchedStatusPlaceholder = "placeholder"
+)
+
+// matchStatuses handles statuses for stations missing from stations
+// according to UNMATCHED_STATUS_MODE: they are dropped, or kept along with
+// placeholder stations to store first so their availability has a station
+// to reference. Either way they are logged and counted.
+func (s *StationService) matchStatuses(systemID string, stations []DivvyStation, statuses []DivvyStationStatus) ([]DivvyStationStatus, []Station) {
+	known := make(map[string]bool, len(stations))
+	for _, station := range stations {
+		known[station.StationID] = true
+	}
+
+	placeholder := s.unmatchedStatusMode == UnmatchedStatusPlaceholder
+	var unmatched []string
+	var placeholders []Station
+	matched := make([]DivvyStationStatus, 0, len(statuses))
+	for _, status := range statuses {
+		if !known[status.StationID] {
+			unmatched = append(unmatched, status.StationID)
+			if !placeholder {
+				continue
+			}
+			placeholders = append(placeholders, Station{StationID: status.StationID, SystemID: systemID, Name: status.StationID})
+		}
+		matched = append(matched, status)
+	}
+	if len(unmatched) == 0 {
+		return statuses, nil
+	}
+
+	action := UnmatchedStatusSkip
+	if placeholder {
+		action = UnmatchedStatusPlaceholder
+	}
+	unmatchedStatuses.WithLabelValues(systemID, action).Add(float64(len(unmatched)))
+	if placeholder {
+		log.Printf("Warning: %s station_status lists unknown station IDs %v; storing placeholder stations", systemID, unmatched)
+	} else {
+		log.Printf("Warning: %s station_status lists unknown station IDs %v; skipping their status", systemID, unmatched)
+	}
+	return matched, placeholders
+}
+
+func (s *StationService) convertToStations(systemID string, divvyStations []DivvyStation) []Station {
+	dbStations := make([]Station, len(divvyStations))
+	for i, divvyStation := range divvyStations {
+		dbStations[i] = s.convertToStation(systemID, divvyStation)
+	}
+	return dbStations
+}
+
+func (s *StationService) convertToStation(systemID string, divvyStation DivvyStation) Station {
 	return Station{
 		StationID: divvyStation.StationID,
+		SystemID:  systemID,
 		Name:      divvyStation.Name,
-		Lat:       divvyStation.Lat,
-		Lon:       divvyStation.Lon,
+		Lat:       Coordinate(divvyStation.Lat),
+		Lon:       Coordinate(divvyStation.Lon),
 		Capacity:  divvyStation.Capacity,
 	}
 }
 
-func (s *StationService) convertToAvailability(divvyStatus DivvyStationStatus) StationAvailability {
+func (s *StationService) convertToAvailability(systemID string, divvyStatus DivvyStationStatus) StationAvailability {
 	return StationAvailability{
 		StationID:         divvyStatus.StationID,
+		SystemID:          systemID,
 		NumBikesAvailable: divvyStatus.NumBikesAvailable,
 		NumDocksAvailable: divvyStatus.NumDocksAvailable,
 		IsInstalled:       divvyStatus.IsInstalled,
@@ -67,3 +745,15 @@ func (s *StationService) convertToAvailability(divvyStatus DivvyStationStatus) S
 		LastReported:      divvyStatus.LastReported,
 	}
 }
+
+func (s *StationService) convertToFreeBike(systemID string, divvyBike DivvyFreeBike) FreeBike {
+	return FreeBike{
+		BikeID:        divvyBike.BikeID,
+		SystemID:      systemID,
+		Lat:           Coordinate(divvyBike.Lat),
+		Lon:           Coordinate(divvyBike.Lon),
+		IsReserved:    divvyBike.IsReserved,
+		IsDisabled:    divvyBike.IsDisabled,
+		VehicleTypeID: divvyBike.VehicleTypeID,
+	}
+}
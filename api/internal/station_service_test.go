@@ -2,8 +2,11 @@ package internal
 
 import (
 	"context"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -59,6 +62,23 @@ func TestStationService_RefreshStationData(t *testing.T) {
 			expectedUpsertCall: 1,
 			expectedInsertCall: 1,
 		},
+		{
+			name:         "stale statuses are not stored again",
+			mockStations: []DivvyStation{{StationID: "123", Name: "Test Station", Capacity: 15}},
+			mockStatuses: []DivvyStationStatus{{StationID: "123", NumBikesAvailable: 5}},
+			fetchError: &StaleFeedError{SystemID: "divvy", Feeds: []string{feedStationStatus},
+				Ages: map[string]time.Duration{feedStationStatus: time.Minute}, Err: assert.AnError},
+			expectErr:          false,
+			expectedUpsertCall: 1,
+		},
+		{
+			name:         "stale station information and statuses",
+			mockStations: []DivvyStation{{StationID: "123", Name: "Test Station", Capacity: 15}},
+			mockStatuses: []DivvyStationStatus{{StationID: "123", NumBikesAvailable: 5}},
+			fetchError: &StaleFeedError{SystemID: "divvy", Feeds: []string{feedStationInformation, feedStationStatus},
+				Ages: map[string]time.Duration{}, Err: assert.AnError},
+			expectErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -66,27 +86,27 @@ func TestStationService_RefreshStationData(t *testing.T) {
 			mockDB := new(MockDatabase)
 			mockClient := new(MockDivvyClient)
 
-			if tt.fetchError != nil {
-				mockClient.On("FetchStationData", mock.Anything).Return(
-					([]DivvyStation)(nil), ([]DivvyStationStatus)(nil), tt.fetchError)
-			} else {
-				mockClient.On("FetchStationData", mock.Anything).Return(
-					tt.mockStations, tt.mockStatuses, nil)
+			mockClient.On("FetchStationData", mock.Anything, mock.Anything).Return(
+				tt.mockStations, tt.mockStatuses, tt.fetchError)
 
-				if tt.expectedUpsertCall > 0 {
-					mockDB.On("UpsertStations", mock.Anything, mock.MatchedBy(func(stations []Station) bool {
-						return len(stations) == len(tt.mockStations)
-					})).Return(tt.upsertError).Times(1)
-				}
+			if tt.expectedUpsertCall > 0 {
+				mockDB.On("UpsertStations", mock.Anything, mock.MatchedBy(func(stations []Station) bool {
+					return len(stations) == len(tt.mockStations)
+				})).Return(tt.upsertError).Times(1)
+			}
 
-				if tt.expectedInsertCall > 0 {
-					mockDB.On("InsertAvailabilities", mock.Anything, mock.MatchedBy(func(availabilities []StationAvailability) bool {
-						return len(availabilities) == len(tt.mockStatuses)
-					})).Return(tt.insertError).Times(1)
-				}
+			if tt.expectedInsertCall > 0 {
+				mockDB.On("InsertAvailabilities", mock.Anything, mock.MatchedBy(func(availabilities []StationAvailability) bool {
+					return len(availabilities) == len(tt.mockStatuses)
+				})).Return(tt.insertError).Times(1)
+			}
+
+			if !tt.expectErr {
+				mockDB.On("GetStationsWithAvailability", mock.Anything, "").
+					Return([]StationWithAvailability{}, nil).Once()
 			}
 
-			service := NewStationService(mockDB, mockClient)
+			service := NewStationService(mockDB, mockClient, NewTestConfig())
 			err := service.RefreshStationData(context.Background())
 
 			if tt.expectErr {
@@ -96,9 +116,7 @@ func TestStationService_RefreshStationData(t *testing.T) {
 			}
 
 			mockClient.AssertExpectations(t)
-			if tt.fetchError == nil {
-				mockDB.AssertExpectations(t)
-			}
+			mockDB.AssertExpectations(t)
 		})
 	}
 }
@@ -114,12 +132,13 @@ func TestStationService_ConvertToStation(t *testing.T) {
 		Capacity:  20,
 	}
 
-	result := service.convertToStation(divvyStation)
+	result := service.convertToStation("chi", divvyStation)
 
 	assert.Equal(t, divvyStation.StationID, result.StationID)
+	assert.Equal(t, "chi", result.SystemID)
 	assert.Equal(t, divvyStation.Name, result.Name)
-	assert.Equal(t, divvyStation.Lat, result.Lat)
-	assert.Equal(t, divvyStation.Lon, result.Lon)
+	assert.Equal(t, Coordinate(divvyStation.Lat), result.Lat)
+	assert.Equal(t, Coordinate(divvyStation.Lon), result.Lon)
 	assert.Equal(t, divvyStation.Capacity, result.Capacity)
 }
 
@@ -136,9 +155,10 @@ func TestStationService_ConvertToAvailability(t *testing.T) {
 		LastReported:      1640995200,
 	}
 
-	result := service.convertToAvailability(divvyStatus)
+	result := service.convertToAvailability("chi", divvyStatus)
 
 	assert.Equal(t, divvyStatus.StationID, result.StationID)
+	assert.Equal(t, "chi", result.SystemID)
 	assert.Equal(t, divvyStatus.NumBikesAvailable, result.NumBikesAvailable)
 	assert.Equal(t, divvyStatus.NumDocksAvailable, result.NumDocksAvailable)
 	assert.Equal(t, divvyStatus.IsInstalled, result.IsInstalled)
@@ -146,3 +166,570 @@ func TestStationService_ConvertToAvailability(t *testing.T) {
 	assert.Equal(t, divvyStatus.IsReturning, result.IsReturning)
 	assert.Equal(t, divvyStatus.LastReported, result.LastReported)
 }
+
+func TestStationService_RefreshStationData_MultipleSystems(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockClient := new(MockDivvyClient)
+
+	chi := SystemConfig{ID: "chi", StationInfoURL: "http://chi/info", StationStatusURL: "http://chi/status"}
+	nyc := SystemConfig{ID: "nyc", StationInfoURL: "http://nyc/info", StationStatusURL: "http://nyc/status"}
+	config := NewTestConfig()
+	config.Divvy.Systems = []SystemConfig{chi, nyc}
+
+	mockClient.On("FetchStationData", mock.Anything, chi).Return(
+		([]DivvyStation)(nil), ([]DivvyStationStatus)(nil), assert.AnError)
+	mockClient.On("FetchStationData", mock.Anything, nyc).Return(
+		[]DivvyStation{{StationID: "n1", Name: "NYC Station"}, {StationID: "shared", Name: "Also Chicago's"}},
+		[]DivvyStationStatus{{StationID: "n1", NumBikesAvailable: 3}, {StationID: "shared"}}, nil)
+
+	// "shared" is already stored for chi, so nyc's copy is dropped.
+	mockDB.On("GetStationIDsOfOtherSystems", mock.Anything, "nyc", []string{"n1", "shared", "n1", "shared"}).
+		Return([]string{"shared"}, nil).Once()
+	mockDB.On("UpsertStations", mock.Anything, mock.MatchedBy(func(stations []Station) bool {
+		return len(stations) == 1 && stations[0].StationID == "n1" && stations[0].SystemID == "nyc"
+	})).Return(nil).Once()
+	mockDB.On("InsertAvailabilities", mock.Anything, mock.MatchedBy(func(availabilities []StationAvailability) bool {
+		return len(availabilities) == 1 && availabilities[0].StationID == "n1" && availabilities[0].SystemID == "nyc"
+	})).Return(nil).Once()
+
+	before := testutil.ToFloat64(stationIDConflicts.WithLabelValues("nyc"))
+	service := NewStationService(mockDB, mockClient, config)
+	err := service.RefreshStationData(context.Background())
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "system chi")
+	assert.Equal(t, 1.0, testutil.ToFloat64(stationIDConflicts.WithLabelValues("nyc"))-before)
+	mockClient.AssertExpectations(t)
+	mockDB.AssertExpectations(t)
+}
+
+func TestStationService_RefreshStationData_DuplicateStationIDs(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockClient := new(MockDivvyClient)
+
+	mockClient.On("FetchStationData", mock.Anything, mock.Anything).Return(
+		[]DivvyStation{{StationID: "1", Name: "First"}, {StationID: "2"}, {StationID: "1", Name: "Second"}},
+		[]DivvyStationStatus{{StationID: "1", NumBikesAvailable: 3}, {StationID: "1", NumBikesAvailable: 9}, {StationID: "2"}}, nil)
+	mockDB.On("UpsertStations", mock.Anything, mock.MatchedBy(func(stations []Station) bool {
+		return len(stations) == 2 && stations[0].StationID == "1" && stations[0].Name == "First" && stations[1].StationID == "2"
+	})).Return(nil).Once()
+	mockDB.On("InsertAvailabilities", mock.Anything, mock.MatchedBy(func(availabilities []StationAvailability) bool {
+		return len(availabilities) == 2 && availabilities[0].NumBikesAvailable == 3 && availabilities[1].StationID == "2"
+	})).Return(nil).Once()
+	mockDB.On("GetStationsWithAvailability", mock.Anything, "").Return([]StationWithAvailability{}, nil).Once()
+
+	service := NewStationService(mockDB, mockClient, NewTestConfig())
+	assert.NoError(t, service.RefreshStationData(context.Background()))
+	mockDB.AssertExpectations(t)
+}
+
+func TestStationService_RefreshStationData_CountsInvalidStations(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockClient := new(MockDivvyClient)
+
+	system := SystemConfig{ID: "invalid-count", StationInfoURL: "http://x/info", StationStatusURL: "http://x/status"}
+	config := NewTestConfig()
+	config.Divvy.Systems = []SystemConfig{system}
+
+	mockClient.On("FetchStationData", mock.Anything, system).Return(
+		[]DivvyStation{{StationID: "1", Name: "Valid"}, {StationID: "2"}, {StationID: "3", Capacity: -1}},
+		[]DivvyStationStatus{{StationID: "1"}, {StationID: "2"}, {StationID: "3"}}, nil)
+	mockDB.On("UpsertStations", mock.Anything, mock.MatchedBy(func(stations []Station) bool {
+		return len(stations) == 3
+	})).Return(nil).Once()
+	mockDB.On("InsertAvailabilities", mock.Anything, mock.Anything).Return(nil).Once()
+	mockDB.On("GetStationsWithAvailability", mock.Anything, "").Return([]StationWithAvailability{}, nil).Once()
+
+	before := testutil.ToFloat64(stationValidationFailures.WithLabelValues(system.ID))
+	service := NewStationService(mockDB, mockClient, config)
+	assert.NoError(t, service.RefreshStationData(context.Background()))
+	assert.Equal(t, 2.0, testutil.ToFloat64(stationValidationFailures.WithLabelValues(system.ID))-before)
+	mockDB.AssertExpectations(t)
+}
+
+func TestStationService_DryRunRefresh(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockClient := new(MockDivvyClient)
+
+	chi := SystemConfig{ID: "chi", StationInfoURL: "http://chi/info", StationStatusURL: "http://chi/status"}
+	nyc := SystemConfig{ID: "nyc", StationInfoURL: "http://nyc/info", StationStatusURL: "http://nyc/status"}
+	config := NewTestConfig()
+	config.Divvy.Systems = []SystemConfig{chi, nyc}
+
+	// Dry runs fetch without a system ID so nothing is tracked per system.
+	mockClient.On("FetchStationData", mock.Anything, SystemConfig{StationInfoURL: chi.StationInfoURL, StationStatusURL: chi.StationStatusURL}).Return(
+		[]DivvyStation{{StationID: "c1", Name: "Valid"}, {StationID: "c2"}, {Name: "No ID"}, {StationID: "c2"}},
+		[]DivvyStationStatus{{StationID: "c1"}}, nil)
+	mockClient.On("FetchStationData", mock.Anything, SystemConfig{StationInfoURL: nyc.StationInfoURL, StationStatusURL: nyc.StationStatusURL}).Return(
+		([]DivvyStation)(nil), ([]DivvyStationStatus)(nil), assert.AnError)
+
+	service := NewStationService(mockDB, mockClient, config)
+	results := service.DryRunRefresh(context.Background())
+
+	assert.Equal(t, []RefreshDryRun{
+		{
+			SystemID:     "chi",
+			StationCount: 3,
+			StatusCount:  1,
+			InvalidCount: 2,
+			ValidationErrors: []StationValidationError{
+				{Index: 1, StationID: "c2", Message: "station name is required"},
+				{Index: 2, StationID: "", Message: "station ID is required"},
+			},
+		},
+		{SystemID: "nyc", ValidationErrors: []StationValidationError{}, Error: assert.AnError.Error()},
+	}, results)
+	mockClient.AssertExpectations(t)
+	mockDB.AssertNotCalled(t, "UpsertStations", mock.Anything, mock.Anything)
+}
+
+func TestStationService_DryRunRefresh_Coalesces(t *testing.T) {
+	mockClient := new(MockDivvyClient)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mockClient.On("FetchStationData", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) {
+			close(started)
+			<-release
+		}).
+		Return([]DivvyStation{{StationID: "c1", Name: "Valid"}}, []DivvyStationStatus{}, nil).Once()
+
+	service := NewStationService(new(MockDatabase), mockClient, NewTestConfig())
+
+	first := make(chan []RefreshDryRun, 1)
+	go func() { first <- service.DryRunRefresh(context.Background()) }()
+	<-started
+	second := make(chan []RefreshDryRun, 1)
+	go func() { second <- service.DryRunRefresh(context.Background()) }()
+	// Give the second caller time to join the in-flight dry run.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	assert.Equal(t, <-first, <-second)
+	mockClient.AssertExpectations(t)
+}
+
+func TestStationService_RefreshStationData_UnmatchedStatuses(t *testing.T) {
+	tests := []struct {
+		name                 string
+		mode                 string
+		expectedPlaceholders []Station
+		expectedStatuses     []string
+	}{
+		{name: "skip", mode: UnmatchedStatusSkip, expectedStatuses: []string{"1"}},
+		{
+			name:                 "placeholder",
+			mode:                 UnmatchedStatusPlaceholder,
+			expectedPlaceholders: []Station{{StationID: "new", SystemID: DefaultSystemID, Name: "new"}},
+			expectedStatuses:     []string{"1", "new"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			mockClient := new(MockDivvyClient)
+
+			mockClient.On("FetchStationData", mock.Anything, mock.Anything).Return(
+				[]DivvyStation{{StationID: "1", Name: "First"}},
+				[]DivvyStationStatus{{StationID: "1"}, {StationID: "new", NumBikesAvailable: 4}}, nil)
+			mockDB.On("UpsertStations", mock.Anything, mock.MatchedBy(func(stations []Station) bool {
+				ids := make([]string, len(stations))
+				for i, station := range stations {
+					ids[i] = station.StationID
+				}
+				return assert.ObjectsAreEqual([]string{"1"}, ids)
+			})).Return(nil).Once()
+			if tt.expectedPlaceholders != nil {
+				mockDB.On("InsertPlaceholderStations", mock.Anything, tt.expectedPlaceholders).Return(nil).Once()
+			}
+			mockDB.On("InsertAvailabilities", mock.Anything, mock.MatchedBy(func(availabilities []StationAvailability) bool {
+				ids := make([]string, len(availabilities))
+				for i, availability := range availabilities {
+					ids[i] = availability.StationID
+				}
+				return assert.ObjectsAreEqual(tt.expectedStatuses, ids)
+			})).Return(nil).Once()
+			mockDB.On("GetStationsWithAvailability", mock.Anything, "").Return([]StationWithAvailability{}, nil).Once()
+
+			config := NewTestConfig()
+			config.Divvy.UnmatchedStatusMode = tt.mode
+			service := NewStationService(mockDB, mockClient, config)
+
+			before := testutil.ToFloat64(unmatchedStatuses.WithLabelValues(DefaultSystemID, tt.mode))
+			assert.NoError(t, service.RefreshStationData(context.Background()))
+			assert.Equal(t, before+1, testutil.ToFloat64(unmatchedStatuses.WithLabelValues(DefaultSystemID, tt.mode)))
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestStationService_RefreshStationData_Deduplicates(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockClient := new(MockDivvyClient)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mockClient.On("FetchStationData", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) {
+			close(started)
+			<-release
+		}).
+		Return([]DivvyStation{}, []DivvyStationStatus{}, nil).Once()
+	mockDB.On("UpsertStations", mock.Anything, mock.Anything).Return(nil).Once()
+	mockDB.On("InsertAvailabilities", mock.Anything, mock.Anything).Return(nil).Once()
+	mockDB.On("GetStationsWithAvailability", mock.Anything, "").Return([]StationWithAvailability{}, nil).Once()
+
+	service := NewStationService(mockDB, mockClient, NewTestConfig())
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs[0] = service.RefreshStationData(context.Background())
+	}()
+	<-started
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs[1] = service.RefreshStationData(context.Background())
+	}()
+	// Give the second caller time to join the in-flight refresh.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	mockClient.AssertExpectations(t)
+	mockDB.AssertExpectations(t)
+}
+
+func TestStationService_RefreshStationData_SurvivesFirstCallerCancel(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockClient := new(MockDivvyClient)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mockClient.On("FetchStationData", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			close(started)
+			<-release
+			assert.NoError(t, args.Get(0).(context.Context).Err(), "the shared refresh must not inherit the first caller's cancellation")
+		}).
+		Return([]DivvyStation{}, []DivvyStationStatus{}, nil).Once()
+	mockDB.On("UpsertStations", mock.Anything, mock.Anything).Return(nil).Once()
+	mockDB.On("InsertAvailabilities", mock.Anything, mock.Anything).Return(nil).Once()
+	mockDB.On("GetStationsWithAvailability", mock.Anything, "").Return([]StationWithAvailability{}, nil).Once()
+
+	service := NewStationService(mockDB, mockClient, NewTestConfig())
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() { firstErr <- service.RefreshStationData(firstCtx) }()
+	<-started
+
+	secondErr := make(chan error, 1)
+	go func() { secondErr <- service.RefreshStationData(context.Background()) }()
+	// Give the second caller time to join the in-flight refresh.
+	time.Sleep(20 * time.Millisecond)
+
+	cancelFirst()
+	assert.ErrorIs(t, <-firstErr, context.Canceled)
+	close(release)
+	assert.NoError(t, <-secondErr)
+	mockClient.AssertExpectations(t)
+	mockDB.AssertExpectations(t)
+}
+
+func TestStationService_RefreshStationData_FreeBikes(t *testing.T) {
+	tests := []struct {
+		name       string
+		fetchError error
+	}{
+		{
+			name: "stores free bikes",
+		},
+		{
+			name:       "free bike fetch error does not fail the refresh",
+			fetchError: assert.AnError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			mockClient := new(MockDivvyClient)
+
+			config := NewTestConfig()
+			config.Divvy.Systems[0].FreeBikeStatusURL = "http://localhost/free_bike_status.json"
+
+			mockClient.On("FetchStationData", mock.Anything, mock.Anything).
+				Return([]DivvyStation{}, []DivvyStationStatus{}, nil)
+			mockDB.On("UpsertStations", mock.Anything, mock.Anything).Return(nil)
+			mockDB.On("InsertAvailabilities", mock.Anything, mock.Anything).Return(nil)
+			mockDB.On("GetStationsWithAvailability", mock.Anything, "").Return([]StationWithAvailability{}, nil).Once()
+
+			if tt.fetchError != nil {
+				mockClient.On("FetchFreeBikes", mock.Anything, mock.Anything).
+					Return(([]DivvyFreeBike)(nil), tt.fetchError)
+			} else {
+				mockClient.On("FetchFreeBikes", mock.Anything, mock.Anything).
+					Return([]DivvyFreeBike{{BikeID: "b1", Lat: 41.88, Lon: -87.63}}, nil)
+				mockDB.On("ReplaceFreeBikes", mock.Anything, DefaultSystemID, mock.MatchedBy(func(bikes []FreeBike) bool {
+					return len(bikes) == 1 && bikes[0].BikeID == "b1" && bikes[0].SystemID == DefaultSystemID
+				})).Return(nil)
+			}
+
+			before := testutil.ToFloat64(freeBikeRefreshFailures.WithLabelValues(DefaultSystemID))
+			service := NewStationService(mockDB, mockClient, config)
+			assert.NoError(t, service.RefreshStationData(context.Background()))
+
+			failures := 0.0
+			if tt.fetchError != nil {
+				failures = 1
+			}
+			assert.Equal(t, failures, testutil.ToFloat64(freeBikeRefreshFailures.WithLabelValues(DefaultSystemID))-before)
+			mockClient.AssertExpectations(t)
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestStationService_RefreshStationData_RecordsAnomalies(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockClient := new(MockDivvyClient)
+
+	config := NewTestConfig()
+	config.Anomaly.SwingThresholdPct = 75
+
+	mockClient.On("FetchStationData", mock.Anything, mock.Anything).Return(
+		[]DivvyStation{{StationID: "123", Name: "Test Station", Capacity: 10}},
+		[]DivvyStationStatus{{StationID: "123", NumBikesAvailable: 0, NumDocksAvailable: 10}}, nil)
+	mockDB.On("UpsertStations", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("GetLatestAvailabilityMap", mock.Anything).Return(map[string]StationAvailability{
+		"123": {StationID: "123", NumBikesAvailable: 10, NumDocksAvailable: 0},
+	}, nil)
+	mockDB.On("InsertAvailabilities", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("InsertAnomalies", mock.Anything, mock.MatchedBy(func(anomalies []AvailabilityAnomaly) bool {
+		return len(anomalies) == 1 && anomalies[0].BikesBefore == 10 && anomalies[0].BikesAfter == 0
+	})).Return(nil)
+	mockDB.On("GetStationsWithAvailability", mock.Anything, "").Return([]StationWithAvailability{}, nil)
+
+	service := NewStationService(mockDB, mockClient, config)
+	err := service.RefreshStationData(context.Background())
+
+	assert.NoError(t, err)
+	mockDB.AssertExpectations(t)
+}
+
+func TestStationService_RefreshStationData_RecordsImpossibleAvailability(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockClient := new(MockDivvyClient)
+
+	config := NewTestConfig()
+	config.Anomaly.OverCapacityPct = 25
+
+	mockClient.On("FetchStationData", mock.Anything, mock.Anything).Return(
+		[]DivvyStation{{StationID: "123", Name: "Test Station", Capacity: 10}},
+		[]DivvyStationStatus{{StationID: "123", NumBikesAvailable: 30, NumDocksAvailable: 10, LastReported: 100}}, nil)
+	mockDB.On("UpsertStations", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("GetLatestAvailabilityMap", mock.Anything).Return(map[string]StationAvailability{
+		"123": {StationID: "123", NumBikesAvailable: 30, NumDocksAvailable: 10, LastReported: 200},
+	}, nil)
+	// The suspicious update is still stored.
+	mockDB.On("InsertAvailabilities", mock.Anything, mock.MatchedBy(func(availabilities []StationAvailability) bool {
+		return len(availabilities) == 1 && availabilities[0].NumBikesAvailable == 30
+	})).Return(nil)
+	mockDB.On("InsertAnomalies", mock.Anything, mock.MatchedBy(func(anomalies []AvailabilityAnomaly) bool {
+		return len(anomalies) == 2 && anomalies[0].Kind == AnomalyKindLastReportedBackwards &&
+			anomalies[1].Kind == AnomalyKindOverCapacity
+	})).Return(nil)
+	mockDB.On("GetStationsWithAvailability", mock.Anything, "").Return([]StationWithAvailability{}, nil)
+
+	before := testutil.ToFloat64(availabilityAnomalies.WithLabelValues(DefaultSystemID, AnomalyKindOverCapacity))
+	service := NewStationService(mockDB, mockClient, config)
+	err := service.RefreshStationData(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(availabilityAnomalies.WithLabelValues(DefaultSystemID, AnomalyKindOverCapacity)))
+	mockDB.AssertExpectations(t)
+}
+
+func TestStationService_RefreshStationData_Snapshot(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockClient := new(MockDivvyClient)
+
+	mockClient.On("FetchStationData", mock.Anything, mock.Anything).Return(
+		[]DivvyStation{{StationID: "123", Name: "Test Station"}},
+		[]DivvyStationStatus{{StationID: "123", NumBikesAvailable: 4}}, nil)
+	mockDB.On("UpsertStations", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("InsertAvailabilities", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("GetStationsWithAvailability", mock.Anything, "").Return([]StationWithAvailability{
+		{Station: Station{StationID: "123", Name: "Test Station"}, NumBikesAvailable: 4},
+	}, nil)
+
+	service := NewStationService(mockDB, mockClient, NewTestConfig())
+	assert.Nil(t, service.Snapshot())
+
+	err := service.RefreshStationData(context.Background())
+
+	assert.NoError(t, err)
+	snapshot := service.Snapshot()
+	if assert.NotNil(t, snapshot) {
+		assert.Contains(t, string(snapshot.Body), `"station_id":"123"`)
+		assert.Contains(t, string(snapshot.Body), `"timezone":"UTC"`)
+	}
+}
+
+func TestStationService_RefreshStationData_PersistEveryN(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockClient := new(MockDivvyClient)
+
+	for bikes := 1; bikes <= 4; bikes++ {
+		mockClient.On("FetchStationData", mock.Anything, mock.Anything).Return(
+			[]DivvyStation{{StationID: "123", Name: "Test Station"}},
+			[]DivvyStationStatus{{StationID: "123", NumBikesAvailable: bikes}}, nil).Once()
+	}
+	mockDB.On("UpsertStations", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("InsertAvailabilities", mock.Anything, mock.Anything).Return(nil)
+	// The database only ever holds the first cycle's availability.
+	mockDB.On("GetStationsWithAvailability", mock.Anything, "").Return([]StationWithAvailability{
+		{Station: Station{StationID: "123", Name: "Test Station"}, NumBikesAvailable: 1},
+	}, nil)
+
+	config := NewTestConfig()
+	config.Timing.AvailabilityPersistEveryN = 3
+	service := NewStationService(mockDB, mockClient, config)
+
+	for cycle := 1; cycle <= 4; cycle++ {
+		assert.NoError(t, service.RefreshStationData(context.Background()))
+		if cycle == 3 {
+			assert.Contains(t, string(service.Snapshot().Body), `"num_bikes_available":3`)
+		}
+	}
+
+	// Cycles 1 and 4 are stored; 2 and 3 are only held in memory.
+	mockDB.AssertNumberOfCalls(t, "InsertAvailabilities", 2)
+	mockDB.AssertNumberOfCalls(t, "UpsertStations", 4)
+	assert.Empty(t, service.unpersisted)
+}
+
+func TestStationService_GetRecentAvailability_IncludesHeldCycle(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockClient := new(MockDivvyClient)
+
+	for bikes := 1; bikes <= 2; bikes++ {
+		mockClient.On("FetchStationData", mock.Anything, mock.Anything).Return(
+			[]DivvyStation{{StationID: "123", Name: "Test Station"}},
+			[]DivvyStationStatus{{StationID: "123", NumBikesAvailable: bikes}}, nil).Once()
+	}
+	mockDB.On("UpsertStations", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("InsertAvailabilities", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("GetStationsWithAvailability", mock.Anything, "").Return([]StationWithAvailability{
+		{Station: Station{StationID: "123", Name: "Test Station"}, NumBikesAvailable: 1},
+	}, nil)
+	stored := time.Now().Add(-time.Minute)
+	mockDB.On("GetRecentAvailability", mock.Anything).Return([]StationAvailability{
+		{StationID: "123", NumBikesAvailable: 1, RecordedAt: stored},
+	}, nil)
+
+	config := NewTestConfig()
+	config.Timing.AvailabilityPersistEveryN = 3
+	service := NewStationService(mockDB, mockClient, config)
+
+	// Cycle 1 is stored, cycle 2 only held in memory.
+	for cycle := 1; cycle <= 2; cycle++ {
+		assert.NoError(t, service.RefreshStationData(context.Background()))
+	}
+
+	records, err := service.GetRecentAvailability(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, 2, records[0].NumBikesAvailable)
+		assert.True(t, records[0].RecordedAt.After(stored))
+		assert.Equal(t, 1, records[1].NumBikesAvailable)
+	}
+}
+
+func TestStationService_RefreshStationData_StationInfoInterval(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockClient := new(MockDivvyClient)
+
+	mockClient.On("FetchStationData", mock.Anything, mock.Anything).
+		Return([]DivvyStation{{StationID: "1", Capacity: 10}}, []DivvyStationStatus{{StationID: "1"}}, nil)
+	// Cycle 2 reuses the cached stations; cycle 3 reports a station missing
+	// from them, so cycle 4 fetches station_information again.
+	mockClient.On("FetchStationStatus", mock.Anything, mock.Anything).
+		Return([]DivvyStationStatus{{StationID: "1"}}, nil).Once()
+	mockClient.On("FetchStationStatus", mock.Anything, mock.Anything).
+		Return([]DivvyStationStatus{{StationID: "1"}, {StationID: "new"}}, nil).Once()
+	mockDB.On("UpsertStations", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("InsertAvailabilities", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("GetStationsWithAvailability", mock.Anything, "").Return([]StationWithAvailability{}, nil)
+
+	config := NewTestConfig()
+	config.Timing.StationInfoRefreshMin = 60
+	service := NewStationService(mockDB, mockClient, config)
+
+	for cycle := 1; cycle <= 4; cycle++ {
+		assert.NoError(t, service.RefreshStationData(context.Background()))
+	}
+
+	mockClient.AssertNumberOfCalls(t, "FetchStationData", 2)
+	mockClient.AssertNumberOfCalls(t, "FetchStationStatus", 2)
+	mockDB.AssertNumberOfCalls(t, "UpsertStations", 2)
+	mockDB.AssertNumberOfCalls(t, "InsertAvailabilities", 4)
+}
+
+func TestStationService_RefreshStationData_RetryBudget(t *testing.T) {
+	chi := SystemConfig{ID: "chi", StationInfoURL: "http://chi/info", StationStatusURL: "http://chi/status"}
+	nyc := SystemConfig{ID: "nyc", StationInfoURL: "http://nyc/info", StationStatusURL: "http://nyc/status"}
+
+	t.Run("retried fetch recovers", func(t *testing.T) {
+		mockDB := new(MockDatabase)
+		mockClient := new(MockDivvyClient)
+		mockClient.On("FetchStationData", mock.Anything, chi).Return(
+			([]DivvyStation)(nil), ([]DivvyStationStatus)(nil), assert.AnError).Once()
+		mockClient.On("FetchStationData", mock.Anything, chi).Return(
+			[]DivvyStation{{StationID: "1"}}, []DivvyStationStatus{{StationID: "1"}}, nil).Once()
+		mockDB.On("UpsertStations", mock.Anything, mock.Anything).Return(nil).Once()
+		mockDB.On("InsertAvailabilities", mock.Anything, mock.Anything).Return(nil).Once()
+		mockDB.On("GetStationsWithAvailability", mock.Anything, "").Return([]StationWithAvailability{}, nil)
+
+		config := NewTestConfig()
+		config.Divvy.Systems = []SystemConfig{chi}
+		config.Timing.RefreshRetryBudget = 2
+		config.Timing.RefreshRetryBudgetSec = 10
+		config.Timing.RefreshRetryBaseDelayMs = 1
+		service := NewStationService(mockDB, mockClient, config)
+
+		assert.NoError(t, service.RefreshStationData(context.Background()))
+		mockClient.AssertExpectations(t)
+		mockDB.AssertExpectations(t)
+	})
+
+	t.Run("exhausted budget abandons the cycle", func(t *testing.T) {
+		mockDB := new(MockDatabase)
+		mockClient := new(MockDivvyClient)
+		mockClient.On("FetchStationData", mock.Anything, chi).Return(
+			([]DivvyStation)(nil), ([]DivvyStationStatus)(nil), assert.AnError).Times(2)
+
+		config := NewTestConfig()
+		config.Divvy.Systems = []SystemConfig{chi, nyc}
+		config.Timing.RefreshRetryBudget = 1
+		config.Timing.RefreshRetryBudgetSec = 10
+		config.Timing.RefreshRetryBaseDelayMs = 1
+		service := NewStationService(mockDB, mockClient, config)
+
+		err := service.RefreshStationData(context.Background())
+
+		assert.ErrorIs(t, err, ErrRetryBudgetExhausted)
+		mockClient.AssertExpectations(t)
+		mockClient.AssertNotCalled(t, "FetchStationData", mock.Anything, nyc)
+		// No snapshot is built from an abandoned cycle.
+		mockDB.AssertNotCalled(t, "GetStationsWithAvailability", mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,285 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/singleflight"
+)
+
+// statusCheckTimeout bounds each check run by GetStatus.
+const statusCheckTimeout = 5 * time.Second
+
+// statusCacheTTL is how long a status report is reused. The checks fetch
+// every GBFS feed, scan availability and call the ML service, so polling
+// /api/status must not rerun them on every request.
+const statusCacheTTL = 5 * time.Second
+
+const (
+	overallStatusOK       = "ok"
+	overallStatusDegraded = "degraded"
+	overallStatusDown     = "down"
+	// overallStatusMaintenance is reported instead of running the checks
+	// while maintenance mode is on; see maintenanceReport.
+	overallStatusMaintenance = "maintenance"
+)
+
+// StatusCheck is the outcome of one subsystem check on the status page.
+type StatusCheck struct {
+	Name      string `json:"name"`
+	Subsystem string `json:"subsystem"`
+	Critical  bool   `json:"critical"`
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latency_ms"`
+	Detail    string `json:"detail,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// StatusReport aggregates every status check. Status is ok when all checks
+// pass, down when a check of a READINESS_CRITICAL_CHECKS subsystem fails and
+// degraded otherwise.
+type StatusReport struct {
+	Status string        `json:"status"`
+	Checks []StatusCheck `json:"checks"`
+}
+
+type statusProbe struct {
+	name      string
+	subsystem string
+	run       func(ctx context.Context) (detail string, err error)
+}
+
+// GetStatus runs every subsystem check in parallel and always responds 200,
+// so a status page can render partial outages. The report is reused for
+// statusCacheTTL. Use /health or /readyz for a pass/fail signal.
+func (h *HTTPHandlers) GetStatus(c *gin.Context) {
+	report, err := h.statusCache.get(c.Request.Context(), "status", h.Status)
+	if err != nil {
+		h.handleError(c, http.StatusServiceUnavailable, ErrCodeTimeout, "Status checks did not finish", err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetReadiness answers 503 while a READINESS_CRITICAL_CHECKS subsystem is
+// failing. Only those subsystems are checked, without fetching the GBFS
+// feeds (the feed subsystem is judged by data freshness alone), and the
+// result is reused for statusCacheTTL, so kubelet probes stay cheap.
+func (h *HTTPHandlers) GetReadiness(c *gin.Context) {
+	if h.maintenance.Enabled() {
+		report := h.maintenanceReport()
+		if report.Status == overallStatusDown {
+			c.JSON(http.StatusServiceUnavailable, report)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	report, err := h.statusCache.get(c.Request.Context(), "readyz", h.Readiness)
+	if err != nil {
+		h.handleError(c, http.StatusServiceUnavailable, ErrCodeTimeout, "Readiness checks did not finish", err)
+		return
+	}
+	if report.Status == overallStatusDown {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// maintenanceReport judges readiness from memory while maintenance mode is
+// on and the database may be down: reads are served from the stations
+// snapshot, so the server is ready as long as one is cached.
+func (h *HTTPHandlers) maintenanceReport() StatusReport {
+	check := StatusCheck{Name: "stations_snapshot", Subsystem: SubsystemFeed, Critical: true}
+	if snapshot := h.stationService.Snapshot(); snapshot != nil {
+		check.OK = true
+		check.Detail = fmt.Sprintf("generated %v ago", time.Since(snapshot.GeneratedAt).Round(time.Second))
+		return StatusReport{Status: overallStatusMaintenance, Checks: []StatusCheck{check}}
+	}
+	check.Error = "no stations snapshot cached"
+	return StatusReport{Status: overallStatusDown, Checks: []StatusCheck{check}}
+}
+
+// Status checks the database, ML service, every GBFS feed, and the freshness
+// of stored availability and predictions.
+func (h *HTTPHandlers) Status(ctx context.Context) StatusReport {
+	probes := h.coreProbes()
+	for _, step := range h.feedProbes(newHTTPClient(h.config, statusCheckTimeout)) {
+		probes = append(probes, statusProbe{name: step.name, subsystem: SubsystemFeed, run: detailless(step.run)})
+	}
+	return h.runProbes(ctx, probes)
+}
+
+// Readiness runs the checks of the READINESS_CRITICAL_CHECKS subsystems
+// only, leaving out the outbound GBFS feed fetches.
+func (h *HTTPHandlers) Readiness(ctx context.Context) StatusReport {
+	critical := h.criticalSubsystems()
+	probes := slices.DeleteFunc(h.coreProbes(), func(probe statusProbe) bool { return !critical[probe.subsystem] })
+	return h.runProbes(ctx, probes)
+}
+
+func (h *HTTPHandlers) coreProbes() []statusProbe {
+	return []statusProbe{
+		{name: "database", subsystem: SubsystemDB, run: detailless(h.database.HealthCheck)},
+		{name: "ml_service", subsystem: SubsystemML, run: detailless(h.probeMLService)},
+		{name: "data_freshness", subsystem: SubsystemFeed, run: h.checkDataFreshness},
+		{name: "prediction_freshness", subsystem: SubsystemPredictions, run: h.checkPredictionFreshness},
+	}
+}
+
+func (h *HTTPHandlers) criticalSubsystems() map[string]bool {
+	critical := make(map[string]bool, len(h.config.Health.ReadinessCriticalChecks))
+	for _, subsystem := range h.config.Health.ReadinessCriticalChecks {
+		critical[subsystem] = true
+	}
+	return critical
+}
+
+// runProbes runs probes in parallel, each bounded by statusCheckTimeout.
+func (h *HTTPHandlers) runProbes(ctx context.Context, probes []statusProbe) StatusReport {
+	critical := h.criticalSubsystems()
+
+	checks := make([]StatusCheck, len(probes))
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, statusCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			detail, err := probe.run(checkCtx)
+			checks[i] = StatusCheck{
+				Name:      probe.name,
+				Subsystem: probe.subsystem,
+				Critical:  critical[probe.subsystem],
+				OK:        err == nil,
+				LatencyMs: time.Since(start).Milliseconds(),
+				Detail:    detail,
+			}
+			if err != nil {
+				checks[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := StatusReport{Status: overallStatusOK, Checks: checks}
+	for _, check := range checks {
+		if check.OK {
+			continue
+		}
+		if check.Critical {
+			report.Status = overallStatusDown
+			break
+		}
+		report.Status = overallStatusDegraded
+	}
+	return report
+}
+
+func detailless(run func(ctx context.Context) error) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		return "", run(ctx)
+	}
+}
+
+// checkDataFreshness passes when the most recently reporting station did so
+// within the stale-station threshold.
+func (h *HTTPHandlers) checkDataFreshness(ctx context.Context) (string, error) {
+	staleness, err := h.database.GetStationStaleness(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(staleness) == 0 {
+		return "", errors.New("no availability recorded")
+	}
+	// Staleness is ordered oldest first.
+	freshest := time.Duration(staleness[len(staleness)-1].AgeSeconds) * time.Second
+	detail := fmt.Sprintf("newest report %v old", freshest)
+	if threshold := time.Duration(h.config.Health.StaleStationThresholdMin) * time.Minute; freshest > threshold {
+		return detail, fmt.Errorf("no station has reported in the last %v", threshold)
+	}
+	return detail, nil
+}
+
+// checkPredictionFreshness passes when a prediction was stored or confirmed
+// within two prediction intervals.
+func (h *HTTPHandlers) checkPredictionFreshness(ctx context.Context) (string, error) {
+	predictions, err := h.database.GetLatestPredictions(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(predictions) == 0 {
+		return "", errors.New("no predictions stored")
+	}
+	var newest time.Time
+	for _, p := range predictions {
+		if p.LastConfirmedAt.After(newest) {
+			newest = p.LastConfirmedAt
+		}
+	}
+	age := time.Since(newest).Round(time.Second)
+	detail := fmt.Sprintf("newest prediction %v old", age)
+	if limit := 2 * time.Duration(h.config.Timing.PredictionIntervalHours) * time.Hour; age > limit {
+		return detail, fmt.Errorf("no predictions in the last %v", limit)
+	}
+	return detail, nil
+}
+
+// statusCache reuses status reports for statusCacheTTL. Concurrent requests
+// for an expired report share one run of the checks, detached from the
+// request that started it; each check is bounded by statusCheckTimeout.
+type statusCache struct {
+	ttl     time.Duration
+	now     func() time.Time
+	runs    singleflight.Group
+	mu      sync.Mutex
+	reports map[string]cachedStatusReport
+}
+
+type cachedStatusReport struct {
+	report    StatusReport
+	checkedAt time.Time
+}
+
+func newStatusCache(ttl time.Duration) *statusCache {
+	return &statusCache{ttl: ttl, now: time.Now, reports: map[string]cachedStatusReport{}}
+}
+
+// get returns the report cached under key, running build when there is
+// none younger than the ttl. A nil cache always runs build.
+func (c *statusCache) get(ctx context.Context, key string, build func(context.Context) StatusReport) (StatusReport, error) {
+	if c == nil {
+		return build(ctx), nil
+	}
+	c.mu.Lock()
+	cached, ok := c.reports[key]
+	c.mu.Unlock()
+	if ok && c.now().Sub(cached.checkedAt) < c.ttl {
+		return cached.report, nil
+	}
+
+	results := c.runs.DoChan(key, func() (interface{}, error) {
+		report := build(context.WithoutCancel(ctx))
+		c.mu.Lock()
+		c.reports[key] = cachedStatusReport{report: report, checkedAt: c.now()}
+		c.mu.Unlock()
+		return report, nil
+	})
+	select {
+	case result := <-results:
+		return result.Val.(StatusReport), nil
+	case <-ctx.Done():
+		return StatusReport{}, ctx.Err()
+	}
+}
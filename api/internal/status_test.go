@@ -0,0 +1,281 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHTTPHandlers_GetStatus(t *testing.T) {
+	fresh := []StationStaleness{{StationID: "old", AgeSeconds: 7200}, {StationID: "new", AgeSeconds: 60}}
+	recent := []Prediction{{StationID: "1", LastConfirmedAt: time.Now().Add(-time.Hour)}}
+
+	tests := []struct {
+		name           string
+		dbErr          error
+		predictions    []Prediction
+		expectedStatus string
+		expectedFailed []string
+	}{
+		{
+			name:           "all healthy",
+			predictions:    recent,
+			expectedStatus: overallStatusOK,
+		},
+		{
+			name:           "stale predictions",
+			predictions:    []Prediction{{StationID: "1", LastConfirmedAt: time.Now().Add(-24 * time.Hour)}},
+			expectedStatus: overallStatusDegraded,
+			expectedFailed: []string{"prediction_freshness"},
+		},
+		{
+			name:           "database down",
+			dbErr:          errors.New("connection refused"),
+			predictions:    recent,
+			expectedStatus: overallStatusDown,
+			expectedFailed: []string{"database"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewTestConfig()
+			config.Divvy.Systems = nil
+			config.Health.StaleStationThresholdMin = 60
+			config.Timing.PredictionIntervalHours = 2
+
+			mockDB := new(MockDatabase)
+			mockML := new(MockMLService)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), config)
+			handlers.mlService = mockML
+			mockDB.On("HealthCheck", mock.Anything).Return(tt.dbErr)
+			mockDB.On("GetStationStaleness", mock.Anything).Return(fresh, nil)
+			mockDB.On("GetLatestPredictions", mock.Anything).Return(tt.predictions, nil)
+			mockML.On("GetStatus", mock.Anything).Return(map[string]interface{}{"status": "ready"}, nil)
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/status", handlers.GetStatus)
+
+			req := httptest.NewRequest("GET", "/status", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			var report StatusReport
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, tt.expectedStatus, report.Status)
+			assert.Len(t, report.Checks, 4)
+
+			var failed []string
+			for _, check := range report.Checks {
+				if !check.OK {
+					failed = append(failed, check.Name)
+				}
+			}
+			assert.Equal(t, tt.expectedFailed, failed)
+		})
+	}
+}
+
+func TestHTTPHandlers_GetStatus_Cached(t *testing.T) {
+	config := NewTestConfig()
+	config.Divvy.Systems = nil
+
+	mockDB := new(MockDatabase)
+	mockML := new(MockMLService)
+	handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), config)
+	handlers.mlService = mockML
+	now := time.Now()
+	handlers.statusCache.now = func() time.Time { return now }
+	mockDB.On("HealthCheck", mock.Anything).Return(nil).Twice()
+	mockDB.On("GetStationStaleness", mock.Anything).Return([]StationStaleness{{StationID: "1", AgeSeconds: 60}}, nil).Twice()
+	mockDB.On("GetLatestPredictions", mock.Anything).Return([]Prediction{}, nil).Twice()
+	mockML.On("GetStatus", mock.Anything).Return(map[string]interface{}{"status": "ready"}, nil).Twice()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/status", handlers.GetStatus)
+	get := func() {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	get()
+	get()
+	now = now.Add(statusCacheTTL)
+	get()
+
+	mockDB.AssertExpectations(t)
+	mockML.AssertExpectations(t)
+}
+
+func TestHTTPHandlers_GetReadiness(t *testing.T) {
+	recent := []Prediction{{StationID: "1", LastConfirmedAt: time.Now().Add(-time.Hour)}}
+
+	tests := []struct {
+		name           string
+		critical       []string
+		dbErr          error
+		mlErr          error
+		expectedCode   int
+		expectedStatus string
+		expectedChecks []string
+	}{
+		{
+			name:           "all healthy",
+			critical:       []string{SubsystemDB},
+			expectedCode:   http.StatusOK,
+			expectedStatus: overallStatusOK,
+			expectedChecks: []string{"database"},
+		},
+		{
+			name:           "ml down but not critical",
+			critical:       []string{SubsystemDB},
+			mlErr:          errors.New("connection refused"),
+			expectedCode:   http.StatusOK,
+			expectedStatus: overallStatusOK,
+			expectedChecks: []string{"database"},
+		},
+		{
+			name:           "ml down and critical",
+			critical:       []string{SubsystemDB, SubsystemML},
+			mlErr:          errors.New("connection refused"),
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedStatus: overallStatusDown,
+			expectedChecks: []string{"database", "ml_service"},
+		},
+		{
+			name:           "database down but not critical",
+			critical:       []string{SubsystemML},
+			dbErr:          errors.New("connection refused"),
+			expectedCode:   http.StatusOK,
+			expectedStatus: overallStatusOK,
+			expectedChecks: []string{"ml_service"},
+		},
+		{
+			name:           "nothing critical",
+			dbErr:          errors.New("connection refused"),
+			mlErr:          errors.New("connection refused"),
+			expectedCode:   http.StatusOK,
+			expectedStatus: overallStatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewTestConfig()
+			config.Divvy.Systems = nil
+			config.Health.StaleStationThresholdMin = 60
+			config.Health.ReadinessCriticalChecks = tt.critical
+			config.Timing.PredictionIntervalHours = 2
+
+			mockDB := new(MockDatabase)
+			mockML := new(MockMLService)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), config)
+			handlers.mlService = mockML
+			mockDB.On("HealthCheck", mock.Anything).Return(tt.dbErr).Maybe()
+			mockDB.On("GetStationStaleness", mock.Anything).Return([]StationStaleness{{StationID: "1", AgeSeconds: 60}}, nil).Maybe()
+			mockDB.On("GetLatestPredictions", mock.Anything).Return(recent, nil).Maybe()
+			mockML.On("GetStatus", mock.Anything).Return(map[string]interface{}{"status": "ready"}, tt.mlErr).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/readyz", handlers.GetReadiness)
+
+			req := httptest.NewRequest("GET", "/readyz", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			var report StatusReport
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, tt.expectedStatus, report.Status)
+			checks := make([]string, 0, len(report.Checks))
+			for _, check := range report.Checks {
+				checks = append(checks, check.Name)
+			}
+			assert.ElementsMatch(t, tt.expectedChecks, checks)
+			// Only the critical subsystems are probed.
+			if !slices.Contains(tt.critical, SubsystemFeed) {
+				mockDB.AssertNotCalled(t, "GetStationStaleness", mock.Anything)
+			}
+			if !slices.Contains(tt.critical, SubsystemPredictions) {
+				mockDB.AssertNotCalled(t, "GetLatestPredictions", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestHTTPHandlers_HealthDuringMaintenance(t *testing.T) {
+	tests := []struct {
+		name           string
+		snapshot       *StationsSnapshot
+		expectedCode   int
+		expectedHealth string
+		expectedReady  string
+	}{
+		{
+			name:           "snapshot cached",
+			snapshot:       &StationsSnapshot{Body: []byte(`{"stations":[]}`), GeneratedAt: time.Now()},
+			expectedCode:   http.StatusOK,
+			expectedHealth: overallStatusMaintenance,
+			expectedReady:  overallStatusMaintenance,
+		},
+		{
+			name:           "no snapshot",
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedHealth: "unhealthy",
+			expectedReady:  overallStatusDown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewTestConfig()
+			config.Health.ReadinessCriticalChecks = []string{SubsystemDB}
+
+			// The database is down for the maintenance window.
+			mockDB := new(MockDatabase)
+			mockDB.On("HealthCheck", mock.Anything).Return(errors.New("connection refused")).Maybe()
+			mockDB.On("GetLatestPredictions", mock.Anything).Return(([]Prediction)(nil), errors.New("connection refused")).Maybe()
+			mockStationService := new(MockStationService)
+			mockStationService.On("Snapshot").Return(tt.snapshot)
+
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), config)
+			handlers.stationService = mockStationService
+			handlers.maintenance.Set(true)
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/health", handlers.HealthCheck)
+			router.GET("/readyz", handlers.GetReadiness)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+			assert.Equal(t, tt.expectedCode, w.Code)
+			var health map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &health))
+			assert.Equal(t, tt.expectedHealth, health["status"])
+
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+			assert.Equal(t, tt.expectedCode, w.Code)
+			var report StatusReport
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, tt.expectedReady, report.Status)
+
+			mockDB.AssertNotCalled(t, "HealthCheck", mock.Anything)
+			mockDB.AssertNotCalled(t, "GetLatestPredictions", mock.Anything)
+		})
+	}
+}
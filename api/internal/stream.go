@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamStations writes every station (optionally filtered by ?system=) as
+// newline-delimited JSON, flushing after each one so clients can process
+// stations as they arrive. Scanning stops as soon as the client disconnects.
+func (h *HTTPHandlers) StreamStations(c *gin.Context) {
+	ctx := c.Request.Context()
+	encoder := json.NewEncoder(c.Writer)
+	written := 0
+
+	err := h.database.StreamStationsWithAvailability(ctx, c.Query("system"), func(station StationWithAvailability) error {
+		if written == 0 {
+			c.Header("Content-Type", "application/x-ndjson")
+			c.Status(http.StatusOK)
+		}
+		overlaid := []StationWithAvailability{station}
+		h.stationService.OverlayUnpersisted(overlaid)
+		station = overlaid[0]
+		station.Color = h.config.Availability.Color(station.NumBikesAvailable)
+		if err := encoder.Encode(station); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		written++
+		return nil
+	})
+
+	switch {
+	case err == nil:
+		if written == 0 {
+			c.Data(http.StatusOK, "application/x-ndjson", nil)
+		}
+	case ctx.Err() != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded):
+		log.Printf("Station stream stopped after %d stations: client disconnected", written)
+	case written == 0:
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to stream station data", err)
+	default:
+		// Headers are already sent, so the truncated stream is all the
+		// client will see.
+		log.Printf("Station stream failed after %d stations: %v", written, err)
+	}
+}
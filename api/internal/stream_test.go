@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHTTPHandlers_StreamStations(t *testing.T) {
+	second := TestStationWithAvailability
+	second.StationID = "test-002"
+
+	tests := []struct {
+		name           string
+		stations       []StationWithAvailability
+		dbErr          error
+		expectedStatus int
+		expectedIDs    []string
+		expectedCode   string
+	}{
+		{
+			name:           "streams one object per line",
+			stations:       []StationWithAvailability{TestStationWithAvailability, second},
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"test-001", "test-002"},
+		},
+		{
+			name:           "no stations",
+			stations:       []StationWithAvailability{},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "error before first row",
+			stations:       []StationWithAvailability{},
+			dbErr:          errors.New("db down"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   ErrCodeDBError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			mockDB.On("StreamStationsWithAvailability", mock.Anything, "").Return(tt.stations, tt.dbErr)
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/stations/stream", handlers.StreamStations)
+
+			req := httptest.NewRequest("GET", "/stations/stream", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedCode != "" {
+				assertErrorEnvelope(t, w, tt.expectedCode)
+				return
+			}
+			assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+			var ids []string
+			scanner := bufio.NewScanner(w.Body)
+			for scanner.Scan() {
+				var station StationWithAvailability
+				assert.NoError(t, json.Unmarshal(scanner.Bytes(), &station))
+				ids = append(ids, station.StationID)
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
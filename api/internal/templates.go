@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+// templateRetryInterval throttles re-parsing after a failed template load,
+// so fixing a broken file on disk recovers without a restart.
+const templateRetryInterval = 30 * time.Second
+
+const fallbackPage = `<!DOCTYPE html>
+<html><head><title>Divvy Bike Availability</title></head>
+<body><p>The map is temporarily unavailable. The JSON API at /api/stations/json is still up.</p></body>
+</html>`
+
+// templateFuncs are available to every template.
+var templateFuncs = template.FuncMap{
+	"translate": translate,
+}
+
+// templateSet parses HTML templates without panicking. While the templates
+// are broken, HTML routes serve a fallback page and the JSON API is
+// unaffected. It implements gin's render.HTMLRender.
+type templateSet struct {
+	glob    string
+	current atomic.Pointer[template.Template]
+
+	mu          sync.Mutex
+	lastAttempt time.Time
+}
+
+func newTemplateSet(glob string) *templateSet {
+	t := &templateSet{glob: glob}
+	t.reload()
+	return t
+}
+
+// reload parses every template matched by the glob, logging the file that
+// failed to parse. The previous templates are kept on failure.
+func (t *templateSet) reload() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastAttempt = time.Now()
+
+	tmpl, err := parseTemplates(t.glob)
+	if err != nil {
+		log.Printf("Error: HTML templates unavailable, serving fallback page: %v", err)
+		return false
+	}
+	t.current.Store(tmpl)
+	return true
+}
+
+func parseTemplates(glob string) (*template.Template, error) {
+	files, err := filepath.Glob(glob)
+	if err != nil {
+		return nil, fmt.Errorf("templates glob %q: %w", glob, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("templates glob %q matched no files", glob)
+	}
+
+	tmpl := template.New("").Funcs(templateFuncs)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read template %s: %w", file, err)
+		}
+		if _, err := tmpl.New(filepath.Base(file)).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("parse template %s: %w", file, err)
+		}
+	}
+	return tmpl, nil
+}
+
+// ready reports whether templates are loaded, retrying a failed load at most
+// once per templateRetryInterval.
+func (t *templateSet) ready() bool {
+	if t.current.Load() != nil {
+		return true
+	}
+
+	t.mu.Lock()
+	retry := time.Since(t.lastAttempt) >= templateRetryInterval
+	t.mu.Unlock()
+	if !retry {
+		return false
+	}
+	return t.reload()
+}
+
+func (t *templateSet) Instance(name string, data any) render.Render {
+	return render.HTML{Template: t.current.Load(), Name: name, Data: data}
+}
+
+// RequireTemplates guards HTML routes, serving the fallback page with 503
+// while templates are unavailable.
+func (t *templateSet) RequireTemplates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !t.ready() {
+			c.Data(http.StatusServiceUnavailable, "text/html; charset=utf-8", []byte(fallbackPage))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
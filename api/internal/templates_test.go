@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateSet_FallbackAndRetry(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "page.html")
+	assert.NoError(t, os.WriteFile(page, []byte(`{{if .ok}}broken`), 0o644))
+
+	templates := newTemplateSet(filepath.Join(dir, "*"))
+
+	_, err := parseTemplates(filepath.Join(dir, "*"))
+	assert.ErrorContains(t, err, "page.html")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.HTMLRender = templates
+	router.GET("/page", templates.RequireTemplates(), func(c *gin.Context) {
+		c.HTML(http.StatusOK, "page.html", gin.H{"name": "divvy"})
+	})
+	router.GET("/json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := serve("/page")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "temporarily unavailable")
+	assert.Equal(t, http.StatusOK, serve("/json").Code)
+
+	// Fix the template and let the retry interval elapse.
+	assert.NoError(t, os.WriteFile(page, []byte(`hello {{.name}}`), 0o644))
+	templates.lastAttempt = time.Now().Add(-templateRetryInterval)
+
+	w = serve("/page")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello divvy", w.Body.String())
+}
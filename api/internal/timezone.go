@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"net/http"
+	"time"
+	// Embed the timezone database: the alpine runtime image ships without
+	// tzdata, and DISPLAY_TIMEZONE and ?tz= must resolve there too.
+	_ "time/tzdata"
+
+	"github.com/gin-gonic/gin"
+)
+
+// localTimeLayout formats the *_local fields added next to timestamps when a
+// response is converted out of UTC.
+const localTimeLayout = "2006-01-02 15:04 MST"
+
+// formatLocal returns t formatted in loc for a *_local field, or "" when loc
+// is UTC so default responses stay unchanged.
+func formatLocal(t time.Time, loc *time.Location) string {
+	if loc == time.UTC || t.IsZero() {
+		return ""
+	}
+	return t.In(loc).Format(localTimeLayout)
+}
+
+// requestLocation resolves the ?tz= query parameter, falling back to
+// defaultLocation when it is absent. It responds with 400 and returns false
+// for unknown timezone names.
+func requestLocation(c *gin.Context, defaultLocation *time.Location) (*time.Location, bool) {
+	name := c.Query("tz")
+	if name == "" {
+		return defaultLocation, true
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "unknown timezone "+name)
+		return nil, false
+	}
+	return loc, true
+}
+
+func localizeStations(stations []Station, loc *time.Location) {
+	for i := range stations {
+		stations[i].CreatedAt = stations[i].CreatedAt.In(loc)
+		stations[i].UpdatedAt = stations[i].UpdatedAt.In(loc)
+		stations[i].CreatedAtLocal = formatLocal(stations[i].CreatedAt, loc)
+		stations[i].UpdatedAtLocal = formatLocal(stations[i].UpdatedAt, loc)
+	}
+}
+
+func localizeStationsWithAvailability(stations []StationWithAvailability, loc *time.Location) {
+	for i := range stations {
+		stations[i].CreatedAt = stations[i].CreatedAt.In(loc)
+		stations[i].UpdatedAt = stations[i].UpdatedAt.In(loc)
+		stations[i].CreatedAtLocal = formatLocal(stations[i].CreatedAt, loc)
+		stations[i].UpdatedAtLocal = formatLocal(stations[i].UpdatedAt, loc)
+	}
+}
+
+func localizePredictions(predictions []Prediction, loc *time.Location) {
+	for i := range predictions {
+		predictions[i].PredictionTime = predictions[i].PredictionTime.In(loc)
+		predictions[i].CreatedAt = predictions[i].CreatedAt.In(loc)
+		predictions[i].LastConfirmedAt = predictions[i].LastConfirmedAt.In(loc)
+		predictions[i].PredictionTimeLocal = formatLocal(predictions[i].PredictionTime, loc)
+		predictions[i].CreatedAtLocal = formatLocal(predictions[i].CreatedAt, loc)
+	}
+}
+
+func localizeAvailability(records []StationAvailability, loc *time.Location) {
+	for i := range records {
+		records[i].RecordedAt = records[i].RecordedAt.In(loc)
+		records[i].RecordedAtLocal = formatLocal(records[i].RecordedAt, loc)
+	}
+}
+
+func localizeBuckets(buckets []AvailabilityBucket, loc *time.Location) {
+	for i := range buckets {
+		buckets[i].BucketStart = buckets[i].BucketStart.In(loc)
+	}
+}
@@ -0,0 +1,30 @@
+package internal
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultDisplayTimezone_Loads(t *testing.T) {
+	os.Unsetenv("DISPLAY_TIMEZONE")
+	t.Setenv("DB_URL", "postgres://localhost/divvy")
+
+	config := LoadConfig()
+
+	_, err := time.LoadLocation(config.Server.DisplayTimezone)
+	assert.NoError(t, err)
+	assert.NoError(t, config.Validate())
+}
+
+func TestFormatLocal(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	assert.NoError(t, err)
+	at := time.Date(2024, 1, 15, 18, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, "2024-01-15 12:30 CST", formatLocal(at, chicago))
+	assert.Empty(t, formatLocal(at, time.UTC))
+	assert.Empty(t, formatLocal(time.Time{}, chicago))
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// useSpanRecorder installs a recording tracer provider and the trace context
+// propagator for the duration of the test.
+func useSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder
+}
+
+func TestTracingConfig_Enabled(t *testing.T) {
+	assert.False(t, TracingConfig{}.Enabled())
+	assert.True(t, TracingConfig{OTLPEndpoint: "http://collector:4318"}.Enabled())
+	assert.False(t, TracingConfig{OTLPEndpoint: "http://collector:4318", Disabled: true}.Enabled())
+
+	shutdown, err := InitTracing(context.Background(), TracingConfig{})
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestTracing_PropagatesTraceContext(t *testing.T) {
+	recorder := useSpanRecorder(t)
+
+	var outboundTraceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outboundTraceparent = r.Header.Get("traceparent")
+	}))
+	defer upstream.Close()
+
+	client := newHTTPClient(NewTestConfig(), 0)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Tracing())
+	router.GET("/stations/:id", func(c *gin.Context) {
+		req, _ := http.NewRequestWithContext(c.Request.Context(), "GET", upstream.URL, nil)
+		resp, err := client.Do(req)
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+		}
+		c.Status(http.StatusBadGateway)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/stations/42", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		span := spans[0]
+		assert.Equal(t, "GET /stations/:id", span.Name())
+		assert.Equal(t, traceID, span.SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+		assert.Equal(t, codes.Error, span.Status().Code)
+		assert.Contains(t, outboundTraceparent, traceID+"-"+span.SpanContext().SpanID().String())
+	}
+}
+
+func TestEndSpan_RecordsError(t *testing.T) {
+	recorder := useSpanRecorder(t)
+
+	_, span := startDBSpan(context.Background(), "UpsertStations")
+	endSpan(span, assert.AnError)
+	_, span = startSpan(context.Background(), "station.refresh")
+	endSpan(span, nil)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, "db.UpsertStations", spans[0].Name())
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+		assert.Len(t, spans[0].Events(), 1)
+		assert.Equal(t, codes.Unset, spans[1].Status().Code)
+	}
+}
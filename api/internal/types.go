@@ -3,17 +3,28 @@ package internal
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 )
 
+// DefaultSystemID identifies the Chicago Divvy system and is used for rows
+// ingested before multi-system support existed.
+const DefaultSystemID = "divvy"
+
 type Station struct {
-	StationID string    `json:"station_id" db:"station_id" validate:"required"`
-	Name      string    `json:"name" db:"name" validate:"required"`
-	Lat       float64   `json:"lat" db:"lat" validate:"required"`
-	Lon       float64   `json:"lon" db:"lon" validate:"required"`
-	Capacity  int       `json:"capacity" db:"capacity" validate:"min=0"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	StationID string     `json:"station_id" db:"station_id" validate:"required"`
+	SystemID  string     `json:"system_id" db:"system_id"`
+	Name      string     `json:"name" db:"name" validate:"required"`
+	Lat       Coordinate `json:"lat" db:"lat" validate:"required"`
+	Lon       Coordinate `json:"lon" db:"lon" validate:"required"`
+	Capacity  int        `json:"capacity" db:"capacity" validate:"min=0"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+
+	// The *Local fields are CreatedAt and UpdatedAt formatted in the
+	// requested ?tz=, only set when it is not UTC.
+	CreatedAtLocal string `json:"created_at_local,omitempty"`
+	UpdatedAtLocal string `json:"updated_at_local,omitempty"`
 }
 
 func (s *Station) Validate() error {
@@ -29,9 +40,46 @@ func (s *Station) Validate() error {
 	return nil
 }
 
+// StationValidationError is a station record that failed Validate,
+// identified by its station ID and its position in the batch.
+type StationValidationError struct {
+	Index     int    `json:"index"`
+	StationID string `json:"station_id"`
+	Message   string `json:"error"`
+}
+
+func (e StationValidationError) Error() string {
+	return fmt.Sprintf("station %q (record %d): %s", e.StationID, e.Index, e.Message)
+}
+
+// ValidateAll validates every station and returns one error per invalid
+// record, in batch order, rather than stopping at the first. It returns nil
+// when all are valid.
+func ValidateAll(stations []Station) []StationValidationError {
+	var errs []StationValidationError
+	for i := range stations {
+		if err := stations[i].Validate(); err != nil {
+			errs = append(errs, StationValidationError{Index: i, StationID: stations[i].StationID, Message: err.Error()})
+		}
+	}
+	return errs
+}
+
+// RefreshDryRun is what refreshing one system would ingest, reported by a
+// dry-run refresh.
+type RefreshDryRun struct {
+	SystemID         string                   `json:"system_id"`
+	StationCount     int                      `json:"station_count"`
+	StatusCount      int                      `json:"status_count"`
+	InvalidCount     int                      `json:"invalid_count"`
+	ValidationErrors []StationValidationError `json:"validation_errors"`
+	Error            string                   `json:"error,omitempty"`
+}
+
 type StationAvailability struct {
 	ID                int       `json:"id" db:"id"`
 	StationID         string    `json:"station_id" db:"station_id" validate:"required"`
+	SystemID          string    `json:"system_id" db:"system_id"`
 	NumBikesAvailable int       `json:"num_bikes_available" db:"num_bikes_available" validate:"min=0"`
 	NumDocksAvailable int       `json:"num_docks_available" db:"num_docks_available" validate:"min=0"`
 	IsInstalled       int       `json:"is_installed" db:"is_installed"`
@@ -39,6 +87,9 @@ type StationAvailability struct {
 	IsReturning       int       `json:"is_returning" db:"is_returning"`
 	LastReported      int64     `json:"last_reported" db:"last_reported"`
 	RecordedAt        time.Time `json:"recorded_at" db:"recorded_at"`
+	// RecordedAtLocal is RecordedAt formatted in the requested ?tz=, only
+	// set when it is not UTC.
+	RecordedAtLocal string `json:"recorded_at_local,omitempty"`
 }
 
 func (sa *StationAvailability) Validate() error {
@@ -58,7 +109,9 @@ type DivvyStationInfoResponse struct {
 }
 
 type DivvyStationStatusResponse struct {
-	Data struct {
+	// LastUpdated is the feed's POSIX timestamp of its last update.
+	LastUpdated int64 `json:"last_updated"`
+	Data        struct {
 		Stations []DivvyStationStatus `json:"stations"`
 	} `json:"data"`
 }
@@ -81,6 +134,34 @@ type DivvyStationStatus struct {
 	LastReported      int64  `json:"last_reported"`
 }
 
+type DivvyFreeBikeStatusResponse struct {
+	Data struct {
+		Bikes []DivvyFreeBike `json:"bikes"`
+	} `json:"data"`
+}
+
+// DivvyFreeBike is a dockless bike from the GBFS free_bike_status feed.
+type DivvyFreeBike struct {
+	BikeID        string  `json:"bike_id"`
+	Lat           float64 `json:"lat"`
+	Lon           float64 `json:"lon"`
+	IsReserved    int     `json:"is_reserved"`
+	IsDisabled    int     `json:"is_disabled"`
+	VehicleTypeID string  `json:"vehicle_type_id"`
+}
+
+type FreeBike struct {
+	BikeID        string     `json:"bike_id" db:"bike_id"`
+	SystemID      string     `json:"system_id" db:"system_id"`
+	Lat           Coordinate `json:"lat" db:"lat"`
+	Lon           Coordinate `json:"lon" db:"lon"`
+	IsReserved    int        `json:"is_reserved" db:"is_reserved"`
+	IsDisabled    int        `json:"is_disabled" db:"is_disabled"`
+	VehicleTypeID string     `json:"vehicle_type_id" db:"vehicle_type_id"`
+	LastSeenAt    time.Time  `json:"last_seen_at" db:"last_seen_at"`
+	DistanceM     float64    `json:"distance_m,omitempty"`
+}
+
 type StationWithAvailability struct {
 	Station
 	NumBikesAvailable int   `json:"num_bikes_available"`
@@ -89,6 +170,167 @@ type StationWithAvailability struct {
 	IsRenting         int   `json:"is_renting"`
 	IsReturning       int   `json:"is_returning"`
 	LastReported      int64 `json:"last_reported"`
+	// IsStale is set when the station has no availability within
+	// LATEST_AVAILABILITY_MAX_AGE_MIN; the counts above are then zero.
+	IsStale bool `json:"is_stale"`
+
+	// Color is the availability bucket from the configured thresholds.
+	Color string `json:"color,omitempty"`
+}
+
+// Bounds is a latitude/longitude bounding box, e.g. a map viewport.
+type Bounds struct {
+	MinLat float64 `json:"min_lat"`
+	MinLon float64 `json:"min_lon"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLon float64 `json:"max_lon"`
+}
+
+func (b *Bounds) Validate() error {
+	if b.MinLat < -90 || b.MaxLat > 90 || b.MinLon < -180 || b.MaxLon > 180 {
+		return errors.New("bounds must be valid coordinates")
+	}
+	if b.MinLat >= b.MaxLat {
+		return errors.New("min_lat must be less than max_lat")
+	}
+	if b.MinLon >= b.MaxLon {
+		return errors.New("min_lon must be less than max_lon")
+	}
+	return nil
+}
+
+// AvailabilityBucket is the average availability of a station over one time bucket.
+type AvailabilityBucket struct {
+	BucketStart       time.Time `json:"bucket_start"`
+	AvgBikesAvailable float64   `json:"avg_bikes_available"`
+	AvgDocksAvailable float64   `json:"avg_docks_available"`
+	Samples           int       `json:"samples"`
+}
+
+// AvailabilityDelta is the change in one station's availability between two
+// snapshots.
+type AvailabilityDelta struct {
+	StationID   string `json:"station_id"`
+	SystemID    string `json:"system_id"`
+	BikesBefore int    `json:"bikes_before"`
+	BikesAfter  int    `json:"bikes_after"`
+	BikesDelta  int    `json:"bikes_delta"`
+	DocksBefore int    `json:"docks_before"`
+	DocksAfter  int    `json:"docks_after"`
+	DocksDelta  int    `json:"docks_delta"`
+}
+
+// AvailabilityTotals sums availability deltas across all compared stations.
+type AvailabilityTotals struct {
+	BikesBefore int `json:"bikes_before"`
+	BikesAfter  int `json:"bikes_after"`
+	BikesDelta  int `json:"bikes_delta"`
+	DocksBefore int `json:"docks_before"`
+	DocksAfter  int `json:"docks_after"`
+	DocksDelta  int `json:"docks_delta"`
+}
+
+// StationStaleness is the freshest last_reported a station's feed has sent
+// and how long ago that was.
+type StationStaleness struct {
+	StationID    string `json:"station_id"`
+	SystemID     string `json:"system_id"`
+	Name         string `json:"name"`
+	LastReported int64  `json:"last_reported"`
+	AgeSeconds   int64  `json:"age_seconds"`
+	Stale        bool   `json:"stale"`
+}
+
+// CapacityDiscrepancy is a station whose reported capacity differs from the
+// bikes plus docks in its latest status, typically because of broken docks.
+// Gap is capacity minus that sum, so a positive gap means docks unaccounted
+// for.
+type CapacityDiscrepancy struct {
+	StationID     string `json:"station_id"`
+	SystemID      string `json:"system_id"`
+	Name          string `json:"name"`
+	Capacity      int    `json:"capacity"`
+	BikesAndDocks int    `json:"bikes_and_docks"`
+	Gap           int    `json:"gap"`
+}
+
+// OperationalSummary counts stations by what their latest status lets
+// riders do (see the Operational* categories) and lists the IDs of those
+// that are not fully operational.
+type OperationalSummary struct {
+	StationCount           int            `json:"station_count"`
+	Counts                 map[string]int `json:"counts"`
+	NonOperationalStations []string       `json:"non_operational_station_ids"`
+}
+
+// StationDivergence is a station whose predicted availability class differs
+// from the class of its current availability. Change is predicted minus
+// current, so positive values mean the station is expected to empty out.
+// StationForecast is one entry of a batch lookup. Current is null for
+// unknown stations and Forecast when there is no prediction at the
+// requested horizon.
+type StationForecast struct {
+	StationID string                   `json:"station_id"`
+	Current   *StationWithAvailability `json:"current"`
+	Forecast  *Prediction              `json:"forecast"`
+}
+
+// StationNeighbors lists the stations within some radius of a station.
+type StationNeighbors struct {
+	StationID   string
+	Name        string
+	NeighborIDs []string
+}
+
+// PredictionOutlier is a station whose predicted class is far from the
+// average predicted class of its neighbors.
+type PredictionOutlier struct {
+	StationID        string    `json:"station_id"`
+	Name             string    `json:"name"`
+	PredictedClass   int       `json:"predicted_class"`
+	PredictedLabel   string    `json:"predicted_label"`
+	NeighborAvgClass float64   `json:"neighbor_avg_class"`
+	Deviation        float64   `json:"deviation"`
+	NeighborCount    int       `json:"neighbor_count"`
+	PredictionTime   time.Time `json:"prediction_time"`
+}
+
+type StationDivergence struct {
+	StationID         string    `json:"station_id"`
+	SystemID          string    `json:"system_id"`
+	Name              string    `json:"name"`
+	NumBikesAvailable int       `json:"num_bikes_available"`
+	Capacity          int       `json:"capacity"`
+	CurrentClass      int       `json:"current_class"`
+	CurrentLabel      string    `json:"current_label"`
+	PredictedClass    int       `json:"predicted_class"`
+	PredictedLabel    string    `json:"predicted_label"`
+	Change            int       `json:"change"`
+	PredictionTime    time.Time `json:"prediction_time"`
+}
+
+// StationStats totals the latest availability across all stations.
+type StationStats struct {
+	StationCount  int `json:"station_count"`
+	TotalBikes    int `json:"total_bikes"`
+	TotalDocks    int `json:"total_docks"`
+	TotalCapacity int `json:"total_capacity"`
+}
+
+// UtilizationBucket counts stations whose bikes-to-capacity ratio falls in
+// [MinPct, MaxPct); the last bucket also includes full stations.
+type UtilizationBucket struct {
+	Label  string  `json:"label"`
+	MinPct float64 `json:"min_pct"`
+	MaxPct float64 `json:"max_pct"`
+	Count  int     `json:"count"`
+}
+
+// MissingStation is a station with no availability recorded recently.
+// LastRecordAgeSeconds is nil when the station never had any.
+type MissingStation struct {
+	Station
+	LastRecordAgeSeconds *int64 `json:"last_record_age_seconds"`
 }
 
 type Prediction struct {
@@ -99,27 +341,217 @@ type Prediction struct {
 	PredictionTime             time.Time `json:"prediction_time" db:"prediction_time"`
 	HorizonHours               int       `json:"horizon_hours" db:"horizon_hours"`
 	CreatedAt                  time.Time `json:"created_at" db:"created_at"`
+	LastConfirmedAt            time.Time `json:"last_confirmed_at" db:"last_confirmed_at"`
+	// Confidence is the model's confidence in [0, 1], or null when the ML
+	// service did not provide one.
+	Confidence *float64 `json:"confidence" db:"confidence"`
+	// ModelVersion identifies the model that produced the prediction;
+	// "unknown" when the ML service did not say.
+	ModelVersion string `json:"model_version" db:"model_version"`
+
+	// The *Local fields are PredictionTime and CreatedAt formatted in the
+	// requested ?tz=, only set when it is not UTC.
+	PredictionTimeLocal string `json:"prediction_time_local,omitempty"`
+	CreatedAtLocal      string `json:"created_at_local,omitempty"`
+
+	// Smoothed fields are only set for ?smooth=true responses.
+	SmoothedAvailabilityClass      *int   `json:"smoothed_availability_class,omitempty"`
+	SmoothedAvailabilityPrediction string `json:"smoothed_availability_prediction,omitempty"`
+}
+
+// PredictionBatch summarizes the predictions one inference run made for one
+// horizon, whether stored as new rows or confirmed unchanged. CreatedAt is
+// when the run stored them, and Classes counts them by
+// availability_prediction label.
+type PredictionBatch struct {
+	CreatedAt    time.Time      `json:"created_at"`
+	HorizonHours int            `json:"horizon_hours"`
+	Count        int            `json:"count"`
+	Classes      map[string]int `json:"classes"`
+}
+
+// PredictionWithLocation is a prediction with its station's name and
+// coordinates.
+type PredictionWithLocation struct {
+	Prediction
+	Name string
+	Lat  Coordinate
+	Lon  Coordinate
+}
+
+// HorizonPredictionHistory is the predictions stored for one station and
+// horizon, newest first.
+type HorizonPredictionHistory struct {
+	HorizonHours int          `json:"horizon_hours"`
+	Predictions  []Prediction `json:"predictions"`
+}
+
+// PredictionClassBucket counts the predictions created within one time
+// bucket by availability_prediction label.
+type PredictionClassBucket struct {
+	BucketStart time.Time      `json:"bucket_start"`
+	Count       int            `json:"count"`
+	Classes     map[string]int `json:"classes"`
 }
 
+// HorizonFreshness is the newest prediction stored for one horizon and how
+// many are stored. Predictions an inference run reproduced unchanged are
+// confirmed rather than re-inserted, so NewestConfirmedAt is when the horizon
+// was last refreshed and NewestCreatedAt when its predictions last changed.
+type HorizonFreshness struct {
+	HorizonHours         int       `json:"horizon_hours"`
+	NewestCreatedAt      time.Time `json:"newest_created_at"`
+	NewestConfirmedAt    time.Time `json:"newest_confirmed_at"`
+	NewestPredictionTime time.Time `json:"newest_prediction_time"`
+	Count                int       `json:"count"`
+}
+
+// TableStorageStats reports a table's size on disk, indexes and TOAST
+// included, and how many rows it gained each day.
+type TableStorageStats struct {
+	Table      string          `json:"table"`
+	TotalBytes int64           `json:"total_bytes"`
+	Days       []DailyRowCount `json:"days"`
+}
+
+type DailyRowCount struct {
+	Day  time.Time `json:"day"`
+	Rows int       `json:"rows"`
+}
+
+// ErrStationNotFound is returned by single-station lookups for unknown IDs.
+var ErrStationNotFound = errors.New("station not found")
+
 // Focused repository interfaces following Interface Segregation Principle
 type StationRepository interface {
 	UpsertStations(ctx context.Context, stations []Station) error
-	GetStationsWithAvailability(ctx context.Context) ([]StationWithAvailability, error)
+	// InsertPlaceholderStations leaves stations that already exist unchanged.
+	InsertPlaceholderStations(ctx context.Context, stations []Station) error
+	// GetStationsWithAvailability returns stations for systemID, or for every system when it is empty.
+	GetStationsWithAvailability(ctx context.Context, systemID string) ([]StationWithAvailability, error)
+	// StreamStationsWithAvailability calls fn for each station as it is read, stopping at fn's first error.
+	StreamStationsWithAvailability(ctx context.Context, systemID string, fn func(StationWithAvailability) error) error
+	GetStationsUpdatedSince(ctx context.Context, since time.Time) ([]Station, error)
+	CountStations(ctx context.Context) (int, error)
+	// GetStationIDsOfOtherSystems returns which stationIDs are stored for a system other than systemID.
+	GetStationIDsOfOtherSystems(ctx context.Context, systemID string, stationIDs []string) ([]string, error)
+	GetStationsMissingAvailability(ctx context.Context, since time.Time) ([]MissingStation, error)
+	GetStationsInBBox(ctx context.Context, bounds Bounds) ([]StationWithAvailability, error)
+	// GetStationWithAvailability returns ErrStationNotFound for unknown IDs.
+	GetStationWithAvailability(ctx context.Context, stationID string) (StationWithAvailability, error)
+	// GetStationsByIDs omits unknown IDs.
+	GetStationsByIDs(ctx context.Context, stationIDs []string) ([]StationWithAvailability, error)
+	// GetStationNeighbors returns, for every station with at least one
+	// other station within radiusM meters, those stations' IDs.
+	GetStationNeighbors(ctx context.Context, radiusM float64) ([]StationNeighbors, error)
 }
 
 type AvailabilityRepository interface {
 	InsertAvailabilities(ctx context.Context, availabilities []StationAvailability) error
 	GetRecentAvailability(ctx context.Context) ([]StationAvailability, error)
-	GetAvailabilitySince(ctx context.Context, since time.Time) ([]StationAvailability, error)
+	// GetAvailabilitySince and GetAvailabilityForStationsBetween return at
+	// most HISTORY_MAX_ROWS records, and true when more were available.
+	GetAvailabilitySince(ctx context.Context, since time.Time) ([]StationAvailability, bool, error)
+	GetAvailabilitySeries(ctx context.Context, stationID string, from, to time.Time, bucket time.Duration) ([]AvailabilityBucket, error)
+	// GetRollupAvailabilitySeries serves hour-or-longer buckets from the
+	// availability_hourly rollup.
+	GetRollupAvailabilitySeries(ctx context.Context, stationID string, from, to time.Time, bucket time.Duration) ([]AvailabilityBucket, error)
+	UpsertHourlyRollup(ctx context.Context, hour time.Time) (int, error)
+	// GetLatestRollupHour returns the zero time when nothing is rolled up.
+	GetLatestRollupHour(ctx context.Context) (time.Time, error)
+	// GetLatestRecordedAt returns the zero time when nothing is stored.
+	GetLatestRecordedAt(ctx context.Context, systemID string) (time.Time, error)
+	GetLatestAvailabilityMap(ctx context.Context) (map[string]StationAvailability, error)
+	GetStationStaleness(ctx context.Context) ([]StationStaleness, error)
+	// GetAvailabilityNear returns, per station, the record closest to t that is
+	// at most maxGap away from it, keyed by station ID.
+	GetAvailabilityNear(ctx context.Context, t time.Time, maxGap time.Duration) (map[string]StationAvailability, error)
+	// GetAvailabilityForStationsBetween returns the given stations' records
+	// with from <= recorded_at <= to, ordered by station then time.
+	GetAvailabilityForStationsBetween(ctx context.Context, stationIDs []string, from, to time.Time) ([]StationAvailability, bool, error)
+	ImportAvailabilities(ctx context.Context, availabilities []StationAvailability) (int, error)
+	GetStationStats(ctx context.Context) (StationStats, error)
+	// GetUtilizationCounts returns, for stations with a known capacity, how
+	// many fall into each of buckets equal-width utilization buckets, keyed
+	// by 1-based bucket number. Empty buckets are absent.
+	GetUtilizationCounts(ctx context.Context, buckets int) (map[int]int, error)
+}
+
+type AnomalyRepository interface {
+	InsertAnomalies(ctx context.Context, anomalies []AvailabilityAnomaly) error
+	GetAnomaliesSince(ctx context.Context, since time.Time) ([]AvailabilityAnomaly, error)
 }
 
 type PredictionRepository interface {
-	InsertPredictions(ctx context.Context, predictions []Prediction) error
+	InsertPredictions(ctx context.Context, predictions []Prediction) (int, error)
 	GetLatestPredictions(ctx context.Context) ([]Prediction, error)
+	GetLatestPredictionsByHorizon(ctx context.Context) ([]Prediction, error)
+	// GetLatestPredictionsWithLocation returns each station's latest
+	// prediction for horizon per model version, skipping stations without
+	// coordinates.
+	GetLatestPredictionsWithLocation(ctx context.Context, horizon int) ([]PredictionWithLocation, error)
+	GetStationPredictions(ctx context.Context, stationID string) ([]Prediction, error)
+	ConfirmPredictions(ctx context.Context, predictions []Prediction) (int, error)
+	// GetPredictionHistory returns up to limit of the newest stored
+	// predictions per station and horizon, newest first; an empty stationID
+	// covers every station.
+	GetPredictionHistory(ctx context.Context, stationID string, limit int) ([]Prediction, error)
+	RecordPredictionBatches(ctx context.Context, batches []PredictionBatch) error
+	// GetPredictionBatches returns the newest limit batches, newest first.
+	GetPredictionBatches(ctx context.Context, limit int) ([]PredictionBatch, error)
+	// GetPredictionDistribution buckets predictions created in [from, to),
+	// oldest first; horizon 0 covers every horizon.
+	GetPredictionDistribution(ctx context.Context, from, to time.Time, bucket time.Duration, horizon int) ([]PredictionClassBucket, error)
+	// GetPredictionFreshness returns one entry per stored horizon, shortest
+	// first.
+	GetPredictionFreshness(ctx context.Context) ([]HorizonFreshness, error)
+}
+
+// AccuracyRepository backs prediction evaluation. GetActualAvailability
+// returns ErrNoActualAvailability when no record falls within maxGap.
+type AccuracyRepository interface {
+	GetUnevaluatedPredictions(ctx context.Context, from, to time.Time, limit int) ([]Prediction, error)
+	GetActualAvailability(ctx context.Context, stationID string, t time.Time, maxGap time.Duration) (ActualAvailability, error)
+	InsertPredictionAccuracy(ctx context.Context, results []PredictionAccuracy) error
+}
+
+type FreeBikeRepository interface {
+	// ReplaceFreeBikes upserts the latest feed for a system and prunes bikes no longer in it.
+	ReplaceFreeBikes(ctx context.Context, systemID string, bikes []FreeBike) error
+	GetFreeBikesNear(ctx context.Context, lat, lon, radiusM float64) ([]FreeBike, error)
+}
+
+// FeatureFlagRepository persists runtime feature toggles. GetFeatureFlag
+// returns ErrFeatureFlagNotFound for flags without a stored row;
+// SetFeatureFlag returns ErrFeatureFlagVersionConflict when the stored
+// version no longer matches expectedVersion.
+type FeatureFlagRepository interface {
+	GetFeatureFlag(ctx context.Context, name string) (FeatureFlag, error)
+	GetFeatureFlags(ctx context.Context) ([]FeatureFlag, error)
+	SetFeatureFlag(ctx context.Context, name string, enabled bool, expectedVersion int) (FeatureFlag, error)
+}
+
+type WebhookRepository interface {
+	// GetWebhooks returns every webhook, secrets included, oldest first.
+	GetWebhooks(ctx context.Context) ([]StationWebhook, error)
+	CreateWebhook(ctx context.Context, webhook StationWebhook) (StationWebhook, error)
+	// DeleteWebhook and EnableWebhook return ErrWebhookNotFound for unknown IDs.
+	DeleteWebhook(ctx context.Context, id int) error
+	EnableWebhook(ctx context.Context, id int) (StationWebhook, error)
+	SetWebhookConditionMet(ctx context.Context, id int, met bool) (bool, error)
+	// RecordWebhookDelivery resets the failure count on success, or counts a
+	// failure and disables the webhook once disableAfter failures in a row
+	// are reached, reporting whether it did.
+	RecordWebhookDelivery(ctx context.Context, id int, delivered bool, disableAfter int) (bool, error)
 }
 
 type HealthChecker interface {
 	HealthCheck(ctx context.Context) error
+	// AppliedMigrationVersion returns the highest migration version recorded by RecordMigration.
+	AppliedMigrationVersion(ctx context.Context) (int, error)
+	// GetStorageStats covers station_availability and predictions, counting
+	// rows added since since per day, oldest first.
+	GetStorageStats(ctx context.Context, since time.Time) ([]TableStorageStats, error)
 	Close() error
 }
 
@@ -128,23 +560,48 @@ type DatabaseInterface interface {
 	StationRepository
 	AvailabilityRepository
 	PredictionRepository
+	AccuracyRepository
+	FreeBikeRepository
+	AnomalyRepository
+	FeatureFlagRepository
+	WebhookRepository
 	HealthChecker
 }
 
 // Service interfaces
 type DivvyClientInterface interface {
-	FetchStationData(ctx context.Context) ([]DivvyStation, []DivvyStationStatus, error)
+	FetchStationData(ctx context.Context, system SystemConfig) ([]DivvyStation, []DivvyStationStatus, error)
+	// FetchStationStatus fetches station_status alone, for cycles that reuse
+	// the last station list.
+	FetchStationStatus(ctx context.Context, system SystemConfig) ([]DivvyStationStatus, error)
+	FetchFreeBikes(ctx context.Context, system SystemConfig) ([]DivvyFreeBike, error)
+	// FetchFeedLastUpdated fetches a system's station_status last_updated.
+	FetchFeedLastUpdated(ctx context.Context, system SystemConfig) (time.Time, error)
+	// LastFeedUpdated returns the station_status last_updated seen by the
+	// most recent fetch for systemID.
+	LastFeedUpdated(systemID string) (time.Time, bool)
 }
 
 type MLServiceInterface interface {
-	GetPredictions(ctx context.Context) (*PredictionResponse, error)
+	GetPredictions(ctx context.Context, stations []StationWithAvailability) (*PredictionResponse, error)
 	GetStatus(ctx context.Context) (map[string]interface{}, error)
 }
 
 type StationServiceInterface interface {
 	RefreshStationData(ctx context.Context) error
+	// DryRunRefresh fetches and validates every system's feeds without
+	// storing anything.
+	DryRunRefresh(ctx context.Context) []RefreshDryRun
+	Snapshot() *StationsSnapshot
+	// OverlayUnpersisted applies availability held back from the database
+	// (AVAILABILITY_PERSIST_EVERY_N) to stations read from it, in place.
+	OverlayUnpersisted(stations []StationWithAvailability)
+	// GetRecentAvailability returns recent availability, newest first,
+	// including availability held back from the database.
+	GetRecentAvailability(ctx context.Context) ([]StationAvailability, error)
 }
 
 type InferenceServiceInterface interface {
 	RunInferenceWithResults(ctx context.Context) error
+	TimeParseFailures() TimeParseFailureStats
 }
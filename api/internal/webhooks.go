@@ -0,0 +1,536 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	WebhookOperatorBelow = "below"
+	WebhookOperatorAbove = "above"
+
+	// webhookSignatureHeader carries "sha256=" followed by the hex HMAC-SHA256
+	// of the request body, keyed with the webhook's secret.
+	webhookSignatureHeader = "X-Webhook-Signature"
+	webhookRetryBase       = time.Second
+
+	// recentWebhookDeliveries is how many delivery results are kept for
+	// GET /api/admin/webhooks/deliveries.
+	recentWebhookDeliveries = 200
+	defaultDeliveriesLimit  = 50
+)
+
+// ErrWebhookNotFound is returned for unknown webhook IDs.
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// webhookFields are the station fields a webhook condition may watch.
+var webhookFields = []string{"num_bikes_available", "num_docks_available"}
+
+// StationWebhook POSTs to TargetURL when a station's Field crosses Threshold,
+// e.g. num_bikes_available below 1. It fires once per crossing: ConditionMet
+// remembers that the condition held at the last refresh.
+type StationWebhook struct {
+	ID                  int        `json:"id"`
+	StationID           string     `json:"station_id"`
+	Field               string     `json:"field"`
+	Operator            string     `json:"operator"`
+	Threshold           int        `json:"threshold"`
+	TargetURL           string     `json:"target_url"`
+	Secret              string     `json:"-"`
+	Enabled             bool       `json:"enabled"`
+	ConditionMet        bool       `json:"condition_met"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastTriggeredAt     *time.Time `json:"last_triggered_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// Matches reports whether value satisfies the webhook's condition.
+func (w StationWebhook) Matches(value int) bool {
+	if w.Operator == WebhookOperatorAbove {
+		return value > w.Threshold
+	}
+	return value < w.Threshold
+}
+
+// WebhookPayload is the JSON body delivered to a webhook's target URL.
+type WebhookPayload struct {
+	WebhookID   int       `json:"webhook_id"`
+	StationID   string    `json:"station_id"`
+	StationName string    `json:"station_name"`
+	Field       string    `json:"field"`
+	Operator    string    `json:"operator"`
+	Threshold   int       `json:"threshold"`
+	Value       int       `json:"value"`
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+// signWebhookPayload returns the webhookSignatureHeader value for body.
+func signWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookDelivery is the outcome of delivering one payload, kept in the
+// notifier's recent deliveries log. Result is delivered, failed or
+// cancelled (by shutdown).
+type WebhookDelivery struct {
+	WebhookID   int       `json:"webhook_id"`
+	StationID   string    `json:"station_id"`
+	Result      string    `json:"result"`
+	Attempts    int       `json:"attempts"`
+	DurationMs  int64     `json:"duration_ms"`
+	Error       string    `json:"error,omitempty"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// WebhookNotifier evaluates station webhooks after each refresh and delivers
+// the ones whose condition started holding. A nil *WebhookNotifier does
+// nothing.
+type WebhookNotifier struct {
+	database  WebhookRepository
+	client    *http.Client
+	config    WebhookConfig
+	retryBase time.Duration
+	// privateTargets lets deliveries reach private addresses; tests deliver
+	// to loopback servers. Otherwise every delivery is made
+	// withPublicOnlyDial, so a target URL cannot reach internal services.
+	privateTargets bool
+
+	// slots bounds concurrent deliveries to WEBHOOK_CONCURRENCY.
+	slots chan struct{}
+	// stopCtx is cancelled by Shutdown, abandoning queued and in-flight
+	// deliveries.
+	stopCtx  context.Context
+	stop     context.CancelFunc
+	mu       sync.Mutex
+	stopped  bool
+	inFlight sync.WaitGroup
+
+	recentMu sync.Mutex
+	recent   []WebhookDelivery
+}
+
+func NewWebhookNotifier(database WebhookRepository, config *Config) *WebhookNotifier {
+	stopCtx, stop := context.WithCancel(context.Background())
+	return &WebhookNotifier{
+		database:  database,
+		client:    newHTTPClient(config, time.Duration(config.Webhooks.TimeoutSec)*time.Second),
+		config:    config.Webhooks,
+		retryBase: webhookRetryBase,
+		slots:     make(chan struct{}, max(config.Webhooks.Concurrency, 1)),
+		stopCtx:   stopCtx,
+		stop:      stop,
+	}
+}
+
+// webhookJob is one payload due for delivery.
+type webhookJob struct {
+	webhook StationWebhook
+	payload WebhookPayload
+}
+
+// Evaluate checks every enabled webhook against the refreshed stations,
+// records condition changes and starts delivery of newly met conditions in
+// the background, so slow or failing targets never hold up a refresh. Errors
+// are logged; webhooks never fail a refresh.
+//
+// Deliveries outlive the refresh, which may be tied to a request; they stop
+// only on Shutdown or their own WEBHOOK_DELIVERY_TIMEOUT_SEC.
+func (n *WebhookNotifier) Evaluate(ctx context.Context, stations []StationWithAvailability) {
+	if n == nil || len(stations) == 0 {
+		return
+	}
+	webhooks, err := n.database.GetWebhooks(ctx)
+	if err != nil {
+		log.Printf("Skipping webhook evaluation, failed to load webhooks: %v", err)
+		return
+	}
+
+	byID := make(map[string]*StationWithAvailability, len(stations))
+	for i := range stations {
+		byID[stations[i].StationID] = &stations[i]
+	}
+
+	now := time.Now().UTC()
+	var jobs []webhookJob
+	for _, webhook := range webhooks {
+		station, ok := byID[webhook.StationID]
+		if !webhook.Enabled || !ok {
+			continue
+		}
+		value, _ := stationFields[webhook.Field](station).(int)
+		met := webhook.Matches(value)
+		if met == webhook.ConditionMet {
+			continue
+		}
+		// Every replica evaluates each refresh; only the one that records
+		// the change delivers it.
+		changed, err := n.database.SetWebhookConditionMet(ctx, webhook.ID, met)
+		if err != nil {
+			log.Printf("Failed to update webhook %d condition: %v", webhook.ID, err)
+			continue
+		}
+		if !changed || !met {
+			continue
+		}
+
+		jobs = append(jobs, webhookJob{
+			webhook: webhook,
+			payload: WebhookPayload{
+				WebhookID:   webhook.ID,
+				StationID:   station.StationID,
+				StationName: station.Name,
+				Field:       webhook.Field,
+				Operator:    webhook.Operator,
+				Threshold:   webhook.Threshold,
+				Value:       value,
+				TriggeredAt: now,
+			},
+		})
+	}
+	n.dispatch(jobs)
+}
+
+// dispatch delivers each job in its own goroutine, at most
+// WEBHOOK_CONCURRENCY at a time, so one slow target only holds up its own
+// delivery. Jobs still waiting for a slot when Shutdown is called are
+// dropped.
+func (n *WebhookNotifier) dispatch(jobs []webhookJob) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.stopped {
+		return
+	}
+	for _, job := range jobs {
+		n.inFlight.Add(1)
+		go func() {
+			defer n.inFlight.Done()
+			select {
+			case n.slots <- struct{}{}:
+			case <-n.stopCtx.Done():
+				return
+			}
+			defer func() { <-n.slots }()
+			if n.stopCtx.Err() != nil {
+				return
+			}
+
+			ctx, cancel := n.deliveryContext()
+			defer cancel()
+			n.deliver(ctx, job.webhook, job.payload)
+		}()
+	}
+}
+
+// deliveryContext bounds one delivery by Shutdown and
+// WEBHOOK_DELIVERY_TIMEOUT_SEC.
+func (n *WebhookNotifier) deliveryContext() (context.Context, context.CancelFunc) {
+	if timeout := n.config.DeliveryTimeoutSec; timeout > 0 {
+		return context.WithTimeout(n.stopCtx, time.Duration(timeout)*time.Second)
+	}
+	return context.WithCancel(n.stopCtx)
+}
+
+// Shutdown cancels queued and in-flight deliveries and waits for them to
+// finish until ctx is done.
+func (n *WebhookNotifier) Shutdown(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+	n.mu.Lock()
+	n.stopped = true
+	n.mu.Unlock()
+	n.stop()
+
+	done := make(chan struct{})
+	go func() {
+		n.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deliver POSTs payload, retrying with exponential backoff, then records the
+// outcome. Failing every attempt, including running out of delivery time,
+// counts as one failure towards disabling the webhook; a delivery cut short
+// by Shutdown does not.
+func (n *WebhookNotifier) deliver(ctx context.Context, webhook StationWebhook, payload WebhookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to encode webhook %d payload: %v", webhook.ID, err)
+		return
+	}
+
+	start := time.Now()
+	attempts := max(n.config.MaxAttempts, 1)
+	attempt := 1
+	for ; ; attempt++ {
+		if err = n.post(ctx, webhook, body); err == nil {
+			break
+		}
+		log.Printf("Webhook %d delivery attempt %d/%d failed: %v", webhook.ID, attempt, attempts, err)
+		if attempt >= attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(n.retryBase << (attempt - 1)):
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			break
+		}
+	}
+
+	delivered := err == nil
+	result := "delivered"
+	switch {
+	case !delivered && n.stopCtx.Err() != nil:
+		result = "cancelled"
+	case !delivered:
+		result = "failed"
+	}
+	n.observe(webhook, result, attempt, time.Since(start), err)
+	if result == "cancelled" {
+		return
+	}
+
+	// The delivery context may have just timed out; recording must not.
+	disabled, err := n.database.RecordWebhookDelivery(context.WithoutCancel(ctx), webhook.ID, delivered, n.config.DisableAfterFailures)
+	if err != nil {
+		log.Printf("Failed to record webhook %d delivery: %v", webhook.ID, err)
+		return
+	}
+	if disabled {
+		log.Printf("Disabled webhook %d after %d consecutive failed deliveries", webhook.ID, n.config.DisableAfterFailures)
+	}
+}
+
+// observe counts a delivery in the metrics and the recent deliveries log.
+func (n *WebhookNotifier) observe(webhook StationWebhook, result string, attempts int, elapsed time.Duration, err error) {
+	webhookDeliveries.WithLabelValues(result).Inc()
+	webhookDeliveryDuration.WithLabelValues(result).Observe(elapsed.Seconds())
+
+	delivery := WebhookDelivery{
+		WebhookID:   webhook.ID,
+		StationID:   webhook.StationID,
+		Result:      result,
+		Attempts:    attempts,
+		DurationMs:  elapsed.Milliseconds(),
+		CompletedAt: time.Now().UTC(),
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+
+	n.recentMu.Lock()
+	defer n.recentMu.Unlock()
+	n.recent = append(n.recent, delivery)
+	if len(n.recent) > recentWebhookDeliveries {
+		n.recent = slices.Delete(n.recent, 0, len(n.recent)-recentWebhookDeliveries)
+	}
+}
+
+// RecentDeliveries returns up to limit of the latest delivery results,
+// newest first.
+func (n *WebhookNotifier) RecentDeliveries(limit int) []WebhookDelivery {
+	if n == nil {
+		return []WebhookDelivery{}
+	}
+	n.recentMu.Lock()
+	defer n.recentMu.Unlock()
+	deliveries := make([]WebhookDelivery, 0, min(limit, len(n.recent)))
+	for i := len(n.recent) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		deliveries = append(deliveries, n.recent[i])
+	}
+	return deliveries
+}
+
+func (n *WebhookNotifier) post(ctx context.Context, webhook StationWebhook, body []byte) error {
+	if !n.privateTargets {
+		ctx = withPublicOnlyDial(ctx)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.TargetURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", strconv.Itoa(webhook.ID))
+	req.Header.Set(webhookSignatureHeader, signWebhookPayload(webhook.Secret, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("target responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// GetWebhooks lists every registered webhook. Secrets are never returned.
+func (h *HTTPHandlers) GetWebhooks(c *gin.Context) {
+	webhooks, err := h.database.GetWebhooks(c.Request.Context())
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch webhooks", err)
+		return
+	}
+	if webhooks == nil {
+		webhooks = []StationWebhook{}
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": webhooks, "count": len(webhooks)})
+}
+
+// CreateWebhook registers a webhook from a {"station_id", "field",
+// "operator", "threshold", "target_url", "secret"} body. The secret signs
+// deliveries; one is generated when omitted. It is returned only here.
+func (h *HTTPHandlers) CreateWebhook(c *gin.Context) {
+	var body struct {
+		StationID string `json:"station_id"`
+		Field     string `json:"field"`
+		Operator  string `json:"operator"`
+		Threshold *int   `json:"threshold"`
+		TargetURL string `json:"target_url"`
+		Secret    string `json:"secret"`
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(&body); err != nil || body.StationID == "" || body.Threshold == nil {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest,
+			"body must be {\"station_id\": string, \"field\": string, \"operator\": string, \"threshold\": int, \"target_url\": string}")
+		return
+	}
+	if !slices.Contains(webhookFields, body.Field) {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "field must be one of "+strings.Join(webhookFields, ", "))
+		return
+	}
+	if body.Operator != WebhookOperatorBelow && body.Operator != WebhookOperatorAbove {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "operator must be below or above")
+		return
+	}
+	u, err := url.Parse(body.TargetURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "target_url must be an absolute http(s) URL")
+		return
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && isPrivateIP(ip) {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "target_url must not be a private address")
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.database.GetStationWithAvailability(ctx, body.StationID); errors.Is(err, ErrStationNotFound) {
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, "Station not found")
+		return
+	} else if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to fetch station", err)
+		return
+	}
+
+	secret := body.Secret
+	if secret == "" {
+		secret = rand.Text()
+	}
+
+	webhook, err := h.database.CreateWebhook(ctx, StationWebhook{
+		StationID: body.StationID,
+		Field:     body.Field,
+		Operator:  body.Operator,
+		Threshold: *body.Threshold,
+		TargetURL: body.TargetURL,
+		Secret:    secret,
+	})
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to create webhook", err)
+		return
+	}
+	log.Printf("Registered webhook %d for station %s (%s %s %d)", webhook.ID, webhook.StationID, webhook.Field, webhook.Operator, webhook.Threshold)
+	c.JSON(http.StatusCreated, gin.H{"webhook": webhook, "secret": secret})
+}
+
+// DeleteWebhook removes a webhook.
+func (h *HTTPHandlers) DeleteWebhook(c *gin.Context) {
+	id, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+	err := h.database.DeleteWebhook(c.Request.Context(), id)
+	if errors.Is(err, ErrWebhookNotFound) {
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to delete webhook", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// EnableWebhook re-enables a webhook disabled after failed deliveries and
+// resets its failure count.
+func (h *HTTPHandlers) EnableWebhook(c *gin.Context) {
+	id, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+	webhook, err := h.database.EnableWebhook(c.Request.Context(), id)
+	if errors.Is(err, ErrWebhookNotFound) {
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		h.handleError(c, http.StatusInternalServerError, ErrCodeDBError, "Failed to enable webhook", err)
+		return
+	}
+	c.JSON(http.StatusOK, webhook)
+}
+
+// GetWebhookDeliveries returns the latest ?limit= webhook delivery results
+// (default 50), newest first. The log is kept in memory, so it starts empty
+// after a restart.
+func (h *HTTPHandlers) GetWebhookDeliveries(c *gin.Context) {
+	limit := defaultDeliveriesLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > recentWebhookDeliveries {
+			respondError(c, http.StatusBadRequest, ErrCodeBadRequest,
+				fmt.Sprintf("limit must be an integer between 1 and %d", recentWebhookDeliveries))
+			return
+		}
+		limit = parsed
+	}
+	deliveries := h.webhooks.RecentDeliveries(limit)
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries, "count": len(deliveries)})
+}
+
+func webhookIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		respondError(c, http.StatusBadRequest, ErrCodeBadRequest, "webhook id must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
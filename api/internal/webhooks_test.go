@@ -0,0 +1,354 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStationWebhook_Matches(t *testing.T) {
+	below := StationWebhook{Operator: WebhookOperatorBelow, Threshold: 1}
+	assert.True(t, below.Matches(0))
+	assert.False(t, below.Matches(1))
+
+	above := StationWebhook{Operator: WebhookOperatorAbove, Threshold: 10}
+	assert.True(t, above.Matches(11))
+	assert.False(t, above.Matches(10))
+}
+
+func TestWebhookNotifier_Evaluate(t *testing.T) {
+	var requests atomic.Int32
+	received := make(chan WebhookPayload, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first attempt fails so the retry is exercised.
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, signWebhookPayload("s3cret", body), r.Header.Get(webhookSignatureHeader))
+		var payload WebhookPayload
+		assert.NoError(t, json.Unmarshal(body, &payload))
+		received <- payload
+	}))
+	defer target.Close()
+
+	mockDB := new(MockDatabase)
+	mockDB.On("GetWebhooks", mock.Anything).Return([]StationWebhook{
+		{ID: 1, StationID: "empty", Field: "num_bikes_available", Operator: WebhookOperatorBelow, Threshold: 1, TargetURL: target.URL, Secret: "s3cret", Enabled: true},
+		// Already below: no repeat notification.
+		{ID: 2, StationID: "empty", Field: "num_bikes_available", Operator: WebhookOperatorBelow, Threshold: 1, TargetURL: target.URL, Enabled: true, ConditionMet: true},
+		// Recovered: the condition is cleared without a delivery.
+		{ID: 3, StationID: "full", Field: "num_bikes_available", Operator: WebhookOperatorBelow, Threshold: 1, TargetURL: target.URL, Enabled: true, ConditionMet: true},
+		{ID: 4, StationID: "empty", Field: "num_bikes_available", Operator: WebhookOperatorBelow, Threshold: 1, TargetURL: target.URL},
+	}, nil)
+	mockDB.On("SetWebhookConditionMet", mock.Anything, 1, true).Return(true, nil).Once()
+	mockDB.On("SetWebhookConditionMet", mock.Anything, 3, false).Return(true, nil).Once()
+	recorded := make(chan struct{})
+	mockDB.On("RecordWebhookDelivery", mock.Anything, 1, true, 5).Return(false, nil).Once().
+		Run(func(mock.Arguments) { close(recorded) })
+
+	config := NewTestConfig()
+	config.Webhooks = WebhookConfig{TimeoutSec: 5, MaxAttempts: 3, DisableAfterFailures: 5}
+	notifier := NewWebhookNotifier(mockDB, config)
+	notifier.privateTargets = true
+	notifier.retryBase = time.Millisecond
+
+	notifier.Evaluate(context.Background(), []StationWithAvailability{
+		{Station: Station{StationID: "empty", Name: "Empty Station"}, NumBikesAvailable: 0},
+		{Station: Station{StationID: "full", Name: "Full Station"}, NumBikesAvailable: 12},
+	})
+
+	select {
+	case payload := <-received:
+		assert.Equal(t, 1, payload.WebhookID)
+		assert.Equal(t, "Empty Station", payload.StationName)
+		assert.Equal(t, 0, payload.Value)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	select {
+	case <-recorded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery was not recorded")
+	}
+	assert.Equal(t, int32(2), requests.Load())
+	mockDB.AssertExpectations(t)
+}
+
+func TestWebhookNotifier_DisablesAfterFailures(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer target.Close()
+
+	mockDB := new(MockDatabase)
+	mockDB.On("RecordWebhookDelivery", mock.Anything, 7, false, 5).Return(true, nil).Once()
+
+	config := NewTestConfig()
+	config.Webhooks = WebhookConfig{TimeoutSec: 5, MaxAttempts: 2, DisableAfterFailures: 5}
+	notifier := NewWebhookNotifier(mockDB, config)
+	notifier.privateTargets = true
+	notifier.retryBase = time.Millisecond
+
+	notifier.deliver(context.Background(), StationWebhook{ID: 7, TargetURL: target.URL}, WebhookPayload{WebhookID: 7})
+	mockDB.AssertExpectations(t)
+}
+
+func TestWebhookNotifier_EvaluateChangeRecordedElsewhere(t *testing.T) {
+	mockDB := new(MockDatabase)
+	mockDB.On("GetWebhooks", mock.Anything).Return([]StationWebhook{
+		{ID: 1, StationID: "empty", Field: "num_bikes_available", Operator: WebhookOperatorBelow, Threshold: 1, TargetURL: "https://hooks.example.com", Enabled: true},
+	}, nil)
+	// Another replica recorded the crossing first, so this one must not
+	// deliver it again.
+	mockDB.On("SetWebhookConditionMet", mock.Anything, 1, true).Return(false, nil).Once()
+
+	notifier := NewWebhookNotifier(mockDB, NewTestConfig())
+	notifier.Evaluate(context.Background(), []StationWithAvailability{{Station: Station{StationID: "empty"}}})
+	notifier.inFlight.Wait()
+
+	assert.Empty(t, notifier.RecentDeliveries(10))
+	mockDB.AssertExpectations(t)
+}
+
+func TestWebhookNotifier_RefusesPrivateTargets(t *testing.T) {
+	var requests atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer target.Close()
+
+	mockDB := new(MockDatabase)
+	mockDB.On("RecordWebhookDelivery", mock.Anything, 5, false, 5).Return(false, nil).Once()
+
+	config := NewTestConfig()
+	config.Webhooks = WebhookConfig{TimeoutSec: 5, MaxAttempts: 1, DisableAfterFailures: 5}
+	notifier := NewWebhookNotifier(mockDB, config)
+
+	notifier.deliver(context.Background(), StationWebhook{ID: 5, TargetURL: target.URL}, WebhookPayload{WebhookID: 5})
+
+	deliveries := notifier.RecentDeliveries(1)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, "failed", deliveries[0].Result)
+	assert.Contains(t, deliveries[0].Error, ErrPrivateAddress.Error())
+	assert.Zero(t, requests.Load())
+	mockDB.AssertExpectations(t)
+}
+
+func TestWebhookNotifier_EvaluateNil(t *testing.T) {
+	var notifier *WebhookNotifier
+	assert.NotPanics(t, func() { notifier.Evaluate(context.Background(), []StationWithAvailability{{}}) })
+}
+
+func TestWebhookNotifier_DispatchBoundsConcurrency(t *testing.T) {
+	var active, peak atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		active.Add(-1)
+	}))
+	defer target.Close()
+
+	mockDB := new(MockDatabase)
+	mockDB.On("RecordWebhookDelivery", mock.Anything, mock.Anything, true, 5).Return(false, nil).Times(5)
+
+	config := NewTestConfig()
+	config.Webhooks = WebhookConfig{TimeoutSec: 5, MaxAttempts: 1, DisableAfterFailures: 5, Concurrency: 2}
+	notifier := NewWebhookNotifier(mockDB, config)
+	notifier.privateTargets = true
+
+	var jobs []webhookJob
+	for id := 1; id <= 5; id++ {
+		jobs = append(jobs, webhookJob{webhook: StationWebhook{ID: id, TargetURL: target.URL}, payload: WebhookPayload{WebhookID: id}})
+	}
+	notifier.dispatch(jobs)
+	notifier.inFlight.Wait()
+
+	assert.Equal(t, int32(2), peak.Load())
+	deliveries := notifier.RecentDeliveries(10)
+	assert.Len(t, deliveries, 5)
+	for _, delivery := range deliveries {
+		assert.Equal(t, "delivered", delivery.Result)
+		assert.Equal(t, 1, delivery.Attempts)
+	}
+	mockDB.AssertExpectations(t)
+}
+
+func TestWebhookNotifier_DeliveryTimeout(t *testing.T) {
+	release := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer target.Close()
+	defer close(release)
+
+	mockDB := new(MockDatabase)
+	mockDB.On("RecordWebhookDelivery", mock.Anything, 9, false, 5).Return(false, nil).Once()
+
+	config := NewTestConfig()
+	config.Webhooks = WebhookConfig{TimeoutSec: 30, MaxAttempts: 3, DisableAfterFailures: 5, Concurrency: 1, DeliveryTimeoutSec: 1}
+	notifier := NewWebhookNotifier(mockDB, config)
+	notifier.privateTargets = true
+
+	notifier.dispatch([]webhookJob{{webhook: StationWebhook{ID: 9, TargetURL: target.URL}, payload: WebhookPayload{WebhookID: 9}}})
+	notifier.inFlight.Wait()
+
+	deliveries := notifier.RecentDeliveries(1)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, "failed", deliveries[0].Result)
+	assert.Equal(t, 1, deliveries[0].Attempts, "no retry once the delivery ran out of time")
+	mockDB.AssertExpectations(t)
+}
+
+func TestWebhookNotifier_Shutdown(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	defer target.Close()
+	defer close(release)
+
+	mockDB := new(MockDatabase)
+	config := NewTestConfig()
+	config.Webhooks = WebhookConfig{TimeoutSec: 30, MaxAttempts: 1, DisableAfterFailures: 5, Concurrency: 1}
+	notifier := NewWebhookNotifier(mockDB, config)
+	notifier.privateTargets = true
+
+	notifier.dispatch([]webhookJob{
+		// Whichever of the two does not get the single slot is still
+		// queued when shutdown starts.
+		{webhook: StationWebhook{ID: 1, TargetURL: target.URL}, payload: WebhookPayload{WebhookID: 1}},
+		{webhook: StationWebhook{ID: 2, TargetURL: target.URL}, payload: WebhookPayload{WebhookID: 2}},
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, notifier.Shutdown(ctx))
+
+	deliveries := notifier.RecentDeliveries(10)
+	require.Len(t, deliveries, 1, "the queued delivery is dropped")
+	assert.Equal(t, "cancelled", deliveries[0].Result)
+	mockDB.AssertNotCalled(t, "RecordWebhookDelivery", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// Nothing new starts after shutdown.
+	notifier.dispatch([]webhookJob{{webhook: StationWebhook{ID: 3, TargetURL: target.URL}}})
+	notifier.inFlight.Wait()
+	assert.Len(t, notifier.RecentDeliveries(10), 1)
+}
+
+func TestHTTPHandlers_GetWebhookDeliveries(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{name: "default limit", expectedStatus: http.StatusOK, expectedCount: 3},
+		{name: "custom limit", query: "?limit=2", expectedStatus: http.StatusOK, expectedCount: 2},
+		{name: "limit too large", query: "?limit=1000", expectedStatus: http.StatusBadRequest},
+		{name: "invalid limit", query: "?limit=none", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers := NewHTTPHandlers(new(MockDatabase), new(MockDivvyClient), NewTestConfig())
+			for id := 1; id <= 3; id++ {
+				handlers.webhooks.observe(StationWebhook{ID: id}, "delivered", 1, time.Millisecond, nil)
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.GET("/deliveries", handlers.GetWebhookDeliveries)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/deliveries"+tt.query, nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				assertErrorEnvelope(t, w, ErrCodeBadRequest)
+				return
+			}
+			var response struct {
+				Deliveries []WebhookDelivery `json:"deliveries"`
+				Count      int               `json:"count"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedCount, response.Count)
+			assert.Equal(t, 3, response.Deliveries[0].WebhookID, "newest first")
+		})
+	}
+}
+
+func TestHTTPHandlers_CreateWebhook(t *testing.T) {
+	const validBody = `{"station_id": "test-001", "field": "num_bikes_available", "operator": "below", "threshold": 1, "target_url": "https://hooks.example.com/divvy"}`
+
+	tests := []struct {
+		name           string
+		body           string
+		stationErr     error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{name: "created", body: validBody, expectedStatus: http.StatusCreated},
+		{name: "unknown station", body: validBody, stationErr: ErrStationNotFound, expectedStatus: http.StatusNotFound, expectedCode: ErrCodeNotFound},
+		{name: "missing threshold", body: `{"station_id": "test-001", "field": "num_bikes_available", "operator": "below", "target_url": "https://hooks.example.com"}`, expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+		{name: "unsupported field", body: strings.Replace(validBody, "num_bikes_available", "capacity", 1), expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+		{name: "unsupported operator", body: strings.Replace(validBody, "below", "equals", 1), expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+		{name: "private target", body: strings.Replace(validBody, "hooks.example.com", "10.0.0.5", 1), expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+		{name: "relative target", body: strings.Replace(validBody, "https://hooks.example.com/divvy", "/divvy", 1), expectedStatus: http.StatusBadRequest, expectedCode: ErrCodeBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDatabase)
+			handlers := NewHTTPHandlers(mockDB, new(MockDivvyClient), NewTestConfig())
+			mockDB.On("GetStationWithAvailability", mock.Anything, "test-001").
+				Return(TestStationWithAvailability, tt.stationErr).Maybe()
+			mockDB.On("CreateWebhook", mock.Anything, mock.MatchedBy(func(w StationWebhook) bool {
+				return w.StationID == "test-001" && w.Threshold == 1 && w.Secret != ""
+			})).Return(StationWebhook{ID: 1, StationID: "test-001", Secret: "generated", Enabled: true}, nil).Maybe()
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.POST("/webhooks", handlers.CreateWebhook)
+
+			req := httptest.NewRequest("POST", "/webhooks", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedCode != "" {
+				assertErrorEnvelope(t, w, tt.expectedCode)
+				return
+			}
+			var response struct {
+				Webhook map[string]interface{} `json:"webhook"`
+				Secret  string                 `json:"secret"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.NotEmpty(t, response.Secret)
+			assert.NotContains(t, response.Webhook, "secret")
+		})
+	}
+}
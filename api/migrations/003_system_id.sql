@@ -0,0 +1,5 @@
+ALTER TABLE stations ADD COLUMN IF NOT EXISTS system_id VARCHAR(50) NOT NULL DEFAULT 'divvy';
+ALTER TABLE station_availability ADD COLUMN IF NOT EXISTS system_id VARCHAR(50) NOT NULL DEFAULT 'divvy';
+
+CREATE INDEX IF NOT EXISTS idx_stations_system_id ON stations(system_id);
+CREATE INDEX IF NOT EXISTS idx_station_availability_system_id ON station_availability(system_id);
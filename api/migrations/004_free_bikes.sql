@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS free_bikes (
+    system_id VARCHAR(50) NOT NULL,
+    bike_id VARCHAR(100) NOT NULL,
+    lat DECIMAL(10, 8) NOT NULL,
+    lon DECIMAL(11, 8) NOT NULL,
+    is_reserved SMALLINT NOT NULL DEFAULT 0,
+    is_disabled SMALLINT NOT NULL DEFAULT 0,
+    vehicle_type_id VARCHAR(50) NOT NULL DEFAULT '',
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (system_id, bike_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_free_bikes_lat_lon ON free_bikes(lat, lon);
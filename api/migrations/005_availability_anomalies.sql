@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS availability_anomalies (
+    id SERIAL PRIMARY KEY,
+    station_id VARCHAR(50) NOT NULL,
+    system_id VARCHAR(50) NOT NULL DEFAULT 'divvy',
+    bikes_before INTEGER NOT NULL,
+    bikes_after INTEGER NOT NULL,
+    docks_before INTEGER NOT NULL,
+    docks_after INTEGER NOT NULL,
+    change_pct DOUBLE PRECISION NOT NULL,
+    detected_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_availability_anomalies_detected_at ON availability_anomalies(detected_at);
@@ -0,0 +1,9 @@
+ALTER TABLE predictions ADD COLUMN IF NOT EXISTS last_confirmed_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE predictions SET last_confirmed_at = created_at WHERE last_confirmed_at IS NULL;
+
+ALTER TABLE predictions ALTER COLUMN last_confirmed_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE predictions ALTER COLUMN last_confirmed_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_predictions_station_horizon_created
+ON predictions(station_id, horizon_hours, created_at DESC);
@@ -0,0 +1 @@
+ALTER TABLE feature_flags ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
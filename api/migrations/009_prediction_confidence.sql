@@ -0,0 +1 @@
+ALTER TABLE predictions ADD COLUMN IF NOT EXISTS confidence DOUBLE PRECISION;
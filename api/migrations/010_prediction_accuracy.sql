@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS prediction_accuracy (
+    prediction_id INTEGER PRIMARY KEY REFERENCES predictions(id) ON DELETE CASCADE,
+    station_id VARCHAR(50) NOT NULL,
+    horizon_hours INTEGER NOT NULL,
+    predicted_class INTEGER NOT NULL,
+    actual_class INTEGER NOT NULL,
+    correct BOOLEAN NOT NULL,
+    prediction_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    evaluated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_prediction_accuracy_prediction_time ON prediction_accuracy(prediction_time);
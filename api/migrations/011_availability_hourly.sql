@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS availability_hourly (
+    station_id VARCHAR(50) NOT NULL,
+    hour TIMESTAMP WITH TIME ZONE NOT NULL,
+    avg_bikes_available DOUBLE PRECISION NOT NULL,
+    min_bikes_available INTEGER NOT NULL,
+    max_bikes_available INTEGER NOT NULL,
+    avg_docks_available DOUBLE PRECISION NOT NULL,
+    min_docks_available INTEGER NOT NULL,
+    max_docks_available INTEGER NOT NULL,
+    samples INTEGER NOT NULL,
+    PRIMARY KEY (station_id, hour)
+);
+
+CREATE INDEX IF NOT EXISTS idx_availability_hourly_hour ON availability_hourly(hour);
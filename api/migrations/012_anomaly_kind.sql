@@ -0,0 +1,4 @@
+ALTER TABLE availability_anomalies ADD COLUMN IF NOT EXISTS kind VARCHAR(32) NOT NULL DEFAULT 'swing';
+ALTER TABLE availability_anomalies ADD COLUMN IF NOT EXISTS detail TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_availability_anomalies_kind ON availability_anomalies(kind);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS station_webhooks (
+    id SERIAL PRIMARY KEY,
+    station_id VARCHAR(50) NOT NULL,
+    field VARCHAR(50) NOT NULL,
+    operator VARCHAR(10) NOT NULL,
+    threshold INTEGER NOT NULL,
+    target_url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    condition_met BOOLEAN NOT NULL DEFAULT FALSE,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    last_triggered_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_station_webhooks_station_id ON station_webhooks(station_id);
@@ -0,0 +1,4 @@
+ALTER TABLE predictions ADD COLUMN IF NOT EXISTS model_version VARCHAR(50) NOT NULL DEFAULT 'unknown';
+
+CREATE INDEX IF NOT EXISTS idx_predictions_station_model_horizon_created
+ON predictions(station_id, model_version, horizon_hours, created_at DESC);
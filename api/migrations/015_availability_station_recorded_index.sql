@@ -0,0 +1,4 @@
+-- Serves latest-row-per-station lookups (ORDER BY recorded_at DESC LIMIT 1
+-- for one station) without reading the station's whole history.
+CREATE INDEX IF NOT EXISTS idx_station_availability_station_recorded
+ON station_availability(station_id, recorded_at DESC);
@@ -0,0 +1,16 @@
+-- One row per inference run, horizon and class, counting predictions the run
+-- stored as new rows and those it confirmed unchanged alike.
+CREATE TABLE IF NOT EXISTS prediction_batches (
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    horizon_hours INTEGER NOT NULL,
+    availability_prediction VARCHAR(10) NOT NULL,
+    prediction_count INTEGER NOT NULL,
+    PRIMARY KEY (run_at, horizon_hours, availability_prediction)
+);
+
+-- Runs before this table only left their inserted rows behind.
+INSERT INTO prediction_batches (run_at, horizon_hours, availability_prediction, prediction_count)
+SELECT date_trunc('minute', created_at), horizon_hours, availability_prediction, COUNT(*)
+FROM predictions
+GROUP BY 1, 2, 3
+ON CONFLICT DO NOTHING;
@@ -4,6 +4,8 @@ import {
   getAvailabilityColor, 
   getPredictionColor, 
   parseStations,
+  parseLabels,
+  DEFAULT_LABELS,
   createSingleMarker,
   createDualMarker,
   formatPredictionTime
@@ -93,6 +95,29 @@ describe('helpers', () => {
     });
   });
 
+  describe('parseLabels', () => {
+    it('reads translated labels from the fragment', () => {
+      const div = document.createElement('div');
+      div.innerHTML = `
+        <div class="popup-labels" data-bikes="bicicletas" data-docks="anclajes"
+             data-prediction-in="En" data-prediction-at="a las"
+             data-last-updated="Última actualización"></div>`;
+
+      expect(parseLabels(div)).toEqual({
+        bikes: 'bicicletas',
+        docks: 'anclajes',
+        predictionIn: 'En',
+        predictionAt: 'a las',
+        lastUpdated: 'Última actualización'
+      });
+    });
+
+    it('falls back to English without labels', () => {
+      const div = document.createElement('div');
+      expect(parseLabels(div)).toEqual(DEFAULT_LABELS);
+    });
+  });
+
   describe('parseStations', () => {
     it('parses complete station data', () => {
       const div = document.createElement('div');
@@ -105,6 +130,7 @@ describe('helpers', () => {
              data-capacity="20"
              data-bikes-available="5"
              data-docks-available="15"
+             data-color="yellow"
              data-prediction="green"
              data-prediction-time="2023-01-01T12:00:00Z"
              data-horizon-hours="6">
@@ -120,6 +146,7 @@ describe('helpers', () => {
         capacity: 20,
         num_bikes_available: 5,
         num_docks_available: 15,
+        availability_color: 'yellow',
         predicted_availability: 'green',
         prediction_time: '2023-01-01T12:00:00Z',
         horizon_hours: 6
@@ -142,6 +169,7 @@ describe('helpers', () => {
       const result = parseStations(div);
       
       expect(result[0]).toMatchObject({
+        availability_color: null,
         predicted_availability: null,
         prediction_time: null,
         horizon_hours: null
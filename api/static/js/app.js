@@ -4,7 +4,9 @@ import {
     createSingleMarker,
     createDualMarker,
     parseStations,
-    formatPredictionTime
+    parseLabels,
+    formatPredictionTime,
+    DEFAULT_LABELS
 } from './helpers.js';
   
   let map;
@@ -15,7 +17,7 @@ import {
     stationMarkers = [];
   }
   
-  function addStationMarkers(stations, predictions = null) {
+  function addStationMarkers(stations, predictions = null, labels = DEFAULT_LABELS) {
     clearMarkers();
   
     stations.forEach(station => {
@@ -23,7 +25,9 @@ import {
       const predictedAvailability = predictionFromArray?.predicted_availability || station.predicted_availability;
       const predictionTime = predictionFromArray?.prediction_time || station.prediction_time;
       const horizonHours = predictionFromArray?.horizon_hours ?? station.horizon_hours;
-      const currentColor = getAvailabilityColor(station.num_bikes_available);
+      const currentColor = station.availability_color
+        ? getPredictionColor(station.availability_color)
+        : getAvailabilityColor(station.num_bikes_available);
       const predictionColor = predictedAvailability ? getPredictionColor(predictedAvailability) : null;
   
       const marker = predictionColor
@@ -33,9 +37,9 @@ import {
       marker.bindPopup(`
         <div class="station-popup">
           <strong>${station.name}</strong><br>
-          <div class="bike-count">🚲 ${station.num_bikes_available} bikes</div>
-          ${predictedAvailability ? `<div>🔮 In ${horizonHours}h (at ${formatPredictionTime(predictionTime, horizonHours)}): <strong>${predictedAvailability}</strong></div>` : ''}
-          <div class="dock-count">🅿️ ${station.num_docks_available} docks</div>
+          <div class="bike-count">🚲 ${station.num_bikes_available} ${labels.bikes}</div>
+          ${predictedAvailability ? `<div>🔮 ${labels.predictionIn} ${horizonHours}h (${labels.predictionAt} ${formatPredictionTime(predictionTime, horizonHours)}): <strong>${predictedAvailability}</strong></div>` : ''}
+          <div class="dock-count">🅿️ ${station.num_docks_available} ${labels.docks}</div>
         </div>
       `);
   
@@ -44,7 +48,7 @@ import {
     });
   
     const el = document.getElementById('last-updated');
-    if (el) el.textContent = `Last updated: ${new Date().toLocaleTimeString()}`;
+    if (el) el.textContent = `${labels.lastUpdated}: ${new Date().toLocaleTimeString()}`;
   }
   
   function initMap() {
@@ -96,7 +100,7 @@ import {
           statusDiv.textContent = '';
       }
 
-        addStationMarkers(stations);
+        addStationMarkers(stations, null, parseLabels(event.detail.target));
     });
 
     // Add error handling for HTMX requests
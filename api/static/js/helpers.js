@@ -46,6 +46,21 @@ export function getAvailabilityColor(numBikes) {
     return L.featureGroup([outerMarker, innerMarker]);
   }
   
+  // English popup labels, used when the stations fragment carries none.
+  export const DEFAULT_LABELS = {
+    bikes: 'bikes',
+    docks: 'docks',
+    predictionIn: 'In',
+    predictionAt: 'at',
+    lastUpdated: 'Last updated'
+  };
+
+  // Reads the translated popup labels rendered into the stations fragment.
+  export function parseLabels(containerEl) {
+    const el = containerEl.querySelector('.popup-labels');
+    return { ...DEFAULT_LABELS, ...(el ? el.dataset : {}) };
+  }
+
   export function parseStations(containerEl) {
     const stationElements = containerEl.querySelectorAll('.station-data');
     return Array.from(stationElements).map(el => ({
@@ -56,6 +71,7 @@ export function getAvailabilityColor(numBikes) {
       capacity: parseInt(el.dataset.capacity),
       num_bikes_available: parseInt(el.dataset.bikesAvailable),
       num_docks_available: parseInt(el.dataset.docksAvailable),
+      availability_color: el.dataset.color || null,
       predicted_availability: el.dataset.prediction || null,
       prediction_time: el.dataset.predictionTime || null,
       horizon_hours: (() => {
@@ -113,13 +113,17 @@ def predict():
         
         # Convert DataFrame to JSON-serializable format
         predictions = []
+        confidence_columns = [c for c in ('confidence_green', 'confidence_yellow', 'confidence_red')
+                              if c in predictions_df.columns]
         for _, row in predictions_df.iterrows():
             predictions.append({
                 "station_id": str(row['station_id']),
                 "predicted_availability_class": int(row['predicted_availability_class']),
                 "prediction_time": row['prediction_time'].isoformat(),
                 "horizon_hours": int(row['horizon_hours']),
-                "availability_prediction": str(row['availability_prediction'])
+                "availability_prediction": str(row['availability_prediction']),
+                # Probability of the predicted class; null when the model gave none
+                "confidence": float(max(row[c] for c in confidence_columns)) if confidence_columns else None
             })
         
         # Cache the new predictions